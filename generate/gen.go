@@ -430,6 +430,41 @@ func (f FieldLengthMinusBytes) WriteDecode(l *LineWriter) {
 	l.Write("v := b.Span(int(s.%s) - %d)", f.Field, f.LengthMinus)
 }
 
+// decodeState tracks where we are while writing a decode function, so that
+// decoding can return an error naming the field that failed.
+var decodeState struct {
+	typ     string   // the type whose decode function we are writing
+	version bool     // whether a version variable is in scope
+	path    []string // field names and "[%d]" index placeholders
+	idxs    []string // loop variables for each index placeholder
+}
+
+func decodePush(field string) { decodeState.path = append(decodeState.path, field) }
+func decodePop()              { decodeState.path = decodeState.path[:len(decodeState.path)-1] }
+
+// writeDecodeCheck writes an early return of a *DecodeError for the current
+// field path if the reader has run out of data.
+func writeDecodeCheck(l *LineWriter) {
+	var path string
+	for _, p := range decodeState.path {
+		if path != "" && p != "[%d]" {
+			path += "."
+		}
+		path += p
+	}
+	version := "-1"
+	if decodeState.version {
+		version = "version"
+	}
+	args := ""
+	if len(decodeState.idxs) > 0 {
+		args = ", " + strings.Join(decodeState.idxs, ", ")
+	}
+	l.Write("if !b.Ok() {")
+	l.Write("return decodeErr(%q, %s, %q%s)", decodeState.typ, version, path, args)
+	l.Write("}")
+}
+
 func (a Array) WriteDecode(l *LineWriter) {
 	// For decoding arrays, we copy our "v" variable to our own "a"
 	// variable so that the scope opened just below can use its own
@@ -450,9 +485,7 @@ func (a Array) WriteDecode(l *LineWriter) {
 		}
 	}
 
-	l.Write("if !b.Ok() {")
-	l.Write("return b.Complete()")
-	l.Write("}")
+	writeDecodeCheck(l)
 
 	l.Write("a = a[:0]")
 
@@ -460,31 +493,47 @@ func (a Array) WriteDecode(l *LineWriter) {
 	l.Write("a = append(a, make(%s, l)...)", a.TypeName())
 	l.Write("}")
 
-	l.Write("for i := int32(0); i < l; i++ {")
+	// Each nesting level has its own loop variable so that decode errors
+	// can include every index.
+	i := fmt.Sprintf("i%d", len(decodeState.idxs))
+	decodeState.idxs = append(decodeState.idxs, i)
+	decodePush("[%d]")
+	defer func() {
+		decodeState.idxs = decodeState.idxs[:len(decodeState.idxs)-1]
+		decodePop()
+	}()
+
+	l.Write("for %[1]s := int32(0); %[1]s < l; %[1]s++ {", i)
 	switch t := a.Inner.(type) {
 	case Struct:
 		if t.Nullable {
 			l.Write("if present := b.Int8(); present != -1 && b.Ok() {")
 			defer l.Write("}")
 		}
-		l.Write("v := &a[i]")
+		l.Write("v := &a[%s]", i)
 		l.Write("v.Default()") // set defaults first
 	case Array:
 		// With nested arrays, we declare a new v and introduce scope
 		// so that the next level will not collide with our current "a".
-		l.Write("v := a[i]")
+		l.Write("v := a[%s]", i)
 		l.Write("{")
 	}
 
 	a.Inner.WriteDecode(l)
 
+	switch a.Inner.(type) {
+	case Struct, Array:
+	default:
+		writeDecodeCheck(l)
+	}
+
 	if _, isArray := a.Inner.(Array); isArray {
 		// With nested arrays, now we release our scope.
 		l.Write("}")
 	}
 
 	if _, isStruct := a.Inner.(Struct); !isStruct {
-		l.Write("a[i] = v")
+		l.Write("a[%s] = v", i)
 	}
 
 	l.Write("}") // close the for loop
@@ -493,6 +542,9 @@ func (a Array) WriteDecode(l *LineWriter) {
 }
 
 func (f StructField) WriteDecode(l *LineWriter) {
+	decodePush(f.FieldName)
+	defer decodePop()
+
 	switch t := f.Type.(type) {
 	case Struct:
 		// For decoding a nested struct, we copy a pointer out.
@@ -515,6 +567,13 @@ func (f StructField) WriteDecode(l *LineWriter) {
 	}
 	f.Type.WriteDecode(l)
 
+	switch f.Type.(type) {
+	case Struct, Array:
+		// Fields within are checked as they are decoded.
+	default:
+		writeDecodeCheck(l)
+	}
+
 	_, isStruct := f.Type.(Struct)
 	if !isStruct {
 		// If the field was not a struct or it was a nullable struct,
@@ -670,6 +729,15 @@ func (s Struct) WriteDefn(l *LineWriter) {
 		}
 		l.Write("")
 	}
+	if s.TopLevel {
+		l.Write("")
+		l.Write("// RawTail contains any bytes remaining after decoding this message with")
+		l.Write("// ReadFrom, such as fields added in a newer version of the message than")
+		l.Write("// this package knows. Trailing bytes are not an error; RawTail allows")
+		l.Write("// forward compatible tooling to inspect or preserve them. RawTail aliases")
+		l.Write("// the input to ReadFrom and is never encoded.")
+		l.Write("RawTail []byte")
+	}
 	l.Write("}")
 }
 
@@ -762,8 +830,13 @@ func (s Struct) WriteDecodeFunc(l *LineWriter) {
 	l.Write("return v.readFrom(src, true)")
 	l.Write("}")
 
+	decodeState.typ = s.Name
+	decodeState.version = s.TopLevel || s.WithVersionField
 	l.Write("func (v *%s) readFrom(src []byte, unsafe bool) error {", s.Name)
 	l.Write("v.Default()")
+	if s.TopLevel {
+		l.Write("v.RawTail = nil")
+	}
 	l.Write("b := kbin.Reader{Src: src}")
 	if s.WithVersionField {
 		l.Write("v.Version = b.Int16()")
@@ -777,6 +850,31 @@ func (s Struct) WriteDecodeFunc(l *LineWriter) {
 		l.Write("_ = isFlexible")
 	}
 	s.WriteDecode(l)
+	if s.TopLevel {
+		l.Write("if b.Ok() && len(b.Src) > 0 {")
+		l.Write("v.RawTail = b.Src")
+		l.Write("}")
+	}
+	l.Write("return b.Complete()")
+	l.Write("}")
+}
+
+// WriteVersionedDecodeFunc writes a decode function for a nested struct that
+// reads from a reader shared with the caller, using the version of the struct's
+// top level message. This allows one element of a large message to be decoded
+// at a time (see FetchResponse.IterPartitions).
+func (s Struct) WriteVersionedDecodeFunc(l *LineWriter) {
+	decodeState.typ = s.Name
+	decodeState.version = true
+	l.Write("func (v *%s) readVersionFrom(r *kbin.Reader, version int16, unsafe bool) error {", s.Name)
+	l.Write("v.Default()")
+	l.Write("b := *r")
+	l.Write("defer func() { *r = b }()")
+	if s.FlexibleAt >= 0 {
+		l.Write("isFlexible := version >= %d", s.FlexibleAt)
+		l.Write("_ = isFlexible")
+	}
+	s.WriteDecode(l)
 	l.Write("return b.Complete()")
 	l.Write("}")
 }
@@ -820,6 +918,69 @@ func (s Struct) WriteNewPtrFunc(l *LineWriter) {
 	l.Write("}")
 }
 
+func (s Struct) WriteBuilder(l *LineWriter) {
+	l.Write("// %[1]sBuilder builds a %[1]s. Nested structs are initialized", s.Name)
+	l.Write("// with their New functions before being passed to field functions.")
+	l.Write("type %[1]sBuilder struct{ req %[1]s }", s.Name)
+	l.Write("")
+	l.Write("// Build%[1]s returns a builder for a default %[1]s.", s.Name)
+	l.Write("func Build%[1]s() *%[1]sBuilder {", s.Name)
+	l.Write("b := new(%sBuilder)", s.Name)
+	l.Write("b.req.Default()")
+	l.Write("return b")
+	l.Write("}")
+	l.Write("")
+	l.Write("// Version sets the request version.")
+	l.Write("func (b *%[1]sBuilder) Version(v int16) *%[1]sBuilder {", s.Name)
+	l.Write("b.req.Version = v")
+	l.Write("return b")
+	l.Write("}")
+	for _, f := range s.Fields {
+		l.Write("")
+		versions := ""
+		switch {
+		case f.MinVersion > 0 && f.MaxVersion > 0:
+			versions = fmt.Sprintf(" (v%d-v%d)", f.MinVersion, f.MaxVersion)
+		case f.MinVersion > 0:
+			versions = fmt.Sprintf(" (v%d+)", f.MinVersion)
+		case f.MaxVersion > 0:
+			versions = fmt.Sprintf(" (v0-v%d)", f.MaxVersion)
+		}
+		switch t := f.Type.(type) {
+		case Array:
+			if inner, ok := t.Inner.(Struct); ok {
+				l.Write("// %[1]s appends one %[2]s per fn to %[1]s%[3]s, calling", f.FieldName, inner.Name, versions)
+				l.Write("// fn on a new default %s before appending it.", inner.Name)
+				l.Write("func (b *%[1]sBuilder) %[2]s(fns ...func(*%[3]s)) *%[1]sBuilder {", s.Name, f.FieldName, inner.Name)
+				l.Write("for _, fn := range fns {")
+				l.Write("v := New%s()", inner.Name)
+				l.Write("fn(&v)")
+				if inner.Nullable {
+					l.Write("b.req.%s = append(b.req.%[1]s, &v)", f.FieldName)
+				} else {
+					l.Write("b.req.%s = append(b.req.%[1]s, v)", f.FieldName)
+				}
+				l.Write("}")
+			} else {
+				l.Write("// %[1]s appends to %[1]s%[2]s.", f.FieldName, versions)
+				l.Write("func (b *%[1]sBuilder) %[2]s(vs ...%[3]s) *%[1]sBuilder {", s.Name, f.FieldName, t.Inner.TypeName())
+				l.Write("b.req.%s = append(b.req.%[1]s, vs...)", f.FieldName)
+			}
+		default:
+			l.Write("// %[1]s sets %[1]s%[2]s.", f.FieldName, versions)
+			l.Write("func (b *%[1]sBuilder) %[2]s(v %[3]s) *%[1]sBuilder {", s.Name, f.FieldName, f.Type.TypeName())
+			l.Write("b.req.%s = v", f.FieldName)
+		}
+		l.Write("return b")
+		l.Write("}")
+	}
+	l.Write("")
+	l.Write("// Build returns the built request.")
+	l.Write("func (b *%[1]sBuilder) Build() *%[1]s {", s.Name)
+	l.Write("return &b.req")
+	l.Write("}")
+}
+
 func (e Enum) WriteDefn(l *LineWriter) {
 	if e.Comment != "" {
 		l.Write(e.Comment)
@@ -949,3 +1110,462 @@ func (e Enum) WriteConsts(l *LineWriter) {
 		l.Write("%s%s %s = %d", e.Name, sb.String(), e.Name, v.Value)
 	}
 }
+
+// presentCond returns the condition under which a field is serialized, or an
+// empty string if the field is always serialized.
+func (f StructField) presentCond(s Struct) string {
+	switch {
+	case f.MinVersion == -1:
+		if s.FlexibleAt < 0 {
+			die("unexpected tag-only field %s in non-flexible struct %s", f.FieldName, s.Name)
+		}
+		if s.FlexibleAt == 0 {
+			return ""
+		}
+		return fmt.Sprintf("version >= %d", s.FlexibleAt)
+	case f.MaxVersion > -1:
+		return fmt.Sprintf("version >= %d && version <= %d", f.MinVersion, f.MaxVersion)
+	case f.MinVersion > 0:
+		return fmt.Sprintf("version >= %d", f.MinVersion)
+	default:
+		return ""
+	}
+}
+
+func (s Struct) WriteFieldNamesFunc(l *LineWriter) {
+	l.Write("// FieldNames returns the names of the fields in %s that are", s.Name)
+	l.Write("// serialized at the given version, in definition order.")
+	l.Write("func (*%s) FieldNames(version int16) []string {", s.Name)
+	if len(s.Fields) == 0 {
+		l.Write("return nil")
+		l.Write("}")
+		return
+	}
+	l.Write("names := make([]string, 0, %d)", len(s.Fields))
+	for _, f := range s.Fields {
+		if cond := f.presentCond(s); cond != "" {
+			l.Write("if %s {", cond)
+			l.Write("names = append(names, %q)", f.FieldName)
+			l.Write("}")
+		} else {
+			l.Write("names = append(names, %q)", f.FieldName)
+		}
+	}
+	l.Write("return names")
+	l.Write("}")
+}
+
+func (s Struct) WriteVisitFieldsFunc(l *LineWriter) {
+	l.Write("// VisitFields calls fn with the name and value of every field in %s", s.Name)
+	l.Write("// that is serialized at the given version, in definition order.")
+	l.Write("func (v *%s) VisitFields(version int16, fn func(name string, value interface{})) {", s.Name)
+	for _, f := range s.Fields {
+		if cond := f.presentCond(s); cond != "" {
+			l.Write("if %s {", cond)
+			l.Write("fn(%q, v.%s)", f.FieldName, f.FieldName)
+			l.Write("}")
+		} else {
+			l.Write("fn(%q, v.%s)", f.FieldName, f.FieldName)
+		}
+	}
+	l.Write("}")
+}
+
+func (s Struct) WriteEqualFunc(l *LineWriter) {
+	l.Write("// Equal returns whether v and other are equal. See Diff for how fields are")
+	l.Write("// compared.")
+	l.Write("func (v *%s) Equal(other *%s) bool {", s.Name, s.Name)
+	l.Write("return len(v.Diff(other)) == 0")
+	l.Write("}")
+}
+
+func (s Struct) WriteDiffFunc(l *LineWriter) {
+	version := "-1"
+	l.Write("// Diff returns a human readable description of each field that differs")
+	if s.TopLevel || s.WithVersionField {
+		version = "v.Version"
+		l.Write("// between v and other, comparing only fields that are serialized at v's")
+		l.Write("// version. Nil and empty slices are equal unless the field is nullable.")
+	} else {
+		l.Write("// between v and other, comparing all fields. Nil and empty slices are")
+		l.Write("// equal unless the field is nullable.")
+	}
+	l.Write("func (v *%s) Diff(other *%s) []string {", s.Name, s.Name)
+	l.Write("return v.diff(other, %s, nil)", version)
+	l.Write("}")
+
+	l.Write("func (v *%s) diff(o *%s, version int16, ds []string) []string {", s.Name, s.Name)
+	if s.TopLevel {
+		l.Write(`ds = diffValue(ds, "Version", v.Version, o.Version)`)
+	}
+	for _, f := range s.Fields {
+		if cond := f.presentCond(s); cond != "" {
+			l.Write("if version < 0 || %s {", cond)
+			writeFieldDiff(l, f.FieldName, f.Type)
+			l.Write("}")
+		} else {
+			writeFieldDiff(l, f.FieldName, f.Type)
+		}
+	}
+	if s.FlexibleAt > 0 {
+		l.Write("if version < 0 || version >= %d {", s.FlexibleAt)
+		l.Write("ds = v.UnknownTags.diff(&o.UnknownTags, ds)")
+		l.Write("}")
+	} else if s.FlexibleAt == 0 {
+		l.Write("ds = v.UnknownTags.diff(&o.UnknownTags, ds)")
+	}
+	l.Write("return ds")
+	l.Write("}")
+}
+
+func (s Struct) WriteConvertVersionFunc(l *LineWriter) {
+	l.Write("// ConvertVersion converts v to the given version, returning a")
+	l.Write("// *ConvertVersionError and leaving v unchanged if any field set in v cannot")
+	l.Write("// be represented at that version. Fields that only exist at the new version")
+	l.Write("// keep their current (by default, zero or default) values. Opaque byte")
+	l.Write("// fields, such as record batches, are not converted.")
+	l.Write("func (v *%s) ConvertVersion(version int16) error {", s.Name)
+	l.Write("w := new(%s)", s.Name)
+	l.Write("return convertVersion(%q, v, w, version, func() []string { return v.diff(w, v.Version, nil) })", s.Name)
+	l.Write("}")
+}
+
+func writeFieldDiff(l *LineWriter, name string, typ Type) {
+	switch t := typ.(type) {
+	case Struct:
+		if t.Nullable {
+			l.Write("if d, ok := diffNullableStruct(ds, %q, v.%s == nil, o.%s == nil); !ok {", name, name, name)
+			l.Write("ds = d")
+			l.Write("} else {")
+			l.Write("n := len(ds)")
+			l.Write("ds = v.%s.diff(o.%s, version, ds)", name, name)
+			l.Write("prefixDiffs(ds[n:], %q)", name)
+			l.Write("}")
+		} else {
+			l.Write("{")
+			l.Write("n := len(ds)")
+			l.Write("ds = v.%s.diff(&o.%s, version, ds)", name, name)
+			l.Write("prefixDiffs(ds[n:], %q)", name)
+			l.Write("}")
+		}
+	case Array:
+		switch inner := t.Inner.(type) {
+		case Struct:
+			if inner.Nullable {
+				die("unsupported array of nullable structs for field %s", name)
+			}
+			l.Write("if d, ok := diffLen(ds, %q, len(v.%s), len(o.%s), v.%s == nil, o.%s == nil, %v); !ok {", name, name, name, name, name, t.IsNullableArray)
+			l.Write("ds = d")
+			l.Write("} else {")
+			l.Write("for i := range v.%s {", name)
+			l.Write("n := len(ds)")
+			l.Write("ds = v.%s[i].diff(&o.%s[i], version, ds)", name, name)
+			l.Write("prefixIndexDiffs(ds[n:], %q, i)", name)
+			l.Write("}")
+			l.Write("}")
+		case Array, NullableString, Bytes, NullableBytes, VarintBytes, FieldLengthMinusBytes:
+			die("unsupported array of %s for field %s", inner.TypeName(), name)
+		default:
+			l.Write("ds = diffSlice(ds, %q, v.%s, o.%s, %v)", name, name, name, t.IsNullableArray)
+		}
+	case Bytes, VarintBytes, FieldLengthMinusBytes:
+		l.Write("ds = diffBytes(ds, %q, v.%s, o.%s, false)", name, name, name)
+	case NullableBytes:
+		l.Write("ds = diffBytes(ds, %q, v.%s, o.%s, true)", name, name, name)
+	case NullableString:
+		l.Write("ds = diffNullableString(ds, %q, v.%s, o.%s)", name, name, name)
+	default:
+		l.Write("ds = diffValue(ds, %q, v.%s, o.%s)", name, name, name)
+	}
+}
+
+func (e Enum) WriteIsKnownFunc(l *LineWriter) {
+	l.Write("// IsKnown returns whether v is one of the known values of %s.", e.Name)
+	l.Write("func (v %s) IsKnown() bool {", e.Name)
+	l.Write("switch v {")
+	var vs []string
+	for _, v := range e.Values {
+		vs = append(vs, strconv.Itoa(v.Value))
+	}
+	l.Write("case %s:", strings.Join(vs, ", "))
+	l.Write("return true")
+	l.Write("}")
+	l.Write("return false")
+	l.Write("}")
+}
+
+// hasEnum returns whether a type is or contains an enum, and thus whether
+// the type needs validating.
+func hasEnum(typ Type) bool {
+	switch t := typ.(type) {
+	case Enum:
+		return true
+	case Array:
+		return hasEnum(t.Inner)
+	case Struct:
+		for _, f := range t.Fields {
+			if f.Bitfield != nil || hasEnum(f.Type) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (s Struct) WriteValidateFunc(l *LineWriter) {
+	if !s.TopLevel && !hasEnum(s) {
+		return
+	}
+	version := "-1"
+	l.Write("// Validate returns an *EnumError if any enum field in %s holds an", s.Name)
+	if s.TopLevel || s.WithVersionField {
+		version = "v.Version"
+		l.Write("// unknown value, checking only fields that are serialized at v's version.")
+	} else {
+		l.Write("// unknown value.")
+	}
+	l.Write("func (v *%s) Validate() error {", s.Name)
+	if !hasEnum(s) {
+		l.Write("return nil")
+		l.Write("}")
+		return
+	}
+	l.Write("return v.validate(%s)", version)
+	l.Write("}")
+
+	l.Write("func (v *%s) validate(version int16) error {", s.Name)
+	for _, f := range s.Fields {
+		if f.Bitfield == nil && !hasEnum(f.Type) {
+			continue
+		}
+		write := func() { writeFieldValidate(l, f.FieldName, f.Type) }
+		if f.Bitfield != nil {
+			write = func() { writeBitfieldValidate(l, f.FieldName, f.Type, *f.Bitfield) }
+		}
+		if cond := f.presentCond(s); cond != "" {
+			l.Write("if version < 0 || %s {", cond)
+			write()
+			l.Write("}")
+		} else {
+			write()
+		}
+	}
+	l.Write("return nil")
+	l.Write("}")
+}
+
+// writeBitfieldValidate ensures that a bitfield field only has bits set for
+// known values of its enum, unless the field is its default (which for
+// bitfields is usually a sentinel meaning "not set").
+func writeBitfieldValidate(l *LineWriter, name string, typ Type, e Enum) {
+	var mask uint64
+	for _, v := range e.Values {
+		if v.Value < 0 || v.Value > 30 {
+			die("bitfield enum %s value %d cannot be a bit in an int32", e.Name, v.Value)
+		}
+		mask |= 1 << uint(v.Value)
+	}
+	cond := fmt.Sprintf("v.%s&^%#x != 0", name, mask)
+	if d, ok := typ.(Defaulter); ok {
+		if def, ok := d.GetDefault(); ok {
+			cond = fmt.Sprintf("%s && v.%s != %v", cond, name, def)
+		}
+	}
+	l.Write("if %s {", cond)
+	l.Write("return &EnumError{Field: %q, Type: %q, Value: int64(v.%s)}", name, e.Name+" bitfield", name)
+	l.Write("}")
+}
+
+func writeFieldValidate(l *LineWriter, name string, typ Type) {
+	switch t := typ.(type) {
+	case Enum:
+		cond := fmt.Sprintf("!v.%s.IsKnown()", name)
+		if def, ok := t.GetDefault(); ok {
+			cond = fmt.Sprintf("%s && v.%s != %v", cond, name, def)
+		}
+		l.Write("if %s {", cond)
+		l.Write("return &EnumError{Field: %q, Type: %q, Value: int64(v.%s)}", name, t.Name, name)
+		l.Write("}")
+	case Struct:
+		if t.Nullable {
+			l.Write("if v.%s != nil {", name)
+			l.Write("if err := v.%s.validate(version); err != nil {", name)
+			l.Write("return prefixEnumError(err, %q)", name)
+			l.Write("}")
+			l.Write("}")
+		} else {
+			l.Write("if err := v.%s.validate(version); err != nil {", name)
+			l.Write("return prefixEnumError(err, %q)", name)
+			l.Write("}")
+		}
+	case Array:
+		switch inner := t.Inner.(type) {
+		case Struct:
+			l.Write("for i := range v.%s {", name)
+			l.Write("if err := v.%s[i].validate(version); err != nil {", name)
+			l.Write("return prefixIndexEnumError(err, %q, i)", name)
+			l.Write("}")
+			l.Write("}")
+		case Enum:
+			l.Write("for i, e := range v.%s {", name)
+			l.Write("if !e.IsKnown() {")
+			l.Write("return &EnumError{Field: fmt.Sprintf(\"%s[%%d]\", i), Type: %q, Value: int64(e)}", name, inner.Name)
+			l.Write("}")
+			l.Write("}")
+		default:
+			die("unsupported array of %s with enums for field %s", inner.TypeName(), name)
+		}
+	}
+}
+
+// normalizeLess returns a less function body comparing two elements a and b
+// of an array of s, if s is keyed by a topic (name or ID) or partition.
+func normalizeLess(s Struct, a, b string) ([]string, bool) {
+	var topic, topicID, partition bool
+	var nullableTopic bool
+	for _, f := range s.Fields {
+		switch f.FieldName {
+		case "Topic":
+			switch f.Type.(type) {
+			case String:
+				topic = true
+			case NullableString:
+				topic, nullableTopic = true, true
+			}
+		case "TopicID":
+			_, topicID = f.Type.(Uuid)
+		case "Partition":
+			_, partition = f.Type.(Int32)
+		}
+	}
+	if !topic && !topicID && !partition {
+		return nil, false
+	}
+	var lines []string
+	if topic || topicID {
+		at, bt := `""`, `""`
+		if topic {
+			at, bt = a+".Topic", b+".Topic"
+			if nullableTopic {
+				at, bt = "normString("+at+")", "normString("+bt+")"
+			}
+		}
+		aid, bid := "[16]byte{}", "[16]byte{}"
+		if topicID {
+			aid, bid = a+".TopicID", b+".TopicID"
+		}
+		cmp := fmt.Sprintf("compareTopic(%s, %s, %s, %s)", at, aid, bt, bid)
+		if !partition {
+			return []string{fmt.Sprintf("return %s < 0", cmp)}, true
+		}
+		lines = append(lines, fmt.Sprintf("if c := %s; c != 0 {", cmp), "return c < 0", "}")
+	}
+	return append(lines, fmt.Sprintf("return %s.Partition < %s.Partition", a, b)), true
+}
+
+// needsNormalize returns whether a type is or contains a collection that
+// Normalize sorts.
+func needsNormalize(typ Type) bool {
+	switch t := typ.(type) {
+	case Array:
+		if inner, ok := t.Inner.(Struct); ok {
+			if inner.Nullable {
+				return false
+			}
+			if _, ok := normalizeLess(inner, "a", "b"); ok {
+				return true
+			}
+		}
+		return needsNormalize(t.Inner)
+	case Struct:
+		for _, f := range t.Fields {
+			if a, ok := f.Type.(Array); ok && f.FieldName == "Partitions" {
+				if _, ok := a.Inner.(Int32); ok {
+					return true
+				}
+			}
+			if needsNormalize(f.Type) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// collectNormalize adds the names of all structs within s that need a
+// normalize function.
+func collectNormalize(s Struct, names map[string]bool) {
+	if needsNormalize(s) {
+		names[s.Name] = true
+	}
+	for _, f := range s.Fields {
+		typ := f.Type
+		for {
+			a, ok := typ.(Array)
+			if !ok {
+				break
+			}
+			typ = a.Inner
+		}
+		if inner, ok := typ.(Struct); ok {
+			collectNormalize(inner, names)
+		}
+	}
+}
+
+func (s Struct) WriteNormalizeFunc(l *LineWriter, names map[string]bool) {
+	if s.TopLevel && s.ResponseKind != "" {
+		l.Write("// Normalize sorts the order-insensitive collections in %s, those", s.Name)
+		l.Write("// keyed by topic or partition, into a canonical order so that the same")
+		l.Write("// logical request always serializes identically.")
+		l.Write("func (v *%s) Normalize() {", s.Name)
+		if names[s.Name] {
+			l.Write("v.normalize()")
+		}
+		l.Write("}")
+	}
+	if !names[s.Name] {
+		return
+	}
+	l.Write("func (v *%s) normalize() {", s.Name)
+	for _, f := range s.Fields {
+		switch t := f.Type.(type) {
+		case Struct:
+			if !names[t.Name] {
+				continue
+			}
+			if t.Nullable {
+				l.Write("if v.%s != nil {", f.FieldName)
+				l.Write("v.%s.normalize()", f.FieldName)
+				l.Write("}")
+			} else {
+				l.Write("v.%s.normalize()", f.FieldName)
+			}
+		case Array:
+			switch inner := t.Inner.(type) {
+			case Int32:
+				if f.FieldName == "Partitions" {
+					l.Write("sort.Slice(v.%[1]s, func(i, j int) bool { return v.%[1]s[i] < v.%[1]s[j] })", f.FieldName)
+				}
+			case Struct:
+				if inner.Nullable {
+					continue
+				}
+				if names[inner.Name] {
+					l.Write("for i := range v.%s {", f.FieldName)
+					l.Write("v.%s[i].normalize()", f.FieldName)
+					l.Write("}")
+				}
+				if less, ok := normalizeLess(inner, "a", "b"); ok {
+					l.Write("sort.SliceStable(v.%s, func(i, j int) bool {", f.FieldName)
+					l.Write("a, b := &v.%[1]s[i], &v.%[1]s[j]", f.FieldName)
+					for _, line := range less {
+						l.Write(line)
+					}
+					l.Write("})")
+				}
+			}
+		}
+	}
+	l.Write("}")
+}
@@ -120,6 +120,7 @@ type (
 		Tag        int
 		FieldName  string
 		Type       Type
+		Bitfield   *Enum // non-nil if the field is a bitfield of the enum's values
 	}
 
 	Throttle struct {
@@ -153,6 +154,7 @@ type (
 		Admin            bool
 		GroupCoordinator bool
 		TxnCoordinator   bool
+		MinVersion       int // 0 unless the definition has a min version
 		MaxVersion       int
 		FlexibleAt       int
 		ResponseKind     string // for requests
@@ -393,6 +395,12 @@ func (l *LineWriter) Write(line string, args ...any) {
 	l.line++
 }
 
+// versionedDecoders are nested structs that can be decoded on their own; see
+// WriteVersionedDecodeFunc.
+var versionedDecoders = map[string]bool{
+	"FetchResponseTopicPartition": true,
+}
+
 //go:generate sh -c "go run . | gofumpt | gofumpt -lang 1.19 -extra > ../pkg/kmsg/generated.go"
 func main() {
 	const dir = "definitions"
@@ -428,6 +436,7 @@ func main() {
 	l.Write(`"fmt"`)
 	l.Write(`"strings"`)
 	l.Write(`"reflect"`)
+	l.Write(`"sort"`)
 	l.Write("")
 	l.Write(`"github.com/burningass23/franz-go/pkg/kmsg/internal/kbin"`)
 	l.Write(")")
@@ -439,6 +448,13 @@ func main() {
 
 	var name2structs []Struct
 
+	normalize := make(map[string]bool)
+	for _, s := range newStructs {
+		if s.TopLevel && s.ResponseKind != "" {
+			collectNormalize(s, normalize)
+		}
+	}
+
 	sort.SliceStable(newStructs, func(i, j int) bool { return newStructs[i].Key < newStructs[j].Key })
 	for _, s := range newStructs {
 		s.WriteDefn(l)
@@ -452,6 +468,7 @@ func main() {
 			s.WriteSetVersionFunc(l)
 			s.WriteGetVersionFunc(l)
 			s.WriteIsFlexibleFunc(l)
+			s.WriteConvertVersionFunc(l)
 
 			for _, f := range s.Fields {
 				switch f.Type.(type) {
@@ -484,6 +501,9 @@ func main() {
 			s.WriteAppendFunc(l)
 			s.WriteDecodeFunc(l)
 			s.WriteNewPtrFunc(l)
+			if s.ResponseKind != "" {
+				s.WriteBuilder(l)
+			}
 		} else if !s.Anonymous && !s.WithNoEncoding {
 			s.WriteAppendFunc(l)
 			s.WriteDecodeFunc(l)
@@ -492,9 +512,27 @@ func main() {
 			}
 		}
 
+		if versionedDecoders[s.Name] {
+			s.WriteVersionedDecodeFunc(l)
+		}
+
 		// everything gets a default and new function
 		s.WriteDefaultFunc(l)
 		s.WriteNewFunc(l)
+
+		// and field iteration functions
+		s.WriteFieldNamesFunc(l)
+		s.WriteVisitFieldsFunc(l)
+
+		// and comparison functions
+		s.WriteEqualFunc(l)
+		s.WriteDiffFunc(l)
+
+		// and enum validation
+		s.WriteValidateFunc(l)
+
+		// and request normalization
+		s.WriteNormalizeFunc(l, normalize)
 	}
 
 	l.Write("// RequestForKey returns the request corresponding to the given request key")
@@ -546,12 +584,35 @@ func main() {
 	l.Write("// Int16 is an alias for int16(k).")
 	l.Write("func (k Key) Int16() int16 { return int16(k) }")
 
+	l.Write("// ApiVersionRange is the range of versions this package supports for a request key.")
+	l.Write("type ApiVersionRange struct {")
+	l.Write("// MinVersion is the minimum supported version.")
+	l.Write("MinVersion int16")
+	l.Write("// MaxVersion is the maximum supported version.")
+	l.Write("MaxVersion int16")
+	l.Write("}")
+	l.Write("// ApiVersionRanges returns a map of every request key this package knows of to")
+	l.Write("// the range of versions this package supports for that key. The returned")
+	l.Write("// map is new on every call and can be freely modified.")
+	l.Write("//")
+	l.Write("// This can be intersected with the versions a broker advertises in an")
+	l.Write("// ApiVersionsResponse to determine the versions to use when talking to")
+	l.Write("// that broker.")
+	l.Write("func ApiVersionRanges() map[int16]ApiVersionRange {")
+	l.Write("return map[int16]ApiVersionRange{")
+	for _, key2struct := range name2structs {
+		l.Write("%d: {MinVersion: %d, MaxVersion: %d}, // %s", key2struct.Key, key2struct.MinVersion, key2struct.MaxVersion, strings.TrimSuffix(key2struct.Name, "Request"))
+	}
+	l.Write("}")
+	l.Write("}")
+
 	for _, e := range newEnums {
 		e.WriteDefn(l)
 		e.WriteStringFunc(l)
 		e.WriteStringsFunc(l)
 		e.WriteParseFunc(l)
 		e.WriteConsts(l)
+		e.WriteIsKnownFunc(l)
 		e.WriteMarshalTextFunc(l)
 		e.WriteUnmarshalTextFunc(l)
 	}
@@ -142,6 +142,19 @@ func (s *Struct) BuildFrom(scanner *LineScanner, key, level int) (done bool) {
 			typ = typ[:idx]
 		}
 
+		// A field can be marked as a bitfield of an enum's values,
+		// where every set bit is the value of the enum, e.g.
+		// `int32 bitfield-ACLOperation`.
+		if idx := strings.Index(typ, " bitfield-"); idx >= 0 {
+			name := typ[idx+len(" bitfield-"):]
+			e, ok := enums[name]
+			if !ok {
+				die("unknown bitfield enum %q on line %q", name, line)
+			}
+			f.Bitfield = &e
+			typ = typ[:idx]
+		}
+
 		// Now we do some array processing. Arrays can be nested
 		// (although they are not nested in our definitions since
 		// the flexible tag support).
@@ -545,7 +558,8 @@ func Parse(raw []byte) {
 		}
 
 		// At this point, we are dealing with a top level request.
-		// The order, l to r, is key, version, admin/group/txn.
+		// The order, l to r, is key, optional min version, max version,
+		// flexible, admin/group/txn.
 		// We strip and process r to l.
 		delim := strings.Index(name, " =>")
 		if delim == -1 {
@@ -600,6 +614,15 @@ func Parse(raw []byte) {
 			s.MaxVersion = max
 			rem = rem[:idx]
 		}
+		const minStr = ", min version "
+		if idx := strings.Index(rem, minStr); idx != -1 {
+			min, err := strconv.Atoi(rem[idx+len(minStr):])
+			if err != nil || min < 0 || min > s.MaxVersion {
+				die("min version on line %q parse err: %v", line, err)
+			}
+			s.MinVersion = min
+			rem = rem[:idx]
+		}
 		const keyStr = " key "
 		if idx := strings.Index(rem, keyStr); idx == -1 {
 			die("missing key on line %q", line)
@@ -1107,3 +1107,205 @@ func createDelDescACL(b *ACLBuilder) ([]kmsg.DeleteACLsRequestFilter, []*kmsg.De
 	}
 	return deletions, describes, nil
 }
+
+// ACL is a single, fully specified ACL. Unlike the ACLBuilder, which
+// multiplies its inputs into many ACLs or filters, an ACL describes exactly
+// one ACL. This type is used to declaratively manage ACLs with DiffACLs and
+// ReconcileACLs.
+type ACL struct {
+	Principal string // Principal is the ACL's principal, e.g. "User:foo".
+	Host      string // Host is the ACL's host, e.g. "*".
+
+	Type       kmsg.ACLResourceType   // Type is the ACL's resource type.
+	Name       string                 // Name is the ACL's resource name.
+	Pattern    ACLPattern             // Pattern is the ACL's resource name pattern (literal or prefixed).
+	Operation  ACLOperation           // Operation is the operation allowed / denied.
+	Permission kmsg.ACLPermissionType // Permission is whether this is allowed / denied.
+}
+
+// ACLs returns every ACL described across all results, deduplicated. Results
+// that have an error are skipped.
+func (rs DescribeACLsResults) ACLs() []ACL {
+	seen := make(map[ACL]bool)
+	var acls []ACL
+	for _, r := range rs {
+		if r.Err != nil {
+			continue
+		}
+		for _, d := range r.Described {
+			acl := ACL(d)
+			if !seen[acl] {
+				seen[acl] = true
+				acls = append(acls, acl)
+			}
+		}
+	}
+	return acls
+}
+
+// DiffACLs returns the minimal set of ACLs to create and delete to change
+// the current ACLs into the desired ACLs: every desired ACL that does not
+// currently exist must be created, and every current ACL that is not desired
+// must be deleted. Duplicates in either input are ignored.
+func DiffACLs(current, desired []ACL) (toCreate, toDelete []ACL) {
+	have := make(map[ACL]bool, len(current))
+	for _, acl := range current {
+		have[acl] = true
+	}
+	want := make(map[ACL]bool, len(desired))
+	for _, acl := range desired {
+		if !want[acl] && !have[acl] {
+			toCreate = append(toCreate, acl)
+		}
+		want[acl] = true
+	}
+	for _, acl := range current {
+		if !want[acl] {
+			toDelete = append(toDelete, acl)
+			want[acl] = true // avoid deleting duplicates twice
+		}
+	}
+	return toCreate, toDelete
+}
+
+// ReconcileACLs makes the ACLs matched by scope equal to the desired ACLs.
+// This describes all ACLs that match the scope builder (which is used as a
+// filter, see DescribeACLs), computes the minimal set of ACLs to create and
+// delete with DiffACLs, and then creates and deletes exactly those ACLs.
+//
+// The scope limits which existing ACLs can be deleted: ACLs outside of the
+// scope are never described and thus never deleted. To manage all ACLs in a
+// cluster, use a scope that matches everything, e.g.
+//
+//	kadm.NewACLs().AnyResource().Allow().Deny().AllowHosts().DenyHosts().
+//	        Operations(kadm.OpAny).ResourcePatternType(kadm.ACLPatternAny)
+//
+// Desired ACLs that fall outside of the scope are created if they do not
+// exist, but because they are not described, they are always attempted to be
+// created (which is harmless if they already exist).
+//
+// This returns an error if the scope is invalid or if any request fails to be
+// issued. Individual ACL errors are returned in the results, and ACLs are
+// deleted before they are created. If nothing needs to change, no create or
+// delete requests are issued and both results are empty.
+func (cl *Client) ReconcileACLs(ctx context.Context, scope *ACLBuilder, desired []ACL) (CreateACLsResults, DeleteACLsResults, error) {
+	described, err := cl.DescribeACLs(ctx, scope)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, r := range described {
+		if r.Err != nil {
+			return nil, nil, fmt.Errorf("unable to describe current ACLs: %w", r.Err)
+		}
+	}
+
+	create, del := DiffACLs(described.ACLs(), desired)
+
+	var deleted DeleteACLsResults
+	if len(del) > 0 {
+		if deleted, err = cl.deleteExactACLs(ctx, del); err != nil {
+			return nil, nil, err
+		}
+	}
+	var created CreateACLsResults
+	if len(create) > 0 {
+		if created, err = cl.createExactACLs(ctx, create); err != nil {
+			return nil, deleted, err
+		}
+	}
+	return created, deleted, nil
+}
+
+func (cl *Client) createExactACLs(ctx context.Context, acls []ACL) (CreateACLsResults, error) {
+	req := kmsg.NewPtrCreateACLsRequest()
+	for _, acl := range acls {
+		c := kmsg.NewCreateACLsRequestCreation()
+		c.ResourceType = acl.Type
+		c.ResourceName = acl.Name
+		c.ResourcePatternType = acl.Pattern
+		c.Operation = acl.Operation
+		c.Principal = acl.Principal
+		c.Host = acl.Host
+		c.PermissionType = acl.Permission
+		req.Creations = append(req.Creations, c)
+	}
+
+	resp, err := req.RequestWith(ctx, cl.cl)
+	if err != nil {
+		return nil, err
+	}
+	if len(resp.Results) != len(req.Creations) {
+		return nil, fmt.Errorf("received %d results to %d creations", len(resp.Results), len(req.Creations))
+	}
+
+	var rs CreateACLsResults
+	for i, r := range resp.Results {
+		acl := acls[i]
+		rs = append(rs, CreateACLsResult{
+			Principal: acl.Principal,
+			Host:      acl.Host,
+
+			Type:       acl.Type,
+			Name:       acl.Name,
+			Pattern:    acl.Pattern,
+			Operation:  acl.Operation,
+			Permission: acl.Permission,
+
+			Err: kerr.ErrorForCode(r.ErrorCode),
+		})
+	}
+	return rs, nil
+}
+
+func (cl *Client) deleteExactACLs(ctx context.Context, acls []ACL) (DeleteACLsResults, error) {
+	req := kmsg.NewPtrDeleteACLsRequest()
+	for _, acl := range acls {
+		f := kmsg.NewDeleteACLsRequestFilter()
+		f.ResourceType = acl.Type
+		f.ResourceName = kmsg.StringPtr(acl.Name)
+		f.ResourcePatternType = acl.Pattern
+		f.Operation = acl.Operation
+		f.Principal = kmsg.StringPtr(acl.Principal)
+		f.Host = kmsg.StringPtr(acl.Host)
+		f.PermissionType = acl.Permission
+		req.Filters = append(req.Filters, f)
+	}
+
+	resp, err := req.RequestWith(ctx, cl.cl)
+	if err != nil {
+		return nil, err
+	}
+	if len(resp.Results) != len(req.Filters) {
+		return nil, fmt.Errorf("received %d results to %d filters", len(resp.Results), len(req.Filters))
+	}
+
+	var rs DeleteACLsResults
+	for i, r := range resp.Results {
+		f := &req.Filters[i]
+		var ms DeletedACLs
+		for _, m := range r.MatchingACLs {
+			ms = append(ms, DeletedACL{
+				Principal:  m.Principal,
+				Host:       m.Host,
+				Type:       m.ResourceType,
+				Name:       m.ResourceName,
+				Pattern:    m.ResourcePatternType,
+				Operation:  m.Operation,
+				Permission: m.PermissionType,
+				Err:        kerr.ErrorForCode(m.ErrorCode),
+			})
+		}
+		rs = append(rs, DeleteACLsResult{
+			Principal:  f.Principal,
+			Host:       f.Host,
+			Type:       f.ResourceType,
+			Name:       f.ResourceName,
+			Pattern:    f.ResourcePatternType,
+			Operation:  f.Operation,
+			Permission: f.PermissionType,
+			Deleted:    ms,
+			Err:        kerr.ErrorForCode(r.ErrorCode),
+		})
+	}
+	return rs, nil
+}
@@ -391,6 +391,9 @@ func (rs DeleteGroupResponses) On(group string, fn func(*DeleteGroupResponse) er
 // after Kafka 1.1, which removed RetentionTimeMillis from offset commits. See
 // KIP-229 for more details.
 //
+// Groups that still have members cannot be deleted: Kafka rejects them with
+// kerr.NonEmptyGroup, which is returned as the group's Err.
+//
 // This may return *ShardErrors. This does not return on authorization
 // failures, instead, authorization failures are included in the responses.
 func (cl *Client) DeleteGroups(ctx context.Context, groups ...string) (DeleteGroupResponses, error) {
@@ -401,6 +404,16 @@ func (cl *Client) DeleteGroups(ctx context.Context, groups ...string) (DeleteGro
 	req.Groups = append(req.Groups, groups...)
 	shards := cl.cl.RequestSharded(ctx, req)
 
+	// The client converts per-group error codes into a shard error, but
+	// keeps the response. We want per-group errors (most importantly,
+	// NON_EMPTY_GROUP) in the per-group responses, so we only keep shard
+	// errors for shards that have no response.
+	for i := range shards {
+		if shards[i].Resp != nil {
+			shards[i].Err = nil
+		}
+	}
+
 	rs := make(map[string]DeleteGroupResponse)
 	return rs, shardErrEach(req, shards, func(kr kmsg.Response) error {
 		resp := kr.(*kmsg.DeleteGroupsResponse)
@@ -414,6 +427,55 @@ func (cl *Client) DeleteGroups(ctx context.Context, groups ...string) (DeleteGro
 	})
 }
 
+// DeleteEmptyGroups lists all groups in the Empty or Dead state and deletes
+// them, returning the delete responses. This can be used to periodically clean
+// up groups that no longer have any members.
+//
+// Listing groups by state requires Kafka 2.6+. Against older brokers, group
+// states are unknown and this tries to delete every listed group; Kafka
+// rejects deleting any group that still has members with kerr.NonEmptyGroup,
+// which is included in the responses. A group can also gain members between
+// being listed and being deleted, in which case it is similarly not deleted.
+//
+// This may return *ShardErrors. If listing groups returns a *ShardErrors,
+// this deletes all successfully listed empty groups and appends the list
+// shard errors to any delete shard errors.
+func (cl *Client) DeleteEmptyGroups(ctx context.Context) (DeleteGroupResponses, error) {
+	var seList *ShardErrors
+	listed, err := cl.ListGroups(ctx, "Empty", "Dead")
+	switch {
+	case err == nil:
+	case errors.As(err, &seList):
+	default:
+		return nil, err
+	}
+
+	var groups []string
+	for _, g := range listed.Sorted() {
+		switch g.State {
+		case "Empty", "Dead", "": // no state: the broker is < 2.6
+			groups = append(groups, g.Group)
+		}
+	}
+	if len(groups) == 0 {
+		return nil, err
+	}
+
+	deleted, err := cl.DeleteGroups(ctx, groups...)
+	var seDel *ShardErrors
+	switch {
+	case err == nil:
+		return deleted, seList.into()
+	case errors.As(err, &seDel):
+		if seList != nil {
+			seDel.Errs = append(seList.Errs, seDel.Errs...)
+		}
+		return deleted, seDel.into()
+	default:
+		return nil, err
+	}
+}
+
 // LeaveGroupBuilder helps build a leave group request, rather than having
 // a function signature (string, string, ...string).
 //
@@ -873,6 +935,170 @@ func (cl *Client) FetchOffsetsForTopics(ctx context.Context, group string, topic
 	return resps, nil
 }
 
+// FetchGroupOffsets returns all committed offsets for the group, failing if
+// any partition had a fetch error. The returned Offsets only contains plain
+// exported fields and can be serialized (for example, with encoding/json) to
+// back up a group's offsets, to later be restored with ImportGroupOffsets.
+//
+// This method requires talking to Kafka v0.11+.
+func (cl *Client) FetchGroupOffsets(ctx context.Context, group string) (Offsets, error) {
+	resps, err := cl.FetchOffsets(ctx, group)
+	if err != nil {
+		return nil, err
+	}
+	if err := resps.Error(); err != nil {
+		return nil, fmt.Errorf("offset fetches had a load error, first error: %w", err)
+	}
+	return resps.Offsets(), nil
+}
+
+// ImportGroupOffsets commits the input offsets for a group, restoring offsets
+// previously backed up with FetchGroupOffsets.
+//
+// Committing offsets for a group with active members can cause the members to
+// overwrite the imported offsets or to reprocess data, so this first
+// describes the group and returns an error if the group is not Empty (or
+// Dead, meaning the group does not yet exist).
+//
+// Topics and partitions that no longer exist are not committed; these are
+// returned in the responses with kerr.UnknownTopicOrPartition. As with
+// CommitOffsets, per-partition commit failures are also included in the
+// responses rather than returned as an error.
+func (cl *Client) ImportGroupOffsets(ctx context.Context, group string, os Offsets) (OffsetResponses, error) {
+	if err := cl.requireEmptyGroup(ctx, group); err != nil {
+		return nil, err
+	}
+
+	listed, err := cl.ListTopics(ctx, os.TopicsSet().Topics()...)
+	if err != nil {
+		return nil, fmt.Errorf("unable to list topics: %w", err)
+	}
+	exists := listed.TopicsSet()
+
+	var (
+		commit  = make(Offsets)
+		missing OffsetResponses
+	)
+	os.Each(func(o Offset) {
+		if exists.Lookup(o.Topic, o.Partition) {
+			commit.Add(o)
+		} else {
+			missing.Add(OffsetResponse{Offset: o, Err: kerr.UnknownTopicOrPartition})
+		}
+	})
+
+	rs := make(OffsetResponses)
+	if len(commit) > 0 {
+		if rs, err = cl.CommitOffsets(ctx, group, commit); err != nil {
+			return nil, err
+		}
+	}
+	missing.Each(rs.Add)
+	return rs, nil
+}
+
+// requireEmptyGroup returns an error if the group is not Empty (or Dead,
+// meaning the group does not yet exist).
+func (cl *Client) requireEmptyGroup(ctx context.Context, group string) error {
+	described, err := cl.DescribeGroups(ctx, group)
+	if err != nil {
+		return fmt.Errorf("unable to describe group: %w", err)
+	}
+	g, err := described.On(group, nil)
+	if err != nil {
+		return fmt.Errorf("unable to describe group: %w", err)
+	}
+	if g.Err != nil {
+		return fmt.Errorf("unable to describe group: %w", g.Err)
+	}
+	if g.State != "Empty" && g.State != "Dead" {
+		return fmt.Errorf("group %q is in state %s with %d member(s), not Empty", group, g.State, len(g.Members))
+	}
+	return nil
+}
+
+// SetGroupOffsetsToStart resets a group's offsets to the start (oldest)
+// offsets of each partition in the requested topics, returning the offsets
+// that were committed per partition. If no topics are specified, the topics
+// the group currently has committed offsets for are reset.
+//
+// As with ImportGroupOffsets, this returns an error if the group is not Empty
+// (or Dead) unless force is true. Forcing a reset on a group with active
+// members is not recommended, because the members may immediately commit
+// over the reset offsets.
+//
+// Partitions whose start offsets could not be listed are not committed and
+// are returned in the responses with the listing error. Topics that do not
+// exist are skipped. As with CommitOffsets, per-partition commit failures are
+// included in the responses rather than returned as an error.
+func (cl *Client) SetGroupOffsetsToStart(ctx context.Context, group string, force bool, topics ...string) (OffsetResponses, error) {
+	return cl.setGroupOffsetsTo(ctx, group, force, topics, cl.ListStartOffsets)
+}
+
+// SetGroupOffsetsToEnd resets a group's offsets to the end (newest) offsets
+// of each partition in the requested topics, returning the offsets that were
+// committed per partition. This is the same as SetGroupOffsetsToStart, but
+// skips the group past all existing records.
+func (cl *Client) SetGroupOffsetsToEnd(ctx context.Context, group string, force bool, topics ...string) (OffsetResponses, error) {
+	return cl.setGroupOffsetsTo(ctx, group, force, topics, cl.ListEndOffsets)
+}
+
+func (cl *Client) setGroupOffsetsTo(
+	ctx context.Context,
+	group string,
+	force bool,
+	topics []string,
+	list func(context.Context, ...string) (ListedOffsets, error),
+) (OffsetResponses, error) {
+	if !force {
+		if err := cl.requireEmptyGroup(ctx, group); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(topics) == 0 {
+		fetched, err := cl.FetchOffsets(ctx, group)
+		if err != nil {
+			return nil, fmt.Errorf("unable to fetch group offsets: %w", err)
+		}
+		if topics = fetched.Partitions().Topics(); len(topics) == 0 {
+			return nil, fmt.Errorf("group %q has no committed offsets and no topics were specified", group)
+		}
+	}
+
+	listed, err := list(ctx, topics...)
+	if err != nil {
+		return nil, fmt.Errorf("unable to list offsets: %w", err)
+	}
+
+	var (
+		commit = make(Offsets)
+		failed OffsetResponses
+	)
+	listed.Each(func(l ListedOffset) {
+		o := Offset{
+			Topic:       l.Topic,
+			Partition:   l.Partition,
+			At:          l.Offset,
+			LeaderEpoch: l.LeaderEpoch,
+		}
+		if l.Err != nil {
+			failed.Add(OffsetResponse{Offset: o, Err: l.Err})
+		} else {
+			commit.Add(o)
+		}
+	})
+
+	rs := make(OffsetResponses)
+	if len(commit) > 0 {
+		if rs, err = cl.CommitOffsets(ctx, group, commit); err != nil {
+			return nil, err
+		}
+	}
+	failed.Each(rs.Add)
+	return rs, nil
+}
+
 // FetchOffsetsResponse contains a fetch offsets response for a single group.
 type FetchOffsetsResponse struct {
 	Group   string          // Group is the offsets these fetches correspond to.
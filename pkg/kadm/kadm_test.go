@@ -1,9 +1,13 @@
 package kadm
 
 import (
+	"context"
 	"errors"
 	"reflect"
 	"testing"
+
+	"github.com/burningass23/franz-go/pkg/kerr"
+	"github.com/burningass23/franz-go/pkg/kmsg"
 )
 
 func input[V any](v V) V { return v }
@@ -86,3 +90,132 @@ func TestAny(t *testing.T) {
 		}
 	}
 }
+
+func TestDiffACLs(t *testing.T) {
+	acl := func(principal string, op ACLOperation) ACL {
+		return ACL{
+			Principal:  principal,
+			Host:       "*",
+			Type:       kmsg.ACLResourceTypeTopic,
+			Name:       "foo",
+			Pattern:    ACLPatternLiteral,
+			Operation:  op,
+			Permission: kmsg.ACLPermissionTypeAllow,
+		}
+	}
+	var (
+		aRead  = acl("User:a", OpRead)
+		aWrite = acl("User:a", OpWrite)
+		bRead  = acl("User:b", OpRead)
+	)
+	for i, test := range []struct {
+		current, desired []ACL
+		expCreate        []ACL
+		expDelete        []ACL
+	}{
+		{nil, nil, nil, nil},
+		{[]ACL{aRead}, []ACL{aRead}, nil, nil},
+		{nil, []ACL{aRead, aRead}, []ACL{aRead}, nil},
+		{[]ACL{aRead, aRead}, nil, nil, []ACL{aRead}},
+		{[]ACL{aRead, aWrite}, []ACL{aRead, bRead}, []ACL{bRead}, []ACL{aWrite}},
+	} {
+		create, del := DiffACLs(test.current, test.desired)
+		if !reflect.DeepEqual(create, test.expCreate) {
+			t.Errorf("#%d: got create %v != exp %v", i, create, test.expCreate)
+		}
+		if !reflect.DeepEqual(del, test.expDelete) {
+			t.Errorf("#%d: got delete %v != exp %v", i, del, test.expDelete)
+		}
+	}
+}
+
+func TestValidateReplicaAssignment(t *testing.T) {
+	for i, test := range []struct {
+		assignment map[int32][]int32
+		expErr     bool
+	}{
+		{map[int32][]int32{0: {1, 2}, 1: {2, 3}, 2: {3, 1}}, false},
+		{map[int32][]int32{0: {1}}, false},
+		{nil, true},                                     // empty
+		{map[int32][]int32{1: {1}}, true},               // not numbered from 0
+		{map[int32][]int32{0: {1}, 2: {2}}, true},       // gap
+		{map[int32][]int32{0: {}}, true},                // no replicas
+		{map[int32][]int32{0: {1, 2}, 1: {2}}, true},    // differing replication factor
+		{map[int32][]int32{0: {1, 1}}, true},            // duplicate broker
+		{map[int32][]int32{0: {1, 2}, 1: {3, 3}}, true}, // duplicate broker in a later partition
+	} {
+		err := validateReplicaAssignment(test.assignment)
+		if gotErr := err != nil; gotErr != test.expErr {
+			t.Errorf("#%d: got err %v, exp err? %v", i, err, test.expErr)
+		}
+	}
+
+	// Invalid assignments fail before any request is issued.
+	var cl Client
+	if _, err := cl.CreateTopicsWithAssignment(context.Background(), map[int32][]int32{1: {1}}, nil, "foo"); err == nil {
+		t.Error("expected CreateTopicsWithAssignment to fail on an invalid assignment")
+	}
+	if _, err := cl.ValidateCreateTopicsWithAssignment(context.Background(), nil, nil, "foo"); err == nil {
+		t.Error("expected ValidateCreateTopicsWithAssignment to fail on an empty assignment")
+	}
+}
+
+func TestTopicDetailReady(t *testing.T) {
+	leader := func(l int32) PartitionDetail { return PartitionDetail{Leader: l} }
+	for i, test := range []struct {
+		d      TopicDetail
+		nparts int32
+		exp    bool
+	}{
+		{TopicDetail{Partitions: PartitionDetails{0: leader(1), 1: leader(2)}}, 2, true},
+		{TopicDetail{Partitions: PartitionDetails{0: leader(1), 1: leader(2)}}, -1, true},
+		{TopicDetail{Partitions: PartitionDetails{0: leader(1)}}, 2, false},
+		{TopicDetail{Partitions: PartitionDetails{0: leader(1), 1: leader(-1)}}, 2, false},
+		{TopicDetail{Partitions: PartitionDetails{0: {Leader: 1, Err: kerr.LeaderNotAvailable}}}, 1, false},
+		{TopicDetail{Err: kerr.UnknownTopicOrPartition}, -1, false},
+		{TopicDetail{}, -1, false},
+	} {
+		if got := test.d.ready(test.nparts); got != test.exp {
+			t.Errorf("#%d: got %v != exp %v", i, got, test.exp)
+		}
+	}
+}
+
+func TestNewMetadataQuorum(t *testing.T) {
+	replica := func(id int32, end int64) kmsg.DescribeQuorumResponseTopicPartitionReplicaState {
+		r := kmsg.NewDescribeQuorumResponseTopicPartitionReplicaState()
+		r.ReplicaID = id
+		r.LogEndOffset = end
+		return r
+	}
+	p := kmsg.NewDescribeQuorumResponseTopicPartition()
+	p.LeaderID = 2
+	p.LeaderEpoch = 5
+	p.HighWatermark = 90
+	p.CurrentVoters = append(p.CurrentVoters, replica(3, 80), replica(2, 100), replica(1, -1))
+	p.Observers = append(p.Observers, replica(4, 60))
+
+	exp := MetadataQuorum{
+		LeaderID:      2,
+		LeaderEpoch:   5,
+		HighWatermark: 90,
+		Voters: []QuorumReplica{
+			{ReplicaID: 1, LogEndOffset: -1, Lag: -1, LastFetchTimestamp: -1, LastCaughtUpTimestamp: -1},
+			{ReplicaID: 2, LogEndOffset: 100, Lag: 0, LastFetchTimestamp: -1, LastCaughtUpTimestamp: -1},
+			{ReplicaID: 3, LogEndOffset: 80, Lag: 20, LastFetchTimestamp: -1, LastCaughtUpTimestamp: -1},
+		},
+		Observers: []QuorumReplica{
+			{ReplicaID: 4, LogEndOffset: 60, Lag: 40, LastFetchTimestamp: -1, LastCaughtUpTimestamp: -1},
+		},
+	}
+	if got := newMetadataQuorum(p); !reflect.DeepEqual(got, exp) {
+		t.Errorf("got %+v != exp %+v", got, exp)
+	}
+
+	p.LeaderID = -1
+	for _, r := range newMetadataQuorum(p).Voters {
+		if r.Lag != -1 {
+			t.Errorf("replica %d: got lag %d with unknown leader, exp -1", r.ReplicaID, r.Lag)
+		}
+	}
+}
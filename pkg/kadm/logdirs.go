@@ -228,6 +228,30 @@ func (ds DescribedAllLogDirs) Each(fn func(DescribedLogDir)) {
 	}
 }
 
+// Size returns the total size of all directories across all brokers. Note
+// that this includes every replica of every partition.
+func (ds DescribedAllLogDirs) Size() int64 {
+	var tot int64
+	for _, bds := range ds {
+		tot += bds.Size()
+	}
+	return tot
+}
+
+// TopicSizes returns the total size of each topic across all brokers and
+// directories. Note that this includes every replica of every partition, so
+// a topic with a replication factor of 3 will be roughly three times the size
+// of the topic's data.
+func (ds DescribedAllLogDirs) TopicSizes() map[string]int64 {
+	sizes := make(map[string]int64)
+	for _, bds := range ds {
+		bds.EachPartition(func(d DescribedLogDirPartition) {
+			sizes[d.Topic] += d.Size
+		})
+	}
+	return sizes
+}
+
 // DescribedLogDirs contains per-directory responses to described log
 // directories for a single broker.
 type DescribedLogDirs map[string]DescribedLogDir
@@ -276,6 +300,15 @@ func (ds DescribedLogDirs) Size() int64 {
 	return tot
 }
 
+// TopicSizes returns the total size of each topic across all directories.
+func (ds DescribedLogDirs) TopicSizes() map[string]int64 {
+	sizes := make(map[string]int64)
+	ds.EachPartition(func(d DescribedLogDirPartition) {
+		sizes[d.Topic] += d.Size
+	})
+	return sizes
+}
+
 // Error iterates over all directories and returns the first error encounted,
 // if any. This can be used to check if describing was entirely successful or
 // not.
@@ -426,6 +459,15 @@ func (ds DescribedLogDirTopics) Size() int64 {
 	return tot
 }
 
+// TopicSizes returns the total size of each topic in this directory.
+func (ds DescribedLogDirTopics) TopicSizes() map[string]int64 {
+	sizes := make(map[string]int64, len(ds))
+	ds.Each(func(d DescribedLogDirPartition) {
+		sizes[d.Topic] += d.Size
+	})
+	return sizes
+}
+
 // Sorted returns all partitions sorted by topic then partition.
 func (ds DescribedLogDirTopics) Sorted() []DescribedLogDirPartition {
 	var all []DescribedLogDirPartition
@@ -282,6 +282,69 @@ func (cl *Client) metadata(ctx context.Context, noTopics bool, topics []string)
 	return m, nil
 }
 
+// ClusterDescription is an aggregate overview of a cluster, as returned from
+// DescribeCluster.
+type ClusterDescription struct {
+	Cluster    string        // Cluster is the cluster name, if any.
+	Controller int32         // Controller is the node ID of the controller broker, if available, otherwise -1.
+	Brokers    BrokerDetails // Brokers contains broker details (including racks), sorted by node ID.
+	Topics     TopicDetails  // Topics contains details for all topics in the cluster.
+
+	NumTopics     int // NumTopics is the number of topics in the cluster, including internal topics.
+	NumPartitions int // NumPartitions is the number of partitions across all topics.
+
+	// UnderReplicated contains partitions whose in sync replica set is
+	// smaller than the replica set, sorted by topic and partition.
+	UnderReplicated []PartitionDetail
+	// Offline contains partitions that have no leader, sorted by topic
+	// and partition.
+	Offline []PartitionDetail
+}
+
+// Healthy returns whether the cluster has a controller and no
+// under-replicated or offline partitions.
+func (d ClusterDescription) Healthy() bool {
+	return d.Controller >= 0 && len(d.UnderReplicated) == 0 && len(d.Offline) == 0
+}
+
+// DescribeCluster issues a metadata request for all topics and returns an
+// aggregate overview of the cluster: brokers, the controller, the cluster ID,
+// topic and partition counts, and any under-replicated or offline
+// partitions. This is useful for building a cluster health overview without
+// issuing and combining several requests manually.
+//
+// A partition is under-replicated if it has fewer in sync replicas than
+// replicas, and offline if it has no leader. Topics that fail to load are not
+// included in the partition count; they can be inspected with
+// Topics.EachError.
+//
+// This returns an error if the request fails to be issued, or an *AuthErr.
+func (cl *Client) DescribeCluster(ctx context.Context) (ClusterDescription, error) {
+	m, err := cl.Metadata(ctx)
+	if err != nil {
+		return ClusterDescription{}, err
+	}
+	d := ClusterDescription{
+		Cluster:    m.Cluster,
+		Controller: m.Controller,
+		Brokers:    m.Brokers,
+		Topics:     m.Topics,
+		NumTopics:  len(m.Topics),
+	}
+	for _, td := range m.Topics.Sorted() {
+		for _, pd := range td.Partitions.Sorted() {
+			d.NumPartitions++
+			if pd.Leader < 0 {
+				d.Offline = append(d.Offline, pd)
+			}
+			if len(pd.ISR) < len(pd.Replicas) {
+				d.UnderReplicated = append(d.UnderReplicated, pd)
+			}
+		}
+	}
+	return d, nil
+}
+
 // ListedOffset contains record offset information.
 type ListedOffset struct {
 	Topic     string // Topic is the topic this offset is for.
@@ -391,7 +454,8 @@ func (cl *Client) ListCommittedOffsets(ctx context.Context, topics ...string) (L
 // millisecond timestamp. Unlike listing start/end/committed offsets, offsets
 // returned from this function also include the timestamp of the offset. If no
 // topics are specified, all topics are listed. If a partition has no offsets
-// after the requested millisecond, the offset will be the current end offset.
+// after the requested millisecond, the offset will be the current end offset
+// and the timestamp will be -1.
 //
 // This may return *ShardErrors.
 func (cl *Client) ListOffsetsAfterMilli(ctx context.Context, millisecond int64, topics ...string) (ListedOffsets, error) {
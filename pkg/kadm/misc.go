@@ -5,6 +5,7 @@ import (
 	"crypto/rand"
 	"crypto/sha256"
 	"crypto/sha512"
+	"errors"
 	"fmt"
 	"sort"
 	"strings"
@@ -774,12 +775,69 @@ type ElectLeadersResult struct {
 	How        ElectLeadersHow // How is the type of election that was performed.
 	Err        error           // Err is non-nil if electing this partition's leader failed, such as the partition not existing or the preferred leader is not available and you used ElectPreferredReplica.
 	ErrMessage string          // ErrMessage a potential extra message describing any error.
+
+	// NotNeeded is true if Err is kerr.ElectionNotNeeded, meaning the
+	// partition's leader was already the leader that would be elected.
+	NotNeeded bool
 }
 
 // ElectLeadersResults contains per-topic, per-partition results for an elect
 // leaders request.
 type ElectLeadersResults map[string]map[int32]ElectLeadersResult
 
+// Lookup returns the result at t and p and whether it exists.
+func (rs ElectLeadersResults) Lookup(t string, p int32) (ElectLeadersResult, bool) {
+	if len(rs) == 0 {
+		return ElectLeadersResult{}, false
+	}
+	ps := rs[t]
+	if len(ps) == 0 {
+		return ElectLeadersResult{}, false
+	}
+	r, exists := ps[p]
+	return r, exists
+}
+
+// Each calls fn for every result.
+func (rs ElectLeadersResults) Each(fn func(ElectLeadersResult)) {
+	for _, ps := range rs {
+		for _, r := range ps {
+			fn(r)
+		}
+	}
+}
+
+// Sorted returns all results sorted first by topic, then by partition.
+func (rs ElectLeadersResults) Sorted() []ElectLeadersResult {
+	var s []ElectLeadersResult
+	rs.Each(func(r ElectLeadersResult) { s = append(s, r) })
+	sort.Slice(s, func(i, j int) bool {
+		l, r := s[i], s[j]
+		return l.Topic < r.Topic || l.Topic == r.Topic && l.Partition < r.Partition
+	})
+	return s
+}
+
+// Error iterates over all results and returns the first error encountered,
+// if any. Results that have an ElectionNotNeeded error are not considered
+// failures and are skipped.
+func (rs ElectLeadersResults) Error() error {
+	for _, ps := range rs {
+		for _, r := range ps {
+			if r.Err != nil && !r.NotNeeded {
+				return r.Err
+			}
+		}
+	}
+	return nil
+}
+
+// Ok returns true if there are no errors. This is a shortcut for rs.Error() ==
+// nil.
+func (rs ElectLeadersResults) Ok() bool {
+	return rs.Error() == nil
+}
+
 // ElectLeaders elects leaders for partitions. This request was added in Kafka
 // 2.2 to replace the previously-ZooKeeper-only option of triggering leader
 // elections. See KIP-183 for more details.
@@ -826,12 +884,38 @@ func (cl *Client) ElectLeaders(ctx context.Context, how ElectLeadersHow, s Topic
 				How:        how,
 				Err:        kerr.ErrorForCode(p.ErrorCode),
 				ErrMessage: unptrStr(p.ErrorMessage),
+				NotNeeded:  p.ErrorCode == kerr.ElectionNotNeeded.Code,
 			}
 		}
 	}
 	return rs, nil
 }
 
+// ElectLeadersTopics elects leaders for every partition of the given topics.
+// This first lists the topics to discover their partitions and then issues
+// ElectLeaders. If no topics are given, this elects leaders for every
+// partition of all non-internal topics.
+//
+// This returns an error if listing fails or if any requested topic has a
+// load error (such as the topic not existing); otherwise, this returns the
+// same as ElectLeaders.
+func (cl *Client) ElectLeadersTopics(ctx context.Context, how ElectLeadersHow, topics ...string) (ElectLeadersResults, error) {
+	listed, err := cl.ListTopics(ctx, topics...)
+	if err != nil {
+		return nil, err
+	}
+	for _, t := range listed.Sorted() {
+		if t.Err != nil {
+			return nil, fmt.Errorf("unable to list topic %q: %w", t.Topic, t.Err)
+		}
+	}
+	s := listed.TopicsSet()
+	if len(s) == 0 {
+		return make(ElectLeadersResults), nil
+	}
+	return cl.ElectLeaders(ctx, how, s)
+}
+
 // OffsetForLeaderEpochRequest contains topics, partitions, and leader epochs
 // to request offsets for in an OffsetForLeaderEpoch.
 type OffsetForLeaderEpochRequest map[string]map[int32]int32
@@ -938,3 +1022,111 @@ func (cl *Client) OffetForLeaderEpoch(ctx context.Context, r OffsetForLeaderEpoc
 		return nil
 	})
 }
+
+// QuorumReplica is the state of a single replica in the KRaft metadata
+// quorum.
+type QuorumReplica struct {
+	ReplicaID    int32 // ReplicaID is the node ID of this replica.
+	LogEndOffset int64 // LogEndOffset is the last known log end offset of this replica.
+
+	// Lag is how far this replica is behind the leader, i.e., the leader's
+	// log end offset minus this replica's log end offset. This is -1 if
+	// the leader's log end offset is unknown.
+	Lag int64
+
+	// LastFetchTimestamp is the last millisecond timestamp at which this
+	// replica fetched from the leader, or -1 if unknown (always -1 before
+	// Kafka 3.3).
+	LastFetchTimestamp int64
+
+	// LastCaughtUpTimestamp is the last millisecond timestamp at which
+	// this replica was caught up to the leader, or -1 if unknown (always
+	// -1 before Kafka 3.3).
+	LastCaughtUpTimestamp int64
+}
+
+// MetadataQuorum is the state of the KRaft metadata quorum, as returned from
+// DescribeMetadataQuorum.
+type MetadataQuorum struct {
+	LeaderID      int32           // LeaderID is the node ID of the quorum leader, or -1 if unknown.
+	LeaderEpoch   int32           // LeaderEpoch is the latest known leader epoch.
+	HighWatermark int64           // HighWatermark is the high watermark of the metadata log.
+	Voters        []QuorumReplica // Voters are the voting members of the quorum, sorted by replica ID.
+	Observers     []QuorumReplica // Observers are the non-voting members of the quorum, sorted by replica ID.
+}
+
+// DescribeMetadataQuorum describes the KRaft metadata quorum: the quorum
+// leader, and the log end offset, lag, and fetch timestamps of every voter
+// and observer.
+//
+// This request is only supported on KRaft clusters (Kafka 3.0+). ZooKeeper
+// based clusters do not support this request, and this returns an error
+// indicating the broker is too old.
+func (cl *Client) DescribeMetadataQuorum(ctx context.Context) (MetadataQuorum, error) {
+	req := kmsg.NewPtrDescribeQuorumRequest()
+	rt := kmsg.NewDescribeQuorumRequestTopic()
+	rt.Topic = "__cluster_metadata"
+	rp := kmsg.NewDescribeQuorumRequestTopicPartition()
+	rp.Partition = 0
+	rt.Partitions = append(rt.Partitions, rp)
+	req.Topics = append(req.Topics, rt)
+
+	resp, err := req.RequestWith(ctx, cl.cl)
+	if err != nil {
+		return MetadataQuorum{}, err
+	}
+	if err := maybeAuthErr(resp.ErrorCode); err != nil {
+		return MetadataQuorum{}, err
+	}
+	if err := kerr.ErrorForCode(resp.ErrorCode); err != nil {
+		return MetadataQuorum{}, err
+	}
+	if len(resp.Topics) != 1 || len(resp.Topics[0].Partitions) != 1 {
+		return MetadataQuorum{}, errors.New("describe quorum response did not contain the metadata partition")
+	}
+	p := resp.Topics[0].Partitions[0]
+	if err := maybeAuthErr(p.ErrorCode); err != nil {
+		return MetadataQuorum{}, err
+	}
+	if err := kerr.ErrorForCode(p.ErrorCode); err != nil {
+		return MetadataQuorum{}, err
+	}
+	return newMetadataQuorum(p), nil
+}
+
+func newMetadataQuorum(p kmsg.DescribeQuorumResponseTopicPartition) MetadataQuorum {
+	q := MetadataQuorum{
+		LeaderID:      p.LeaderID,
+		LeaderEpoch:   p.LeaderEpoch,
+		HighWatermark: p.HighWatermark,
+	}
+
+	leaderEnd := int64(-1)
+	for _, v := range p.CurrentVoters {
+		if v.ReplicaID == p.LeaderID {
+			leaderEnd = v.LogEndOffset
+		}
+	}
+
+	replicas := func(rs []kmsg.DescribeQuorumResponseTopicPartitionReplicaState) []QuorumReplica {
+		var qrs []QuorumReplica
+		for _, r := range rs {
+			lag := int64(-1)
+			if leaderEnd >= 0 && r.LogEndOffset >= 0 {
+				lag = leaderEnd - r.LogEndOffset
+			}
+			qrs = append(qrs, QuorumReplica{
+				ReplicaID:             r.ReplicaID,
+				LogEndOffset:          r.LogEndOffset,
+				Lag:                   lag,
+				LastFetchTimestamp:    r.LastFetchTimestamp,
+				LastCaughtUpTimestamp: r.LastCaughtUpTimestamp,
+			})
+		}
+		sort.Slice(qrs, func(i, j int) bool { return qrs[i].ReplicaID < qrs[j].ReplicaID })
+		return qrs
+	}
+	q.Voters = replicas(p.CurrentVoters)
+	q.Observers = replicas(p.Observers)
+	return q
+}
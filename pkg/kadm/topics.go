@@ -3,7 +3,9 @@ package kadm
 import (
 	"context"
 	"errors"
+	"fmt"
 	"sort"
+	"time"
 
 	"github.com/burningass23/franz-go/pkg/kerr"
 	"github.com/burningass23/franz-go/pkg/kmsg"
@@ -49,6 +51,7 @@ type CreateTopicResponse struct {
 	NumPartitions     int32             // NumPartitions is the number of partitions in the response, if talking to Kafka v2.4+.
 	ReplicationFactor int16             // ReplicationFactor is how many replicas every partition has for this topic, if talking to Kafka 2.4+.
 	Configs           map[string]Config // Configs contains the topic configuration (minus config synonyms), if talking to Kafka 2.4+.
+	ValidateOnly      bool              // ValidateOnly is true if this response is from a validation only request, meaning the topic was not actually created.
 }
 
 // CreateTopicRepsonses contains per-topic responses for created topics.
@@ -158,7 +161,82 @@ func (cl *Client) CreateTopics(
 	configs map[string]*string,
 	topics ...string,
 ) (CreateTopicResponses, error) {
-	return cl.createTopics(ctx, false, partitions, replicationFactor, configs, topics)
+	return cl.createTopics(ctx, false, partitions, replicationFactor, nil, configs, topics)
+}
+
+// CreateTopicsAndWait is CreateTopics, but after topics are created, this
+// waits until every partition of every successfully created topic has a
+// leader, meaning the topics are immediately usable for producing and
+// consuming. Metadata is polled until the topics are ready or the context is
+// canceled, in which case this returns the create responses and the
+// context's error.
+//
+// Topics that failed to be created (including topics that already existed)
+// are not waited on; check each response's Err.
+func (cl *Client) CreateTopicsAndWait(
+	ctx context.Context,
+	partitions int32,
+	replicationFactor int16,
+	configs map[string]*string,
+	topics ...string,
+) (CreateTopicResponses, error) {
+	rs, err := cl.CreateTopics(ctx, partitions, replicationFactor, configs, topics...)
+	if err != nil {
+		return nil, err
+	}
+	return rs, cl.waitTopicsReady(ctx, rs)
+}
+
+// waitTopicsReady polls metadata until all successfully created topics in rs
+// have a leader for every partition.
+func (cl *Client) waitTopicsReady(ctx context.Context, rs CreateTopicResponses) error {
+	want := make(map[string]int32) // topic => # partitions, or -1 if unknown
+	for _, r := range rs {
+		if r.Err == nil {
+			want[r.Topic] = r.NumPartitions
+		}
+	}
+
+	const pollInterval = 100 * time.Millisecond
+	for len(want) > 0 {
+		waiting := make([]string, 0, len(want))
+		for t := range want {
+			waiting = append(waiting, t)
+		}
+		m, err := cl.Metadata(ctx, waiting...)
+		if err == nil {
+			for t, np := range want {
+				if m.Topics[t].ready(np) {
+					delete(want, t)
+				}
+			}
+			if len(want) == 0 {
+				return nil
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(pollInterval):
+		}
+	}
+	return nil
+}
+
+// ready returns whether the topic loaded without error and every partition
+// has a leader. If nparts is positive, the topic must also have that many
+// partitions.
+func (d TopicDetail) ready(nparts int32) bool {
+	if d.Err != nil || len(d.Partitions) == 0 || nparts > 0 && len(d.Partitions) != int(nparts) {
+		return false
+	}
+	for _, p := range d.Partitions {
+		if p.Err != nil || p.Leader < 0 {
+			return false
+		}
+	}
+	return true
 }
 
 // ValidateCreateTopics validates a create topics request with the given
@@ -176,10 +254,89 @@ func (cl *Client) ValidateCreateTopics(
 	configs map[string]*string,
 	topics ...string,
 ) (CreateTopicResponses, error) {
-	return cl.createTopics(ctx, true, partitions, replicationFactor, configs, topics)
+	return cl.createTopics(ctx, true, partitions, replicationFactor, nil, configs, topics)
 }
 
-func (cl *Client) createTopics(ctx context.Context, dry bool, p int32, rf int16, configs map[string]*string, topics []string) (CreateTopicResponses, error) {
+// CreateTopicsWithAssignment issues a create topics request with an explicit
+// replica assignment and (optional) configs for every topic. The assignment
+// maps each partition to the broker IDs that should host its replicas; the
+// first broker for a partition is the preferred leader. Partitions must be
+// numbered from 0 and all partitions must have the same number of replicas;
+// this returns an error without issuing a request if the assignment is
+// invalid. The number of partitions and the replication factor of each topic
+// are inferred from the assignment.
+//
+// This is useful for pinning replicas to specific brokers, e.g. for capacity
+// aware topic provisioning. Use ValidateCreateTopicsWithAssignment to check
+// the assignment without creating anything.
+//
+// This does not return an error on authorization failures, instead,
+// authorization failures are included in the responses. This only returns an
+// error if the request fails to be issued.
+func (cl *Client) CreateTopicsWithAssignment(
+	ctx context.Context,
+	assignment map[int32][]int32,
+	configs map[string]*string,
+	topics ...string,
+) (CreateTopicResponses, error) {
+	if err := validateReplicaAssignment(assignment); err != nil {
+		return nil, err
+	}
+	return cl.createTopics(ctx, false, -1, -1, assignment, configs, topics)
+}
+
+// ValidateCreateTopicsWithAssignment validates a create topics request with
+// the given replica assignment and (optional) configs for every topic.
+//
+// This uses the same logic as CreateTopicsWithAssignment, but with the
+// request's ValidateOnly field set to true. The response is the same response
+// you would receive from CreateTopicsWithAssignment, but no topics are
+// actually created.
+func (cl *Client) ValidateCreateTopicsWithAssignment(
+	ctx context.Context,
+	assignment map[int32][]int32,
+	configs map[string]*string,
+	topics ...string,
+) (CreateTopicResponses, error) {
+	if err := validateReplicaAssignment(assignment); err != nil {
+		return nil, err
+	}
+	return cl.createTopics(ctx, true, -1, -1, assignment, configs, topics)
+}
+
+// validateReplicaAssignment ensures an assignment has partitions numbered
+// from 0 with no gaps, that every partition has the same non-zero number of
+// replicas, and that no partition lists a broker twice.
+func validateReplicaAssignment(assignment map[int32][]int32) error {
+	if len(assignment) == 0 {
+		return errors.New("invalid empty replica assignment")
+	}
+	rf := -1
+	for p := int32(0); p < int32(len(assignment)); p++ {
+		replicas, exists := assignment[p]
+		if !exists {
+			return fmt.Errorf("invalid replica assignment: partitions must be numbered from 0 with no gaps, missing partition %d", p)
+		}
+		if len(replicas) == 0 {
+			return fmt.Errorf("invalid replica assignment: partition %d has no replicas", p)
+		}
+		if rf == -1 {
+			rf = len(replicas)
+		} else if len(replicas) != rf {
+			return fmt.Errorf("invalid replica assignment: partition %d has %d replicas, but partition 0 has %d", p, len(replicas), rf)
+		}
+		seen := make(map[int32]bool, len(replicas))
+		for _, b := range replicas {
+			if seen[b] {
+				return fmt.Errorf("invalid replica assignment: partition %d lists broker %d more than once", p, b)
+			}
+			seen[b] = true
+		}
+	}
+	return nil
+}
+
+func (cl *Client) createTopics(ctx context.Context, dry bool, p int32, rf int16, assignment map[int32][]int32, configs map[string]*string, topics []string) (CreateTopicResponses, error) {
 	if len(topics) == 0 {
 		return make(CreateTopicResponses), nil
 	}
@@ -192,6 +349,15 @@ func (cl *Client) createTopics(ctx context.Context, dry bool, p int32, rf int16,
 		rt.Topic = t
 		rt.NumPartitions = p
 		rt.ReplicationFactor = rf
+		for partition, replicas := range assignment {
+			ra := kmsg.NewCreateTopicsRequestTopicReplicaAssignment()
+			ra.Partition = partition
+			ra.Replicas = replicas
+			rt.ReplicaAssignment = append(rt.ReplicaAssignment, ra)
+		}
+		sort.Slice(rt.ReplicaAssignment, func(i, j int) bool {
+			return rt.ReplicaAssignment[i].Partition < rt.ReplicaAssignment[j].Partition
+		})
 		for k, v := range configs {
 			rc := kmsg.NewCreateTopicsRequestTopicConfig()
 			rc.Name = k
@@ -215,6 +381,7 @@ func (cl *Client) createTopics(ctx context.Context, dry bool, p int32, rf int16,
 			NumPartitions:     t.NumPartitions,
 			ReplicationFactor: t.ReplicationFactor,
 			Configs:           make(map[string]Config),
+			ValidateOnly:      dry,
 		}
 		for _, c := range t.Configs {
 			rt.Configs[c.Name] = Config{
@@ -10,7 +10,8 @@ import (
 
 // TODO
 // * Leaders
-// * Support txns
+// * Support AddPartitionsToTxn / EndTxn; transactional batches currently
+//   implicitly open a transaction that is ended with WriteTxnMarkers
 // * Multiple batches in one produce
 
 func init() { regKey(0, 3, 9) }
@@ -55,10 +56,6 @@ func (c *Cluster) handleProduce(b *broker, kreq kmsg.Request) (kmsg.Response, er
 		return resp
 	}
 
-	if req.TransactionID != nil {
-		donets(kerr.TransactionalIDAuthorizationFailed.Code)
-		return toresp(), nil
-	}
 	switch req.Acks {
 	case -1, 0, 1:
 	default:
@@ -115,10 +112,20 @@ func (c *Cluster) handleProduce(b *broker, kreq kmsg.Request) (kmsg.Response, er
 				b.MaxTimestamp = now
 				logAppendTime = now
 			}
-			if attrs&0xfff0 != 0 { // TODO txn bit
+			if attrs&0xffe0 != 0 { // only the txn bit is allowed; no control batches
 				donep(rt.Topic, rp, kerr.CorruptMessage.Code)
 				continue
 			}
+			if attrs&0x0010 != 0 {
+				if req.TransactionID == nil {
+					donep(rt.Topic, rp, kerr.InvalidTxnState.Code)
+					continue
+				}
+				if !c.pids.txnal(b.ProducerID, *req.TransactionID) {
+					donep(rt.Topic, rp, kerr.InvalidProducerIDMapping.Code)
+					continue
+				}
+			}
 			if b.LastOffsetDelta != b.NumRecords-1 {
 				donep(rt.Topic, rp, kerr.CorruptMessage.Code)
 				continue
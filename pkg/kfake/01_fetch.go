@@ -15,6 +15,13 @@ import (
 // * If any partition is on a different broker, we return immediately
 // * Out of range fetch causes early return
 // * Raw bytes of batch counts against wait bytes
+// * READ_COMMITTED fetches stop at the last stable offset
+// * v11+: any replica serves fetches, not just the leader
+// * v11+: if the request rack differs from the leader's rack and a replica is
+//   in the request rack, the leader returns no data and that replica as the
+//   preferred read replica
+// * If FetchMaxRecords is configured, batches stop being added once that many
+//   records are in the response
 
 func init() { regKey(1, 4, 13) }
 
@@ -29,9 +36,10 @@ func (c *Cluster) handleFetch(creq clientReq, w *watchFetch) (kmsg.Response, err
 	}
 
 	var (
-		nbytes      int
-		returnEarly bool
-		needp       tps[int]
+		nbytes        int
+		returnEarly   bool
+		needp         tps[int]
+		readCommitted = req.IsolationLevel == 1
 	)
 	if w == nil {
 	out:
@@ -49,8 +57,8 @@ func (c *Cluster) handleFetch(creq clientReq, w *watchFetch) (kmsg.Response, err
 				if !ok || pd.createdAt.After(creq.at) {
 					continue
 				}
-				if pd.leader != creq.cc.b {
-					returnEarly = true // NotLeaderForPartition
+				if preferred, errCode := c.fetchReplica(req, rt.Topic, pd, creq.cc.b); errCode != 0 || preferred != -1 {
+					returnEarly = true // NotLeaderForPartition, or redirect to preferred replica
 					break out
 				}
 				i, ok, atEnd := pd.searchOffset(rp.FetchOffset)
@@ -63,6 +71,9 @@ func (c *Cluster) handleFetch(creq clientReq, w *watchFetch) (kmsg.Response, err
 				}
 				pbytes := 0
 				for _, b := range pd.batches[i:] {
+					if readCommitted && b.FirstOffset >= pd.lastStableOffset {
+						break
+					}
 					nbytes += b.nbytes
 					pbytes += b.nbytes
 					if pbytes >= int(rp.PartitionMaxBytes) {
@@ -132,7 +143,7 @@ func (c *Cluster) handleFetch(creq clientReq, w *watchFetch) (kmsg.Response, err
 		return &st.Partitions[len(st.Partitions)-1]
 	}
 
-	var batchesAdded int
+	var batchesAdded, nrecords int
 full:
 	for _, rt := range req.Topics {
 		for _, rp := range rt.Partitions {
@@ -145,14 +156,19 @@ full:
 				}
 				continue
 			}
-			if pd.leader != creq.cc.b {
-				donep(rt.Topic, rt.TopicID, rp.Partition, kerr.NotLeaderForPartition.Code)
+			preferred, errCode := c.fetchReplica(req, rt.Topic, pd, creq.cc.b)
+			if errCode != 0 {
+				donep(rt.Topic, rt.TopicID, rp.Partition, errCode)
 				continue
 			}
 			sp := donep(rt.Topic, rt.TopicID, rp.Partition, 0)
 			sp.HighWatermark = pd.highWatermark
 			sp.LastStableOffset = pd.lastStableOffset
 			sp.LogStartOffset = pd.logStartOffset
+			if preferred != -1 {
+				sp.PreferredReadReplica = preferred
+				continue
+			}
 			i, ok, atEnd := pd.searchOffset(rp.FetchOffset)
 			if atEnd {
 				continue
@@ -161,8 +177,17 @@ full:
 				sp.ErrorCode = kerr.OffsetOutOfRange.Code
 				continue
 			}
-			var pbytes int
+			var (
+				pbytes int
+				end    int64
+			)
 			for _, b := range pd.batches[i:] {
+				if readCommitted && b.FirstOffset >= pd.lastStableOffset {
+					break
+				}
+				if max := c.cfg.fetchMaxRecords; max > 0 && nrecords >= max && batchesAdded > 0 {
+					break full
+				}
 				if nbytes = nbytes + b.nbytes; nbytes > int(req.MaxBytes) && batchesAdded > 1 {
 					break full
 				}
@@ -170,7 +195,12 @@ full:
 					break
 				}
 				batchesAdded++
+				nrecords += int(b.NumRecords)
 				sp.RecordBatches = b.AppendTo(sp.RecordBatches)
+				end = b.FirstOffset + int64(b.LastOffsetDelta) + 1
+			}
+			if readCommitted {
+				pd.addAbortedTxns(sp, rp.FetchOffset, end)
 			}
 		}
 	}
@@ -178,6 +208,36 @@ full:
 	return resp, nil
 }
 
+// fetchReplica returns whether broker b can serve a fetch for the partition:
+// an error code if b cannot, or the node ID of a preferred read replica that
+// the client should fetch from instead (-1 if b should serve the fetch).
+func (c *Cluster) fetchReplica(req *kmsg.FetchRequest, t string, pd *partData, b *broker) (preferred int32, errCode int16) {
+	if req.Version < 11 {
+		if pd.leader != b {
+			return -1, kerr.NotLeaderForPartition.Code
+		}
+		return -1, 0
+	}
+	replicas := c.replicas(t, pd)
+	if pd.leader != b {
+		for _, r := range replicas {
+			if r == b {
+				return -1, 0
+			}
+		}
+		return -1, kerr.NotLeaderForPartition.Code
+	}
+	if req.Rack == "" || b.rack != nil && *b.rack == req.Rack {
+		return -1, 0
+	}
+	for _, r := range replicas {
+		if r.rack != nil && *r.rack == req.Rack {
+			return r.node, 0
+		}
+	}
+	return -1, 0
+}
+
 type watchFetch struct {
 	need     int
 	needp    tps[int]
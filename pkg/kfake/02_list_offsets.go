@@ -75,24 +75,43 @@ func (c *Cluster) handleListOffsets(b *broker, kreq kmsg.Request) (kmsg.Response
 					sp.Offset = pd.highWatermark
 				}
 			default:
+				// We find the first batch containing a record
+				// at or after the requested timestamp, and
+				// then the first such record in the batch.
 				idx, _ := sort.Find(len(pd.batches), func(idx int) int {
 					maxEarlier := pd.batches[idx].maxEarlierTimestamp
 					switch {
-					case maxEarlier < rp.Timestamp:
-						return -1
-					case maxEarlier == rp.Timestamp:
+					case rp.Timestamp > maxEarlier:
+						return 1
+					case rp.Timestamp == maxEarlier:
 						return 0
 					default:
-						return 1
+						return -1
 					}
 				})
 				if idx == len(pd.batches) {
 					sp.Offset = -1
 				} else {
-					sp.Offset = pd.batches[idx].FirstOffset
+					sp.Offset, sp.Timestamp = pd.batches[idx].firstAtOrAfter(rp.Timestamp)
 				}
 			}
 		}
 	}
 	return resp, nil
 }
+
+// firstAtOrAfter returns the offset and timestamp of the first record in the
+// batch with a timestamp at or after ts. If the batch uses log append time,
+// every record has the batch's max timestamp.
+func (b *partBatch) firstAtOrAfter(ts int64) (int64, int64) {
+	if b.Attributes&0x0008 == 0 { // create time
+		if krs, err := b.ReadRecords(decompress); err == nil {
+			for _, kr := range krs {
+				if rts := b.FirstTimestamp + kr.TimestampDelta64; rts >= ts {
+					return b.FirstOffset + int64(kr.OffsetDelta), rts
+				}
+			}
+		}
+	}
+	return b.FirstOffset, b.MaxTimestamp
+}
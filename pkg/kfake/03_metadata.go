@@ -25,6 +25,7 @@ func (c *Cluster) handleMetadata(kreq kmsg.Request) (kmsg.Response, error) {
 		sb.NodeID = b.node
 		sb.Host = h
 		sb.Port = int32(p32)
+		sb.Rack = b.rack
 		resp.Brokers = append(resp.Brokers, sb)
 	}
 
@@ -58,18 +59,12 @@ func (c *Cluster) handleMetadata(kreq kmsg.Request) (kmsg.Response, error) {
 		return &st.Partitions[len(st.Partitions)-1]
 	}
 	okp := func(t string, id uuid, p int32, pd *partData) {
-		nreplicas := c.data.treplicas[t]
-		if nreplicas > len(c.bs) {
-			nreplicas = len(c.bs)
-		}
-
 		sp := donep(t, id, p, 0)
 		sp.Leader = pd.leader.node
 		sp.LeaderEpoch = pd.epoch
 
-		for i := 0; i < nreplicas; i++ {
-			idx := (pd.leader.bsIdx + i) % len(c.bs)
-			sp.Replicas = append(sp.Replicas, c.bs[idx].node)
+		for _, b := range c.replicas(t, pd) {
+			sp.Replicas = append(sp.Replicas, b.node)
 		}
 		sp.ISR = sp.Replicas
 	}
@@ -93,6 +88,9 @@ func (c *Cluster) handleMetadata(kreq kmsg.Request) (kmsg.Response, error) {
 		}
 
 		ps, ok := c.data.tps.gett(topic)
+		if !ok {
+			ps, ok = c.data.deleting[topic]
+		}
 		if !ok {
 			if !allowAuto {
 				donet(topic, rt.TopicID, kerr.UnknownTopicOrPartition.Code)
@@ -107,11 +105,13 @@ func (c *Cluster) handleMetadata(kreq kmsg.Request) (kmsg.Response, error) {
 			okp(topic, id, p, pd)
 		}
 	}
-	if req.Topics == nil && c.data.tps != nil {
-		for topic, ps := range c.data.tps {
-			id := c.data.t2id[topic]
-			for p, pd := range ps {
-				okp(topic, id, p, pd)
+	if req.Topics == nil {
+		for _, tps := range []map[string]map[int32]*partData{c.data.tps, c.data.deleting} {
+			for topic, ps := range tps {
+				id := c.data.t2id[topic]
+				for p, pd := range ps {
+					okp(topic, id, p, pd)
+				}
 			}
 		}
 	}
@@ -1,9 +1,15 @@
 package kfake
 
 import (
+	"github.com/burningass23/franz-go/pkg/kerr"
 	"github.com/burningass23/franz-go/pkg/kmsg"
 )
 
+// Behavior:
+//
+// * With CoordinatorLoadDelay, early requests return
+//   COORDINATOR_LOAD_IN_PROGRESS for every group
+
 func init() { regKey(9, 0, 8) }
 
 func (c *Cluster) handleOffsetFetch(creq clientReq) (kmsg.Response, error) {
@@ -13,5 +19,20 @@ func (c *Cluster) handleOffsetFetch(creq clientReq) (kmsg.Response, error) {
 		return nil, err
 	}
 
+	if c.coordinatorLoading() {
+		resp := req.ResponseKind().(*kmsg.OffsetFetchResponse)
+		if req.Version <= 7 {
+			resp.ErrorCode = kerr.CoordinatorLoadInProgress.Code
+			return resp, nil
+		}
+		for _, rg := range req.Groups {
+			sg := kmsg.NewOffsetFetchResponseGroup()
+			sg.Group = rg.Group
+			sg.ErrorCode = kerr.CoordinatorLoadInProgress.Code
+			resp.Groups = append(resp.Groups, sg)
+		}
+		return resp, nil
+	}
+
 	return c.groups.handleOffsetFetch(creq), nil
 }
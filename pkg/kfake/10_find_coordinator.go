@@ -8,6 +8,15 @@ import (
 	"github.com/burningass23/franz-go/pkg/kmsg"
 )
 
+// Behavior:
+//
+// * The coordinator for a key is deterministic, see Cluster.CoordinatorFor
+// * Group and transaction coordinator requests sent to the wrong broker
+//   return NOT_COORDINATOR
+// * Unknown coordinator types return INVALID_REQUEST
+// * With CoordinatorLoadDelay, early requests return
+//   COORDINATOR_LOAD_IN_PROGRESS for every key
+
 func init() { regKey(10, 0, 4) }
 
 func (c *Cluster) handleFindCoordinator(kreq kmsg.Request) (kmsg.Response, error) {
@@ -18,6 +27,8 @@ func (c *Cluster) handleFindCoordinator(kreq kmsg.Request) (kmsg.Response, error
 		return nil, err
 	}
 
+	loading := c.coordinatorLoading()
+
 	var unknown bool
 	if req.CoordinatorType != 0 && req.CoordinatorType != 1 {
 		unknown = true
@@ -47,6 +58,10 @@ func (c *Cluster) handleFindCoordinator(kreq kmsg.Request) (kmsg.Response, error
 			sc.ErrorCode = kerr.InvalidRequest.Code
 			continue
 		}
+		if loading {
+			sc.ErrorCode = kerr.CoordinatorLoadInProgress.Code
+			continue
+		}
 
 		b := c.coordinator(key)
 		host, port, _ := net.SplitHostPort(b.ln.Addr().String())
@@ -15,7 +15,8 @@ func (c *Cluster) handleSASLHandshake(creq clientReq) (kmsg.Response, error) {
 		return nil, err
 	}
 
-	if creq.cc.saslStage != saslStageBegin {
+	// A completed session can begin reauthenticating (KIP-368).
+	if creq.cc.saslStage != saslStageBegin && creq.cc.saslStage != saslStageComplete {
 		resp.ErrorCode = kerr.IllegalSaslState.Code
 		return resp, nil
 	}
@@ -27,9 +28,15 @@ func (c *Cluster) handleSASLHandshake(creq clientReq) (kmsg.Response, error) {
 		creq.cc.saslStage = saslStageAuthScram0_256
 	case saslScram512:
 		creq.cc.saslStage = saslStageAuthScram0_512
+	case saslOAuth:
+		if c.sasls.oauth != nil {
+			creq.cc.saslStage = saslStageAuthOAuth
+			break
+		}
+		fallthrough
 	default:
 		resp.ErrorCode = kerr.UnsupportedSaslMechanism.Code
-		resp.SupportedMechanisms = []string{saslPlain, saslScram256, saslScram512}
+		resp.SupportedMechanisms = c.sasls.mechanisms()
 	}
 	return resp, nil
 }
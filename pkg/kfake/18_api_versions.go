@@ -5,6 +5,7 @@ import (
 	"sort"
 	"sync"
 
+	"github.com/burningass23/franz-go/pkg/kerr"
 	"github.com/burningass23/franz-go/pkg/kmsg"
 )
 
@@ -18,7 +19,7 @@ func (c *Cluster) handleApiVersions(kreq kmsg.Request) (kmsg.Response, error) {
 		resp.Version = 0 // downgrades to 0 if the version is unknown
 	}
 
-	if err := checkReqVersion(req.Key(), req.Version); err != nil {
+	if err := checkReqVersion(req.Key(), req.Version); err != nil && req.Version <= 3 {
 		return nil, err
 	}
 
@@ -34,9 +35,50 @@ func (c *Cluster) handleApiVersions(kreq kmsg.Request) (kmsg.Response, error) {
 	})
 	resp.ApiKeys = apiVersionsSorted
 
+	if len(c.cfg.maxVersions) > 0 {
+		resp.ApiKeys = nil
+		for _, k := range apiVersionsSorted {
+			if max, ok := c.cfg.maxVersions[k.ApiKey]; ok {
+				if max < k.MinVersion {
+					continue
+				}
+				if max < k.MaxVersion {
+					k.MaxVersion = max
+				}
+			}
+			resp.ApiKeys = append(resp.ApiKeys, k)
+		}
+	}
+
+	// Like Kafka, if the client requests a version we do not support, we
+	// reply with UNSUPPORTED_VERSION in a v0 response that contains the
+	// versions we do support.
+	if req.Version > 3 || c.aboveMaxVersion(req.Key(), req.Version) {
+		resp.Version = 0
+		resp.ErrorCode = kerr.UnsupportedVersion.Code
+	}
+
 	return resp, nil
 }
 
+// aboveMaxVersion returns whether the version is above the cap configured
+// with the MaxVersions option.
+func (c *Cluster) aboveMaxVersion(key, version int16) bool {
+	max, ok := c.cfg.maxVersions[key]
+	return ok && version > max
+}
+
+// Called before handling every request, this validates that the client is
+// not sending requests above any version cap from MaxVersions. ApiVersions
+// is checked in its handler, because that is replied to with an error code.
+func (c *Cluster) checkMaxVersion(kreq kmsg.Request) error {
+	key, version := kreq.Key(), kreq.GetVersion()
+	if key == int16(kmsg.ApiVersions) || !c.aboveMaxVersion(key, version) {
+		return nil
+	}
+	return fmt.Errorf("%s version %d above max version %d: %w", kmsg.NameForKey(key), version, c.cfg.maxVersions[key], kerr.UnsupportedVersion)
+}
+
 // Called at the beginning of every request, this validates that the client
 // is sending requests within version ranges we can handle.
 func checkReqVersion(key, version int16) error {
@@ -47,7 +47,8 @@ func (c *Cluster) handleCreateTopics(b *broker, kreq kmsg.Request) (kmsg.Respons
 	}
 
 	for _, rt := range req.Topics {
-		if _, ok := c.data.tps.gett(rt.Topic); ok {
+		_, deleting := c.data.deleting[rt.Topic]
+		if _, ok := c.data.tps.gett(rt.Topic); ok || deleting {
 			donet(rt.Topic, kerr.TopicAlreadyExists.Code)
 			continue
 		}
@@ -1,10 +1,18 @@
 package kfake
 
 import (
+	"time"
+
 	"github.com/burningass23/franz-go/pkg/kerr"
 	"github.com/burningass23/franz-go/pkg/kmsg"
 )
 
+// Behavior:
+//
+// * Topic data is removed immediately
+// * If TopicDeletionDelay is configured, the topic remains in metadata (and
+//   cannot be recreated) until the delay passes
+
 func init() { regKey(20, 0, 6) }
 
 func (c *Cluster) handleDeleteTopics(b *broker, kreq kmsg.Request) (kmsg.Response, error) {
@@ -55,10 +63,15 @@ func (c *Cluster) handleDeleteTopics(b *broker, kreq kmsg.Request) (kmsg.Respons
 	var toDeletes []toDelete
 	defer func() {
 		for _, td := range toDeletes {
+			if delay := c.cfg.topicDeletionDelay; delay > 0 {
+				c.data.deleting[td.topic] = c.data.tps[td.topic]
+				delete(c.data.tps, td.topic)
+				c.finishDeleteAfter(td.topic, td.id, delay)
+				continue
+			}
 			delete(c.data.tps, td.topic)
 			delete(c.data.id2t, td.id)
 			delete(c.data.t2id, td.topic)
-
 		}
 	}()
 	for _, rt := range req.Topics {
@@ -92,3 +105,18 @@ func (c *Cluster) handleDeleteTopics(b *broker, kreq kmsg.Request) (kmsg.Respons
 
 	return resp, nil
 }
+
+// finishDeleteAfter removes a topic that is being deleted from metadata once
+// the delay passes.
+func (c *Cluster) finishDeleteAfter(topic string, id uuid, delay time.Duration) {
+	time.AfterFunc(delay, func() {
+		select {
+		case <-c.die:
+		case c.adminCh <- func() {
+			delete(c.data.deleting, topic)
+			delete(c.data.id2t, id)
+			delete(c.data.t2id, topic)
+		}:
+		}
+	})
+}
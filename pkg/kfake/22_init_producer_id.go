@@ -7,12 +7,12 @@ import (
 
 // TODO
 //
-// * Transactional IDs
+// * Transactional ID timeouts and transaction state beyond open / aborted
 // * v3+
 
 func init() { regKey(22, 0, 4) }
 
-func (c *Cluster) handleInitProducerID(kreq kmsg.Request) (kmsg.Response, error) {
+func (c *Cluster) handleInitProducerID(b *broker, kreq kmsg.Request) (kmsg.Response, error) {
 	var (
 		req  = kreq.(*kmsg.InitProducerIDRequest)
 		resp = req.ResponseKind().(*kmsg.InitProducerIDResponse)
@@ -22,12 +22,25 @@ func (c *Cluster) handleInitProducerID(kreq kmsg.Request) (kmsg.Response, error)
 		return nil, err
 	}
 
+	if txnalID := req.TransactionalID; txnalID != nil {
+		if c.coordinator(*txnalID) != b {
+			resp.ErrorCode = kerr.NotCoordinator.Code
+			return resp, nil
+		}
+	}
+
+	pid := c.pids.create(req.TransactionalID)
+
+	// Re-initializing a transactional ID bumps the epoch, fencing the
+	// prior producer, and aborts anything it left open.
 	if req.TransactionalID != nil {
-		resp.ErrorCode = kerr.UnknownServerError.Code
-		return resp, nil
+		c.data.tps.each(func(_ string, _ int32, pd *partData) {
+			if _, open := pd.openTxns[pid.id]; open {
+				pd.writeTxnMarker(pid.id, pid.epoch, 0, false)
+			}
+		})
 	}
 
-	pid := c.pids.create(nil)
 	resp.ProducerID = pid.id
 	resp.ProducerEpoch = pid.epoch
 	return resp, nil
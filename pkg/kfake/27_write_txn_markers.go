@@ -0,0 +1,57 @@
+package kfake
+
+import (
+	"github.com/burningass23/franz-go/pkg/kerr"
+	"github.com/burningass23/franz-go/pkg/kmsg"
+)
+
+// Behavior:
+//
+// * Markers can be written for any producer, as admin tooling does when
+//   forcibly ending hanging transactions
+// * A marker for a producer with no open transaction in a partition is still
+//   written, but does not record an aborted transaction
+
+func init() { regKey(27, 0, 1) }
+
+func (c *Cluster) handleWriteTxnMarkers(b *broker, kreq kmsg.Request) (kmsg.Response, error) {
+	req := kreq.(*kmsg.WriteTxnMarkersRequest)
+	resp := req.ResponseKind().(*kmsg.WriteTxnMarkersResponse)
+
+	if err := checkReqVersion(req.Key(), req.Version); err != nil {
+		return nil, err
+	}
+
+	for _, rm := range req.Markers {
+		sm := kmsg.NewWriteTxnMarkersResponseMarker()
+		sm.ProducerID = rm.ProducerID
+
+		epoch, known := c.pids.epoch(rm.ProducerID)
+		fenced := known && rm.ProducerEpoch < epoch
+
+		for _, rt := range rm.Topics {
+			st := kmsg.NewWriteTxnMarkersResponseMarkerTopic()
+			st.Topic = rt.Topic
+			for _, p := range rt.Partitions {
+				sp := kmsg.NewWriteTxnMarkersResponseMarkerTopicPartition()
+				sp.Partition = p
+				pd, ok := c.data.tps.getp(rt.Topic, p)
+				switch {
+				case !ok:
+					sp.ErrorCode = kerr.UnknownTopicOrPartition.Code
+				case pd.leader != b:
+					sp.ErrorCode = kerr.NotLeaderForPartition.Code
+				case fenced:
+					sp.ErrorCode = kerr.InvalidProducerEpoch.Code
+				default:
+					pd.writeTxnMarker(rm.ProducerID, rm.ProducerEpoch, rm.CoordinatorEpoch, rm.Committed)
+				}
+				st.Partitions = append(st.Partitions, sp)
+			}
+			sm.Topics = append(sm.Topics, st)
+		}
+		resp.Markers = append(resp.Markers, sm)
+	}
+
+	return resp, nil
+}
@@ -2,6 +2,7 @@ package kfake
 
 import (
 	"errors"
+	"time"
 
 	"github.com/burningass23/franz-go/pkg/kerr"
 	"github.com/burningass23/franz-go/pkg/kmsg"
@@ -34,6 +35,7 @@ func (c *Cluster) handleSASLAuthenticate(creq clientReq) (kmsg.Response, error)
 			return nil, errors.New("invalid sasl")
 		}
 		creq.cc.saslStage = saslStageComplete
+		creq.cc.user = u
 
 	case saslStageAuthScram0_256:
 		c0, err := scramParseClient0(req.SASLAuthBytes)
@@ -51,6 +53,7 @@ func (c *Cluster) handleSASLAuthenticate(creq clientReq) (kmsg.Response, error)
 		resp.SASLAuthBytes = serverFirst
 		creq.cc.saslStage = saslStageAuthScram1
 		creq.cc.s0 = &s0
+		creq.cc.user = c0.user
 
 	case saslStageAuthScram0_512:
 		c0, err := scramParseClient0(req.SASLAuthBytes)
@@ -68,6 +71,7 @@ func (c *Cluster) handleSASLAuthenticate(creq clientReq) (kmsg.Response, error)
 		resp.SASLAuthBytes = serverFirst
 		creq.cc.saslStage = saslStageAuthScram1
 		creq.cc.s0 = &s0
+		creq.cc.user = c0.user
 
 	case saslStageAuthScram1:
 		serverFinal, err := creq.cc.s0.serverFinal(req.SASLAuthBytes)
@@ -77,6 +81,27 @@ func (c *Cluster) handleSASLAuthenticate(creq clientReq) (kmsg.Response, error)
 		resp.SASLAuthBytes = serverFinal
 		creq.cc.saslStage = saslStageComplete
 		creq.cc.s0 = nil
+
+	case saslStageAuthOAuth:
+		token, err := saslOAuthToken(req.SASLAuthBytes)
+		if err != nil {
+			return nil, err
+		}
+		principal, err := c.sasls.oauth(token)
+		if err != nil {
+			resp.ErrorCode = kerr.SaslAuthenticationFailed.Code
+			msg := err.Error()
+			resp.ErrorMessage = &msg
+			creq.cc.saslStage = saslStageBegin
+			return resp, nil
+		}
+		creq.cc.saslStage = saslStageComplete
+		creq.cc.user = principal
+	}
+
+	if lifetime := c.cfg.saslSessionLifetime; lifetime > 0 && req.Version >= 1 && creq.cc.saslStage == saslStageComplete {
+		resp.SessionLifetimeMillis = lifetime.Milliseconds()
+		creq.cc.saslExp = time.Now().Add(lifetime)
 	}
 
 	return resp, nil
@@ -0,0 +1,32 @@
+package kfake
+
+import (
+	"github.com/burningass23/franz-go/pkg/kmsg"
+)
+
+func init() { regKey(68, 0, 1) }
+
+func (c *Cluster) handleConsumerGroupHeartbeat(creq clientReq) (kmsg.Response, error) {
+	req := creq.kreq.(*kmsg.ConsumerGroupHeartbeatRequest)
+	resp := req.ResponseKind().(*kmsg.ConsumerGroupHeartbeatResponse)
+
+	if err := checkReqVersion(req.Key(), req.Version); err != nil {
+		return nil, err
+	}
+
+	if kerr := c.validateGroup(creq, req.Group); kerr != nil {
+		resp.ErrorCode = kerr.Code
+		return resp, nil
+	}
+
+	// Groups are managed outside of the cluster loop, so we pass a
+	// snapshot of the topics for the group to assign partitions from.
+	topics := make(map[string]consumerTopic, len(c.data.tps))
+	for t, ps := range c.data.tps {
+		topics[t] = consumerTopic{id: c.data.t2id[t], partitions: int32(len(ps))}
+	}
+	if kresp := c.groups.handleConsumerHeartbeat(creq, topics); kresp != nil {
+		return kresp, nil
+	}
+	return nil, nil // hijacked by the group
+}
@@ -19,6 +19,8 @@ type (
 
 		saslStage saslStage
 		s0        *scramServer0
+		user      string    // the authenticated SASL principal, if SASL is enabled
+		saslExp   time.Time // when the SASL session expires, if SASLSessionLifetime is used
 	}
 
 	clientReq struct {
@@ -109,6 +111,45 @@ func (cc *clientConn) read() {
 	}
 }
 
+// writeSlow writes b after the given delay, in chunks per trickle if
+// trickling is enabled.
+func (cc *clientConn) writeSlow(b []byte, delay time.Duration, trickle responseTrickle) error {
+	sleep := func(d time.Duration) bool {
+		if d <= 0 {
+			return true
+		}
+		timer := time.NewTimer(d)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+			return true
+		case <-cc.c.die:
+			return false
+		}
+	}
+	if !sleep(delay) {
+		return net.ErrClosed
+	}
+	if trickle.chunks < 2 || len(b) < trickle.chunks {
+		_, err := cc.conn.Write(b)
+		return err
+	}
+	size := len(b) / trickle.chunks
+	for i := 0; i < trickle.chunks; i++ {
+		chunk := b[i*size : (i+1)*size]
+		if i == trickle.chunks-1 {
+			chunk = b[i*size:]
+		}
+		if i > 0 && !sleep(trickle.pause) {
+			return net.ErrClosed
+		}
+		if _, err := cc.conn.Write(chunk); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (cc *clientConn) write() {
 	defer cc.conn.Close()
 
@@ -130,7 +171,10 @@ func (cc *clientConn) write() {
 	)
 	for {
 		resp, ok := oooresp[seq]
-		if !ok {
+		if ok {
+			delete(oooresp, seq)
+			seq++
+		} else {
 			select {
 			case resp = <-cc.respCh:
 				if resp.seq != seq {
@@ -147,6 +191,13 @@ func (cc *clientConn) write() {
 			return
 		}
 
+		// A request that has no response (produce with acks=0) is
+		// still sent to us so that we can advance our seq, but we
+		// write nothing back.
+		if resp.kresp == nil {
+			continue
+		}
+
 		// Size, corr, and empty tag section if flexible: 9 bytes max.
 		buf = append(buf[:0], 0, 0, 0, 0, 0, 0, 0, 0, 0)
 		buf = resp.kresp.AppendTo(buf)
@@ -160,9 +211,9 @@ func (cc *clientConn) write() {
 		binary.BigEndian.PutUint32(buf[start:], uint32(l))
 		binary.BigEndian.PutUint32(buf[start+4:], uint32(resp.corr))
 
+		delay, trickle := cc.c.slowResponse(resp.kresp.Key())
 		go func() {
-			_, err := cc.conn.Write(buf[start:])
-			writeCh <- err
+			writeCh <- cc.writeSlow(buf[start:], delay, trickle)
 		}()
 
 		var err error
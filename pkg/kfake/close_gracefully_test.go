@@ -0,0 +1,167 @@
+package kfake
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/burningass23/franz-go/pkg/kgo"
+	"github.com/burningass23/franz-go/pkg/kmsg"
+)
+
+// If the context expires while records are buffered, CloseGracefully returns
+// the records it failed, and produces are rejected once closing begins.
+func TestCloseGracefullyUnflushed(t *testing.T) {
+	const topic = "close-gracefully"
+	c, err := NewCluster(
+		NumBrokers(1),
+		AllowAutoTopicCreation(),
+		DefaultNumPartitions(1),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	cl, err := kgo.NewClient(
+		kgo.SeedBrokers(c.ListenAddrs()...),
+		kgo.DefaultProduceTopic(topic),
+		kgo.AllowAutoTopicCreation(),
+		kgo.ManualFlushing(),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cl.Close()
+
+	// The produce request issued by flushing never gets a response.
+	flushing := make(chan struct{})
+	c.ControlKey(int16(kmsg.Produce), func(kmsg.Request) (kmsg.Response, error, bool) {
+		close(flushing)
+		return nil, nil, true
+	})
+
+	promised := make(chan error, 3)
+	promise := func(_ *kgo.Record, err error) { promised <- err }
+	for i := 0; i < 2; i++ {
+		cl.Produce(context.Background(), &kgo.Record{Value: []byte("v")}, promise)
+	}
+
+	type closed struct {
+		unflushed []*kgo.Record
+		err       error
+	}
+	done := make(chan closed, 1)
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+		defer cancel()
+		unflushed, err := cl.CloseGracefully(ctx)
+		done <- closed{unflushed, err}
+	}()
+
+	select {
+	case <-flushing:
+	case <-time.After(10 * time.Second):
+		t.Fatal("timed out waiting for CloseGracefully to flush")
+	}
+	cl.Produce(context.Background(), &kgo.Record{Value: []byte("late")}, func(_ *kgo.Record, err error) {
+		if !errors.Is(err, kgo.ErrClientClosed) {
+			t.Errorf("producing while closing: got err %v, exp ErrClientClosed", err)
+		}
+		promised <- nil
+	})
+
+	var got closed
+	select {
+	case got = <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatal("timed out waiting for CloseGracefully to return")
+	}
+	if !errors.Is(got.err, context.DeadlineExceeded) {
+		t.Errorf("got err %v, exp context.DeadlineExceeded", got.err)
+	}
+	if len(got.unflushed) != 2 {
+		t.Errorf("got %d unflushed records, exp 2", len(got.unflushed))
+	}
+	for i := 0; i < 3; i++ {
+		select {
+		case <-promised:
+		default:
+			t.Errorf("got %d promises called before CloseGracefully returned, exp 3", i)
+			return
+		}
+	}
+}
+
+// A group consumer commits what it consumed before closing.
+func TestCloseGracefullyCommits(t *testing.T) {
+	const topic, group = "close-gracefully-commit", "close-gracefully-group"
+	c, err := NewCluster(
+		NumBrokers(1),
+		AllowAutoTopicCreation(),
+		DefaultNumPartitions(1),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	producer, err := kgo.NewClient(
+		kgo.SeedBrokers(c.ListenAddrs()...),
+		kgo.DefaultProduceTopic(topic),
+		kgo.AllowAutoTopicCreation(),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer producer.Close()
+	for i := 0; i < 3; i++ {
+		if err := producer.ProduceSync(ctx, &kgo.Record{Value: []byte("v")}).FirstErr(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The autocommit interval is long enough that only closing commits.
+	consumer, err := kgo.NewClient(
+		kgo.SeedBrokers(c.ListenAddrs()...),
+		kgo.ConsumeTopics(topic),
+		kgo.ConsumerGroup(group),
+		kgo.AutoCommitInterval(time.Hour),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var n int
+	for n < 3 {
+		fs := consumer.PollFetches(ctx)
+		if err := fs.Err0(); err != nil {
+			t.Fatal(err)
+		}
+		n += fs.NumRecords()
+	}
+	if unflushed, err := consumer.CloseGracefully(ctx); err != nil || len(unflushed) != 0 {
+		t.Fatalf("got %d unflushed records and err %v, exp none", len(unflushed), err)
+	}
+
+	req := kmsg.NewPtrOffsetFetchRequest()
+	req.Group = group
+	resp, err := req.RequestWith(ctx, producer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var committed int64 = -1
+	for _, rt := range resp.Topics {
+		for _, rp := range rt.Partitions {
+			if rt.Topic == topic && rp.Partition == 0 {
+				committed = rp.Offset
+			}
+		}
+	}
+	if committed != 3 {
+		t.Errorf("got committed offset %d, exp 3", committed)
+	}
+}
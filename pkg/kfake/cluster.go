@@ -1,6 +1,7 @@
 package kfake
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"math/rand"
@@ -36,11 +37,17 @@ type (
 		control            map[int16][]controlFn
 		keepCurrentControl atomic.Bool
 
+		slowMu   sync.Mutex
+		delays   map[int16]responseDelay
+		trickles map[int16]responseTrickle
+
 		data   data
 		pids   pids
 		groups groups
 		sasls  sasls
 
+		coordLoadsLeft int // remaining requests to fail per CoordinatorLoadDelay
+
 		die  chan struct{}
 		dead atomic.Bool
 	}
@@ -50,9 +57,20 @@ type (
 		ln    net.Listener
 		node  int32
 		bsIdx int
+		rack  *string
 	}
 
 	controlFn func(kmsg.Request) (kmsg.Response, error, bool)
+
+	responseDelay struct {
+		delay  time.Duration
+		jitter time.Duration
+	}
+
+	responseTrickle struct {
+		chunks int
+		pause  time.Duration
+	}
 )
 
 // MustCluster is like NewCluster, but panics on error.
@@ -70,10 +88,13 @@ func NewCluster(opts ...Opt) (c *Cluster, err error) {
 		nbrokers:        3,
 		logger:          new(nopLogger),
 		clusterID:       "kfake",
+		controllerNode:  -1,
 		defaultNumParts: 10,
 
 		minSessionTimeout: 6 * time.Second,
 		maxSessionTimeout: 5 * time.Minute,
+
+		consumerHeartbeatInterval: 5 * time.Second,
 	}
 	for _, opt := range opts {
 		opt.apply(&cfg)
@@ -81,6 +102,9 @@ func NewCluster(opts ...Opt) (c *Cluster, err error) {
 	if len(cfg.ports) > 0 {
 		cfg.nbrokers = len(cfg.ports)
 	}
+	if cfg.controllerNode >= int32(cfg.nbrokers) {
+		return nil, fmt.Errorf("controller node %d not found", cfg.controllerNode)
+	}
 
 	c = &Cluster{
 		cfg: cfg,
@@ -94,8 +118,11 @@ func NewCluster(opts ...Opt) (c *Cluster, err error) {
 			id2t:      make(map[uuid]string),
 			t2id:      make(map[string]uuid),
 			treplicas: make(map[string]int),
+			deleting:  make(map[string]map[int32]*partData),
 		},
 
+		coordLoadsLeft: cfg.coordinatorLoadDelay,
+
 		die: make(chan struct{}),
 	}
 	c.data.c = c
@@ -128,6 +155,7 @@ func NewCluster(opts ...Opt) (c *Cluster, err error) {
 		}
 	}
 	cfg.sasls = nil
+	c.sasls.oauth = cfg.oauth
 
 	if cfg.enableSASL && c.sasls.empty() {
 		c.sasls.scram256 = map[string]scramAuth{
@@ -151,10 +179,16 @@ func NewCluster(opts ...Opt) (c *Cluster, err error) {
 			node:  int32(i),
 			bsIdx: len(c.bs),
 		}
+		if rack, ok := cfg.brokerRacks[b.node]; ok {
+			b.rack = &rack
+		}
 		c.bs = append(c.bs, b)
 		go b.listen()
 	}
 	c.controller = c.bs[len(c.bs)-1]
+	if cfg.controllerNode >= 0 {
+		c.controller = c.bs[cfg.controllerNode]
+	}
 	go c.run()
 	return c, nil
 }
@@ -170,6 +204,63 @@ func (c *Cluster) ListenAddrs() []string {
 	return addrs
 }
 
+// DialFn returns a function that connects to the cluster's brokers over
+// in-memory pipes rather than TCP. The returned function has the same
+// signature as net.Dialer's DialContext and can be used with kgo's Dialer
+// option:
+//
+//	cl, err := kgo.NewClient(
+//		kgo.SeedBrokers(c.ListenAddrs()...),
+//		kgo.Dialer(c.DialFn()),
+//	)
+//
+// The host must be one of the cluster's ListenAddrs, which are also the
+// addresses advertised in metadata. Brokers serve the protocol over the pipe
+// exactly as they do over TCP; no client sockets are opened, which avoids
+// ephemeral port exhaustion and OS networking flakiness in large parallel test
+// suites.
+func (c *Cluster) DialFn() func(ctx context.Context, network, host string) (net.Conn, error) {
+	return func(ctx context.Context, _, host string) (net.Conn, error) {
+		var b *broker
+		found := make(chan struct{})
+		fn := func() {
+			defer close(found)
+			for _, cb := range c.bs {
+				if cb.ln.Addr().String() == host {
+					b = cb
+					return
+				}
+			}
+		}
+		select {
+		case c.adminCh <- fn:
+		case <-c.die:
+			return nil, errors.New("cluster is closed")
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		<-found
+		if b == nil {
+			return nil, fmt.Errorf("no broker is listening on %s", host)
+		}
+		client, server := net.Pipe()
+		b.serve(server)
+		return client, nil
+	}
+}
+
+// CoordinatorFor returns the node ID of the broker that is the coordinator
+// for the given key. Group and transaction coordinators are chosen the same
+// way: the key is hashed to pick one of the cluster's brokers. The choice is
+// stable until nodes are added or removed.
+func (c *Cluster) CoordinatorFor(key string) int32 {
+	var n int32
+	c.admin(func() {
+		n = c.coordinator(key).node
+	})
+	return n
+}
+
 // Close shuts down the cluster.
 func (c *Cluster) Close() {
 	if c.dead.Swap(true) {
@@ -192,16 +283,20 @@ func (b *broker) listen() {
 		if err != nil {
 			return
 		}
+		b.serve(conn)
+	}
+}
 
-		cc := &clientConn{
-			c:      b.c,
-			b:      b,
-			conn:   conn,
-			respCh: make(chan clientResp, 2),
-		}
-		go cc.read()
-		go cc.write()
+// serve begins reading requests from and writing responses to conn.
+func (b *broker) serve(conn net.Conn) {
+	cc := &clientConn{
+		c:      b.c,
+		b:      b,
+		conn:   conn,
+		respCh: make(chan clientResp, 2),
 	}
+	go cc.read()
+	go cc.write()
 }
 
 func (c *Cluster) run() {
@@ -238,6 +333,10 @@ func (c *Cluster) run() {
 			}
 		}
 
+		if err = c.checkMaxVersion(kreq); err != nil {
+			goto afterControl
+		}
+
 		switch k := kmsg.Key(kreq.Key()); k {
 		case kmsg.Produce:
 			kresp, err = c.handleProduce(creq.cc.b, kreq)
@@ -274,7 +373,7 @@ func (c *Cluster) run() {
 		case kmsg.DeleteTopics:
 			kresp, err = c.handleDeleteTopics(creq.cc.b, kreq)
 		case kmsg.InitProducerID:
-			kresp, err = c.handleInitProducerID(kreq)
+			kresp, err = c.handleInitProducerID(creq.cc.b, kreq)
 		case kmsg.OffsetForLeaderEpoch:
 			kresp, err = c.handleOffsetForLeaderEpoch(creq.cc.b, kreq)
 		case kmsg.SASLAuthenticate:
@@ -287,12 +386,27 @@ func (c *Cluster) run() {
 			kresp, err = c.handleDescribeUserSCRAMCredentials(kreq)
 		case kmsg.AlterUserSCRAMCredentials:
 			kresp, err = c.handleAlterUserSCRAMCredentials(creq.cc.b, kreq)
+		case kmsg.WriteTxnMarkers:
+			kresp, err = c.handleWriteTxnMarkers(creq.cc.b, kreq)
+		case kmsg.ConsumerGroupHeartbeat:
+			kresp, err = c.handleConsumerGroupHeartbeat(creq)
 		default:
 			err = fmt.Errorf("unahndled key %v", k)
 		}
 
 	afterControl:
 		if kresp == nil && err == nil { // produce request with no acks, or hijacked group request
+			// Produce requests with no acks have no response, but
+			// we still need the connection's write loop to advance
+			// past this request's seq so that responses to later
+			// requests are not held forever waiting for this one.
+			if req, ok := kreq.(*kmsg.ProduceRequest); ok && req.Acks == 0 {
+				select {
+				case creq.cc.respCh <- clientResp{corr: creq.corr, seq: creq.seq}:
+				case <-c.die:
+					return
+				}
+			}
 			continue
 		}
 
@@ -304,6 +418,17 @@ func (c *Cluster) run() {
 	}
 }
 
+// coordinatorLoading returns whether a coordinator request should fail with
+// COORDINATOR_LOAD_IN_PROGRESS per CoordinatorLoadDelay. This is only called
+// from the cluster's run loop.
+func (c *Cluster) coordinatorLoading() bool {
+	if c.coordLoadsLeft <= 0 {
+		return false
+	}
+	c.coordLoadsLeft--
+	return true
+}
+
 // Control is a function to call on any client request the cluster handles.
 //
 // If the control function returns true, then either the response is written
@@ -353,6 +478,69 @@ func (c *Cluster) KeepControl() {
 	c.keepCurrentControl.Swap(true)
 }
 
+// DelayResponses delays writing every response to requests of the given key
+// by delay plus a random duration in [0, jitter). A key of -1 delays responses
+// to all requests that do not have a key specific delay. Calling this with
+// zero delay and jitter removes the delay for the key.
+//
+// Unlike sleeping in a control function, which blocks the entire cluster,
+// the delay is per connection: only the connection the response is written
+// to is blocked, and later responses on that connection are written after
+// the delayed response. This can be used to test client read timeouts and
+// retries.
+func (c *Cluster) DelayResponses(key int16, delay, jitter time.Duration) {
+	c.slowMu.Lock()
+	defer c.slowMu.Unlock()
+	if delay <= 0 && jitter <= 0 {
+		delete(c.delays, key)
+		return
+	}
+	if c.delays == nil {
+		c.delays = make(map[int16]responseDelay)
+	}
+	c.delays[key] = responseDelay{delay, jitter}
+}
+
+// TrickleResponses writes every response to requests of the given key in the
+// given number of chunks, pausing between each chunk. A key of -1 trickles
+// responses to all requests that do not have a key specific setting. Calling
+// this with chunks less than 2 removes trickling for the key.
+//
+// This can be used to test client read timeouts for responses that start
+// arriving but are slow to finish.
+func (c *Cluster) TrickleResponses(key int16, chunks int, pause time.Duration) {
+	c.slowMu.Lock()
+	defer c.slowMu.Unlock()
+	if chunks < 2 {
+		delete(c.trickles, key)
+		return
+	}
+	if c.trickles == nil {
+		c.trickles = make(map[int16]responseTrickle)
+	}
+	c.trickles[key] = responseTrickle{chunks, pause}
+}
+
+// slowResponse returns how long to delay and how to trickle a response for
+// the given request key.
+func (c *Cluster) slowResponse(key int16) (time.Duration, responseTrickle) {
+	c.slowMu.Lock()
+	defer c.slowMu.Unlock()
+	d, ok := c.delays[key]
+	if !ok {
+		d = c.delays[-1]
+	}
+	t, ok := c.trickles[key]
+	if !ok {
+		t = c.trickles[-1]
+	}
+	delay := d.delay
+	if d.jitter > 0 {
+		delay += time.Duration(rand.Int63n(int64(d.jitter)))
+	}
+	return delay, t
+}
+
 func (c *Cluster) tryControl(kreq kmsg.Request) (kresp kmsg.Response, err error, handled bool) {
 	c.controlMu.Lock()
 	defer c.controlMu.Unlock()
@@ -496,6 +684,9 @@ func (c *Cluster) RemoveNode(nodeID int32) error {
 				c.bs[i] = c.bs[len(c.bs)-1]
 				c.bs[i].bsIdx = i
 				c.bs = c.bs[:len(c.bs)-1]
+				if c.controller == b {
+					c.controller = c.bs[len(c.bs)-1]
+				}
 				c.shufflePartitionsLocked()
 				return
 			}
@@ -505,6 +696,24 @@ func (c *Cluster) RemoveNode(nodeID int32) error {
 	return err
 }
 
+// MoveController simulates a controller election, making the given node the
+// controller. Clients must rediscover the controller from metadata after
+// receiving NOT_CONTROLLER from the prior controller. This returns an error
+// if the node does not exist.
+func (c *Cluster) MoveController(nodeID int32) error {
+	var err error
+	c.admin(func() {
+		for _, b := range c.bs {
+			if b.node == nodeID {
+				c.controller = b
+				return
+			}
+		}
+		err = fmt.Errorf("node %d not found", nodeID)
+	})
+	return err
+}
+
 // ShufflePartitionLeaders simulates a leader election for all partitions: all
 // partitions have a randomly selected new leader and their internal epochs are
 // bumped.
@@ -16,14 +16,29 @@ type cfg struct {
 	ports           []int
 	logger          Logger
 	clusterID       string
+	controllerNode  int32
 	allowAutoTopic  bool
 	defaultNumParts int
+	brokerRacks     map[int32]string
 
 	minSessionTimeout time.Duration
 	maxSessionTimeout time.Duration
 
+	consumerHeartbeatInterval time.Duration
+
+	coordinatorLoadDelay int
+
+	fetchMaxRecords int
+
+	topicDeletionDelay time.Duration
+
+	maxVersions map[int16]int16
+
 	enableSASL bool
 	sasls      map[struct{ m, u string }]string // cleared after client initialization
+	oauth      func(token string) (principal string, err error)
+
+	saslSessionLifetime time.Duration
 }
 
 // NumBrokers sets the number of brokers to start in the fake cluster.
@@ -47,6 +62,16 @@ func ClusterID(clusterID string) Opt {
 	return opt{func(cfg *cfg) { cfg.clusterID = clusterID }}
 }
 
+// Controller sets the node ID of the broker that is the controller,
+// overriding the default of the broker with the highest node ID. Requests
+// that must be sent to the controller (CreateTopics, DeleteTopics,
+// CreatePartitions, AlterUserScramCredentials) fail with NOT_CONTROLLER if
+// they are sent to any other broker. The controller can be changed while the
+// cluster is running with MoveController.
+func Controller(nodeID int32) Opt {
+	return opt{func(cfg *cfg) { cfg.controllerNode = nodeID }}
+}
+
 // AllowAutoTopicCreation allows metadata requests to create topics if the
 // metadata request has its AllowAutoTopicCreation field set to true.
 func AllowAutoTopicCreation() Opt {
@@ -59,6 +84,26 @@ func DefaultNumPartitions(n int) Opt {
 	return opt{func(cfg *cfg) { cfg.defaultNumParts = n }}
 }
 
+// BrokerRacks sets the rack for brokers in the cluster, keyed by node ID.
+// Brokers advertise their rack in metadata responses, and brokers without a
+// rack advertise none.
+//
+// Racks enable follower fetching (KIP-392): if a fetch request to a
+// partition's leader carries a rack ID that does not match the leader's rack,
+// and a replica of the partition is in the requested rack, the leader replies
+// with that replica as the preferred read replica. Fetch requests to any
+// replica of a partition, not just the leader, are served.
+func BrokerRacks(racks map[int32]string) Opt {
+	return opt{func(cfg *cfg) {
+		if cfg.brokerRacks == nil {
+			cfg.brokerRacks = make(map[int32]string)
+		}
+		for node, rack := range racks {
+			cfg.brokerRacks[node] = rack
+		}
+	}}
+}
+
 // GroupMinSessionTimeout sets the cluster's minimum session timeout allowed
 // for groups, overriding the default 6 seconds.
 func GroupMinSessionTimeout(d time.Duration) Opt {
@@ -71,6 +116,67 @@ func GroupMaxSessionTimeout(d time.Duration) Opt {
 	return opt{func(cfg *cfg) { cfg.maxSessionTimeout = d }}
 }
 
+// ConsumerGroupHeartbeatInterval sets the heartbeat interval the cluster
+// returns to members of next generation consumer groups (KIP-848), overriding
+// the default 5 seconds. Members learn of partitions they must revoke, and of
+// partitions that other members have released, when they heartbeat, so a
+// short interval speeds up rebalancing in tests.
+func ConsumerGroupHeartbeatInterval(d time.Duration) Opt {
+	return opt{func(cfg *cfg) { cfg.consumerHeartbeatInterval = d }}
+}
+
+// CoordinatorLoadDelay emulates coordinators that are still loading after the
+// cluster starts: the first n FindCoordinator and OffsetFetch requests the
+// cluster receives (counted together) fail with COORDINATOR_LOAD_IN_PROGRESS.
+// Every request after the first n is handled as normal. This can be used to
+// test that clients retry the transient error.
+func CoordinatorLoadDelay(n int) Opt {
+	return opt{func(cfg *cfg) { cfg.coordinatorLoadDelay = n }}
+}
+
+// FetchMaxRecords caps the number of records the cluster returns in a single
+// fetch response, across all partitions, regardless of the request's
+// MaxBytes. Batches are never split: the cluster stops adding batches to the
+// response once the cap is reached, but always returns at least one batch,
+// which may contain more records than the cap. Producing one record per batch
+// makes the cap exact. This can be used to deterministically test consuming
+// over many fetches.
+func FetchMaxRecords(n int) Opt {
+	return opt{func(cfg *cfg) { cfg.fetchMaxRecords = n }}
+}
+
+// TopicDeletionDelay emulates the delay between a topic being deleted and the
+// deletion being fully propagated. Deleting a topic removes its data
+// immediately, and producing to or fetching from the topic fails with
+// UNKNOWN_TOPIC_OR_PARTITION, but the topic remains in metadata responses
+// until the delay passes. While the topic is being deleted, it cannot be
+// recreated. This can be used to test how clients react to the transitional
+// state of a topic deletion.
+func TopicDeletionDelay(d time.Duration) Opt {
+	return opt{func(cfg *cfg) { cfg.topicDeletionDelay = d }}
+}
+
+// MaxVersions caps the max version the cluster advertises in ApiVersions for
+// the given request keys, allowing you to emulate older brokers. For example,
+// capping Produce (key 0) at 3 forces clients to use produce v3. If a cap is
+// below the minimum version the cluster supports for a key, the key is not
+// advertised at all.
+//
+// Requests above the cap are rejected the same way Kafka rejects requests
+// with an unsupported version: ApiVersions requests receive an
+// UNSUPPORTED_VERSION error response, and all other requests have their
+// connection closed.
+func MaxVersions(vs map[int16]int16) Opt {
+	return opt{func(cfg *cfg) {
+		if cfg.maxVersions == nil {
+			cfg.maxVersions = make(map[int16]int16)
+		}
+		for k, v := range vs {
+			cfg.maxVersions[k] = v
+		}
+	}}
+}
+
 // EnableSASL enables SASL authentication for the cluster. If you do not
 // configure a bootstrap user / pass, the default superuser is "admin" /
 // "admin" with the SCRAM-SHA-256 SASL mechanisms.
@@ -78,11 +184,46 @@ func EnableSASL() Opt {
 	return opt{func(cfg *cfg) { cfg.enableSASL = true }}
 }
 
+// SASLOAuthBearer enables SASL authentication for the cluster with the
+// OAUTHBEARER mechanism, using validate to validate tokens. The validate
+// function is called with the token from every OAUTHBEARER
+// SASLAuthenticate request and must return the principal the token
+// authenticates as, or an error if the token is invalid. Invalid tokens fail
+// authentication with SASL_AUTHENTICATION_FAILED and the error's message.
+//
+// This option can be used alongside Superuser to support multiple SASL
+// mechanisms at once.
+func SASLOAuthBearer(validate func(token string) (principal string, err error)) Opt {
+	return opt{func(cfg *cfg) {
+		cfg.enableSASL = true
+		cfg.oauth = validate
+	}}
+}
+
+// SASLSessionLifetime sets the lifetime of authenticated SASL sessions
+// (KIP-368), emulating Kafka's connections.max.reauth.ms. This option only
+// has an effect if SASL is enabled.
+//
+// SASLAuthenticate v1+ responses that complete authentication return the
+// lifetime, and connections must reauthenticate (SASLHandshake followed by
+// SASLAuthenticate) before the lifetime expires. Once a session expires, any
+// request other than a reauthentication closes the connection. Clients that
+// authenticate with SASLAuthenticate v0 cannot reauthenticate and do not
+// have their sessions expire, which matches Kafka.
+func SASLSessionLifetime(d time.Duration) Opt {
+	return opt{func(cfg *cfg) { cfg.saslSessionLifetime = d }}
+}
+
 // Superuser seeds the cluster with a superuser. The method must be either
 // PLAIN, SCRAM-SHA-256, or SCRAM-SHA-512.
 // Note that PLAIN superusers cannot be deleted.
 // SCRAM superusers can be modified with AlterUserScramCredentials.
 // If you delete all SASL users, the kfake cluster will be unusable.
 func Superuser(method, user, pass string) Opt {
-	return opt{func(cfg *cfg) { cfg.sasls[struct{ m, u string }{method, user}] = pass }}
+	return opt{func(cfg *cfg) {
+		if cfg.sasls == nil {
+			cfg.sasls = make(map[struct{ m, u string }]string)
+		}
+		cfg.sasls[struct{ m, u string }{method, user}] = pass
+	}}
 }
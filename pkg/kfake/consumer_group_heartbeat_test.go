@@ -0,0 +1,194 @@
+package kfake
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/burningass23/franz-go/pkg/kerr"
+	"github.com/burningass23/franz-go/pkg/kgo"
+	"github.com/burningass23/franz-go/pkg/kmsg"
+)
+
+// nextGenMember tracks the partitions a next generation group member owns.
+type nextGenMember struct {
+	cl *kgo.Client
+
+	mu    sync.Mutex
+	owned map[int32]bool
+	lost  int
+}
+
+func (m *nextGenMember) numOwned() (int, int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.owned), m.lost
+}
+
+func newNextGenMember(t *testing.T, c *Cluster, topic, group string) *nextGenMember {
+	t.Helper()
+	m := &nextGenMember{owned: make(map[int32]bool)}
+	update := func(ps map[string][]int32, owned bool) {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		for _, p := range ps[topic] {
+			if owned {
+				m.owned[p] = true
+			} else {
+				delete(m.owned, p)
+			}
+		}
+	}
+	cl, err := kgo.NewClient(
+		kgo.SeedBrokers(c.ListenAddrs()...),
+		kgo.ConsumeTopics(topic),
+		kgo.ConsumerGroup(group),
+		kgo.EnableNextGenGroupProtocol(),
+		kgo.OnPartitionsAssigned(func(_ context.Context, _ *kgo.Client, ps map[string][]int32) {
+			update(ps, true)
+		}),
+		kgo.OnPartitionsRevoked(func(_ context.Context, _ *kgo.Client, ps map[string][]int32) {
+			update(ps, false)
+		}),
+		kgo.OnPartitionsLost(func(_ context.Context, _ *kgo.Client, ps map[string][]int32) {
+			update(ps, false)
+			m.mu.Lock()
+			m.lost++
+			m.mu.Unlock()
+		}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.cl = cl
+	return m
+}
+
+func newNextGenCluster(t *testing.T, topic string) *Cluster {
+	t.Helper()
+	c, err := NewCluster(
+		NumBrokers(1),
+		AllowAutoTopicCreation(),
+		DefaultNumPartitions(4),
+		ConsumerGroupHeartbeatInterval(50*time.Millisecond),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(c.Close)
+
+	cl, err := kgo.NewClient(
+		kgo.SeedBrokers(c.ListenAddrs()...),
+		kgo.DefaultProduceTopic(topic),
+		kgo.AllowAutoTopicCreation(),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cl.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := cl.ProduceSync(ctx, &kgo.Record{Value: []byte("v")}).FirstErr(); err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func waitFor(t *testing.T, what string, fn func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(10 * time.Second)
+	for !fn() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestConsumerGroupHeartbeat(t *testing.T) {
+	const topic, group = "cgh", "cgh-group"
+	c := newNextGenCluster(t, topic)
+
+	a := newNextGenMember(t, c, topic, group)
+	defer a.cl.Close()
+	waitFor(t, "a to own every partition", func() bool {
+		n, _ := a.numOwned()
+		return n == 4
+	})
+
+	// Commits use the member epoch as the generation.
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	fs := a.cl.PollFetches(ctx)
+	if err := fs.Err0(); err != nil {
+		t.Fatal(err)
+	}
+	if fs.NumRecords() != 1 {
+		t.Fatalf("got %d records, exp 1", fs.NumRecords())
+	}
+	if err := a.cl.CommitUncommittedOffsets(ctx); err != nil {
+		t.Fatalf("unable to commit: %v", err)
+	}
+	freq := kmsg.NewPtrOffsetFetchRequest()
+	freq.Group = group
+	fresp, err := freq.RequestWith(ctx, a.cl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var committed bool
+	for _, ft := range fresp.Topics {
+		for _, fp := range ft.Partitions {
+			committed = committed || ft.Topic == topic && fp.Offset == 1
+		}
+	}
+	if !committed {
+		t.Errorf("offset 1 was not committed, got %v", fresp.Topics)
+	}
+
+	// A second member splits the partitions once a revokes its half.
+	b := newNextGenMember(t, c, topic, group)
+	waitFor(t, "a and b to split the partitions", func() bool {
+		na, _ := a.numOwned()
+		nb, _ := b.numOwned()
+		return na == 2 && nb == 2
+	})
+
+	// Once b leaves, a owns everything again.
+	b.cl.Close()
+	waitFor(t, "a to own every partition after b leaves", func() bool {
+		n, _ := a.numOwned()
+		return n == 4
+	})
+	if _, lost := a.numOwned(); lost != 0 {
+		t.Errorf("a unexpectedly lost partitions %d times", lost)
+	}
+}
+
+func TestConsumerGroupHeartbeatFenced(t *testing.T) {
+	const topic, group = "cgh-fenced", "cgh-fenced-group"
+	c := newNextGenCluster(t, topic)
+
+	a := newNextGenMember(t, c, topic, group)
+	defer a.cl.Close()
+	waitFor(t, "a to own every partition", func() bool {
+		n, _ := a.numOwned()
+		return n == 4
+	})
+
+	c.ControlKey(int16(kmsg.ConsumerGroupHeartbeat), func(kreq kmsg.Request) (kmsg.Response, error, bool) {
+		req := kreq.(*kmsg.ConsumerGroupHeartbeatRequest)
+		if req.MemberEpoch <= 0 {
+			return nil, nil, false
+		}
+		resp := req.ResponseKind().(*kmsg.ConsumerGroupHeartbeatResponse)
+		resp.ErrorCode = kerr.FencedMemberEpoch.Code
+		return resp, nil, true
+	})
+
+	// Being fenced loses everything, and we rejoin to get it all back.
+	waitFor(t, "a to lose and regain every partition", func() bool {
+		n, lost := a.numOwned()
+		return lost == 1 && n == 4
+	})
+}
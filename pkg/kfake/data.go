@@ -2,6 +2,7 @@ package kfake
 
 import (
 	"crypto/sha256"
+	"encoding/binary"
 	"math/rand"
 	"sort"
 	"strconv"
@@ -13,7 +14,6 @@ import (
 // TODO
 //
 // * Write to disk, if configured.
-// * When transactional, wait to send out data until txn committed or aborted.
 
 var noID uuid
 
@@ -27,6 +27,10 @@ type (
 		id2t      map[uuid]string // topic IDs => topic name
 		t2id      map[string]uuid // topic name => topic IDs
 		treplicas map[string]int  // topic name => # replicas
+
+		// deleting contains topics that have been deleted but remain
+		// in metadata until the TopicDeletionDelay passes.
+		deleting map[string]map[int32]*partData
 	}
 
 	partData struct {
@@ -38,7 +42,12 @@ type (
 		epoch            int32 // current epoch
 		maxTimestamp     int64 // current max timestamp in all batches
 
-		// abortedTxns
+		// openTxns tracks the first offset of each producer's open
+		// transaction, which bounds the last stable offset. Aborted
+		// transactions are kept for READ_COMMITTED fetches.
+		openTxns    map[int64]int64 // producer ID => first offset
+		abortedTxns []abortedTxn
+
 		rf     int8
 		leader *broker
 
@@ -55,14 +64,22 @@ type (
 		// For list offsets, we may need to return the first offset
 		// after a given requested timestamp. Client provided
 		// timestamps gan go forwards and backwards. We answer list
-		// offsets with a binary search: even if this batch has a small
-		// timestamp, this is produced _after_ a potentially higher
-		// timestamp, so it is after it in the list offset response.
+		// offsets with a binary search over the max timestamp of this
+		// batch and all earlier batches: even if this batch has a
+		// small timestamp, this is produced _after_ a potentially
+		// higher timestamp, so it is after it in the list offset
+		// response.
 		//
 		// When we drop the earlier timestamp, we update all following
 		// firstMaxTimestamps that match the dropped timestamp.
 		maxEarlierTimestamp int64
 	}
+
+	abortedTxn struct {
+		producerID  int64
+		firstOffset int64
+		lastOffset  int64 // offset of the abort marker
+	}
 )
 
 func (d *data) mkt(t string, nparts int, nreplicas int) {
@@ -101,6 +118,19 @@ func (c *Cluster) noLeader() *broker {
 	}
 }
 
+// replicas returns the brokers replicating a partition, leader first.
+func (c *Cluster) replicas(t string, pd *partData) []*broker {
+	nreplicas := c.data.treplicas[t]
+	if nreplicas > len(c.bs) {
+		nreplicas = len(c.bs)
+	}
+	replicas := make([]*broker, 0, nreplicas)
+	for i := 0; i < nreplicas; i++ {
+		replicas = append(replicas, c.bs[(pd.leader.bsIdx+i)%len(c.bs)])
+	}
+	return replicas
+}
+
 func (c *Cluster) newPartData() *partData {
 	return &partData{
 		leader:    c.bs[rand.Intn(len(c.bs))],
@@ -110,7 +140,7 @@ func (c *Cluster) newPartData() *partData {
 }
 
 func (pd *partData) pushBatch(nbytes int, b kmsg.RecordBatch) {
-	maxEarlierTimestamp := b.FirstTimestamp
+	maxEarlierTimestamp := b.MaxTimestamp
 	if maxEarlierTimestamp < pd.maxTimestamp {
 		maxEarlierTimestamp = pd.maxTimestamp
 	} else {
@@ -119,13 +149,75 @@ func (pd *partData) pushBatch(nbytes int, b kmsg.RecordBatch) {
 	b.FirstOffset = pd.highWatermark
 	b.PartitionLeaderEpoch = pd.epoch
 	pd.batches = append(pd.batches, partBatch{b, nbytes, pd.epoch, maxEarlierTimestamp})
+	if b.Attributes&0x0030 == 0x0010 { // transactional, not control
+		if _, open := pd.openTxns[b.ProducerID]; !open {
+			if pd.openTxns == nil {
+				pd.openTxns = make(map[int64]int64)
+			}
+			pd.openTxns[b.ProducerID] = b.FirstOffset
+		}
+	}
 	pd.highWatermark += int64(b.NumRecords)
-	pd.lastStableOffset += int64(b.NumRecords) // TODO
+	pd.recalculateLSO()
 	for w := range pd.watch {
 		w.push(nbytes)
 	}
 }
 
+// recalculateLSO sets the last stable offset to the first offset of the
+// earliest open transaction, or to the high watermark if no transactions are
+// open.
+func (pd *partData) recalculateLSO() {
+	pd.lastStableOffset = pd.highWatermark
+	for _, first := range pd.openTxns {
+		if first < pd.lastStableOffset {
+			pd.lastStableOffset = first
+		}
+	}
+}
+
+// writeTxnMarker appends a commit or abort control batch for a producer,
+// ending the producer's open transaction in this partition if there is one.
+func (pd *partData) writeTxnMarker(producerID int64, producerEpoch int16, coordinatorEpoch int32, commit bool) {
+	var typ uint16 // 0 is abort, 1 is commit
+	if commit {
+		typ = 1
+	}
+	key := make([]byte, 4) // version 0, type
+	binary.BigEndian.PutUint16(key[2:], typ)
+	value := make([]byte, 6) // version 0, coordinator epoch
+	binary.BigEndian.PutUint32(value[2:], uint32(coordinatorEpoch))
+
+	bb := kmsg.NewRecordBatchBuilder()
+	bb.ProducerID = producerID
+	bb.ProducerEpoch = producerEpoch
+	bb.Attributes = 0x0030 // transactional, control
+	bb.Add(key, value, nil, time.Now())
+	b, _ := bb.Build() // we added a record and do not compress; this cannot fail
+
+	firstOffset, open := pd.openTxns[producerID]
+	delete(pd.openTxns, producerID)
+	markerOffset := pd.highWatermark
+	pd.pushBatch(len(b.AppendTo(nil)), b)
+	if open && !commit {
+		pd.abortedTxns = append(pd.abortedTxns, abortedTxn{producerID, firstOffset, markerOffset})
+	}
+}
+
+// addAbortedTxns adds all aborted transactions that overlap the fetched
+// offsets [from, end) to a READ_COMMITTED fetch response partition.
+func (pd *partData) addAbortedTxns(sp *kmsg.FetchResponseTopicPartition, from, end int64) {
+	for _, a := range pd.abortedTxns {
+		if a.lastOffset < from || a.firstOffset >= end {
+			continue
+		}
+		sa := kmsg.NewFetchResponseTopicPartitionAbortedTransaction()
+		sa.ProducerID = a.producerID
+		sa.FirstOffset = a.firstOffset
+		sp.AbortedTransactions = append(sp.AbortedTransactions, sa)
+	}
+}
+
 func (pd *partData) searchOffset(o int64) (index int, found bool, atEnd bool) {
 	if len(pd.batches) == 0 {
 		if o == 0 {
@@ -5,5 +5,7 @@ go 1.20
 require (
 	github.com/burningass23/franz-go v1.13.0
 	github.com/burningass23/franz-go/pkg/kmsg v1.4.0
+	github.com/klauspost/compress v1.16.3
+	github.com/pierrec/lz4/v4 v4.1.17
 	golang.org/x/crypto v0.7.0
 )
@@ -3,6 +3,7 @@ package kfake
 import (
 	"bytes"
 	"fmt"
+	"sort"
 	"sync"
 	"time"
 
@@ -45,6 +46,14 @@ type (
 
 		tRebalance *time.Timer
 
+		// Next generation (KIP-848) consumer group state. A group
+		// with no members can switch between the classic and next
+		// generation protocols.
+		nextGen    bool
+		cmembers   map[string]*consumerMember
+		groupEpoch int32
+		topics     map[string]consumerTopic // latest topic snapshot from the cluster
+
 		quit   sync.Once
 		quitCh chan struct{}
 	}
@@ -66,6 +75,29 @@ type (
 		last time.Time
 	}
 
+	consumerMember struct {
+		memberID   string
+		clientID   string
+		clientHost string
+
+		epoch      int32
+		subscribed []string
+
+		target   map[uuid][]int32 // what the member will eventually own
+		assigned map[uuid][]int32 // what the member has been told to own
+		owned    map[uuid][]int32 // what the member last said it owns
+
+		sendAssigned bool // if the next response must include assigned
+
+		t    *time.Timer
+		last time.Time
+	}
+
+	consumerTopic struct {
+		id         uuid
+		partitions int32
+	}
+
 	offsetCommit struct {
 		offset      int64
 		leaderEpoch int32
@@ -140,17 +172,7 @@ func (gs *groups) handleJoin(creq clientReq) {
 start:
 	g := gs.gs[req.Group]
 	if g == nil {
-		g = &group{
-			c:         gs.c,
-			gs:        gs,
-			name:      req.Group,
-			members:   make(map[string]*groupMember),
-			pending:   make(map[string]*groupMember),
-			protocols: make(map[string]int),
-			reqCh:     make(chan clientReq),
-			controlCh: make(chan func()),
-			quitCh:    make(chan struct{}),
-		}
+		g = gs.newGroup(req.Group)
 		waitJoin := make(chan struct{})
 		gs.gs[req.Group] = g
 		go g.manage(func() { close(waitJoin) })
@@ -163,6 +185,52 @@ start:
 	}
 }
 
+func (gs *groups) newGroup(name string) *group {
+	return &group{
+		c:         gs.c,
+		gs:        gs,
+		name:      name,
+		members:   make(map[string]*groupMember),
+		pending:   make(map[string]*groupMember),
+		protocols: make(map[string]int),
+		cmembers:  make(map[string]*consumerMember),
+		reqCh:     make(chan clientReq),
+		controlCh: make(chan func()),
+		quitCh:    make(chan struct{}),
+	}
+}
+
+// handleConsumerHeartbeat hijacks the incoming request, returning a response
+// only if the request does not need to be passed to the group. Groups run
+// outside of the cluster loop, so the caller passes a snapshot of the
+// cluster's topics for the group to assign from.
+func (gs *groups) handleConsumerHeartbeat(creq clientReq, topics map[string]consumerTopic) kmsg.Response {
+	if gs.gs == nil {
+		gs.gs = make(map[string]*group)
+	}
+	req := creq.kreq.(*kmsg.ConsumerGroupHeartbeatRequest)
+start:
+	g := gs.gs[req.Group]
+	if g == nil {
+		if req.MemberEpoch != 0 {
+			resp := req.ResponseKind().(*kmsg.ConsumerGroupHeartbeatResponse)
+			resp.ErrorCode = kerr.UnknownMemberID.Code
+			return resp
+		}
+		g = gs.newGroup(req.Group)
+		gs.gs[req.Group] = g
+		go g.manage(func() {})
+	}
+	select {
+	case g.controlCh <- func() {
+		g.reply(creq, g.handleConsumerHeartbeat(creq, topics), nil)
+	}:
+	case <-g.quitCh:
+		goto start
+	}
+	return nil
+}
+
 // Returns true if the request is hijacked and handled, otherwise false if the
 // group does not exist.
 func (gs *groups) handleHijack(group string, creq clientReq) bool {
@@ -448,6 +516,11 @@ func (g *group) manage(detachNew func()) {
 				m.t.Stop()
 			}
 		}
+		for _, m := range g.cmembers {
+			if m.t != nil {
+				m.t.Stop()
+			}
+		}
 	}()
 
 	for {
@@ -516,6 +589,13 @@ func (g *group) handleJoin(creq clientReq) (kmsg.Response, bool) {
 		resp.ErrorCode = kerr.InvalidGroupID.Code
 		return resp, false
 	}
+	if g.nextGen {
+		if len(g.cmembers) > 0 {
+			resp.ErrorCode = kerr.InconsistentGroupProtocol.Code
+			return resp, true
+		}
+		g.nextGen = false
+	}
 	if st := int64(req.SessionTimeoutMillis); st < g.c.cfg.minSessionTimeout.Milliseconds() || st > g.c.cfg.maxSessionTimeout.Milliseconds() {
 		resp.ErrorCode = kerr.InvalidSessionTimeout.Code
 		return resp, false
@@ -734,6 +814,9 @@ func (g *group) handleOffsetCommit(creq clientReq) *kmsg.OffsetCommitResponse {
 		fillOffsetCommit(req, resp, kerr.InvalidGroupID.Code)
 		return resp
 	}
+	if g.nextGen {
+		return g.handleConsumerOffsetCommit(req, resp)
+	}
 	m, ok := g.members[req.MemberID]
 	if !ok {
 		fillOffsetCommit(req, resp, kerr.UnknownMemberID.Code)
@@ -751,15 +834,7 @@ func (g *group) handleOffsetCommit(creq clientReq) *kmsg.OffsetCommitResponse {
 	case groupEmpty:
 		// for when we support empty group commits
 	case groupPreparingRebalance, groupStable:
-		for _, t := range req.Topics {
-			for _, p := range t.Partitions {
-				g.commits.set(t.Topic, p.Partition, offsetCommit{
-					offset:      p.Offset,
-					leaderEpoch: p.LeaderEpoch,
-					metadata:    p.Metadata,
-				})
-			}
-		}
+		g.commitOffsets(req)
 		fillOffsetCommit(req, resp, 0)
 		g.updateHeartbeat(m)
 	case groupCompletingRebalance:
@@ -769,6 +844,18 @@ func (g *group) handleOffsetCommit(creq clientReq) *kmsg.OffsetCommitResponse {
 	return resp
 }
 
+func (g *group) commitOffsets(req *kmsg.OffsetCommitRequest) {
+	for _, t := range req.Topics {
+		for _, p := range t.Partitions {
+			g.commits.set(t.Topic, p.Partition, offsetCommit{
+				offset:      p.Offset,
+				leaderEpoch: p.LeaderEpoch,
+				metadata:    p.Metadata,
+			})
+		}
+	}
+}
+
 // Transitions the group to the preparing rebalance state. We first need to
 // clear any member that is currently sitting in sync. If enough members have
 // entered join, we immediately proceed to completeRebalance, otherwise we
@@ -847,6 +934,13 @@ func (g *group) completeRebalance() {
 	}
 	if len(g.members) == 0 {
 		g.state = groupEmpty
+		g.protocol = ""
+		g.leader = ""
+		for proto, nsupport := range g.protocols {
+			if nsupport <= 0 {
+				delete(g.protocols, proto)
+			}
+		}
 		return
 	}
 	g.state = groupCompletingRebalance
@@ -863,10 +957,13 @@ func (g *group) completeRebalance() {
 		panic(fmt.Sprint("unable to find commonly supported protocol!", g.protocols, len(g.members)))
 	}
 
-	for _, m := range g.members {
-		if !foundLeader {
+	if !foundLeader {
+		for _, m := range g.members {
 			g.leader = m.memberID
+			break
 		}
+	}
+	for _, m := range g.members {
 		req := m.join
 		resp := req.ResponseKind().(*kmsg.JoinGroupResponse)
 		g.fillJoinResp(req, resp)
@@ -979,23 +1076,22 @@ func (g *group) addMemberAndRebalance(m *groupMember, waitingReply clientReq, jo
 }
 
 // Returns if a new join can even join the group based on the join's supported
-// protocols.
+// protocols. This mirrors Kafka: an empty group accepts any protocol type and
+// protocols, while a non-empty group requires the same protocol type and at
+// least one protocol that every current member supports.
 func (g *group) protocolsMatch(protocolType string, protocols []kmsg.JoinGroupRequestProtocol) bool {
-	if g.protocolType == "" {
-		if protocolType == "" || len(protocols) == 0 {
-			return false
-		}
+	if protocolType == "" || len(protocols) == 0 {
+		return false
+	}
+	if len(g.members) == 0 {
 		g.protocolType = protocolType
 		return true
 	}
 	if protocolType != g.protocolType {
 		return false
 	}
-	if len(g.protocols) == 0 {
-		return true
-	}
 	for _, p := range protocols {
-		if _, ok := g.protocols[p.Name]; ok {
+		if g.protocols[p.Name] == len(g.members) {
 			return true
 		}
 	}
@@ -1059,3 +1155,310 @@ func (g *group) reply(creq clientReq, kresp kmsg.Response, m *groupMember) {
 		g.updateHeartbeat(m)
 	}
 }
+
+/////////////////////////////////////
+// NEXT GENERATION CONSUMER GROUPS //
+/////////////////////////////////////
+
+// Kafka's default group.consumer.session.timeout.ms.
+const consumerSessionTimeout = 45 * time.Second
+
+// Handles a ConsumerGroupHeartbeat. We do not run assignors: every subscribed
+// topic's partitions are spread round robin across the members subscribed to
+// it. A member is assigned its target partitions as soon as no other member
+// owns them, and a member's epoch catches up to the group epoch once its
+// assignment matches its target.
+func (g *group) handleConsumerHeartbeat(creq clientReq, topics map[string]consumerTopic) kmsg.Response {
+	req := creq.kreq.(*kmsg.ConsumerGroupHeartbeatRequest)
+	resp := req.ResponseKind().(*kmsg.ConsumerGroupHeartbeatResponse)
+
+	if kerr := g.c.validateGroup(creq, req.Group); kerr != nil {
+		resp.ErrorCode = kerr.Code
+		return resp
+	}
+	if req.InstanceID != nil {
+		resp.ErrorCode = kerr.InvalidGroupID.Code
+		return resp
+	}
+	if !g.nextGen {
+		if len(g.members) > 0 || len(g.pending) > 0 {
+			resp.ErrorCode = kerr.InconsistentGroupProtocol.Code
+			return resp
+		}
+		g.nextGen = true
+		g.protocolType = "consumer"
+	}
+	g.topics = topics
+
+	var (
+		m       *consumerMember
+		changed bool
+	)
+	switch {
+	case req.MemberEpoch == 0:
+		if req.SubscribedTopicNames == nil || req.Topics == nil {
+			resp.ErrorCode = kerr.InvalidRequest.Code
+			return resp
+		}
+		if req.MemberID == "" {
+			if req.Version >= 1 {
+				resp.ErrorCode = kerr.InvalidRequest.Code
+				return resp
+			}
+			req.MemberID = generateMemberID(creq.cid, nil)
+		}
+		if prior, ok := g.cmembers[req.MemberID]; ok {
+			g.removeConsumer(prior)
+		}
+		m = &consumerMember{
+			memberID:     req.MemberID,
+			clientID:     creq.cid,
+			clientHost:   creq.cc.conn.RemoteAddr().String(),
+			sendAssigned: true,
+		}
+		g.cmembers[m.memberID] = m
+		g.state = groupStable
+		changed = true
+
+	case req.MemberEpoch == -1 || req.MemberEpoch == -2:
+		m, ok := g.cmembers[req.MemberID]
+		if !ok {
+			resp.ErrorCode = kerr.UnknownMemberID.Code
+			return resp
+		}
+		g.removeConsumer(m)
+		g.computeConsumerTargets(true)
+		resp.MemberID = &req.MemberID
+		resp.MemberEpoch = req.MemberEpoch
+		return resp
+
+	case req.MemberEpoch < -2:
+		resp.ErrorCode = kerr.InvalidRequest.Code
+		return resp
+
+	default:
+		var ok bool
+		if m, ok = g.cmembers[req.MemberID]; !ok {
+			resp.ErrorCode = kerr.UnknownMemberID.Code
+			return resp
+		}
+		if req.MemberEpoch != m.epoch {
+			resp.ErrorCode = kerr.FencedMemberEpoch.Code
+			return resp
+		}
+	}
+
+	if req.SubscribedTopicNames != nil {
+		subscribed := append([]string(nil), req.SubscribedTopicNames...)
+		sort.Strings(subscribed)
+		if !equalStrings(subscribed, m.subscribed) {
+			m.subscribed = subscribed
+			changed = true
+		}
+	}
+	if req.Topics != nil {
+		m.owned = make(map[uuid][]int32, len(req.Topics))
+		for _, t := range req.Topics {
+			m.owned[t.TopicID] = append(m.owned[t.TopicID], t.Partitions...)
+		}
+	}
+
+	g.atConsumerSessionTimeout(m)
+	g.computeConsumerTargets(changed)
+	g.reconcileConsumer(m)
+	if req.MemberEpoch == 0 {
+		m.epoch = g.groupEpoch
+	}
+
+	resp.MemberID = &m.memberID
+	resp.MemberEpoch = m.epoch
+	resp.HeartbeatIntervalMillis = int32(g.c.cfg.consumerHeartbeatInterval.Milliseconds())
+	if m.sendAssigned {
+		m.sendAssigned = false
+		a := kmsg.NewConsumerGroupHeartbeatResponseAssignment()
+		for id, ps := range m.assigned {
+			at := kmsg.NewConsumerGroupHeartbeatResponseAssignmentTopic()
+			at.TopicID = id
+			at.Partitions = append([]int32(nil), ps...)
+			a.Topics = append(a.Topics, at)
+		}
+		resp.Assignment = &a
+	}
+	return resp
+}
+
+// Handles a commit from a next generation consumer group member, which
+// commits with its member epoch as the generation.
+func (g *group) handleConsumerOffsetCommit(req *kmsg.OffsetCommitRequest, resp *kmsg.OffsetCommitResponse) *kmsg.OffsetCommitResponse {
+	m, ok := g.cmembers[req.MemberID]
+	if !ok {
+		fillOffsetCommit(req, resp, kerr.UnknownMemberID.Code)
+		return resp
+	}
+	if req.Generation != m.epoch {
+		fillOffsetCommit(req, resp, kerr.StaleMemberEpoch.Code)
+		return resp
+	}
+	g.commitOffsets(req)
+	fillOffsetCommit(req, resp, 0)
+	return resp
+}
+
+// Recomputes every member's target assignment, bumping the group epoch if
+// anything changed. Membership and subscription changes always bump the
+// epoch; otherwise, targets change only if topics are created or grow.
+func (g *group) computeConsumerTargets(changed bool) {
+	subscribers := make(map[string][]string)
+	for _, m := range g.cmembers {
+		for _, t := range m.subscribed {
+			subscribers[t] = append(subscribers[t], m.memberID)
+		}
+	}
+	targets := make(map[string]map[uuid][]int32)
+	for t, members := range subscribers {
+		ct, ok := g.topics[t]
+		if !ok {
+			continue
+		}
+		sort.Strings(members)
+		for p := int32(0); p < ct.partitions; p++ {
+			memberID := members[int(p)%len(members)]
+			target := targets[memberID]
+			if target == nil {
+				target = make(map[uuid][]int32)
+				targets[memberID] = target
+			}
+			target[ct.id] = append(target[ct.id], p)
+		}
+	}
+	for _, m := range g.cmembers {
+		target := targets[m.memberID]
+		if !equalAssignment(m.target, target) {
+			m.target = target
+			changed = true
+		}
+	}
+	if changed {
+		g.groupEpoch++
+	}
+}
+
+// Moves the member's assignment toward its target. Partitions in the target
+// are withheld until every other member has been told to release them and
+// has stopped reporting them as owned.
+func (g *group) reconcileConsumer(m *consumerMember) {
+	claimed := make(map[uuid]map[int32]struct{})
+	claim := func(a map[uuid][]int32) {
+		for id, ps := range a {
+			c := claimed[id]
+			if c == nil {
+				c = make(map[int32]struct{})
+				claimed[id] = c
+			}
+			for _, p := range ps {
+				c[p] = struct{}{}
+			}
+		}
+	}
+	for _, o := range g.cmembers {
+		if o != m {
+			claim(o.assigned)
+			claim(o.owned)
+		}
+	}
+
+	assigned := make(map[uuid][]int32)
+	for id, ps := range m.target {
+		for _, p := range ps {
+			if _, ok := claimed[id][p]; !ok {
+				assigned[id] = append(assigned[id], p)
+			}
+		}
+	}
+	if !equalAssignment(m.assigned, assigned) {
+		m.assigned = assigned
+		m.sendAssigned = true
+	}
+	if equalAssignment(m.assigned, m.target) {
+		m.epoch = g.groupEpoch
+	}
+}
+
+func (g *group) removeConsumer(m *consumerMember) {
+	delete(g.cmembers, m.memberID)
+	if m.t != nil {
+		m.t.Stop()
+	}
+	if len(g.cmembers) == 0 {
+		g.state = groupEmpty
+	}
+}
+
+func (g *group) atConsumerSessionTimeout(m *consumerMember) {
+	if m.t != nil {
+		m.t.Stop()
+	}
+	m.last = time.Now()
+	tfn := func() {
+		select {
+		case <-g.quitCh:
+		case g.controlCh <- func() {
+			if time.Since(m.last) >= consumerSessionTimeout && g.cmembers[m.memberID] == m {
+				g.removeConsumer(m)
+				g.computeConsumerTargets(true)
+			}
+		}:
+		}
+	}
+	m.t = time.AfterFunc(consumerSessionTimeout, tfn)
+}
+
+func equalStrings(l, r []string) bool {
+	if len(l) != len(r) {
+		return false
+	}
+	for i := range l {
+		if l[i] != r[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// Compares two assignments, ignoring topics with no partitions. Partitions
+// must be in the same order, which they are for anything we build.
+func equalAssignment(l, r map[uuid][]int32) bool {
+	nonEmpty := func(a map[uuid][]int32) int {
+		var n int
+		for _, ps := range a {
+			if len(ps) > 0 {
+				n++
+			}
+		}
+		return n
+	}
+	if nonEmpty(l) != nonEmpty(r) {
+		return false
+	}
+	for id, ps := range l {
+		if len(ps) == 0 {
+			continue
+		}
+		if !equalInt32s(ps, r[id]) {
+			return false
+		}
+	}
+	return true
+}
+
+func equalInt32s(l, r []int32) bool {
+	if len(l) != len(r) {
+		return false
+	}
+	for i := range l {
+		if l[i] != r[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,95 @@
+package kfake
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/burningass23/franz-go/pkg/kgo"
+	"github.com/burningass23/franz-go/pkg/kmsg"
+)
+
+// A partition with no commit lags by everything from the log start offset,
+// and a partition with a commit lags from the commit.
+func TestGroupLag(t *testing.T) {
+	const topic, group = "lag", "lag-group"
+	c, err := NewCluster(
+		NumBrokers(1),
+		AllowAutoTopicCreation(),
+		DefaultNumPartitions(1),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	cl, err := kgo.NewClient(
+		kgo.SeedBrokers(c.ListenAddrs()...),
+		kgo.DefaultProduceTopic(topic),
+		kgo.AllowAutoTopicCreation(),
+		kgo.ConsumeTopics(topic),
+		kgo.ConsumerGroup(group),
+		kgo.DisableAutoCommit(),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cl.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	for i := 0; i < 5; i++ {
+		if err := cl.ProduceSync(ctx, &kgo.Record{Value: []byte("v")}).FirstErr(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var rs []*kgo.Record
+	for len(rs) < 5 {
+		fs := cl.PollFetches(ctx)
+		if err := fs.Err0(); err != nil {
+			t.Fatal(err)
+		}
+		rs = append(rs, fs.Records()...)
+	}
+
+	// kfake does not delete records, so we pretend that retention has
+	// since deleted the first two.
+	const logStart = 2
+	c.ControlKey(int16(kmsg.ListOffsets), func(kreq kmsg.Request) (kmsg.Response, error, bool) {
+		req := kreq.(*kmsg.ListOffsetsRequest)
+		resp := req.ResponseKind().(*kmsg.ListOffsetsResponse)
+		for _, rt := range req.Topics {
+			st := kmsg.NewListOffsetsResponseTopic()
+			st.Topic = rt.Topic
+			for _, rp := range rt.Partitions {
+				if rt.Topic != topic || rp.Timestamp != -2 {
+					return nil, nil, false
+				}
+				sp := kmsg.NewListOffsetsResponseTopicPartition()
+				sp.Partition = rp.Partition
+				sp.Offset = logStart
+				st.Partitions = append(st.Partitions, sp)
+			}
+			resp.Topics = append(resp.Topics, st)
+		}
+		return resp, nil, true
+	})
+
+	check := func(when string, exp kgo.PartitionLag) {
+		t.Helper()
+		lags, err := cl.Lag(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := lags[topic][0]; got != exp {
+			t.Errorf("%s: got %+v, exp %+v", when, got, exp)
+		}
+	}
+
+	check("before committing", kgo.PartitionLag{Committed: -1, Start: logStart, End: 5, Lag: 3})
+	if err := cl.CommitRecords(ctx, rs[3]); err != nil {
+		t.Fatal(err)
+	}
+	check("after committing", kgo.PartitionLag{Committed: 4, Start: -1, End: 5, Lag: 1})
+}
@@ -0,0 +1,86 @@
+package kfake
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/burningass23/franz-go/pkg/kgo"
+	"github.com/burningass23/franz-go/pkg/kmsg"
+)
+
+func TestListOffsetsTimestamp(t *testing.T) {
+	const topic = "list-offsets-ts"
+	c, err := NewCluster(
+		NumBrokers(1),
+		AllowAutoTopicCreation(),
+		DefaultNumPartitions(1),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	cl, err := kgo.NewClient(
+		kgo.SeedBrokers(c.ListenAddrs()...),
+		kgo.DefaultProduceTopic(topic),
+		kgo.AllowAutoTopicCreation(),
+		kgo.ManualFlushing(),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cl.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// The first three records are flushed in one batch, and the
+	// timestamps within it go backwards.
+	produce := func(millis ...int64) {
+		t.Helper()
+		for _, ms := range millis {
+			cl.Produce(ctx, &kgo.Record{Timestamp: time.UnixMilli(ms)}, func(_ *kgo.Record, err error) {
+				if err != nil {
+					t.Errorf("unable to produce: %v", err)
+				}
+			})
+		}
+		if err := cl.Flush(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+	produce(1000, 3000, 2000)
+	produce(5000)
+
+	for _, test := range []struct {
+		ts        int64
+		expOffset int64
+		expTs     int64
+	}{
+		{500, 0, 1000},
+		{1000, 0, 1000},
+		{1500, 1, 3000},
+		{2500, 1, 3000},
+		{4000, 3, 5000},
+		{6000, -1, -1},
+	} {
+		req := kmsg.NewPtrListOffsetsRequest()
+		rt := kmsg.NewListOffsetsRequestTopic()
+		rt.Topic = topic
+		rp := kmsg.NewListOffsetsRequestTopicPartition()
+		rp.Timestamp = test.ts
+		rt.Partitions = append(rt.Partitions, rp)
+		req.Topics = append(req.Topics, rt)
+
+		resp, err := req.RequestWith(ctx, cl)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sp := resp.Topics[0].Partitions[0]
+		if sp.Offset != test.expOffset || sp.Timestamp != test.expTs {
+			t.Errorf("timestamp %d: got offset %d timestamp %d, exp offset %d timestamp %d",
+				test.ts, sp.Offset, sp.Timestamp, test.expOffset, test.expTs)
+		}
+	}
+}
@@ -0,0 +1,278 @@
+package kfake
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/burningass23/franz-go/pkg/kerr"
+	"github.com/burningass23/franz-go/pkg/kgo"
+	"github.com/burningass23/franz-go/pkg/kmsg"
+)
+
+type refreshHook struct {
+	mu        sync.Mutex
+	refreshes []kgo.MetadataRefresh
+}
+
+func (h *refreshHook) OnMetadataRefresh(r kgo.MetadataRefresh) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.refreshes = append(h.refreshes, r)
+}
+
+// find returns the first refresh matching fn, if any.
+func (h *refreshHook) find(fn func(kgo.MetadataRefresh) bool) (kgo.MetadataRefresh, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, r := range h.refreshes {
+		if fn(r) {
+			return r, true
+		}
+	}
+	return kgo.MetadataRefresh{}, false
+}
+
+func (h *refreshHook) all(fn func(kgo.MetadataRefresh) bool) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, r := range h.refreshes {
+		if !fn(r) {
+			return false
+		}
+	}
+	return len(h.refreshes) > 0
+}
+
+func newRefreshCluster(t *testing.T, topic string) *Cluster {
+	t.Helper()
+	c, err := NewCluster(
+		NumBrokers(3),
+		AllowAutoTopicCreation(),
+		DefaultNumPartitions(1),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(c.Close)
+
+	cl, err := kgo.NewClient(
+		kgo.SeedBrokers(c.ListenAddrs()...),
+		kgo.DefaultProduceTopic(topic),
+		kgo.AllowAutoTopicCreation(),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cl.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := cl.ProduceSync(ctx, &kgo.Record{Value: []byte("v")}).FirstErr(); err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+// Periodic refreshes and refreshes triggered by errors are distinguished by
+// their trigger, and list the requested topics.
+func TestMetadataRefreshTrigger(t *testing.T) {
+	const topic = "refresh-trigger"
+	c := newRefreshCluster(t, topic)
+
+	// The first produce request fails with a retriable error.
+	c.ControlKey(int16(kmsg.Produce), func(kreq kmsg.Request) (kmsg.Response, error, bool) {
+		req := kreq.(*kmsg.ProduceRequest)
+		resp := req.ResponseKind().(*kmsg.ProduceResponse)
+		for _, rt := range req.Topics {
+			st := kmsg.NewProduceResponseTopic()
+			st.Topic = rt.Topic
+			for _, rp := range rt.Partitions {
+				sp := kmsg.NewProduceResponseTopicPartition()
+				sp.Partition = rp.Partition
+				sp.ErrorCode = kerr.NotLeaderForPartition.Code
+				st.Partitions = append(st.Partitions, sp)
+			}
+			resp.Topics = append(resp.Topics, st)
+		}
+		return resp, nil, true
+	})
+
+	hook := new(refreshHook)
+	cl, err := kgo.NewClient(
+		kgo.SeedBrokers(c.ListenAddrs()...),
+		kgo.DefaultProduceTopic(topic),
+		kgo.MetadataMinImmediateAge(10*time.Millisecond),
+		kgo.MetadataMinAge(10*time.Millisecond),
+		kgo.MetadataMaxAge(100*time.Millisecond),
+		kgo.WithHooks(hook),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cl.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := cl.ProduceSync(ctx, &kgo.Record{Value: []byte("v")}).FirstErr(); err != nil {
+		t.Fatal(err)
+	}
+
+	hasTopic := func(r kgo.MetadataRefresh) bool {
+		return len(r.Topics) == 1 && r.Topics[0] == topic
+	}
+	waitFor(t, "an error triggered refresh", func() bool {
+		_, ok := hook.find(func(r kgo.MetadataRefresh) bool {
+			return r.Trigger == "produce request had retry batches" && hasTopic(r) && r.Err == nil
+		})
+		return ok
+	})
+	waitFor(t, "a periodic refresh", func() bool {
+		r, ok := hook.find(func(r kgo.MetadataRefresh) bool { return r.Trigger == "periodic" })
+		if ok && (r.Immediate || !hasTopic(r)) {
+			t.Fatalf("periodic refresh: got immediate %v, topics %v; exp not immediate, [%s]", r.Immediate, r.Topics, topic)
+		}
+		return ok
+	})
+}
+
+// Regex consumers request all topics, which is reported as nil topics.
+func TestMetadataRefreshRegexTopics(t *testing.T) {
+	const topic = "refresh-regex"
+	c := newRefreshCluster(t, topic)
+
+	hook := new(refreshHook)
+	cl, err := kgo.NewClient(
+		kgo.SeedBrokers(c.ListenAddrs()...),
+		kgo.ConsumeTopics("refresh-.*"),
+		kgo.ConsumeRegex(),
+		kgo.ConsumeResetOffset(kgo.NewOffset().AtStart()),
+		kgo.WithHooks(hook),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cl.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if fs := cl.PollFetches(ctx); fs.NumRecords() != 1 {
+		t.Fatalf("got %d records and err %v, exp 1 record", fs.NumRecords(), fs.Err0())
+	}
+	if !hook.all(func(r kgo.MetadataRefresh) bool { return r.Topics == nil }) {
+		t.Errorf("got refreshes with topics, exp every refresh to have nil topics")
+	}
+}
+
+// A leader election is reported in the next refresh's leader changes.
+func TestMetadataRefreshLeaderChanges(t *testing.T) {
+	const topic = "refresh-leaders"
+	c := newRefreshCluster(t, topic)
+
+	hook := new(refreshHook)
+	cl, err := kgo.NewClient(
+		kgo.SeedBrokers(c.ListenAddrs()...),
+		kgo.DefaultProduceTopic(topic),
+		kgo.MetadataMinImmediateAge(10*time.Millisecond),
+		kgo.MetadataMinAge(10*time.Millisecond),
+		kgo.WithHooks(hook),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cl.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := cl.ProduceSync(ctx, &kgo.Record{Value: []byte("v")}).FirstErr(); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := hook.find(func(r kgo.MetadataRefresh) bool { return len(r.LeaderChanges) > 0 }); ok {
+		t.Fatal("saw leader changes before any leader election")
+	}
+
+	c.ShufflePartitionLeaders()
+	cl.ForceMetadataRefresh()
+	waitFor(t, "a refresh with leader changes", func() bool {
+		r, ok := hook.find(func(r kgo.MetadataRefresh) bool { return len(r.LeaderChanges) > 0 })
+		if ok {
+			lc := r.LeaderChanges[0]
+			if len(r.LeaderChanges) != 1 || lc.Topic != topic || lc.Partition != 0 || lc.NewLeaderEpoch <= lc.OldLeaderEpoch {
+				t.Fatalf("got leader changes %+v, exp one change for %s partition 0 with a higher epoch", r.LeaderChanges, topic)
+			}
+		}
+		return ok
+	})
+}
+
+type partitionsChange struct {
+	topic    string
+	old, new int
+}
+
+type partitionsHook struct {
+	mu      sync.Mutex
+	changes []partitionsChange
+}
+
+func (h *partitionsHook) OnTopicPartitionsChanged(topic string, oldPartitions, newPartitions int) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.changes = append(h.changes, partitionsChange{topic, oldPartitions, newPartitions})
+}
+
+func (h *partitionsHook) get() []partitionsChange {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return append([]partitionsChange(nil), h.changes...)
+}
+
+// Adding partitions is reported once the client sees them, while the first
+// load of a topic is not.
+func TestTopicPartitionsChanged(t *testing.T) {
+	const topic = "partitions-changed"
+	c := newRefreshCluster(t, topic)
+
+	hook := new(partitionsHook)
+	cl, err := kgo.NewClient(
+		kgo.SeedBrokers(c.ListenAddrs()...),
+		kgo.DefaultProduceTopic(topic),
+		kgo.MetadataMinImmediateAge(10*time.Millisecond),
+		kgo.MetadataMinAge(10*time.Millisecond),
+		kgo.WithHooks(hook),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cl.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := cl.ProduceSync(ctx, &kgo.Record{Value: []byte("v")}).FirstErr(); err != nil {
+		t.Fatal(err)
+	}
+	if changes := hook.get(); len(changes) != 0 {
+		t.Fatalf("got partition changes %v on the first load, exp none", changes)
+	}
+
+	req := kmsg.NewPtrCreatePartitionsRequest()
+	req.TimeoutMillis = 5000
+	rt := kmsg.NewCreatePartitionsRequestTopic()
+	rt.Topic = topic
+	rt.Count = 3
+	req.Topics = append(req.Topics, rt)
+	resp, err := req.RequestWith(ctx, cl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := kerr.ErrorForCode(resp.Topics[0].ErrorCode); err != nil {
+		t.Fatal(err)
+	}
+
+	cl.ForceMetadataRefresh()
+	waitFor(t, "a partition change", func() bool { return len(hook.get()) > 0 })
+	exp := partitionsChange{topic, 1, 3}
+	if changes := hook.get(); len(changes) != 1 || changes[0] != exp {
+		t.Errorf("got partition changes %+v, exp only %+v", changes, exp)
+	}
+}
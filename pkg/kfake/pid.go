@@ -17,9 +17,10 @@ type (
 	pids map[int64]*pidMap
 
 	pidMap struct {
-		id    int64
-		epoch int16
-		tps   tps[pidseqs]
+		id      int64
+		epoch   int16
+		txnalID *string
+		tps     tps[pidseqs]
 	}
 
 	pid struct {
@@ -64,13 +65,30 @@ func (pids *pids) create(txnalID *string) pid {
 	pm, exists := (*pids)[id]
 	if exists {
 		pm.epoch++
+		pm.tps = nil // sequence numbers restart with a new epoch
 		return pid{id, pm.epoch}
 	}
-	pm = &pidMap{id: id}
+	pm = &pidMap{id: id, txnalID: txnalID}
 	(*pids)[id] = pm
 	return pid{id, 0}
 }
 
+// txnal returns whether the producer ID is currently assigned to the given
+// transactional ID.
+func (pids *pids) txnal(id int64, txnalID string) bool {
+	pm := (*pids)[id]
+	return pm != nil && pm.txnalID != nil && *pm.txnalID == txnalID
+}
+
+// epoch returns the current epoch of a producer ID, if the ID exists.
+func (pids *pids) epoch(id int64) (int16, bool) {
+	pm := (*pids)[id]
+	if pm == nil {
+		return 0, false
+	}
+	return pm.epoch, true
+}
+
 func (seqs *pidseqs) pushAndValidate(firstSeq, numRecs int32) (ok, dup bool) {
 	// If there is no pid, we do not do duplicate detection.
 	if seqs == nil {
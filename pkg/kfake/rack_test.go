@@ -0,0 +1,292 @@
+package kfake
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/burningass23/franz-go/pkg/kerr"
+	"github.com/burningass23/franz-go/pkg/kgo"
+	"github.com/burningass23/franz-go/pkg/kmsg"
+	"github.com/burningass23/franz-go/pkg/kversion"
+)
+
+func TestBrokerRacks(t *testing.T) {
+	const topic = "racks"
+	c, err := NewCluster(
+		NumBrokers(3),
+		AllowAutoTopicCreation(),
+		DefaultNumPartitions(1),
+		BrokerRacks(map[int32]string{0: "a", 1: "b", 2: "c"}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	cl, err := kgo.NewClient(
+		kgo.SeedBrokers(c.ListenAddrs()...),
+		kgo.DefaultProduceTopic(topic),
+		kgo.AllowAutoTopicCreation(),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cl.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := cl.ProduceSync(ctx, &kgo.Record{Value: []byte("v")}).FirstErr(); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.MoveTopicPartition(topic, 0, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	// Metadata advertises racks and all three replicas, leader first.
+	mreq := kmsg.NewPtrMetadataRequest()
+	mreqTopic := kmsg.NewMetadataRequestTopic()
+	mreqTopic.Topic = kmsg.StringPtr(topic)
+	mreq.Topics = append(mreq.Topics, mreqTopic)
+	mresp, err := mreq.RequestWith(ctx, cl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, b := range mresp.Brokers {
+		if exp := string(rune('a' + b.NodeID)); b.Rack == nil || *b.Rack != exp {
+			t.Errorf("broker %d: got rack %v, exp %s", b.NodeID, b.Rack, exp)
+		}
+	}
+	if replicas := mresp.Topics[0].Partitions[0].Replicas; len(replicas) != 3 || replicas[0] != 0 {
+		t.Errorf("got replicas %v, exp 3 replicas led by 0", replicas)
+	}
+
+	// Each fetch is issued from a client whose max fetch version is the
+	// version we want to test.
+	fetch := func(node int32, version int16, rack string) kmsg.FetchResponseTopicPartition {
+		t.Helper()
+		vs := kversion.Stable()
+		vs.SetMaxKeyVersion(int16(kmsg.Fetch), version)
+		vcl, err := kgo.NewClient(
+			kgo.SeedBrokers(c.ListenAddrs()...),
+			kgo.MaxVersions(vs),
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer vcl.Close()
+		if _, err := kmsg.NewPtrMetadataRequest().RequestWith(ctx, vcl); err != nil {
+			t.Fatal(err)
+		}
+
+		req := kmsg.NewPtrFetchRequest()
+		req.ReplicaID = -1
+		req.MaxBytes = 1 << 20
+		req.Rack = rack
+		rt := kmsg.NewFetchRequestTopic()
+		rt.Topic = topic
+		rp := kmsg.NewFetchRequestTopicPartition()
+		rp.PartitionMaxBytes = 1 << 20
+		rp.CurrentLeaderEpoch = -1
+		rp.LogStartOffset = -1
+		rt.Partitions = append(rt.Partitions, rp)
+		req.Topics = append(req.Topics, rt)
+		kresp, err := vcl.Broker(int(node)).Request(ctx, req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return kresp.(*kmsg.FetchResponse).Topics[0].Partitions[0]
+	}
+
+	// The leader redirects a request from another replica's rack.
+	if p := fetch(0, 11, "b"); p.ErrorCode != 0 || p.PreferredReadReplica != 1 || len(p.RecordBatches) != 0 {
+		t.Errorf("leader fetch from rack b: got err %d, preferred %d, %d bytes; exp 0, 1, 0", p.ErrorCode, p.PreferredReadReplica, len(p.RecordBatches))
+	}
+	// The leader serves requests from its own rack, an unknown rack, or no rack.
+	for _, rack := range []string{"a", "z", ""} {
+		if p := fetch(0, 11, rack); p.ErrorCode != 0 || p.PreferredReadReplica != -1 || len(p.RecordBatches) == 0 {
+			t.Errorf("leader fetch from rack %q: got err %d, preferred %d, %d bytes; exp data", rack, p.ErrorCode, p.PreferredReadReplica, len(p.RecordBatches))
+		}
+	}
+	// Followers serve v11+ fetches, but older fetches must go to the leader.
+	if p := fetch(1, 11, "b"); p.ErrorCode != 0 || len(p.RecordBatches) == 0 {
+		t.Errorf("follower v11 fetch: got err %d, %d bytes; exp data", p.ErrorCode, len(p.RecordBatches))
+	}
+	if p := fetch(1, 10, ""); p.ErrorCode != kerr.NotLeaderForPartition.Code {
+		t.Errorf("follower v10 fetch: got err %d, exp NotLeaderForPartition", p.ErrorCode)
+	}
+}
+
+// A rack-aware consumer is redirected to, and consumes from, the replica in
+// its rack.
+func TestBrokerRacksFollowerFetching(t *testing.T) {
+	const topic = "racks"
+	c, err := NewCluster(
+		NumBrokers(3),
+		AllowAutoTopicCreation(),
+		DefaultNumPartitions(1),
+		BrokerRacks(map[int32]string{0: "a", 1: "b", 2: "c"}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	producer, err := kgo.NewClient(
+		kgo.SeedBrokers(c.ListenAddrs()...),
+		kgo.DefaultProduceTopic(topic),
+		kgo.AllowAutoTopicCreation(),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer producer.Close()
+	if err := producer.ProduceSync(ctx, &kgo.Record{Value: []byte("v")}).FirstErr(); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.MoveTopicPartition(topic, 0, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	hook := &fetchNodeHook{fetched: make(map[int32]int)}
+	consumer, err := kgo.NewClient(
+		kgo.SeedBrokers(c.ListenAddrs()...),
+		kgo.ConsumeTopics(topic),
+		kgo.ConsumeResetOffset(kgo.NewOffset().AtStart()),
+		kgo.Rack("c"),
+		kgo.WithHooks(hook),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer consumer.Close()
+
+	fs := consumer.PollFetches(ctx)
+	if err := fs.Err0(); err != nil {
+		t.Fatal(err)
+	}
+	if n := fs.NumRecords(); n != 1 {
+		t.Fatalf("got %d records, exp 1", n)
+	}
+
+	hook.mu.Lock()
+	defer hook.mu.Unlock()
+	if hook.fetched[2] == 0 {
+		t.Errorf("no fetches to the rack c replica; fetches per node: %v", hook.fetched)
+	}
+}
+
+// A ConsumeReplicaSelector choosing a follower is honored, and a selection
+// that is not a replica falls back to the leader.
+func TestConsumeReplicaSelector(t *testing.T) {
+	for _, test := range []struct {
+		name     string
+		selected int32
+		expNode  int32
+	}{
+		{"follower", 2, 2},
+		{"not a replica", 7, 0},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			const topic, produceTopic = "selector", "selector-produce"
+			c, err := NewCluster(
+				NumBrokers(3),
+				AllowAutoTopicCreation(),
+				DefaultNumPartitions(1),
+			)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer c.Close()
+
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+
+			producer, err := kgo.NewClient(
+				kgo.SeedBrokers(c.ListenAddrs()...),
+				kgo.DefaultProduceTopic(topic),
+				kgo.AllowAutoTopicCreation(),
+			)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer producer.Close()
+			if err := producer.ProduceSync(ctx, &kgo.Record{Value: []byte("v")}).FirstErr(); err != nil {
+				t.Fatal(err)
+			}
+			if err := c.MoveTopicPartition(topic, 0, 0); err != nil {
+				t.Fatal(err)
+			}
+
+			var (
+				mu       sync.Mutex
+				selected = make(map[string]int)
+			)
+			hook := &fetchNodeHook{fetched: make(map[int32]int)}
+			consumer, err := kgo.NewClient(
+				kgo.SeedBrokers(c.ListenAddrs()...),
+				kgo.ConsumeTopics(topic),
+				kgo.ConsumeResetOffset(kgo.NewOffset().AtStart()),
+				kgo.ConsumeReplicaSelector(func(topic string, _ kgo.PartitionMetadata) int32 {
+					mu.Lock()
+					defer mu.Unlock()
+					selected[topic]++
+					return test.selected
+				}),
+				kgo.DefaultProduceTopic(produceTopic),
+				kgo.AllowAutoTopicCreation(),
+				kgo.WithHooks(hook),
+			)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer consumer.Close()
+
+			// Producing loads metadata for a topic that is not
+			// consumed, which must not call the selector.
+			if err := consumer.ProduceSync(ctx, &kgo.Record{Value: []byte("v")}).FirstErr(); err != nil {
+				t.Fatal(err)
+			}
+			fs := consumer.PollFetches(ctx)
+			if err := fs.Err0(); err != nil {
+				t.Fatal(err)
+			}
+			if n := fs.NumRecords(); n != 1 {
+				t.Fatalf("got %d records, exp 1", n)
+			}
+
+			mu.Lock()
+			if selected[topic] == 0 || selected[produceTopic] != 0 {
+				t.Errorf("got selector calls %v, exp calls only for %s", selected, topic)
+			}
+			mu.Unlock()
+
+			hook.mu.Lock()
+			defer hook.mu.Unlock()
+			for node, n := range hook.fetched {
+				if node != test.expNode && n > 0 || hook.fetched[test.expNode] == 0 {
+					t.Errorf("got fetches per node %v, exp fetches only to node %d", hook.fetched, test.expNode)
+					break
+				}
+			}
+		})
+	}
+}
+
+type fetchNodeHook struct {
+	mu      sync.Mutex
+	fetched map[int32]int
+}
+
+func (h *fetchNodeHook) OnBrokerRead(meta kgo.BrokerMetadata, key int16, _ int, _, _ time.Duration, err error) {
+	if key != int16(kmsg.Fetch) || err != nil {
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.fetched[meta.NodeID]++
+}
@@ -0,0 +1,140 @@
+package kfake
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/klauspost/compress/s2"
+	"github.com/klauspost/compress/zstd"
+	"github.com/pierrec/lz4/v4"
+
+	"github.com/burningass23/franz-go/pkg/kmsg"
+)
+
+// Record is a record stored in the cluster, as returned from Records.
+type Record struct {
+	Topic     string
+	Partition int32
+	Offset    int64
+
+	Key       []byte
+	Value     []byte
+	Headers   []kmsg.Header
+	Timestamp time.Time
+
+	ProducerID    int64
+	ProducerEpoch int16
+	LeaderEpoch   int32 // the partition epoch when the record was produced
+
+	// Transactional is whether the record was produced in a transaction.
+	// Records from aborted transactions are still stored and returned.
+	Transactional bool
+}
+
+// Records returns all records currently stored in the given topic partition,
+// in offset order. Records are read directly from storage, without going
+// through the Kafka protocol, which makes it easy to assert what a producer
+// wrote. Batches are decompressed regardless of their compression codec.
+// Control records (transaction markers) are not returned.
+//
+// This returns an error if the topic or partition does not exist, or if a
+// stored batch cannot be decoded.
+func (c *Cluster) Records(topic string, partition int32) ([]Record, error) {
+	var (
+		rs  []Record
+		err error
+	)
+	c.admin(func() {
+		pd, ok := c.data.tps.getp(topic, partition)
+		if !ok {
+			err = fmt.Errorf("topic %q partition %d not found", topic, partition)
+			return
+		}
+		for _, b := range pd.batches {
+			if b.Attributes&0x0020 != 0 { // control batch
+				continue
+			}
+			var krs []kmsg.Record
+			if krs, err = b.ReadRecords(decompress); err != nil {
+				err = fmt.Errorf("unable to read batch at offset %d: %w", b.FirstOffset, err)
+				return
+			}
+			for _, kr := range krs {
+				ts := b.FirstTimestamp + kr.TimestampDelta64
+				if b.Attributes&0x0008 != 0 { // log append time
+					ts = b.MaxTimestamp
+				}
+				rs = append(rs, Record{
+					Topic:         topic,
+					Partition:     partition,
+					Offset:        b.FirstOffset + int64(kr.OffsetDelta),
+					Key:           kr.Key,
+					Value:         kr.Value,
+					Headers:       kr.Headers,
+					Timestamp:     time.UnixMilli(ts),
+					ProducerID:    b.ProducerID,
+					ProducerEpoch: b.ProducerEpoch,
+					LeaderEpoch:   b.epoch,
+					Transactional: b.Attributes&0x0010 != 0,
+				})
+			}
+		}
+	})
+	return rs, err
+}
+
+func decompress(codec int8, src []byte) ([]byte, error) {
+	switch codec {
+	case 1:
+		r, err := gzip.NewReader(bytes.NewReader(src))
+		if err != nil {
+			return nil, err
+		}
+		return io.ReadAll(r)
+	case 2:
+		if len(src) > 16 && bytes.HasPrefix(src, xerialPfx) {
+			return xerialDecode(src)
+		}
+		return s2.Decode(nil, src)
+	case 3:
+		return io.ReadAll(lz4.NewReader(bytes.NewReader(src)))
+	case 4:
+		r, err := zstd.NewReader(nil)
+		if err != nil {
+			return nil, err
+		}
+		defer r.Close()
+		return r.DecodeAll(src, nil)
+	default:
+		return nil, fmt.Errorf("unknown compression codec %d", codec)
+	}
+}
+
+var xerialPfx = []byte{130, 83, 78, 65, 80, 80, 89, 0}
+
+func xerialDecode(src []byte) ([]byte, error) {
+	src = src[16:] // 8 byte header, 8 byte version
+	var dst []byte
+	for len(src) > 0 {
+		if len(src) < 4 {
+			return nil, errors.New("malformed xerial framing")
+		}
+		size := int32(binary.BigEndian.Uint32(src))
+		src = src[4:]
+		if size < 0 || len(src) < int(size) {
+			return nil, errors.New("malformed xerial framing")
+		}
+		chunk, err := s2.Decode(nil, src[:size])
+		if err != nil {
+			return nil, err
+		}
+		dst = append(dst, chunk...)
+		src = src[size:]
+	}
+	return dst, nil
+}
@@ -0,0 +1,82 @@
+package kfake
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/burningass23/franz-go/pkg/kgo"
+)
+
+func TestRecords(t *testing.T) {
+	c, err := NewCluster(
+		NumBrokers(1),
+		AllowAutoTopicCreation(),
+		DefaultNumPartitions(1),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	if _, err := c.Records("missing", 0); err == nil {
+		t.Error("expected error for a missing topic")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	for _, codec := range []struct {
+		name  string
+		codec kgo.CompressionCodec
+	}{
+		{"none", kgo.NoCompression()},
+		{"gzip", kgo.GzipCompression()},
+		{"snappy", kgo.SnappyCompression()},
+		{"lz4", kgo.Lz4Compression()},
+		{"zstd", kgo.ZstdCompression()},
+	} {
+		topic := "records-" + codec.name
+		cl, err := kgo.NewClient(
+			kgo.SeedBrokers(c.ListenAddrs()...),
+			kgo.DefaultProduceTopic(topic),
+			kgo.AllowAutoTopicCreation(),
+			kgo.ProducerBatchCompression(codec.codec),
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var produce []*kgo.Record
+		for i := 0; i < 10; i++ {
+			produce = append(produce, &kgo.Record{
+				Key:     []byte(strconv.Itoa(i)),
+				Value:   []byte("value value value value " + strconv.Itoa(i)),
+				Headers: []kgo.RecordHeader{{Key: "h", Value: []byte("v")}},
+			})
+		}
+		err = cl.ProduceSync(ctx, produce...).FirstErr()
+		cl.Close()
+		if err != nil {
+			t.Fatalf("%s: %v", codec.name, err)
+		}
+
+		rs, err := c.Records(topic, 0)
+		if err != nil {
+			t.Fatalf("%s: %v", codec.name, err)
+		}
+		if len(rs) != len(produce) {
+			t.Fatalf("%s: got %d records, exp %d", codec.name, len(rs), len(produce))
+		}
+		for i, r := range rs {
+			exp := produce[i]
+			if r.Topic != topic || r.Partition != 0 || r.Offset != int64(i) ||
+				string(r.Key) != string(exp.Key) || string(r.Value) != string(exp.Value) ||
+				len(r.Headers) != 1 || r.Headers[0].Key != "h" || string(r.Headers[0].Value) != "v" ||
+				r.Timestamp.UnixMilli() != exp.Timestamp.UnixMilli() || r.Transactional {
+				t.Errorf("%s: record %d: got %+v, exp key %s value %s", codec.name, i, r, exp.Key, exp.Value)
+			}
+		}
+	}
+}
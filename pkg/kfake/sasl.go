@@ -21,6 +21,7 @@ const (
 	saslPlain       = "PLAIN"
 	saslScram256    = "SCRAM-SHA-256"
 	saslScram512    = "SCRAM-SHA-512"
+	saslOAuth       = "OAUTHBEARER"
 	scramIterations = 4096
 )
 
@@ -29,13 +30,22 @@ type (
 		plain    map[string]string    // user => pass
 		scram256 map[string]scramAuth // user => scram auth
 		scram512 map[string]scramAuth // user => scram auth
+		oauth    func(string) (string, error)
 	}
 
 	saslStage uint8
 )
 
 func (s sasls) empty() bool {
-	return len(s.plain) == 0 && len(s.scram256) == 0 && len(s.scram512) == 0
+	return len(s.plain) == 0 && len(s.scram256) == 0 && len(s.scram512) == 0 && s.oauth == nil
+}
+
+func (s sasls) mechanisms() []string {
+	ms := []string{saslPlain, saslScram256, saslScram512}
+	if s.oauth != nil {
+		ms = append(ms, saslOAuth)
+	}
+	return ms
 }
 
 const (
@@ -44,6 +54,7 @@ const (
 	saslStageAuthScram0_256
 	saslStageAuthScram0_512
 	saslStageAuthScram1
+	saslStageAuthOAuth
 	saslStageComplete
 )
 
@@ -60,7 +71,8 @@ func (c *Cluster) handleSASL(creq clientReq) (allow bool) {
 	case saslStageAuthPlain,
 		saslStageAuthScram0_256,
 		saslStageAuthScram0_512,
-		saslStageAuthScram1:
+		saslStageAuthScram1,
+		saslStageAuthOAuth:
 		switch creq.kreq.(type) {
 		case *kmsg.ApiVersionsRequest,
 			*kmsg.SASLAuthenticateRequest:
@@ -69,6 +81,12 @@ func (c *Cluster) handleSASL(creq clientReq) (allow bool) {
 			return false
 		}
 	case saslStageComplete:
+		// If the session expired, the client must reauthenticate
+		// before issuing any other request.
+		if !creq.cc.saslExp.IsZero() && creq.at.After(creq.cc.saslExp) {
+			_, isHandshake := creq.kreq.(*kmsg.SASLHandshakeRequest)
+			return isHandshake
+		}
 		return true
 	default:
 		panic("unreachable")
@@ -90,6 +108,32 @@ func saslSplitPlain(auth []byte) (user, pass string, err error) {
 	return parts[1], parts[2], nil
 }
 
+///////////
+// OAUTH //
+///////////
+
+// saslOAuthToken parses the token from an OAUTHBEARER client initial
+// response, per RFC 7628 section 3.1:
+//
+//	gs2-header kvsep *kvpair kvsep
+//
+// where the auth kvpair is "auth=Bearer <token>".
+func saslOAuthToken(auth []byte) (string, error) {
+	gs2, kvs, ok := strings.Cut(string(auth), "\x01")
+	if !ok || !strings.HasPrefix(gs2, "n,") && !strings.HasPrefix(gs2, "y,") {
+		return "", errors.New("invalid oauth gs2 header")
+	}
+	if !strings.HasSuffix(kvs, "\x01\x01") {
+		return "", errors.New("invalid oauth message termination")
+	}
+	for _, kv := range strings.Split(strings.TrimSuffix(kvs, "\x01\x01"), "\x01") {
+		if token, ok := strings.CutPrefix(kv, "auth=Bearer "); ok {
+			return token, nil
+		}
+	}
+	return "", errors.New("missing oauth auth=Bearer token")
+}
+
 ///////////
 // SCRAM //
 ///////////
@@ -0,0 +1,160 @@
+package kfake
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/burningass23/franz-go/pkg/kerr"
+	"github.com/burningass23/franz-go/pkg/kgo"
+	"github.com/burningass23/franz-go/pkg/kmsg"
+	"github.com/burningass23/franz-go/pkg/sasl/oauth"
+)
+
+func TestSASLOAuthBearer(t *testing.T) {
+	var (
+		mu     sync.Mutex
+		tokens []string
+	)
+	c, err := NewCluster(
+		NumBrokers(1),
+		SASLOAuthBearer(func(token string) (string, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			tokens = append(tokens, token)
+			if token != "good" {
+				return "", errors.New("token expired")
+			}
+			return "User:alice", nil
+		}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	ping := func(token string) error {
+		cl, err := kgo.NewClient(
+			kgo.SeedBrokers(c.ListenAddrs()...),
+			kgo.SASL(oauth.Auth{Token: token}.AsMechanism()),
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer cl.Close()
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		return cl.Ping(ctx)
+	}
+
+	if err := ping("good"); err != nil {
+		t.Errorf("unable to ping with a valid token: %v", err)
+	}
+
+	err = ping("bad")
+	if !errors.Is(err, kerr.SaslAuthenticationFailed) || !strings.Contains(err.Error(), "token expired") {
+		t.Errorf("got err %v, exp SASL_AUTHENTICATION_FAILED with the validator's message", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(tokens) < 2 || tokens[0] != "good" || tokens[len(tokens)-1] != "bad" {
+		t.Errorf("validator saw tokens %v, exp \"good\" then \"bad\"", tokens)
+	}
+}
+
+type connectHook struct {
+	mu    sync.Mutex
+	conns int
+}
+
+func (h *connectHook) OnBrokerConnect(meta kgo.BrokerMetadata, _ time.Duration, _ net.Conn, err error) {
+	if err == nil && meta.NodeID >= 0 { // ignore seed brokers
+		h.mu.Lock()
+		h.conns++
+		h.mu.Unlock()
+	}
+}
+
+func TestSASLReauthentication(t *testing.T) {
+	var (
+		mu     sync.Mutex
+		nauths int
+	)
+	c, err := NewCluster(
+		NumBrokers(1),
+		SASLOAuthBearer(func(string) (string, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			nauths++
+			return "User:alice", nil
+		}),
+		SASLSessionLifetime(1500*time.Millisecond),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	hook := new(connectHook)
+	cl, err := kgo.NewClient(
+		kgo.SeedBrokers(c.ListenAddrs()...),
+		kgo.SASL(oauth.Auth{Token: "token"}.AsMechanism()),
+		kgo.WithHooks(hook),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cl.Close()
+
+	// The client reauthenticates 1s before the session lifetime, so
+	// every request after the first 500ms reauthenticates on the same
+	// connection.
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	for i := 0; i < 4; i++ {
+		if i > 0 {
+			time.Sleep(600 * time.Millisecond)
+		}
+		if _, err := cl.Broker(0).Request(ctx, kmsg.NewPtrMetadataRequest()); err != nil {
+			t.Fatalf("request %d: %v", i, err)
+		}
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if nauths < 4 {
+		t.Errorf("got %d authentications, exp at least 4 (one per request)", nauths)
+	}
+	hook.mu.Lock()
+	defer hook.mu.Unlock()
+	if hook.conns != 1 {
+		t.Errorf("got %d connections, exp 1 reauthenticated connection", hook.conns)
+	}
+}
+
+func TestSASLExpiredSession(t *testing.T) {
+	c := new(Cluster)
+	now := time.Now()
+	cc := &clientConn{saslStage: saslStageComplete, saslExp: now.Add(-time.Second)}
+
+	// Once expired, only reauthentication is allowed.
+	if c.handleSASL(clientReq{cc: cc, kreq: kmsg.NewPtrMetadataRequest(), at: now}) {
+		t.Error("metadata request allowed on an expired session")
+	}
+	if !c.handleSASL(clientReq{cc: cc, kreq: kmsg.NewPtrSASLHandshakeRequest(), at: now}) {
+		t.Error("reauthentication not allowed on an expired session")
+	}
+
+	// Unexpired and non-expiring sessions allow all requests.
+	for _, exp := range []time.Time{now.Add(time.Second), {}} {
+		cc.saslExp = exp
+		if !c.handleSASL(clientReq{cc: cc, kreq: kmsg.NewPtrMetadataRequest(), at: now}) {
+			t.Errorf("metadata request not allowed with session expiry %v", exp)
+		}
+	}
+}
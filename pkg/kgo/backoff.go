@@ -0,0 +1,78 @@
+package kgo
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// ExponentialBackoff returns a backoff function for RetryBackoffFn that
+// doubles the backoff from min for every failure, with +/-20% jitter, capped
+// at max. The jitter is applied before capping, so once the backoff has grown
+// past max, the backoff is always exactly max. The default client backoff is
+// ExponentialBackoff(250ms, 2.5s).
+func ExponentialBackoff(min, max time.Duration) func(int) time.Duration {
+	rng := newLockedRand()
+	return func(fails int) time.Duration {
+		if fails <= 0 {
+			return min
+		}
+		// We grow to at most 1.25x max: anything larger than that
+		// is still larger than max after -20% jitter.
+		limit := max + max/4
+		if limit < max { // overflow
+			limit = max
+		}
+		backoff := time.Duration(float64(expBackoff(min, limit, fails)) * (0.8 + 0.4*rng.float64()))
+		if backoff > max {
+			return max
+		}
+		return backoff
+	}
+}
+
+// FullJitterBackoff returns a backoff function for RetryBackoffFn that
+// chooses a random backoff between min and an exponentially growing ceiling
+// that starts at min and doubles for every failure, up to max. Compared to
+// ExponentialBackoff, this spreads out retries from many clients that failed
+// at the same time.
+func FullJitterBackoff(min, max time.Duration) func(int) time.Duration {
+	rng := newLockedRand()
+	return func(fails int) time.Duration {
+		if fails <= 0 {
+			return min
+		}
+		ceil := expBackoff(min, max, fails)
+		return min + time.Duration(rng.float64()*float64(ceil-min))
+	}
+}
+
+// expBackoff returns min doubled fails-1 times, capped at max.
+func expBackoff(min, max time.Duration, fails int) time.Duration {
+	backoff := min
+	for i := 1; i < fails; i++ {
+		if backoff > max/2 {
+			return max
+		}
+		backoff *= 2
+	}
+	if backoff > max {
+		return max
+	}
+	return backoff
+}
+
+type lockedRand struct {
+	mu  sync.Mutex
+	rng *rand.Rand
+}
+
+func newLockedRand() *lockedRand {
+	return &lockedRand{rng: rand.New(rand.NewSource(time.Now().UnixNano()))}
+}
+
+func (r *lockedRand) float64() float64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.rng.Float64()
+}
@@ -0,0 +1,86 @@
+package kgo
+
+import (
+	"testing"
+	"time"
+)
+
+func TestExponentialBackoff(t *testing.T) {
+	const (
+		min = 250 * time.Millisecond
+		max = 5 * time.Second / 2
+	)
+	backoff := ExponentialBackoff(min, max)
+	for i := 0; i < 100; i++ {
+		if got := backoff(0); got != min {
+			t.Fatalf("fails 0: got %v != exp %v", got, min)
+		}
+		for fails, exp := range []time.Duration{
+			1: min,
+			2: 2 * min,
+			3: 4 * min,
+			4: 8 * min,
+		} {
+			if fails == 0 {
+				continue
+			}
+			lo, hi := exp*8/10, exp*12/10
+			if got := backoff(fails); got < lo || got > hi {
+				t.Fatalf("fails %d: got %v, exp between %v and %v", fails, got, lo, hi)
+			}
+		}
+		// Past the max, jitter no longer applies.
+		for _, fails := range []int{5, 10, 11, 64, 1 << 20} {
+			if got := backoff(fails); got != max {
+				t.Fatalf("fails %d: got %v != exp %v", fails, got, max)
+			}
+		}
+	}
+}
+
+func TestFullJitterBackoff(t *testing.T) {
+	const (
+		min = 100 * time.Millisecond
+		max = time.Second
+	)
+	backoff := FullJitterBackoff(min, max)
+	for i := 0; i < 100; i++ {
+		if got := backoff(0); got != min {
+			t.Fatalf("fails 0: got %v != exp %v", got, min)
+		}
+		if got := backoff(1); got != min {
+			t.Fatalf("fails 1: got %v != exp %v", got, min)
+		}
+		for _, test := range []struct {
+			fails int
+			ceil  time.Duration
+		}{
+			{2, 2 * min},
+			{3, 4 * min},
+			{5, max},
+			{1 << 20, max},
+		} {
+			if got := backoff(test.fails); got < min || got > test.ceil {
+				t.Fatalf("fails %d: got %v, exp between %v and %v", test.fails, got, min, test.ceil)
+			}
+		}
+	}
+}
+
+func TestExpBackoff(t *testing.T) {
+	for _, test := range []struct {
+		min, max time.Duration
+		fails    int
+		exp      time.Duration
+	}{
+		{time.Second, 10 * time.Second, 1, time.Second},
+		{time.Second, 10 * time.Second, 3, 4 * time.Second},
+		{time.Second, 10 * time.Second, 5, 10 * time.Second},
+		{time.Second, 10 * time.Second, 1000, 10 * time.Second}, // no overflow
+		{time.Second, time.Duration(1<<63 - 1), 100, time.Duration(1<<63 - 1)},
+	} {
+		if got := expBackoff(test.min, test.max, test.fails); got != test.exp {
+			t.Errorf("expBackoff(%v, %v, %d): got %v != exp %v", test.min, test.max, test.fails, got, test.exp)
+		}
+	}
+}
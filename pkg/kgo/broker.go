@@ -154,6 +154,9 @@ type broker struct {
 
 	// reqs manages incoming message requests.
 	reqs ringReq
+	// inflight, if non-nil, bounds the number of requests in flight to
+	// this broker; see MaxInFlightPerBroker.
+	inflight chan struct{}
 	// dead is an atomic so a backed up reqs cannot block broker stoppage.
 	dead atomicBool
 }
@@ -199,8 +202,13 @@ func unknownSeedID(seedNum int) int32 {
 }
 
 func (cl *Client) newBroker(nodeID int32, host string, port int32, rack *string) *broker {
+	var inflight chan struct{}
+	if n := cl.cfg.maxInflightPerBroker; n > 0 {
+		inflight = make(chan struct{}, n)
+	}
 	return &broker{
-		cl: cl,
+		cl:       cl,
+		inflight: inflight,
 
 		addr: net.JoinHostPort(host, strconv.Itoa(int(port))),
 		meta: BrokerMetadata{
@@ -395,6 +403,26 @@ start:
 	default:
 	}
 
+	// If we are limiting in flight requests, we wait for a slot and
+	// release it as soon as this request is done (written with no
+	// response expected, failed, or the response is read).
+	promise := pr.promise
+	if b.inflight != nil {
+		select {
+		case b.inflight <- struct{}{}:
+		case <-pr.ctx.Done():
+			pr.promise(nil, pr.ctx.Err())
+			return
+		case <-b.cl.ctx.Done():
+			pr.promise(nil, ErrClientClosed)
+			return
+		}
+		promise = func(resp kmsg.Response, err error) {
+			<-b.inflight
+			pr.promise(resp, err)
+		}
+	}
+
 	// Produce requests (and only produce requests) can be written
 	// without receiving a reply. If we see required acks is 0,
 	// then we immediately call the promise with no response.
@@ -425,29 +453,27 @@ start:
 		noResp.Version = req.GetVersion()
 	}
 
-	corrID, bytesWritten, writeWait, timeToWrite, readEnqueue, writeErr := cxn.writeRequest(pr.ctx, pr.enqueue, req)
+	corrID, bytesWritten, writeWait, timeToWrite, readEnqueue, rt, writeErr := cxn.writeRequest(pr.ctx, pr.enqueue, req)
 
 	if writeErr != nil {
-		pr.promise(nil, writeErr)
+		promise(nil, writeErr)
 		cxn.die()
 		cxn.hookWriteE2E(req.Key(), bytesWritten, writeWait, timeToWrite, writeErr)
 		return
 	}
 
 	if isNoResp {
-		pr.promise(noResp, nil)
+		promise(noResp, nil)
 		cxn.hookWriteE2E(req.Key(), bytesWritten, writeWait, timeToWrite, writeErr)
 		return
 	}
 
-	rt, _ := cxn.cl.connTimeouter.timeouts(req)
-
 	cxn.waitResp(promisedResp{
 		pr.ctx,
 		corrID,
 		req.IsFlexible() && req.Key() != 18, // response header not flexible if ApiVersions; see promisedResp doc
 		req.ResponseKind(),
-		pr.promise,
+		promise,
 		rt,
 		bytesWritten,
 		writeWait,
@@ -467,6 +493,9 @@ func (cxn *brokerCxn) hookWriteE2E(key int16, bytesWritten int, writeWait, timeT
 			})
 		}
 	})
+	if m := cxn.cl.metrics; m != nil {
+		m.observeRequest(writeErr)
+	}
 }
 
 // bufPool is used to reuse issued-request buffers across writes to brokers.
@@ -607,17 +636,68 @@ func (b *broker) reapConnections(idleTimeout time.Duration) (total int) {
 	return total
 }
 
+// handshakeTLS performs the TLS handshake on a newly dialed connection, if it
+// has not been performed already, and calls any HookBrokerTLSHandshake.
+func (b *broker) handshakeTLS(ctx context.Context, tc *tls.Conn) error {
+	err := tc.HandshakeContext(ctx)
+	state := tc.ConnectionState()
+	b.cl.cfg.hooks.each(func(h Hook) {
+		if h, ok := h.(HookBrokerTLSHandshake); ok {
+			h.OnBrokerTLSHandshake(b.meta, state, err)
+		}
+	})
+	if err == nil {
+		b.cl.cfg.logger.Log(LogLevelDebug, "tls handshake complete", "addr", b.addr, "broker", logID(b.meta.NodeID), "version", tlsVersionName(state.Version), "cipher_suite", tls.CipherSuiteName(state.CipherSuite))
+	}
+	return err
+}
+
+func tlsVersionName(v uint16) string {
+	switch v {
+	case tls.VersionSSL30: //nolint:staticcheck // we are just naming the version
+		return "SSL v3"
+	case tls.VersionTLS10:
+		return "TLS v1.0"
+	case tls.VersionTLS11:
+		return "TLS v1.1"
+	case tls.VersionTLS12:
+		return "TLS v1.2"
+	case tls.VersionTLS13:
+		return "TLS v1.3"
+	}
+	return fmt.Sprintf("unknown TLS version (hex %x)", v)
+}
+
 // connect connects to the broker's addr, returning the new connection.
 func (b *broker) connect(ctx context.Context) (net.Conn, error) {
 	b.cl.cfg.logger.Log(LogLevelDebug, "opening connection to broker", "addr", b.addr, "broker", logID(b.meta.NodeID))
 	start := time.Now()
 	conn, err := b.cl.cfg.dialFn(ctx, "tcp", b.addr)
+	if err == nil {
+		if tc, ok := conn.(*tls.Conn); ok {
+			// The handshake shares the dial timeout: connecting,
+			// including the handshake, takes at most dialTimeout.
+			hctx := ctx
+			if timeout := b.cl.cfg.dialTimeout; timeout > 0 {
+				var cancel func()
+				hctx, cancel = context.WithDeadline(ctx, start.Add(timeout))
+				defer cancel()
+			}
+			if err = b.handshakeTLS(hctx, tc); err != nil {
+				conn.Close()
+				conn = nil
+			}
+		}
+	}
 	since := time.Since(start)
 	b.cl.cfg.hooks.each(func(h Hook) {
 		if h, ok := h.(HookBrokerConnect); ok {
 			h.OnBrokerConnect(b.meta, since, conn, err)
 		}
 	})
+	if m := b.cl.metrics; m != nil {
+		m.observeConnect(err)
+	}
 	if err != nil {
 		if !errors.Is(err, ErrClientClosed) && !strings.Contains(err.Error(), "operation was canceled") {
 			if errors.Is(err, io.EOF) {
@@ -717,13 +797,12 @@ start:
 	req.ClientSoftwareName = cxn.cl.cfg.softwareName
 	req.ClientSoftwareVersion = cxn.cl.cfg.softwareVersion
 	cxn.cl.cfg.logger.Log(LogLevelDebug, "issuing api versions request", "broker", logID(cxn.b.meta.NodeID), "version", maxVersion)
-	corrID, bytesWritten, writeWait, timeToWrite, readEnqueue, writeErr := cxn.writeRequest(nil, time.Now(), req)
+	corrID, bytesWritten, writeWait, timeToWrite, readEnqueue, rt, writeErr := cxn.writeRequest(nil, time.Now(), req)
 	if writeErr != nil {
 		cxn.hookWriteE2E(req.Key(), bytesWritten, writeWait, timeToWrite, writeErr)
 		return writeErr
 	}
 
-	rt, _ := cxn.cl.connTimeouter.timeouts(req)
 	// api versions does *not* use flexible response headers; see comment in promisedResp
 	rawResp, err := cxn.readResponse(nil, req.Key(), req.GetVersion(), corrID, false, rt, bytesWritten, writeWait, timeToWrite, readEnqueue)
 	if err != nil {
@@ -790,13 +869,12 @@ start:
 		req.Mechanism = mechanism.Name()
 		req.Version = v.versions[req.Key()]
 		cxn.cl.cfg.logger.Log(LogLevelDebug, "issuing SASLHandshakeRequest", "broker", logID(cxn.b.meta.NodeID))
-		corrID, bytesWritten, writeWait, timeToWrite, readEnqueue, writeErr := cxn.writeRequest(nil, time.Now(), req)
+		corrID, bytesWritten, writeWait, timeToWrite, readEnqueue, rt, writeErr := cxn.writeRequest(nil, time.Now(), req)
 		if writeErr != nil {
 			cxn.hookWriteE2E(req.Key(), bytesWritten, writeWait, timeToWrite, writeErr)
 			return writeErr
 		}
 
-		rt, _ := cxn.cl.connTimeouter.timeouts(req)
 		rawResp, err := cxn.readResponse(nil, req.Key(), req.GetVersion(), corrID, req.IsFlexible(), rt, bytesWritten, writeWait, timeToWrite, readEnqueue)
 		if err != nil {
 			return err
@@ -841,8 +919,12 @@ func (cxn *brokerCxn) doSasl(authenticate bool) error {
 	var lifetimeMillis int64
 
 	// Even if we do not wrap our reads/writes in SASLAuthenticate, we
-	// still use the SASLAuthenticate timeouts.
-	rt, wt := cxn.cl.connTimeouter.timeouts(kmsg.NewPtrSASLAuthenticateRequest())
+	// still use the SASLAuthenticate timeouts. If we do wrap, the
+	// timeouts are computed per request when writing.
+	var rt, wt time.Duration
+	if !authenticate {
+		rt, wt = cxn.cl.connTimeouter.timeouts(kmsg.NewPtrSASLAuthenticateRequest())
+	}
 
 	// We continue writing until both the challenging is done AND the
 	// responses are done. We can have an additional response once we
@@ -881,7 +963,7 @@ func (cxn *brokerCxn) doSasl(authenticate bool) error {
 			// Lifetime: we take the timestamp before we write our
 			// request; see usage below for why.
 			prereq = time.Now()
-			corrID, bytesWritten, writeWait, timeToWrite, readEnqueue, writeErr := cxn.writeRequest(nil, time.Now(), req)
+			corrID, bytesWritten, writeWait, timeToWrite, readEnqueue, rt, writeErr := cxn.writeRequest(nil, time.Now(), req)
 
 			// As mentioned above, we could have one final write
 			// without reading a response back (kerberos). If this
@@ -922,6 +1004,12 @@ func (cxn *brokerCxn) doSasl(authenticate bool) error {
 		}
 	}
 
+	if ls, ok := session.(sasl.LifetimeSession); ok {
+		if mechMillis := ls.SessionLifetime().Milliseconds(); mechMillis > 0 && (lifetimeMillis <= 0 || mechMillis < lifetimeMillis) {
+			lifetimeMillis = mechMillis
+		}
+	}
+
 	if lifetimeMillis > 0 {
 		// Lifetime is problematic. We need to be a bit pessimistic.
 		//
@@ -980,7 +1068,10 @@ func maybeUpdateCtxErr(clientCtx, reqCtx context.Context, err *error) {
 
 // writeRequest writes a message request to the broker connection, bumping the
 // connection's correlation ID as appropriate for the next write.
-func (cxn *brokerCxn) writeRequest(ctx context.Context, enqueuedForWritingAt time.Time, req kmsg.Request) (corrID int32, bytesWritten int, writeWait, timeToWrite time.Duration, readEnqueue time.Time, writeErr error) {
+// writeRequest writes req and returns, among other things, the read timeout to
+// use for the response. The read and write timeouts are computed together, so
+// that ConnTimeoutsFn is called once per request.
+func (cxn *brokerCxn) writeRequest(ctx context.Context, enqueuedForWritingAt time.Time, req kmsg.Request) (corrID int32, bytesWritten int, writeWait, timeToWrite time.Duration, readEnqueue time.Time, readTimeout time.Duration, writeErr error) {
 	// A nil ctx means we cannot be throttled.
 	if ctx != nil {
 		throttleUntil := time.Unix(0, cxn.throttleUntil.Load())
@@ -1004,13 +1095,17 @@ func (cxn *brokerCxn) writeRequest(ctx context.Context, enqueuedForWritingAt tim
 		}
 	}
 
+	id := cxn.corrID
+	if fn := cxn.cl.cfg.corrIDFn; fn != nil {
+		id = fn(cxn.b.meta, req)
+	}
 	buf := cxn.cl.reqFormatter.AppendRequest(
 		cxn.cl.bufPool.get()[:0],
 		req,
-		cxn.corrID,
+		id,
 	)
 
-	_, wt := cxn.cl.connTimeouter.timeouts(req)
+	readTimeout, wt := cxn.cl.connTimeouter.timeouts(req)
 	bytesWritten, writeWait, timeToWrite, readEnqueue, writeErr = cxn.writeConn(ctx, buf, wt, enqueuedForWritingAt)
 
 	cxn.cl.bufPool.put(buf)
@@ -1027,7 +1122,7 @@ func (cxn *brokerCxn) writeRequest(ctx context.Context, enqueuedForWritingAt tim
 	if writeErr != nil {
 		return
 	}
-	corrID = cxn.corrID
+	corrID = id
 	cxn.corrID++
 	if cxn.corrID < 0 {
 		cxn.corrID = 0
@@ -1163,22 +1258,7 @@ func (cxn *brokerCxn) parseReadSize(sizeBuf []byte) (int32, error) {
 		// if this we received a TLS alert.
 		tlsVersion := uint16(sizeBuf[1])<<8 | uint16(sizeBuf[2])
 		if sizeBuf[0] == 21 && tlsVersion&0x0300 != 0 {
-			versionGuess := fmt.Sprintf("unknown TLS version (hex %x)", tlsVersion)
-			for _, guess := range []struct {
-				num  uint16
-				text string
-			}{
-				{tls.VersionSSL30, "SSL v3"},
-				{tls.VersionTLS10, "TLS v1.0"},
-				{tls.VersionTLS11, "TLS v1.1"},
-				{tls.VersionTLS12, "TLS v1.2"},
-				{tls.VersionTLS13, "TLS v1.3"},
-			} {
-				if tlsVersion == guess.num {
-					versionGuess = guess.text
-				}
-			}
-			return 0, fmt.Errorf("invalid large response size %d > limit %d; the first three bytes received appear to be a tls alert record for %s; is this a plaintext connection speaking to a tls endpoint?", size, maxSize, versionGuess)
+			return 0, fmt.Errorf("invalid large response size %d > limit %d; the first three bytes received appear to be a tls alert record for %s; is this a plaintext connection speaking to a tls endpoint?", size, maxSize, tlsVersionName(tlsVersion))
 		}
 		return 0, fmt.Errorf("invalid large response size %d > limit %d", size, maxSize)
 	}
@@ -1220,6 +1300,9 @@ func (cxn *brokerCxn) readResponse(
 			})
 		}
 	})
+	if m := cxn.cl.metrics; m != nil {
+		m.observeRequest(readErr)
+	}
 	if logger := cxn.cl.cfg.logger; logger.Level() >= LogLevelDebug {
 		logger.Log(LogLevelDebug, fmt.Sprintf("read %s v%d", kmsg.NameForKey(key), version), "broker", logID(cxn.b.meta.NodeID), "bytes_read", bytesRead, "read_wait", readWait, "time_to_read", timeToRead, "err", readErr)
 	}
@@ -0,0 +1,238 @@
+package kgo
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/burningass23/franz-go/pkg/kmsg"
+)
+
+type inflightHook struct {
+	mu       sync.Mutex
+	inflight int
+	max      int
+}
+
+func (h *inflightHook) OnBrokerWrite(_ BrokerMetadata, _ int16, _ int, _, _ time.Duration, err error) {
+	if err != nil {
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.inflight++
+	if h.inflight > h.max {
+		h.max = h.inflight
+	}
+}
+
+func (h *inflightHook) OnBrokerRead(BrokerMetadata, int16, int, time.Duration, time.Duration, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.inflight--
+}
+
+func TestMaxInFlightPerBroker(t *testing.T) {
+	t.Parallel()
+
+	for _, test := range []struct {
+		limit  int
+		expMax int // 0 means only that more than one was in flight
+	}{
+		{1, 1},
+		{3, 3},
+		{0, 0},
+	} {
+		h := new(inflightHook)
+		cl, err := NewClient(
+			getSeedBrokers(),
+			MaxInFlightPerBroker(test.limit),
+			WithHooks(h),
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		// We issue all requests to a single discovered broker, over
+		// the one connection used for metadata requests.
+		if _, err := cl.Request(ctx, kmsg.NewPtrMetadataRequest()); err != nil {
+			t.Fatal(err)
+		}
+		br := cl.DiscoveredBrokers()[0]
+		h.mu.Lock()
+		h.max = 0
+		h.mu.Unlock()
+
+		var wg sync.WaitGroup
+		for i := 0; i < 50; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if _, err := br.Request(ctx, kmsg.NewPtrMetadataRequest()); err != nil {
+					t.Errorf("unable to request: %v", err)
+				}
+			}()
+		}
+		wg.Wait()
+		cancel()
+		cl.Close()
+
+		h.mu.Lock()
+		max := h.max
+		h.mu.Unlock()
+		switch {
+		case test.expMax == 0 && max <= 1:
+			t.Errorf("limit %d: saw at most %d in flight, expected unbounded pipelining", test.limit, max)
+		case test.expMax != 0 && max > test.expMax:
+			t.Errorf("limit %d: saw %d in flight", test.limit, max)
+		}
+	}
+
+	if _, err := NewClient(MaxInFlightPerBroker(-1)); err == nil {
+		t.Error("expected error with a negative in flight limit")
+	}
+}
+
+type tlsHandshakeHook struct {
+	mu       sync.Mutex
+	dialDurs []time.Duration
+	hsErrs   []error
+}
+
+func (h *tlsHandshakeHook) OnBrokerConnect(_ BrokerMetadata, dialDur time.Duration, _ net.Conn, _ error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.dialDurs = append(h.dialDurs, dialDur)
+}
+
+func (h *tlsHandshakeHook) OnBrokerTLSHandshake(_ BrokerMetadata, _ tls.ConnectionState, err error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.hsErrs = append(h.hsErrs, err)
+}
+
+func TestTLSHandshakeSharesDialTimeout(t *testing.T) {
+	t.Parallel()
+
+	// The listener accepts connections but never speaks TLS, so every
+	// handshake hangs until the dial timeout.
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		var conns []net.Conn
+		defer func() {
+			for _, conn := range conns {
+				conn.Close()
+			}
+		}()
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			conns = append(conns, conn)
+		}
+	}()
+
+	const (
+		dialTimeout = 300 * time.Millisecond
+		dialDelay   = 200 * time.Millisecond
+	)
+	hook := new(tlsHandshakeHook)
+	cl, _ := NewClient(
+		SeedBrokers(ln.Addr().String()),
+		DialTimeout(dialTimeout),
+		Dialer(func(ctx context.Context, network, host string) (net.Conn, error) {
+			time.Sleep(dialDelay) // a slow dial uses up some of the timeout
+			conn, err := new(net.Dialer).DialContext(ctx, network, host)
+			if err != nil {
+				return nil, err
+			}
+			return tls.Client(conn, &tls.Config{InsecureSkipVerify: true}), nil //nolint:gosec // the handshake never completes
+		}),
+		WithHooks(hook),
+		RequestRetries(0),
+	)
+	defer cl.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := cl.Ping(ctx); err == nil {
+		t.Fatal("expected ping to fail")
+	}
+
+	hook.mu.Lock()
+	defer hook.mu.Unlock()
+	if len(hook.dialDurs) == 0 || len(hook.hsErrs) == 0 {
+		t.Fatalf("got %d connects, %d handshakes, exp at least one of each", len(hook.dialDurs), len(hook.hsErrs))
+	}
+	if hook.hsErrs[0] == nil {
+		t.Error("expected the handshake to fail")
+	}
+	if d := hook.dialDurs[0]; d < dialTimeout || d > dialTimeout+dialDelay/2 {
+		t.Errorf("connecting took %v, exp about the dial timeout %v", d, dialTimeout)
+	}
+}
+
+type writesHook struct {
+	mu     sync.Mutex
+	writes map[int16]int
+}
+
+func (h *writesHook) OnBrokerWrite(_ BrokerMetadata, key int16, _ int, _, _ time.Duration, _ error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.writes[key]++
+}
+
+// ConnTimeoutsFn is called once per request, not once for the write timeout
+// and again for the read timeout.
+func TestConnTimeoutsFnOncePerRequest(t *testing.T) {
+	var (
+		mu    sync.Mutex
+		calls = make(map[int16]int)
+	)
+	hook := &writesHook{writes: make(map[int16]int)}
+	cl, err := NewClient(
+		getSeedBrokers(),
+		WithHooks(hook),
+		ConnTimeoutsFn(func(req kmsg.Request, read, write time.Duration) (time.Duration, time.Duration) {
+			mu.Lock()
+			defer mu.Unlock()
+			calls[req.Key()]++
+			return read, write
+		}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	for i := 0; i < 3; i++ {
+		if _, err := kmsg.NewPtrMetadataRequest().RequestWith(ctx, cl); err != nil {
+			t.Fatal(err)
+		}
+	}
+	cl.Close()
+
+	mu.Lock()
+	defer mu.Unlock()
+	hook.mu.Lock()
+	defer hook.mu.Unlock()
+	if calls[int16(kmsg.Metadata)] < 3 {
+		t.Errorf("got %d calls for metadata requests, exp at least 3", calls[int16(kmsg.Metadata)])
+	}
+	for key, n := range hook.writes {
+		if calls[key] != n {
+			t.Errorf("%s: got %d calls for %d writes", kmsg.NameForKey(key), calls[key], n)
+		}
+	}
+}
@@ -0,0 +1,144 @@
+package kgo
+
+import (
+	"sync"
+	"time"
+)
+
+// circuitBreaker tracks consecutive failed produce requests to a single
+// broker, backing ProduceCircuitBreaker. The breaker is closed while requests
+// succeed, opens once failures reach the configured threshold, and after the
+// cooldown allows one probe request: if the probe succeeds, the breaker
+// closes, otherwise it reopens for another cooldown.
+type circuitBreaker struct {
+	mu        sync.Mutex
+	failures  int
+	openUntil time.Time
+	probing   bool // a probe request is inflight
+	waking    bool // a drain is scheduled for the end of the cooldown
+}
+
+// breakerAllows returns whether the sink can issue a produce request, and
+// whether that request is the probe after a cooldown. If the request is a
+// probe and the sink does not issue it, the sink must call breakerProbeUnsent.
+//
+// If the breaker is open, this fails all buffered records that can be failed
+// with ErrBrokerCircuitOpen and schedules a drain for when the cooldown ends.
+func (s *sink) breakerAllows() (allowed, probe bool) {
+	threshold := s.cl.cfg.breakerFailures
+	if threshold <= 0 {
+		return true, false
+	}
+	b := &s.breaker
+
+	b.mu.Lock()
+	if b.failures < threshold {
+		b.mu.Unlock()
+		return true, false
+	}
+	if b.probing {
+		b.mu.Unlock()
+		return false, false // the probe response drains again
+	}
+	if wait := time.Until(b.openUntil); wait <= 0 {
+		b.probing = true
+		b.mu.Unlock()
+		s.cl.cfg.logger.Log(LogLevelInfo, "produce circuit breaker cooldown elapsed, probing broker", "broker", logID(s.nodeID))
+		return true, true
+	} else if !b.waking {
+		b.waking = true
+		time.AfterFunc(wait, func() {
+			b.mu.Lock()
+			b.waking = false
+			b.mu.Unlock()
+			s.maybeDrain()
+		})
+	}
+	b.mu.Unlock()
+
+	s.failOpenBreakerRecords()
+	return false, false
+}
+
+// breakerProbeUnsent clears the probe that breakerAllows started if the sink
+// returned without issuing a produce request (nothing to send, a producer ID
+// or AddPartitionsToTxn failure, etc.). Without this, no response would ever
+// clear the probe and the sink would never produce again. The next drain
+// probes again.
+func (s *sink) breakerProbeUnsent() {
+	b := &s.breaker
+	b.mu.Lock()
+	b.probing = false
+	b.mu.Unlock()
+}
+
+// failOpenBreakerRecords fails the buffered records of every partition on
+// this sink, except for partitions that are waiting on a metadata update
+// (which may move the partition to a different broker), and partitions whose
+// first batch may have been written already while we are idempotent (failing
+// those would break the sequence numbers of the partition). Skipped records
+// are retried once the cooldown ends.
+func (s *sink) failOpenBreakerRecords() {
+	s.recBufsMu.Lock()
+	recBufs := append([]*recBuf(nil), s.recBufs...)
+	s.recBufsMu.Unlock()
+
+	idempotent := s.cl.idempotent()
+	for _, recBuf := range recBufs {
+		recBuf.mu.Lock()
+		if !recBuf.failing && len(recBuf.batches) > 0 && (!idempotent || recBuf.batches[0].canFailFromLoadErrs) {
+			recBuf.failAllRecords(ErrBrokerCircuitOpen)
+		}
+		recBuf.mu.Unlock()
+	}
+}
+
+// breakerFailure records a failed produce request, opening the breaker if
+// we have hit our failure threshold.
+func (s *sink) breakerFailure(err error) {
+	threshold := s.cl.cfg.breakerFailures
+	if threshold <= 0 {
+		return
+	}
+	b := &s.breaker
+
+	b.mu.Lock()
+	b.failures++
+	b.probing = false
+	failures := b.failures
+	open := failures >= threshold
+	if open {
+		b.openUntil = time.Now().Add(s.cl.cfg.breakerCooldown)
+	}
+	b.mu.Unlock()
+
+	if open {
+		s.cl.cfg.logger.Log(LogLevelWarn, "opening produce circuit breaker, failing records to this broker until the cooldown elapses",
+			"broker", logID(s.nodeID),
+			"consecutive_failures", failures,
+			"cooldown", s.cl.cfg.breakerCooldown,
+			"err", err,
+		)
+		// If leadership moved, a metadata update moves partitions to
+		// their new broker and out from under the open breaker.
+		s.cl.triggerUpdateMetadataNow("produce circuit breaker opened")
+	}
+}
+
+// breakerSuccess records a successful produce request, closing the breaker.
+func (s *sink) breakerSuccess() {
+	if s.cl.cfg.breakerFailures <= 0 {
+		return
+	}
+	b := &s.breaker
+
+	b.mu.Lock()
+	wasOpen := b.failures >= s.cl.cfg.breakerFailures
+	b.failures = 0
+	b.probing = false
+	b.mu.Unlock()
+
+	if wasOpen {
+		s.cl.cfg.logger.Log(LogLevelInfo, "closing produce circuit breaker after successful probe", "broker", logID(s.nodeID))
+	}
+}
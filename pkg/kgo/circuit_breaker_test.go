@@ -0,0 +1,91 @@
+package kgo
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func newBreakerTestSink(t *testing.T, failures int) *sink {
+	t.Helper()
+	cl, err := NewClient(
+		getSeedBrokers(),
+		ProduceCircuitBreaker(failures, time.Hour),
+	)
+	if err != nil {
+		t.Fatalf("unable to create client: %v", err)
+	}
+	t.Cleanup(cl.Close)
+	return &sink{cl: cl, nodeID: 1}
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	s := newBreakerTestSink(t, 2)
+	errFail := errors.New("fail")
+
+	check := func(when string, expAllowed, expProbe bool) {
+		t.Helper()
+		if allowed, probe := s.breakerAllows(); allowed != expAllowed || probe != expProbe {
+			t.Errorf("%s: got allowed %v probe %v, exp allowed %v probe %v", when, allowed, probe, expAllowed, expProbe)
+		}
+	}
+	endCooldown := func() {
+		s.breaker.mu.Lock()
+		s.breaker.openUntil = time.Now().Add(-time.Millisecond)
+		s.breaker.mu.Unlock()
+	}
+
+	// Closed: requests are allowed until we hit the failure threshold.
+	check("closed", true, false)
+	s.breakerFailure(errFail)
+	check("closed after one failure", true, false)
+	s.breakerSuccess()
+	s.breakerFailure(errFail)
+	check("closed after a success resets failures", true, false)
+
+	// Open: requests are not allowed until the cooldown ends.
+	s.breakerFailure(errFail)
+	check("open", false, false)
+
+	// Probe: one request is allowed, and nothing else until its response.
+	endCooldown()
+	check("cooldown elapsed", true, true)
+	check("probe inflight", false, false)
+
+	// Probe failure: the breaker reopens for another cooldown.
+	s.breakerFailure(errFail)
+	check("probe failed", false, false)
+	s.breaker.mu.Lock()
+	reopened := time.Until(s.breaker.openUntil) > 0
+	s.breaker.mu.Unlock()
+	if !reopened {
+		t.Error("probe failed: breaker did not reopen for another cooldown")
+	}
+
+	// Probe success: the breaker closes.
+	endCooldown()
+	check("second cooldown elapsed", true, true)
+	s.breakerSuccess()
+	check("probe succeeded", true, false)
+	check("closed after probe", true, false)
+}
+
+// If the sink does not issue the probe, the next drain must be able to probe
+// again rather than the sink being stuck waiting on a response that will
+// never come.
+func TestCircuitBreakerProbeUnsent(t *testing.T) {
+	s := newBreakerTestSink(t, 1)
+
+	s.breakerFailure(errors.New("fail"))
+	s.breaker.mu.Lock()
+	s.breaker.openUntil = time.Now().Add(-time.Millisecond)
+	s.breaker.mu.Unlock()
+
+	if allowed, probe := s.breakerAllows(); !allowed || !probe {
+		t.Fatalf("cooldown elapsed: got allowed %v probe %v, exp a probe", allowed, probe)
+	}
+	s.breakerProbeUnsent()
+	if allowed, probe := s.breakerAllows(); !allowed || !probe {
+		t.Errorf("after unsent probe: got allowed %v probe %v, exp another probe", allowed, probe)
+	}
+}
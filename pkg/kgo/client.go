@@ -26,6 +26,7 @@ import (
 
 	"github.com/burningass23/franz-go/pkg/kerr"
 	"github.com/burningass23/franz-go/pkg/kmsg"
+	"github.com/burningass23/franz-go/pkg/kversion"
 	"github.com/burningass23/franz-go/pkg/sasl"
 )
 
@@ -91,6 +92,8 @@ type Client struct {
 
 	mappedMetaMu sync.Mutex
 	mappedMeta   map[string]mappedMetadataTopic
+
+	metrics *clientMetrics // non-nil if client metrics are enabled
 }
 
 func (cl *Client) idempotent() bool { return !cl.cfg.disableIdempotency }
@@ -244,6 +247,16 @@ func (cl *Client) OptValues(opt any) []any {
 		return []any{cfg.requestTimeoutOverhead}
 	case namefn(ConnIdleTimeout):
 		return []any{cfg.connIdleTimeout}
+	case namefn(ConnReadTimeout):
+		return []any{cfg.connReadTimeout}
+	case namefn(ConnWriteTimeout):
+		return []any{cfg.connWriteTimeout}
+	case namefn(ConnTimeoutsFn):
+		return []any{cfg.connTimeoutsFn}
+	case namefn(DialKeepAlive):
+		return []any{cfg.dialKeepAlive}
+	case namefn(MaxInFlightPerBroker):
+		return []any{cfg.maxInflightPerBroker}
 	case namefn(Dialer):
 		return []any{cfg.dialFn}
 	case namefn(DialTLSConfig):
@@ -262,8 +275,15 @@ func (cl *Client) OptValues(opt any) []any {
 		return []any{cfg.retryTimeout(0)}
 	case namefn(RetryTimeoutFn):
 		return []any{cfg.retryTimeout}
+	case namefn(CorrelationIDFn):
+		return []any{cfg.corrIDFn}
 	case namefn(AllowAutoTopicCreation):
 		return []any{cfg.allowAutoTopicCreation}
+	case namefn(AutoCreateTopic):
+		if cfg.autoCreateTopic == nil {
+			return []any{int32(0), int16(0), map[string]*string(nil)}
+		}
+		return []any{cfg.autoCreateTopic.partitions, cfg.autoCreateTopic.replicationFactor, cfg.autoCreateTopic.configs}
 	case namefn(BrokerMaxWriteBytes):
 		return []any{cfg.maxBrokerWriteBytes}
 	case namefn(BrokerMaxReadBytes):
@@ -272,10 +292,20 @@ func (cl *Client) OptValues(opt any) []any {
 		return []any{cfg.metadataMaxAge}
 	case namefn(MetadataMinAge):
 		return []any{cfg.metadataMinAge}
+	case namefn(MetadataMinImmediateAge):
+		return []any{cfg.metadataMinImmediateAge}
 	case namefn(SASL):
 		return []any{cfg.sasls}
 	case namefn(WithHooks):
 		return []any{cfg.hooks}
+	case namefn(EnableClientMetrics):
+		return []any{cfg.clientMetrics, cfg.metricsProviders}
+	case namefn(KeySerde):
+		return []any{cfg.keySerde}
+	case namefn(ValueSerde):
+		return []any{cfg.valueSerde}
+	case namefn(SkipSerdeErrors):
+		return []any{cfg.onSerdeErr}
 	case namefn(ConcurrentTransactionsBackoff):
 		return []any{cfg.txnBackoff}
 
@@ -295,10 +325,26 @@ func (cl *Client) OptValues(opt any) []any {
 		return []any{cfg.maxBufferedRecords}
 	case namefn(RecordPartitioner):
 		return []any{cfg.partitioner}
+	case namefn(ProduceToPartition):
+		return []any{cfg.pinnedPartitions}
+	case namefn(UnavailablePartitionFallback):
+		return []any{cfg.partitionFallback, cfg.partitionFallbackStrictKeys}
 	case namefn(ProduceRequestTimeout):
 		return []any{cfg.produceTimeout}
 	case namefn(RecordRetries):
 		return []any{cfg.recordRetries}
+	case namefn(ProduceRateLimit):
+		return []any{cfg.produceBytesRate, cfg.produceRecordsRate}
+	case namefn(ValidateTimestampType):
+		return []any{cfg.validateTimestampType, cfg.failLogAppendTimestamps}
+	case namefn(FailOversizedRecords):
+		return []any{cfg.failOversized, cfg.onOversized}
+	case namefn(ProduceCircuitBreaker):
+		return []any{cfg.breakerFailures, cfg.breakerCooldown}
+	case namefn(ProduceInterceptors):
+		return []any{cfg.interceptors}
+	case namefn(ProduceDedup):
+		return []any{cfg.dedupHeader, cfg.dedupSize, cfg.dedupTTL}
 	case namefn(UnknownTopicRetries):
 		return []any{cfg.maxUnknownFailures}
 	case namefn(StopProducerOnDataLossDetected):
@@ -329,10 +375,19 @@ func (cl *Client) OptValues(opt any) []any {
 		return []any{cfg.resetOffset}
 	case namefn(ConsumeTopics):
 		return []any{cfg.topics}
+	case namefn(ConsumeTopicsRegexp):
+		return []any{cfg.topics}
 	case namefn(DisableFetchSessions):
 		return []any{cfg.disableFetchSessions}
+	case namefn(DisableFetchSessionsFor):
+		nodeIDs := make([]int32, 0, len(cfg.disableFetchSessionsFor))
+		for id := range cfg.disableFetchSessionsFor {
+			nodeIDs = append(nodeIDs, id)
+		}
+		sort.Slice(nodeIDs, func(i, j int) bool { return nodeIDs[i] < nodeIDs[j] })
+		return []any{nodeIDs}
 	case namefn(FetchIsolationLevel):
-		return []any{cfg.isolationLevel}
+		return []any{int8(cfg.isolationLevel)}
 	case namefn(FetchMaxBytes):
 		return []any{int32(cfg.maxBytes)}
 	case namefn(FetchMaxPartitionBytes):
@@ -343,10 +398,16 @@ func (cl *Client) OptValues(opt any) []any {
 		return []any{cfg.minBytes}
 	case namefn(KeepControlRecords):
 		return []any{cfg.keepControl}
+	case namefn(KeepFetchBatchBoundaries):
+		return []any{cfg.keepBatches}
+	case namefn(MaxBufferedFetchBytes):
+		return []any{cfg.maxBufferedFetchBytes}
 	case namefn(MaxConcurrentFetches):
 		return []any{cfg.maxConcurrentFetches}
 	case namefn(Rack):
 		return []any{cfg.rack}
+	case namefn(ConsumeReplicaSelector):
+		return []any{cfg.replicaSelector}
 
 	case namefn(AdjustFetchOffsetsFn):
 		return []any{cfg.adjustOffsetsBeforeAssign}
@@ -366,8 +427,12 @@ func (cl *Client) OptValues(opt any) []any {
 		return []any{cfg.autocommitDisable}
 	case namefn(GreedyAutoCommit):
 		return []any{cfg.autocommitGreedy}
+	case namefn(GroupOffsetStore):
+		return []any{cfg.offsetStore}
 	case namefn(GroupProtocol):
 		return []any{cfg.protocol}
+	case namefn(EnableNextGenGroupProtocol):
+		return []any{cfg.nextGenGroup}
 	case namefn(HeartbeatInterval):
 		return []any{cfg.heartbeatInterval}
 	case namefn(InstanceID):
@@ -379,10 +444,14 @@ func (cl *Client) OptValues(opt any) []any {
 		return []any{cfg.onFetched}
 	case namefn(OnPartitionsAssigned):
 		return []any{cfg.onAssigned}
+	case namefn(OnPartitionsAssignedVeto):
+		return []any{cfg.onAssignedVeto}
 	case namefn(OnPartitionsLost):
 		return []any{cfg.onLost}
 	case namefn(OnPartitionsRevoked):
 		return []any{cfg.onRevoked}
+	case namefn(OnRevokeCommit):
+		return []any{cfg.onRevokeCommit}
 	case namefn(RebalanceTimeout):
 		return []any{cfg.rebalanceTimeout}
 	case namefn(RequireStableFetchOffsets):
@@ -412,12 +481,47 @@ func NewClient(opts ...Opt) (*Client, error) {
 		return nil, err
 	}
 
+	// The client metrics requests are newer than our default max
+	// versions; if the user opted in to client metrics, we allow them. We
+	// only raise the default versions: explicit MaxVersions that do not
+	// allow the requests are rejected in validation.
+	if cfg.clientMetrics && cfg.maxVersions != nil && !cfg.maxVersionsSet {
+		vs := new(kversion.Versions)
+		cfg.maxVersions.EachMaxKeyVersion(vs.SetMaxKeyVersion)
+		for _, key := range []int16{
+			int16(kmsg.GetTelemetrySubscriptions),
+			int16(kmsg.PushTelemetry),
+		} {
+			if !vs.HasKey(key) {
+				vs.SetMaxKeyVersion(key, 0)
+			}
+		}
+		cfg.maxVersions = vs
+	}
+
+	// Similarly, the next generation group protocol is newer than our
+	// default max versions and requires OffsetCommit v9. We only raise
+	// the default versions: explicit MaxVersions that do not allow the
+	// protocol are rejected in validation.
+	if cfg.nextGenGroup && cfg.maxVersions != nil && !cfg.maxVersionsSet {
+		vs := new(kversion.Versions)
+		cfg.maxVersions.EachMaxKeyVersion(vs.SetMaxKeyVersion)
+		if !vs.HasKey(int16(kmsg.ConsumerGroupHeartbeat)) {
+			vs.SetMaxKeyVersion(int16(kmsg.ConsumerGroupHeartbeat), 1)
+			if v, _ := vs.LookupMaxKeyVersion(int16(kmsg.OffsetCommit)); v < 9 {
+				vs.SetMaxKeyVersion(int16(kmsg.OffsetCommit), 9)
+			}
+		}
+		cfg.maxVersions = vs
+	}
+
 	if cfg.retryTimeout == nil {
 		cfg.retryTimeout = func(key int16) time.Duration {
 			switch key {
 			case ((*kmsg.JoinGroupRequest)(nil)).Key(),
 				((*kmsg.SyncGroupRequest)(nil)).Key(),
-				((*kmsg.HeartbeatRequest)(nil)).Key():
+				((*kmsg.HeartbeatRequest)(nil)).Key(),
+				((*kmsg.ConsumerGroupHeartbeatRequest)(nil)).Key():
 				return cfg.sessionTimeout
 			}
 			return 30 * time.Second
@@ -425,7 +529,7 @@ func NewClient(opts ...Opt) (*Client, error) {
 	}
 
 	if cfg.dialFn == nil {
-		dialer := &net.Dialer{Timeout: cfg.dialTimeout}
+		dialer := &net.Dialer{Timeout: cfg.dialTimeout, KeepAlive: cfg.dialKeepAlive}
 		cfg.dialFn = dialer.DialContext
 		if cfg.dialTLS != nil {
 			cfg.dialFn = func(ctx context.Context, network, host string) (net.Conn, error) {
@@ -437,10 +541,14 @@ func NewClient(opts ...Opt) (*Client, error) {
 					}
 					c.ServerName = server
 				}
-				return (&tls.Dialer{
-					NetDialer: dialer,
-					Config:    c,
-				}).DialContext(ctx, network, host)
+				// The handshake is performed when connecting to
+				// the broker, so that handshake results can be
+				// passed to HookBrokerTLSHandshake.
+				conn, err := dialer.DialContext(ctx, network, host)
+				if err != nil {
+					return nil, err
+				}
+				return tls.Client(conn, c), nil
 			}
 		}
 	}
@@ -466,8 +574,7 @@ func NewClient(opts ...Opt) (*Client, error) {
 
 		sinksAndSources: make(map[int32]sinkAndSource),
 
-		reqFormatter:  kmsg.NewRequestFormatter(),
-		connTimeouter: connTimeouter{def: cfg.requestTimeoutOverhead},
+		reqFormatter: kmsg.NewRequestFormatter(),
 
 		bufPool: newBufPool(),
 		prsPool: newPrsPool(),
@@ -483,6 +590,10 @@ func NewClient(opts ...Opt) (*Client, error) {
 		metadone:             make(chan struct{}),
 	}
 
+	if cfg.clientMetrics {
+		cl.metrics = newClientMetrics(cl)
+	}
+
 	// Before we start any goroutines below, we must notify any interested
 	// hooks of our existence.
 	cl.cfg.hooks.each(func(h Hook) {
@@ -494,6 +605,7 @@ func NewClient(opts ...Opt) (*Client, error) {
 	cl.producer.init(cl)
 	cl.consumer.init(cl)
 	cl.metawait.init()
+	cl.connTimeouter.init(&cl.cfg)
 
 	if cfg.id != nil {
 		cl.reqFormatter = kmsg.NewRequestFormatter(kmsg.FormatterClientID(*cfg.id))
@@ -507,6 +619,9 @@ func NewClient(opts ...Opt) (*Client, error) {
 	cl.seeds.Store(seedBrokers)
 	go cl.updateMetadataLoop()
 	go cl.reapConnectionsLoop()
+	if cl.metrics != nil {
+		go cl.metrics.pushLoop()
+	}
 
 	return cl, nil
 }
@@ -640,28 +755,51 @@ func parseBrokerAddr(addr string) (hostport, error) {
 }
 
 type connTimeouter struct {
-	def                  time.Duration
+	def                  time.Duration // base read timeout
+	write                time.Duration
+	fn                   func(kmsg.Request, time.Duration, time.Duration) (time.Duration, time.Duration)
 	joinMu               sync.Mutex
 	lastRebalanceTimeout time.Duration
 }
 
+func (c *connTimeouter) init(cfg *cfg) {
+	c.def = cfg.requestTimeoutOverhead
+	c.write = cfg.requestTimeoutOverhead
+	c.fn = cfg.connTimeoutsFn
+	if cfg.connReadTimeout > 0 {
+		c.def = cfg.connReadTimeout
+	}
+	if cfg.connWriteTimeout > 0 {
+		c.write = cfg.connWriteTimeout
+	}
+}
+
 func (c *connTimeouter) timeouts(req kmsg.Request) (r, w time.Duration) {
+	r, w = c.baseTimeouts(req)
+	if c.fn != nil {
+		r, w = c.fn(req, r, w)
+	}
+	return r, w
+}
+
+func (c *connTimeouter) baseTimeouts(req kmsg.Request) (r, w time.Duration) {
 	def := c.def
+	write := c.write
 	millis := func(m int32) time.Duration { return time.Duration(m) * time.Millisecond }
 	switch t := req.(type) {
 	default:
 		if timeoutRequest, ok := req.(kmsg.TimeoutRequest); ok {
 			timeoutMillis := timeoutRequest.Timeout()
-			return def + millis(timeoutMillis), def
+			return def + millis(timeoutMillis), write
 		}
-		return def, def
+		return def, write
 
 	case *produceRequest:
-		return def + millis(t.timeout), def
+		return def + millis(t.timeout), write
 	case *fetchRequest:
-		return def + millis(t.maxWait), def
+		return def + millis(t.maxWait), write
 	case *kmsg.FetchRequest:
-		return def + millis(t.MaxWaitMillis), def
+		return def + millis(t.MaxWaitMillis), write
 
 	// Join and sync can take a long time. Sync has no notion of
 	// timeouts, but since the flow of requests should be first
@@ -672,7 +810,7 @@ func (c *connTimeouter) timeouts(req kmsg.Request) (r, w time.Duration) {
 		c.lastRebalanceTimeout = millis(t.RebalanceTimeoutMillis)
 		c.joinMu.Unlock()
 
-		return def + millis(t.RebalanceTimeoutMillis), def
+		return def + millis(t.RebalanceTimeoutMillis), write
 	case *kmsg.SyncGroupRequest:
 		read := def
 		c.joinMu.Lock()
@@ -681,7 +819,7 @@ func (c *connTimeouter) timeouts(req kmsg.Request) (r, w time.Duration) {
 		}
 		c.joinMu.Unlock()
 
-		return read, def
+		return read, write
 	}
 }
 
@@ -961,6 +1099,13 @@ func (cl *Client) Close() {
 	wg.Wait()
 	sessCloseCancel()
 
+	// Before killing the client context, we stop pushing client metrics,
+	// which issues one final terminating push if we have a subscription.
+	if m := cl.metrics; m != nil {
+		m.cancel()
+		<-m.done
+	}
+
 	// Now we kill the client context and all brokers, ensuring all
 	// requests fail. This will finish all producer callbacks and
 	// stop the metadata loop.
@@ -1000,6 +1145,52 @@ func (cl *Client) Close() {
 	}
 }
 
+// CloseGracefully stops accepting new produces, flushes any buffered records,
+// commits pending offsets if group consuming with autocommitting enabled, and
+// then closes the client as with Close. Flushing and committing respect the
+// context: if the context is canceled or its deadline passes, this stops
+// waiting and closes immediately. Any record produced after this function is
+// called is failed with ErrClientClosed.
+//
+// This returns every buffered record that could not be produced (and that was
+// failed by this function) along with the flush or commit error, if any.
+// Records that failed before this function was called are not returned. The
+// promise for every returned record has already been called.
+//
+// The same caveats about BlockRebalanceOnPoll that apply to Close apply here.
+func (cl *Client) CloseGracefully(ctx context.Context) ([]*Record, error) {
+	p := &cl.producer
+	p.closing.Store(true)
+
+	// We only collect failed records after flushing, so that records that
+	// fail on their own while flushing (i.e., not from us closing) are
+	// not returned.
+	rerr := cl.Flush(ctx)
+	p.collectUnflushed.Store(true)
+
+	if c := &cl.consumer; c.g != nil && !cl.cfg.autocommitDisable && rerr == nil {
+		if cl.cfg.autocommitMarks {
+			rerr = cl.CommitMarkedOffsets(ctx)
+		} else {
+			rerr = cl.CommitUncommittedOffsets(ctx)
+		}
+	}
+
+	cl.Close()
+
+	// Close fails all buffered records, but the promises are finished
+	// asynchronously. We flush once more to wait for all promises to be
+	// called; nothing can be produced anymore, so this returns once
+	// everything has been failed.
+	cl.Flush(context.Background())
+
+	p.unflushedMu.Lock()
+	defer p.unflushedMu.Unlock()
+	unflushed := p.unflushed
+	p.unflushed = nil
+	return unflushed, rerr
+}
+
 // Request issues a request to Kafka, waiting for and returning the response.
 // If a retryable network error occurs, or if a retryable group / transaction
 // coordinator error occurs, the request is retried. All other errors are
@@ -1742,6 +1933,8 @@ func (cl *Client) handleCoordinatorReq(ctx context.Context, req kmsg.Request) Re
 		return cl.handleCoordinatorReqSimple(ctx, coordinatorTypeGroup, t.Group, req)
 	case *kmsg.OffsetDeleteRequest:
 		return cl.handleCoordinatorReqSimple(ctx, coordinatorTypeGroup, t.Group, req)
+	case *kmsg.ConsumerGroupHeartbeatRequest:
+		return cl.handleCoordinatorReqSimple(ctx, coordinatorTypeGroup, t.Group, req)
 	}
 }
 
@@ -1808,6 +2001,8 @@ func (cl *Client) handleReqWithCoordinator(
 			code = t.ErrorCode
 		case *kmsg.SyncGroupResponse:
 			code = t.ErrorCode
+		case *kmsg.ConsumerGroupHeartbeatResponse:
+			code = t.ErrorCode
 		}
 
 		// ListGroups, OffsetFetch, DeleteGroups, DescribeGroups, and
@@ -1860,6 +2055,201 @@ func (cl *Client) SeedBrokers() []*Broker {
 	return bs
 }
 
+// ClusterMetadata is a snapshot of cluster metadata as seen by the client,
+// returned from Client.Metadata.
+type ClusterMetadata struct {
+	// Brokers contains all brokers the client knows of, sorted by node
+	// ID. This does not include seed brokers.
+	Brokers []BrokerMetadata
+
+	// Controller is the ID of the controller broker, or -1 if it is not
+	// known.
+	Controller int32
+
+	// Topics contains metadata for each requested topic (or every topic,
+	// if no topics were requested).
+	Topics map[string]TopicMetadata
+}
+
+// TopicMetadata is metadata for a single topic.
+type TopicMetadata struct {
+	// Topic is the topic name.
+	Topic string
+
+	// ID is the topic ID, if the broker supports topic IDs.
+	ID [16]byte
+
+	// IsInternal is whether the topic is an internal Kafka topic.
+	IsInternal bool
+
+	// Err is any error loading the topic, e.g.
+	// kerr.UnknownTopicOrPartition. If non-nil, Partitions is likely
+	// empty.
+	Err error
+
+	// Partitions contains metadata for every partition in the topic,
+	// sorted by partition number.
+	Partitions []PartitionMetadata
+}
+
+// PartitionMetadata is metadata for a single partition.
+type PartitionMetadata struct {
+	// Partition is the partition number.
+	Partition int32
+
+	// Leader is the broker leading this partition, or -1 if there is no
+	// leader.
+	Leader int32
+
+	// LeaderEpoch is the leader epoch of the partition, or -1 if the
+	// broker does not support leader epochs.
+	LeaderEpoch int32
+
+	// Replicas, ISR, and OfflineReplicas are the replica sets for this
+	// partition.
+	Replicas        []int32
+	ISR             []int32
+	OfflineReplicas []int32
+
+	// Err is any error for this partition, e.g. kerr.LeaderNotAvailable.
+	Err error
+}
+
+// Metadata returns a snapshot of the cluster's brokers and the metadata for
+// the requested topics. If no topics are requested, this loads metadata for
+// all topics.
+//
+// When topics are requested, this uses the same short lived cache that the
+// client uses for sharded admin requests (ListOffsets, etc.): topics loaded
+// within MetadataMinAge are returned from the cache, and only uncached topics
+// are requested from the cluster. Requesting all topics always issues a
+// metadata request. Every metadata request issued here also updates the
+// client's known brokers and controller.
+//
+// This does not modify which topics the client produces to or consumes.
+// Topics that do not exist are returned with kerr.UnknownTopicOrPartition,
+// unless AllowAutoTopicCreation is enabled, in which case the broker may
+// create them.
+func (cl *Client) Metadata(ctx context.Context, topics ...string) (ClusterMetadata, error) {
+	m := ClusterMetadata{Topics: make(map[string]TopicMetadata)}
+
+	if len(topics) == 0 {
+		_, meta, err := cl.fetchMetadataForTopics(ctx, true, nil)
+		if err != nil {
+			return m, err
+		}
+		for _, t := range meta.Topics {
+			if t.Topic == nil {
+				continue
+			}
+			m.Topics[*t.Topic] = newTopicMetadata(t)
+		}
+	} else {
+		mapped, err := cl.fetchMappedMetadata(ctx, append([]string(nil), topics...), true)
+		if err != nil {
+			return m, err
+		}
+		for _, topic := range topics {
+			t, exists := mapped[topic]
+			if !exists {
+				m.Topics[topic] = TopicMetadata{Topic: topic, Err: kerr.UnknownTopicOrPartition}
+				continue
+			}
+			m.Topics[topic] = newTopicMetadata(t.t)
+		}
+	}
+
+	cl.brokersMu.RLock()
+	for _, b := range cl.brokers {
+		m.Brokers = append(m.Brokers, b.meta)
+	}
+	cl.brokersMu.RUnlock()
+
+	cl.controllerIDMu.Lock()
+	m.Controller = cl.controllerID
+	cl.controllerIDMu.Unlock()
+	return m, nil
+}
+
+func newTopicMetadata(t kmsg.MetadataResponseTopic) TopicMetadata {
+	tm := TopicMetadata{
+		ID:         t.TopicID,
+		IsInternal: t.IsInternal,
+		Err:        kerr.ErrorForCode(t.ErrorCode),
+	}
+	if t.Topic != nil {
+		tm.Topic = *t.Topic
+	}
+	for _, p := range t.Partitions {
+		tm.Partitions = append(tm.Partitions, PartitionMetadata{
+			Partition:       p.Partition,
+			Leader:          p.Leader,
+			LeaderEpoch:     p.LeaderEpoch,
+			Replicas:        p.Replicas,
+			ISR:             p.ISR,
+			OfflineReplicas: p.OfflineReplicas,
+			Err:             kerr.ErrorForCode(p.ErrorCode),
+		})
+	}
+	sort.Slice(tm.Partitions, func(i, j int) bool { return tm.Partitions[i].Partition < tm.Partitions[j].Partition })
+	return tm
+}
+
+// ControllerBroker returns a handle to the cluster's controller broker, for
+// directly issuing requests that must go to the controller. The controller is
+// loaded from metadata if it is not yet known, and the load is retried per the
+// client's retry options.
+//
+// Note that the controller can change at any time. If a request to the
+// returned broker fails with NOT_CONTROLLER, call this again.
+func (cl *Client) ControllerBroker(ctx context.Context) (*Broker, error) {
+	return cl.loadBrokerRetrying(ctx, func() (*broker, error) { return cl.controller(ctx) })
+}
+
+// CoordinatorType is the type of a coordinator to look up in
+// CoordinatorBroker.
+type CoordinatorType int8
+
+const (
+	// CoordinatorTypeGroup looks up the coordinator for a group.
+	CoordinatorTypeGroup CoordinatorType = CoordinatorType(coordinatorTypeGroup)
+	// CoordinatorTypeTxn looks up the coordinator for a transactional ID.
+	CoordinatorTypeTxn CoordinatorType = CoordinatorType(coordinatorTypeTxn)
+)
+
+// CoordinatorBroker returns a handle to the coordinator broker for the given
+// group or transactional ID, for directly issuing requests that must go to
+// the coordinator. This uses the client's internal coordinator cache: if the
+// coordinator was already loaded (for example, because the client is
+// consuming in the group), no FindCoordinator request is issued. Loading is
+// retried per the client's retry options.
+//
+// Note that the coordinator can change at any time. If a request to the
+// returned broker fails with NOT_COORDINATOR, call this again.
+func (cl *Client) CoordinatorBroker(ctx context.Context, typ CoordinatorType, key string) (*Broker, error) {
+	return cl.loadBrokerRetrying(ctx, func() (*broker, error) { return cl.loadCoordinator(ctx, int8(typ), key) })
+}
+
+// loadBrokerRetrying loads a broker with the given function, retrying
+// retryable errors with the client's backoff up to the client's retries.
+func (cl *Client) loadBrokerRetrying(ctx context.Context, fn func() (*broker, error)) (*Broker, error) {
+	for tries := 1; ; tries++ {
+		br, err := fn()
+		if err == nil {
+			return &Broker{id: br.meta.NodeID, cl: cl}, nil
+		}
+		var (
+			ec *errUnknownController
+			eu *errUnknownCoordinator
+		)
+		retry := cl.shouldRetry(tries, err) ||
+			(errors.As(err, &ec) || errors.As(err, &eu)) && int64(tries) < cl.cfg.retries
+		if !retry || !cl.waitTries(ctx, cl.cfg.retryBackoff(tries)) {
+			return nil, err
+		}
+	}
+}
+
 // UpdateSeedBrokers updates the client's list of seed brokers. Over the course
 // of a long period of time, your might replace all brokers that you originally
 // specified as seeds. This command allows you to replace the client's list of
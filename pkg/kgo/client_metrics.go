@@ -0,0 +1,416 @@
+package kgo
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"math"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/burningass23/franz-go/pkg/kerr"
+	"github.com/burningass23/franz-go/pkg/kmsg"
+)
+
+// MetricType is the type of a client metric, following the OpenTelemetry
+// metric data model.
+type MetricType uint8
+
+const (
+	// MetricTypeSum is a monotonically increasing counter. Sums should
+	// be reported cumulatively since the client started; if a broker
+	// requests delta temporality, the client converts sums to the delta
+	// since the prior push.
+	MetricTypeSum MetricType = iota
+
+	// MetricTypeGauge is a point in time value.
+	MetricTypeGauge
+)
+
+// Metric is a client metric to push to brokers with KIP-714 client metrics.
+//
+// Metric names should follow OpenTelemetry naming conventions: lowercase and
+// dot separated, prefixed with a namespace. The standard Kafka client metrics
+// are prefixed with "org.apache.kafka.".
+type Metric struct {
+	// Name is the name of the metric.
+	Name string
+
+	// Type is the type of the metric.
+	Type MetricType
+
+	// ValueInt is the value of the metric if the metric is an integer.
+	ValueInt int64
+
+	// ValueFloat is the value of the metric if the metric is a float. If
+	// ValueFloat is non-zero, it is used rather than ValueInt.
+	ValueFloat float64
+
+	// Attrs are optional attributes for the metric (OpenTelemetry data
+	// point attributes).
+	Attrs map[string]string
+}
+
+// MetricsProvider provides additional metrics to push alongside the client's
+// own metrics when client metrics are enabled. This can be implemented by a
+// bridge from an OpenTelemetry metric reader, or from any other metrics
+// system.
+type MetricsProvider interface {
+	// ClientMetrics returns the current values of all metrics to push.
+	// This is called once per push interval, and only metrics whose
+	// names match a prefix requested by the broker are pushed.
+	ClientMetrics() []Metric
+}
+
+// clientMetrics tracks the client's internal metrics and runs the KIP-714
+// subscription and push loop. This is only created if client metrics are
+// enabled. Brokers call into it directly to track connections and requests,
+// rather than through the user's hooks.
+type clientMetrics struct {
+	cl *Client
+
+	ctx    context.Context
+	cancel func()
+	done   chan struct{}
+
+	start      time.Time
+	instanceID [16]byte
+
+	connCreations int64 // atomic
+	connErrors    int64 // atomic
+	reqSuccess    int64 // atomic
+	reqErrors     int64 // atomic
+
+	prior map[string]int64 // for delta temporality, prior sums per metric & attrs
+}
+
+func newClientMetrics(cl *Client) *clientMetrics {
+	ctx, cancel := context.WithCancel(cl.ctx)
+	return &clientMetrics{
+		cl:     cl,
+		ctx:    ctx,
+		cancel: cancel,
+		done:   make(chan struct{}),
+		start:  time.Now(),
+		prior:  make(map[string]int64),
+	}
+}
+
+func (m *clientMetrics) observeConnect(err error) {
+	if err != nil {
+		atomic.AddInt64(&m.connErrors, 1)
+	} else {
+		atomic.AddInt64(&m.connCreations, 1)
+	}
+}
+
+func (m *clientMetrics) observeRequest(err error) {
+	if err != nil {
+		atomic.AddInt64(&m.reqErrors, 1)
+	} else {
+		atomic.AddInt64(&m.reqSuccess, 1)
+	}
+}
+
+// collect returns the client's internal metrics followed by all metrics from
+// user providers.
+func (m *clientMetrics) collect() []Metric {
+	ms := []Metric{
+		{Name: "org.apache.kafka.client.connection.creations", Type: MetricTypeSum, ValueInt: atomic.LoadInt64(&m.connCreations)},
+		{Name: "org.apache.kafka.client.connection.errors", Type: MetricTypeSum, ValueInt: atomic.LoadInt64(&m.connErrors)},
+		{Name: "org.apache.kafka.client.request.success", Type: MetricTypeSum, ValueInt: atomic.LoadInt64(&m.reqSuccess)},
+		{Name: "org.apache.kafka.client.request.errors", Type: MetricTypeSum, ValueInt: atomic.LoadInt64(&m.reqErrors)},
+		{Name: "org.apache.kafka.producer.record.queue.count", Type: MetricTypeGauge, ValueInt: m.cl.BufferedProduceRecords()},
+		{Name: "org.apache.kafka.consumer.record.queue.count", Type: MetricTypeGauge, ValueInt: m.cl.BufferedFetchRecords()},
+	}
+	for _, p := range m.cl.cfg.metricsProviders {
+		ms = append(ms, p.ClientMetrics()...)
+	}
+	return ms
+}
+
+// pushLoop requests a telemetry subscription and then pushes metrics every
+// push interval until the client is closed. If the subscription changes or
+// is unknown, this requests a new subscription. If brokers do not support
+// client metrics, this quits.
+func (m *clientMetrics) pushLoop() {
+	defer close(m.done)
+
+	var (
+		cl       = m.cl
+		sub      *kmsg.GetTelemetrySubscriptionsResponse
+		interval time.Duration
+		wait     time.Duration
+		tries    int
+		lastPush = m.start
+	)
+
+	for {
+		timer := time.NewTimer(wait)
+		select {
+		case <-m.ctx.Done():
+			timer.Stop()
+			if sub != nil {
+				m.pushTerminating(sub, lastPush)
+			}
+			return
+		case <-timer.C:
+		}
+
+		if sub == nil {
+			req := kmsg.NewPtrGetTelemetrySubscriptionsRequest()
+			req.ClientInstanceID = m.instanceID
+			resp, err := req.RequestWith(m.ctx, cl)
+			if err == nil {
+				err = kerr.ErrorForCode(resp.ErrorCode)
+			}
+			if err != nil {
+				if errors.Is(err, errBrokerTooOld) || errors.Is(err, errUnknownRequestKey) {
+					cl.cfg.logger.Log(LogLevelInfo, "brokers do not support client metrics, no longer attempting to push client metrics")
+					return
+				}
+				if m.ctx.Err() == nil {
+					tries++
+					wait = cl.cfg.retryBackoff(tries)
+					cl.cfg.logger.Log(LogLevelWarn, "unable to get client metrics subscription, retrying", "err", err, "backoff", wait)
+				}
+				continue
+			}
+			tries = 0
+
+			m.instanceID = resp.ClientInstanceID
+			interval = time.Duration(resp.PushIntervalMillis) * time.Millisecond
+			if interval <= 0 {
+				interval = 5 * time.Minute // KIP-714 default
+			}
+			wait = interval
+			if len(resp.RequestedMetrics) == 0 {
+				cl.cfg.logger.Log(LogLevelDebug, "brokers requested no client metrics, checking again after the push interval", "interval", interval)
+				continue
+			}
+			sub = resp
+
+			// KIP-714 recommends jittering the first push between
+			// 0.5x and 1.5x the push interval to avoid all clients
+			// pushing at once.
+			wait = time.Duration((0.5 + cl.rng()) * float64(interval))
+			cl.cfg.logger.Log(LogLevelInfo, "received client metrics subscription",
+				"subscription_id", sub.SubscriptionID,
+				"interval", interval,
+				"requested_metrics", sub.RequestedMetrics,
+			)
+			continue
+		}
+
+		now := time.Now()
+		resp, err := m.push(m.ctx, sub, false, lastPush, now)
+		wait = interval
+		switch {
+		case err == nil:
+			lastPush = now
+
+		case m.ctx.Err() != nil:
+			// We are closing; the terminating push is issued at
+			// the top of the loop.
+
+		case errors.Is(err, kerr.UnknownSubscriptionID),
+			errors.Is(err, kerr.UnsupportedCompressionType):
+			cl.cfg.logger.Log(LogLevelInfo, "client metrics subscription is outdated, requesting a new subscription", "err", err)
+			sub, wait = nil, 0
+
+		case errors.Is(err, kerr.InvalidRequest),
+			errors.Is(err, kerr.InvalidRecord):
+			cl.cfg.logger.Log(LogLevelError, "brokers rejected client metrics as invalid, no longer pushing client metrics", "err", err)
+			return
+
+		default:
+			if resp != nil && resp.ThrottleMillis > 0 {
+				if throttle := time.Duration(resp.ThrottleMillis) * time.Millisecond; throttle > wait {
+					wait = throttle
+				}
+			}
+			cl.cfg.logger.Log(LogLevelWarn, "unable to push client metrics, will push again after the push interval", "err", err, "wait", wait)
+		}
+	}
+}
+
+// pushTerminating issues one final push, letting the broker know this client
+// instance is going away. This is best effort and bounded to one second.
+func (m *clientMetrics) pushTerminating(sub *kmsg.GetTelemetrySubscriptionsResponse, lastPush time.Time) {
+	ctx, cancel := context.WithTimeout(m.cl.ctx, time.Second)
+	defer cancel()
+	if _, err := m.push(ctx, sub, true, lastPush, time.Now()); err != nil {
+		m.cl.cfg.logger.Log(LogLevelDebug, "unable to push final client metrics", "err", err)
+	}
+}
+
+func (m *clientMetrics) push(
+	ctx context.Context,
+	sub *kmsg.GetTelemetrySubscriptionsResponse,
+	terminating bool,
+	lastPush time.Time,
+	now time.Time,
+) (*kmsg.PushTelemetryResponse, error) {
+	payload, pending := m.encode(sub, lastPush, now)
+	if maxBytes := sub.TelemetryMaxBytes; maxBytes > 0 && len(payload) > int(maxBytes) {
+		return nil, kerr.TelemetryTooLarge
+	}
+
+	req := kmsg.NewPtrPushTelemetryRequest()
+	req.ClientInstanceID = m.instanceID
+	req.SubscriptionID = sub.SubscriptionID
+	req.Terminating = terminating
+	req.Metrics = payload
+	resp, err := req.RequestWith(ctx, m.cl)
+	if err == nil {
+		err = kerr.ErrorForCode(resp.ErrorCode)
+	}
+	if err != nil {
+		return resp, err
+	}
+
+	// Only once the broker has accepted our metrics do we advance our
+	// delta baseline; if the push failed, the next push must include
+	// what this push would have.
+	for k, v := range pending {
+		m.prior[k] = v
+	}
+	return resp, nil
+}
+
+// encode returns all requested metrics encoded as an OpenTelemetry
+// MetricsData protobuf. We only need a small subset of the OTLP schema, so we
+// hand encode rather than depend on a protobuf library.
+//
+// For delta temporality, this also returns the sums to use as the next
+// push's baseline; the caller must only save them if the push succeeds.
+func (m *clientMetrics) encode(sub *kmsg.GetTelemetrySubscriptionsResponse, lastPush, now time.Time) ([]byte, map[string]int64) {
+	var (
+		start    = uint64(m.start.UnixNano())
+		tsNow    = uint64(now.UnixNano())
+		metrics  []byte
+		requests = sub.RequestedMetrics
+		pending  = make(map[string]int64)
+	)
+	if sub.DeltaTemporality {
+		start = uint64(lastPush.UnixNano())
+	}
+
+	for _, metric := range m.collect() {
+		var requested bool
+		for _, prefix := range requests {
+			if strings.HasPrefix(metric.Name, prefix) {
+				requested = true
+				break
+			}
+		}
+		if !requested {
+			continue
+		}
+
+		if metric.Type == MetricTypeSum && sub.DeltaTemporality && metric.ValueFloat == 0 {
+			key := metricKey(metric)
+			pending[key] = metric.ValueInt
+			metric.ValueInt -= m.prior[key]
+		}
+
+		var point []byte
+		for _, k := range sortedAttrKeys(metric.Attrs) {
+			var anyv []byte
+			anyv = pbAppendString(anyv, 1, metric.Attrs[k]) // AnyValue.string_value
+			var kv []byte
+			kv = pbAppendString(kv, 1, k)   // KeyValue.key
+			kv = pbAppendBytes(kv, 2, anyv) // KeyValue.value
+			point = pbAppendBytes(point, 7, kv)
+		}
+		point = pbAppendFixed64(point, 2, start) // start_time_unix_nano
+		point = pbAppendFixed64(point, 3, tsNow) // time_unix_nano
+		if metric.ValueFloat != 0 {
+			point = pbAppendFixed64(point, 4, math.Float64bits(metric.ValueFloat)) // as_double
+		} else {
+			point = pbAppendFixed64(point, 6, uint64(metric.ValueInt)) // as_int
+		}
+
+		var data []byte
+		data = pbAppendBytes(data, 1, point) // data_points
+		var metricb []byte
+		metricb = pbAppendString(metricb, 1, metric.Name)
+		switch metric.Type {
+		case MetricTypeSum:
+			temporality := uint64(2) // cumulative
+			if sub.DeltaTemporality {
+				temporality = 1 // delta
+			}
+			data = pbAppendVarint(data, 2, temporality) // aggregation_temporality
+			data = pbAppendVarint(data, 3, 1)           // is_monotonic
+			metricb = pbAppendBytes(metricb, 7, data)
+		default:
+			metricb = pbAppendBytes(metricb, 5, data)
+		}
+		metrics = pbAppendBytes(metrics, 2, metricb) // ScopeMetrics.metrics
+	}
+
+	var scope []byte
+	scope = pbAppendString(scope, 1, "kgo") // InstrumentationScope.name
+	scopeMetrics := pbAppendBytes(nil, 1, scope)
+	scopeMetrics = append(scopeMetrics, metrics...)
+
+	resourceMetrics := pbAppendBytes(nil, 2, scopeMetrics) // ResourceMetrics.scope_metrics
+	return pbAppendBytes(nil, 1, resourceMetrics), pending // MetricsData.resource_metrics
+}
+
+func metricKey(m Metric) string {
+	var sb strings.Builder
+	sb.WriteString(m.Name)
+	for _, k := range sortedAttrKeys(m.Attrs) {
+		sb.WriteByte(0)
+		sb.WriteString(k)
+		sb.WriteByte(0)
+		sb.WriteString(m.Attrs[k])
+	}
+	return sb.String()
+}
+
+func sortedAttrKeys(attrs map[string]string) []string {
+	if len(attrs) == 0 {
+		return nil
+	}
+	keys := make([]string, 0, len(attrs))
+	for k := range attrs {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func pbAppendUvarint(dst []byte, v uint64) []byte {
+	var buf [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(buf[:], v)
+	return append(dst, buf[:n]...)
+}
+
+func pbAppendVarint(dst []byte, field int, v uint64) []byte {
+	dst = pbAppendUvarint(dst, uint64(field)<<3) // wire type 0
+	return pbAppendUvarint(dst, v)
+}
+
+func pbAppendFixed64(dst []byte, field int, v uint64) []byte {
+	dst = pbAppendUvarint(dst, uint64(field)<<3|1)
+	var buf [8]byte
+	binary.LittleEndian.PutUint64(buf[:], v)
+	return append(dst, buf[:]...)
+}
+
+func pbAppendBytes(dst []byte, field int, b []byte) []byte {
+	dst = pbAppendUvarint(dst, uint64(field)<<3|2)
+	dst = pbAppendUvarint(dst, uint64(len(b)))
+	return append(dst, b...)
+}
+
+func pbAppendString(dst []byte, field int, s string) []byte {
+	dst = pbAppendUvarint(dst, uint64(field)<<3|2)
+	dst = pbAppendUvarint(dst, uint64(len(s)))
+	return append(dst, s...)
+}
@@ -0,0 +1,120 @@
+package kgo
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/burningass23/franz-go/pkg/kmsg"
+	"github.com/burningass23/franz-go/pkg/kversion"
+)
+
+// Delta temporality sums must only advance their baseline once a push
+// succeeds; a failed push must not lose the delta it would have reported.
+func TestClientMetricsDeltaOnlyAfterPush(t *testing.T) {
+	t.Parallel()
+
+	cl, err := NewClient(getSeedBrokers())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cl.Close()
+
+	m := newClientMetrics(cl)
+	defer m.cancel()
+
+	const name = "org.apache.kafka.client.request.success"
+	sub := &kmsg.GetTelemetrySubscriptionsResponse{
+		DeltaTemporality: true,
+		RequestedMetrics: []string{name},
+	}
+	key := metricKey(Metric{Name: name})
+
+	atomic.StoreInt64(&m.reqSuccess, 5)
+	now := time.Now()
+	if _, pending := m.encode(sub, now, now); pending[key] != 5 || len(m.prior) != 0 {
+		t.Fatalf("got pending %v, prior %v; exp pending 5 and no prior", pending, m.prior)
+	}
+
+	// Our test brokers do not support client metrics, so pushing fails.
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if _, err := m.push(ctx, sub, false, now, now); err == nil {
+		t.Fatal("expected push to fail")
+	}
+	if len(m.prior) != 0 {
+		t.Fatalf("prior was saved after a failed push: %v", m.prior)
+	}
+
+	atomic.StoreInt64(&m.reqSuccess, 8)
+	if _, pending := m.encode(sub, now, now); pending[key] != 8 {
+		t.Errorf("got pending %v != exp 8", pending[key])
+	}
+}
+
+func TestClientMetricsEncodeFiltersRequested(t *testing.T) {
+	t.Parallel()
+
+	cl, err := NewClient(getSeedBrokers())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cl.Close()
+
+	m := newClientMetrics(cl)
+	defer m.cancel()
+
+	now := time.Now()
+	none, _ := m.encode(&kmsg.GetTelemetrySubscriptionsResponse{RequestedMetrics: []string{"unknown."}}, now, now)
+	some, pending := m.encode(&kmsg.GetTelemetrySubscriptionsResponse{RequestedMetrics: []string{"org.apache.kafka.client."}}, now, now)
+	if len(some) <= len(none) {
+		t.Errorf("encoding requested metrics (%d bytes) is not larger than encoding none (%d bytes)", len(some), len(none))
+	}
+	if len(pending) != 0 {
+		t.Errorf("got pending %v with cumulative temporality, exp none", pending)
+	}
+}
+
+// Client metrics requests are added to the default versions, but explicit
+// MaxVersions must allow them.
+func TestClientMetricsMaxVersions(t *testing.T) {
+	with := func(k kmsg.Key, v int16) *kversion.Versions {
+		vs := kversion.Stable()
+		vs.SetMaxKeyVersion(int16(kmsg.GetTelemetrySubscriptions), 0)
+		vs.SetMaxKeyVersion(int16(kmsg.PushTelemetry), 0)
+		vs.SetMaxKeyVersion(int16(k), v)
+		return vs
+	}
+	for _, test := range []struct {
+		name   string
+		vs     *kversion.Versions
+		expErr bool
+		expVs  bool
+	}{
+		{"default", nil, false, true},
+		{"telemetry allowed", with(kmsg.PushTelemetry, 0), false, true},
+		{"stable", kversion.Stable(), true, false},
+		{"no push telemetry", with(kmsg.PushTelemetry, -1), true, false},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			opts := []Opt{EnableClientMetrics()}
+			if test.vs != nil {
+				opts = append(opts, MaxVersions(test.vs))
+			}
+			cl, err := NewClient(opts...)
+			if gotErr := err != nil; gotErr != test.expErr {
+				t.Fatalf("got err %v, exp err? %v", err, test.expErr)
+			}
+			if err != nil {
+				return
+			}
+			defer cl.Close()
+			for _, key := range []kmsg.Key{kmsg.GetTelemetrySubscriptions, kmsg.PushTelemetry} {
+				if got := cl.cfg.maxVersions.HasKey(int16(key)); got != test.expVs {
+					t.Errorf("max versions has %s: got %v, exp %v", key.Name(), got, test.expVs)
+				}
+			}
+		})
+	}
+}
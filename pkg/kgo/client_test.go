@@ -2,12 +2,16 @@ package kgo
 
 import (
 	"context"
+	"errors"
 	"reflect"
 	"strconv"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/burningass23/franz-go/pkg/kerr"
 	"github.com/burningass23/franz-go/pkg/kmsg"
+	"github.com/burningass23/franz-go/pkg/kversion"
 )
 
 func TestMaxVersions(t *testing.T) {
@@ -19,6 +23,43 @@ func TestMaxVersions(t *testing.T) {
 	}
 }
 
+func TestNextGenGroupMaxVersions(t *testing.T) {
+	// Stable versions plus the next generation group protocol, with one
+	// key's max version then overridden.
+	with := func(k kmsg.Key, v int16) *kversion.Versions {
+		vs := kversion.Stable()
+		vs.SetMaxKeyVersion(int16(kmsg.ConsumerGroupHeartbeat), 1)
+		vs.SetMaxKeyVersion(int16(kmsg.OffsetCommit), 9)
+		vs.SetMaxKeyVersion(int16(k), v)
+		return vs
+	}
+	for _, test := range []struct {
+		name   string
+		vs     *kversion.Versions
+		expErr bool
+	}{
+		{"consumer group heartbeat v0", with(kmsg.ConsumerGroupHeartbeat, 0), false},
+		{"stable", kversion.Stable(), true},
+		{"no consumer group heartbeat", with(kmsg.ConsumerGroupHeartbeat, -1), true},
+		{"offset commit v8", with(kmsg.OffsetCommit, 8), true},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			cl, err := NewClient(
+				MaxVersions(test.vs),
+				ConsumerGroup("g"),
+				ConsumeTopics("t"),
+				EnableNextGenGroupProtocol(),
+			)
+			if err == nil {
+				cl.Close()
+			}
+			if gotErr := err != nil; gotErr != test.expErr {
+				t.Errorf("got err %v, exp err? %v", err, test.expErr)
+			}
+		})
+	}
+}
+
 func TestParseBrokerAddr(t *testing.T) {
 	tests := []struct {
 		name     string
@@ -109,6 +150,39 @@ func TestParseBrokerAddrErrors(t *testing.T) {
 	}
 }
 
+func TestValidateInstanceID(t *testing.T) {
+	for _, test := range []struct {
+		id     string
+		expErr bool
+	}{
+		{"consumer-0", false},
+		{"host_1.example", false},
+		{"", true},
+		{".", true},
+		{"..", true},
+		{"has space", true},
+		{"slash/id", true},
+		{strings.Repeat("a", 249), false},
+		{strings.Repeat("a", 250), true},
+	} {
+		err := validateInstanceID(test.id)
+		if gotErr := err != nil; gotErr != test.expErr {
+			t.Errorf("%q: got err %v, exp err? %v", test.id, err, test.expErr)
+		}
+	}
+
+	// An instance ID without a group is ignored, as before, but an
+	// invalid instance ID is always an error.
+	if cl, err := NewClient(InstanceID("id")); err != nil {
+		t.Errorf("instance ID without group: unexpected err %v", err)
+	} else {
+		cl.Close()
+	}
+	if _, err := NewClient(InstanceID("bad id")); err == nil {
+		t.Error("invalid instance ID: expected err")
+	}
+}
+
 func TestUnknownGroupOffsetFetchPinned(t *testing.T) {
 	req := kmsg.NewOffsetFetchRequest()
 	req.Group = "unknown-" + strconv.FormatInt(time.Now().UnixNano(), 10)
@@ -216,3 +290,53 @@ type intSliceHook []int
 func (*intSliceHook) OnNewClient(*Client) {
 	// ignore
 }
+
+func TestClientMetadata(t *testing.T) {
+	t.Parallel()
+
+	topic, cleanup := tmpTopicPartitions(t, 3)
+	defer cleanup()
+
+	cl, _ := NewClient(getSeedBrokers())
+	defer cl.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	missing := randsha()
+	m, err := cl.Metadata(ctx, topic, missing)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(m.Brokers) == 0 || m.Controller < 0 {
+		t.Errorf("got %d brokers, controller %d; exp brokers and a controller", len(m.Brokers), m.Controller)
+	}
+	for i := 1; i < len(m.Brokers); i++ {
+		if m.Brokers[i-1].NodeID >= m.Brokers[i].NodeID {
+			t.Errorf("brokers not sorted by node ID: %v", m.Brokers)
+		}
+	}
+	if len(m.Topics) != 2 {
+		t.Errorf("got %d topics, exp 2", len(m.Topics))
+	}
+	tm := m.Topics[topic]
+	if tm.Topic != topic || tm.Err != nil || len(tm.Partitions) != 3 {
+		t.Fatalf("got topic %+v, exp 3 partitions and no error", tm)
+	}
+	for i, p := range tm.Partitions {
+		if p.Partition != int32(i) || p.Leader < 0 || p.Err != nil || len(p.Replicas) == 0 {
+			t.Errorf("got partition %+v at index %d", p, i)
+		}
+	}
+	if tm := m.Topics[missing]; tm.Topic != missing || !errors.Is(tm.Err, kerr.UnknownTopicOrPartition) {
+		t.Errorf("got missing topic %+v, exp UnknownTopicOrPartition", tm)
+	}
+
+	all, err := cl.Metadata(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tm := all.Topics[topic]; len(tm.Partitions) != 3 {
+		t.Errorf("all topics: got %+v for %s, exp 3 partitions", tm, topic)
+	}
+}
@@ -6,11 +6,9 @@ import (
 	"errors"
 	"fmt"
 	"math"
-	"math/rand"
 	"net"
 	"regexp"
 	"runtime/debug"
-	"sync"
 	"time"
 
 	"github.com/burningass23/franz-go/pkg/kmsg"
@@ -73,33 +71,51 @@ type cfg struct {
 	dialTimeout            time.Duration
 	dialTLS                *tls.Config
 	requestTimeoutOverhead time.Duration
+	connReadTimeout        time.Duration
+	connWriteTimeout       time.Duration
+	connTimeoutsFn         func(req kmsg.Request, read, write time.Duration) (time.Duration, time.Duration)
+	dialKeepAlive          time.Duration
 	connIdleTimeout        time.Duration
+	maxInflightPerBroker   int
 
 	softwareName    string // KIP-511
 	softwareVersion string // KIP-511
 
 	logger Logger
 
-	seedBrokers []string
-	maxVersions *kversion.Versions
-	minVersions *kversion.Versions
+	seedBrokers    []string
+	maxVersions    *kversion.Versions
+	maxVersionsSet bool // if the user used MaxVersions
+	minVersions    *kversion.Versions
 
 	retryBackoff func(int) time.Duration
 	retries      int64
 	retryTimeout func(int16) time.Duration
 
+	corrIDFn func(BrokerMetadata, kmsg.Request) int32
+
 	maxBrokerWriteBytes int32
 	maxBrokerReadBytes  int32
 
 	allowAutoTopicCreation bool
 
-	metadataMaxAge time.Duration
-	metadataMinAge time.Duration
+	metadataMaxAge          time.Duration
+	metadataMinAge          time.Duration
+	metadataMinImmediateAge time.Duration
 
 	sasls []sasl.Mechanism
 
 	hooks hooks
 
+	clientMetrics    bool // KIP-714
+	metricsProviders []MetricsProvider
+
+	keySerde   Serde
+	valueSerde Serde
+
+	skipSerdeErrs bool          // SkipSerdeErrors
+	onSerdeErr    func(*Record) // optional, called for skipped records
+
 	//////////////////////
 	// PRODUCER SECTION //
 	//////////////////////
@@ -111,6 +127,24 @@ type cfg struct {
 	maxProduceInflight int                // if idempotency is disabled, we allow a configurable max inflight
 	compression        []CompressionCodec // order of preference
 
+	produceBytesRate   int // ProduceRateLimit, 0 is unlimited
+	produceRecordsRate int
+
+	interceptors []func(*Record) bool // ProduceInterceptors
+
+	dedupHeader string // ProduceDedup, empty disables deduplication
+	dedupSize   int
+	dedupTTL    time.Duration
+
+	validateTimestampType   bool // ValidateTimestampType
+	failLogAppendTimestamps bool
+
+	failOversized bool // FailOversizedRecords
+	onOversized   func(*Record, *ErrRecordTooLarge)
+
+	breakerFailures int // ProduceCircuitBreaker, 0 disables the breaker
+	breakerCooldown time.Duration
+
 	defaultProduceTopic string
 	maxRecordBatchBytes int32
 	maxBufferedRecords  int64
@@ -122,7 +156,13 @@ type cfg struct {
 	manualFlushing      bool
 	txnBackoff          time.Duration
 
-	partitioner Partitioner
+	autoCreateTopic *autoCreateTopic // AutoCreateTopic, nil if unused
+
+	partitioner      Partitioner
+	pinnedPartitions map[string]int32 // ProduceToPartition
+
+	partitionFallback           bool // UnavailablePartitionFallback
+	partitionFallbackStrictKeys bool
 
 	stopOnDataLoss bool
 	onDataLoss     func(string, int32)
@@ -136,13 +176,18 @@ type cfg struct {
 	maxBytes       lazyI32
 	maxPartBytes   lazyI32
 	resetOffset    Offset
-	isolationLevel int8
+	isolationLevel lazyI32 // int8; SetFetchIsolationLevel
 	keepControl    bool
+	keepBatches    bool
 	rack           string
 	preferLagFn    PreferLagFn
 
+	replicaSelector func(topic string, p PartitionMetadata) int32
+
 	maxConcurrentFetches     int
+	maxBufferedFetchBytes    int64
 	disableFetchSessions     bool
+	disableFetchSessionsFor  map[int32]bool // DisableFetchSessionsFor
 	keepFetchRetryableErrors bool
 
 	topics     map[string]*regexp.Regexp   // topics to consume; if regex is true, values are compiled regular expressions
@@ -158,15 +203,21 @@ type cfg struct {
 	balancers  []GroupBalancer // balancers we can use
 	protocol   string          // "consumer" by default, expected to never be overridden
 
+	nextGenGroup bool // EnableNextGenGroupProtocol
+
 	sessionTimeout    time.Duration
 	rebalanceTimeout  time.Duration
 	heartbeatInterval time.Duration
 	requireStable     bool
 
-	onAssigned func(context.Context, *Client, map[string][]int32)
-	onRevoked  func(context.Context, *Client, map[string][]int32)
-	onLost     func(context.Context, *Client, map[string][]int32)
-	onFetched  func(context.Context, *Client, *kmsg.OffsetFetchResponse) error
+	onAssigned     func(context.Context, *Client, map[string][]int32)
+	onAssignedVeto func(context.Context, *Client, map[string][]int32) (map[string][]int32, error)
+	onRevoked      func(context.Context, *Client, map[string][]int32)
+	onRevokeCommit func(context.Context, *Client, map[string][]int32, map[string]map[int32]EpochOffset) map[string]map[int32]EpochOffset
+	onLost         func(context.Context, *Client, map[string][]int32)
+	onFetched      func(context.Context, *Client, *kmsg.OffsetFetchResponse) error
+
+	offsetStore OffsetStore
 
 	adjustOffsetsBeforeAssign func(ctx context.Context, offsets map[string]map[int32]Offset) (map[string]map[int32]Offset, error)
 
@@ -211,6 +262,26 @@ func (cfg *cfg) validate() error {
 		}
 	}
 
+	if cfg.retryBackoff == nil {
+		return errors.New("invalid nil RetryBackoffFn")
+	}
+
+	if cfg.produceBytesRate < 0 || cfg.produceRecordsRate < 0 {
+		return fmt.Errorf("invalid negative produce rate limit (bytes %d, records %d)", cfg.produceBytesRate, cfg.produceRecordsRate)
+	}
+
+	if cfg.dedupHeader != "" && cfg.dedupSize <= 0 {
+		return fmt.Errorf("invalid non-positive ProduceDedup size %d", cfg.dedupSize)
+	}
+	if cfg.dedupTTL < 0 {
+		return fmt.Errorf("invalid negative ProduceDedup ttl %v", cfg.dedupTTL)
+	}
+	for topic, partition := range cfg.pinnedPartitions {
+		if partition < 0 {
+			return fmt.Errorf("invalid negative ProduceToPartition partition %d for topic %q", partition, topic)
+		}
+	}
+
 	for _, limit := range []struct {
 		name    string
 		sp      **string // if field is a *string, we take addr to it
@@ -276,10 +347,16 @@ func (cfg *cfg) validate() error {
 
 		// 0 <= allowed concurrency
 		{name: "max concurrent fetches", v: int64(cfg.maxConcurrentFetches), allowed: 0, badcmp: i64lt},
+		{name: "max in flight per broker", v: int64(cfg.maxInflightPerBroker), allowed: 0, badcmp: i64lt},
+
+		// 0 <= max buffered fetch bytes
+		{name: "max buffered fetch bytes", v: cfg.maxBufferedFetchBytes, allowed: 0, badcmp: i64lt},
 
 		// 1s <= request timeout overhead <= 15m
 		{name: "request timeout max overhead", v: int64(cfg.requestTimeoutOverhead), allowed: int64(15 * time.Minute), badcmp: i64gt, durs: true},
 		{name: "request timeout min overhead", v: int64(cfg.requestTimeoutOverhead), allowed: int64(time.Second), badcmp: i64lt, durs: true},
+		{name: "conn read timeout", v: int64(cfg.connReadTimeout), allowed: 0, badcmp: i64lt, durs: true},
+		{name: "conn write timeout", v: int64(cfg.connWriteTimeout), allowed: 0, badcmp: i64lt, durs: true},
 
 		// 1s <= conn idle <= 15m
 		{name: "conn min idle timeout", v: int64(cfg.connIdleTimeout), allowed: int64(time.Second), badcmp: i64lt, durs: true},
@@ -289,6 +366,8 @@ func (cfg *cfg) validate() error {
 		{name: "metadata max age", v: int64(cfg.metadataMaxAge), allowed: int64(time.Hour), badcmp: i64gt, durs: true},
 		{name: "metadata min age", v: int64(cfg.metadataMinAge), allowed: int64(10 * time.Millisecond), badcmp: i64lt, durs: true},
 		{v: int64(cfg.metadataMaxAge), allowed: int64(cfg.metadataMinAge), badcmp: i64lt, fmt: "metadata max age %v is erroneously less than metadata min age %v", durs: true},
+		{name: "metadata min immediate age", v: int64(cfg.metadataMinImmediateAge), allowed: int64(10 * time.Millisecond), badcmp: i64lt, durs: true},
+		{v: int64(cfg.metadataMinAge), allowed: int64(cfg.metadataMinImmediateAge), badcmp: i64lt, fmt: "metadata min age %v is erroneously less than metadata min immediate age %v", durs: true},
 
 		// Some random producer settings.
 		{name: "max buffered records", v: cfg.maxBufferedRecords, allowed: 1, badcmp: i64lt},
@@ -347,7 +426,10 @@ func (cfg *cfg) validate() error {
 		if len(cfg.partitions) != 0 {
 			return errors.New("invalid direct-partition consuming option when consuming as regex")
 		}
-		for re := range cfg.topics {
+		for re, compiled := range cfg.topics {
+			if compiled != nil {
+				continue // from ConsumeTopicsRegexp
+			}
 			compiled, err := regexp.Compile(re)
 			if err != nil {
 				return fmt.Errorf("invalid regular expression %q", re)
@@ -364,6 +446,10 @@ func (cfg *cfg) validate() error {
 		}
 	}
 
+	if cfg.offsetStore != nil && cfg.txnID != nil {
+		return errors.New("cannot use an OffsetStore with a transactional client; transactional commits must go through Kafka")
+	}
+
 	if cfg.autocommitDisable && cfg.autocommitGreedy {
 		return errors.New("cannot both disable autocommitting and enable greedy autocommitting")
 	}
@@ -379,6 +465,48 @@ func (cfg *cfg) validate() error {
 	if (cfg.setLost || cfg.setRevoked || cfg.setAssigned) && len(cfg.group) == 0 {
 		return errors.New("invalid group partition assigned/revoked/lost functions set when a group was not specified")
 	}
+	if cfg.onRevokeCommit != nil {
+		if len(cfg.group) == 0 {
+			return errors.New("invalid OnRevokeCommit set when a group was not specified")
+		}
+		if cfg.txnID != nil {
+			return errors.New("cannot use OnRevokeCommit with a transactional client; transactional commits must be part of a transaction")
+		}
+	}
+	if cfg.nextGenGroup {
+		if len(cfg.group) == 0 {
+			return errors.New("invalid EnableNextGenGroupProtocol specified when a group was not specified")
+		}
+		if cfg.onAssignedVeto != nil {
+			return errors.New("cannot use OnPartitionsAssignedVeto with EnableNextGenGroupProtocol; the broker owns partition assignment")
+		}
+		if cfg.maxVersionsSet && cfg.maxVersions != nil {
+			if !cfg.maxVersions.HasKey(int16(kmsg.ConsumerGroupHeartbeat)) {
+				return errors.New("invalid MaxVersions with EnableNextGenGroupProtocol: ConsumerGroupHeartbeat is not allowed")
+			}
+			if !cfg.maxVersions.HasKeyVersion(int16(kmsg.OffsetCommit), 9) {
+				return errors.New("invalid MaxVersions with EnableNextGenGroupProtocol: OffsetCommit v9+ is not allowed")
+			}
+		}
+	}
+	if cfg.clientMetrics && cfg.maxVersionsSet && cfg.maxVersions != nil {
+		for _, key := range []kmsg.Key{kmsg.GetTelemetrySubscriptions, kmsg.PushTelemetry} {
+			if !cfg.maxVersions.HasKey(int16(key)) {
+				return fmt.Errorf("invalid MaxVersions with EnableClientMetrics: %s is not allowed", key.Name())
+			}
+		}
+	}
+	if cfg.breakerFailures < 0 || cfg.breakerFailures > 0 && cfg.breakerCooldown <= 0 {
+		return errors.New("invalid ProduceCircuitBreaker: failures must be positive and the cooldown must be positive")
+	}
+	if cfg.skipSerdeErrs && cfg.keySerde == nil && cfg.valueSerde == nil {
+		return errors.New("invalid SkipSerdeErrors without KeySerde or ValueSerde")
+	}
+	if cfg.instanceID != nil {
+		if err := validateInstanceID(*cfg.instanceID); err != nil {
+			return err
+		}
+	}
 
 	processedHooks, err := processHooks(cfg.hooks)
 	if err != nil {
@@ -446,36 +574,8 @@ func defaultCfg() cfg {
 		seedBrokers: []string{"127.0.0.1"},
 		maxVersions: kversion.Stable(),
 
-		retryBackoff: func() func(int) time.Duration {
-			var rngMu sync.Mutex
-			rng := rand.New(rand.NewSource(time.Now().UnixNano()))
-			return func(fails int) time.Duration {
-				const (
-					min = 250 * time.Millisecond
-					max = 5 * time.Second / 2
-				)
-				if fails <= 0 {
-					return min
-				}
-				if fails > 10 {
-					return max
-				}
-
-				backoff := min * time.Duration(1<<(fails-1))
-
-				rngMu.Lock()
-				jitter := 0.8 + 0.4*rng.Float64()
-				rngMu.Unlock()
-
-				backoff = time.Duration(float64(backoff) * jitter)
-
-				if backoff > max {
-					return max
-				}
-				return backoff
-			}
-		}(),
-		retries: 20,
+		retryBackoff: ExponentialBackoff(250*time.Millisecond, 5*time.Second/2),
+		retries:      20,
 
 		maxBrokerWriteBytes: 100 << 20, // Kafka socket.request.max.bytes default is 100<<20
 		maxBrokerReadBytes:  100 << 20,
@@ -483,6 +583,8 @@ func defaultCfg() cfg {
 		metadataMaxAge: 5 * time.Minute,
 		metadataMinAge: 5 * time.Second / 2,
 
+		metadataMinImmediateAge: 10 * time.Millisecond,
+
 		//////////////
 		// producer //
 		//////////////
@@ -602,6 +704,69 @@ func ConnIdleTimeout(timeout time.Duration) Opt {
 	return clientOpt{func(cfg *cfg) { cfg.connIdleTimeout = timeout }}
 }
 
+// MaxInFlightPerBroker sets the maximum number of requests that can be in
+// flight to a single broker at once, across all connections to that broker,
+// overriding the default of 0 (unbounded). Requests beyond the limit are
+// queued and written in order once an in flight request receives its response
+// (or fails). This can be used to bound the load the client puts on a slow or
+// recovering broker.
+//
+// Every request counts against the limit, including fetch requests, which can
+// be in flight for up to FetchMaxWait, and group join requests, which can be
+// in flight for up to the rebalance timeout. A low limit can thus delay all
+// other requests to a broker; MaxConcurrentFetches can be used to separately
+// bound the number of fetches. Internal connection initialization requests
+// (ApiVersions and SASL) do not count against the limit.
+//
+// This limit is applied in addition to the produce in flight limit, which is
+// set with MaxProduceRequestsInflightPerBroker if idempotency is disabled, or
+// is otherwise 1 for Kafka v0.11 and 5 from v1 onward. Setting this lower than
+// the produce limit also limits produce requests.
+func MaxInFlightPerBroker(n int) Opt {
+	return clientOpt{func(cfg *cfg) { cfg.maxInflightPerBroker = n }}
+}
+
+// ConnReadTimeout sets the base timeout for reading a response from a
+// connection, overriding the default of RequestTimeoutOverhead. Requests that
+// have their own timeouts (produce, fetch, join group, and any request with a
+// TimeoutMillis field) extend the read timeout by their own timeout, exactly
+// as described in RequestTimeoutOverhead. This is useful for high latency
+// links where responses can take a while to arrive.
+func ConnReadTimeout(timeout time.Duration) Opt {
+	return clientOpt{func(cfg *cfg) { cfg.connReadTimeout = timeout }}
+}
+
+// ConnWriteTimeout sets the timeout for writing a request to a connection,
+// overriding the default of RequestTimeoutOverhead. Writes are buffered and
+// flushed at once, so this timeout only needs to account for the time it
+// takes to send a request over the network; it is never extended by a
+// request's own timeout (such as a fetch's max wait).
+func ConnWriteTimeout(timeout time.Duration) Opt {
+	return clientOpt{func(cfg *cfg) { cfg.connWriteTimeout = timeout }}
+}
+
+// ConnTimeoutsFn sets a function to override the read and write timeouts for
+// individual requests. The function is called once per request, before the
+// request is written, with the request and the read and write timeouts the
+// client would use (see ConnReadTimeout and ConnWriteTimeout), and returns the
+// read and write timeouts to use. For example, you can use this to give quick
+// metadata requests a short read timeout while leaving long polling fetches
+// alone. A returned timeout that is zero or negative disables that timeout.
+//
+// The request may be an internal client type that wraps a kmsg type, so you
+// should switch on the request's Key rather than the request's type.
+func ConnTimeoutsFn(fn func(req kmsg.Request, read, write time.Duration) (time.Duration, time.Duration)) Opt {
+	return clientOpt{func(cfg *cfg) { cfg.connTimeoutsFn = fn }}
+}
+
+// DialKeepAlive sets the TCP keep-alive period for connections opened with
+// the default dialer, overriding the default of 15s (Go's default). A
+// negative value disables keep-alives. This option has no effect if you use
+// a custom Dialer.
+func DialKeepAlive(keepAlive time.Duration) Opt {
+	return clientOpt{func(cfg *cfg) { cfg.dialKeepAlive = keepAlive }}
+}
+
 // Dialer uses fn to dial addresses, overriding the default dialer that uses a
 // 10s dial timeout and no TLS.
 //
@@ -618,13 +783,22 @@ func ConnIdleTimeout(timeout time.Duration) Opt {
 // or
 //
 //	kgo.Dialer((&tls.Dialer{...}).DialContext)
+//
+// The function is used for every connection the client opens, including
+// connections to seed brokers, and the network is always "tcp". The returned
+// connection does not need to be a TCP connection: you can wrap connections
+// for instrumentation, route through a proxy, or return one end of a net.Pipe
+// to talk to an in-process broker (such as a kfake cluster) without any
+// networking. If the returned connection is a *tls.Conn, the client performs
+// the TLS handshake before using it.
 func Dialer(fn func(ctx context.Context, network, host string) (net.Conn, error)) Opt {
 	return clientOpt{func(cfg *cfg) { cfg.dialFn = fn }}
 }
 
 // DialTimeout sets the dial timeout, overriding the default of 10s. This
 // option is useful if you do not want to set a custom dialer, and is useful in
-// tandem with DialTLSConfig.
+// tandem with DialTLSConfig. The timeout covers both dialing and the TLS
+// handshake, if any.
 func DialTimeout(timeout time.Duration) Opt {
 	return clientOpt{func(cfg *cfg) { cfg.dialTimeout = timeout }}
 }
@@ -660,7 +834,7 @@ func SeedBrokers(seeds ...string) Opt {
 // requests, it is recommended to pin versions so that new fields on requests
 // do not get invalid default zero values before you update your usage.
 func MaxVersions(versions *kversion.Versions) Opt {
-	return clientOpt{func(cfg *cfg) { cfg.maxVersions = versions }}
+	return clientOpt{func(cfg *cfg) { cfg.maxVersions, cfg.maxVersionsSet = versions, true }}
 }
 
 // MinVersions sets the minimum Kafka version a request can be downgraded to,
@@ -683,6 +857,14 @@ func MinVersions(versions *kversion.Versions) Opt {
 // amount of retries, overriding the default jittery exponential backoff that
 // ranges from 250ms min to 2.5s max.
 //
+// The function is called with the number of consecutive failures so far and
+// is used for every retry the client performs: retrying produce requests,
+// backing off after failed fetches, retrying metadata loads, retrying
+// requests issued through Request, and backing off in group and transaction
+// management. The function may be called concurrently. ExponentialBackoff and
+// FullJitterBackoff can be used to build common strategies, or any custom
+// function can be used.
+//
 // This (roughly) corresponds to Kafka's retry.backoff.ms setting and
 // retry.backoff.max.ms (which is being introduced with KIP-500).
 func RetryBackoffFn(backoff func(int) time.Duration) Opt {
@@ -788,12 +970,30 @@ func MetadataMinAge(age time.Duration) Opt {
 	return clientOpt{func(cfg *cfg) { cfg.metadataMinAge = age }}
 }
 
+// MetadataMinImmediateAge sets the minimum time between metadata queries that
+// the client triggers to happen immediately, overriding the default 10ms.
+//
+// Some errors, such as NOT_LEADER_FOR_PARTITION, or producing to a topic the
+// client has not yet loaded, trigger a metadata refresh that bypasses
+// MetadataMinAge. All triggers that occur while waiting are coalesced into a
+// single refresh. During incidents such as a broker roll, many partitions can
+// error at once; raising this value limits how often the client re-queries
+// metadata, at the expense of slower recovery. This cannot be larger than
+// MetadataMinAge.
+func MetadataMinImmediateAge(age time.Duration) Opt {
+	return clientOpt{func(cfg *cfg) { cfg.metadataMinImmediateAge = age }}
+}
+
 // SASL appends sasl authentication options to use for all connections.
 //
 // SASL is tried in order; if the broker supports the first mechanism, all
 // connections will use that mechanism. If the first mechanism fails, the
 // client will pick the first supported mechanism. If the broker does not
 // support any client mechanisms, connections will fail.
+//
+// Any type implementing sasl.Mechanism can be used, including custom
+// mechanisms not provided by this repo; see the sasl package documentation
+// for how the client drives a mechanism and schedules reauthentication.
 func SASL(sasls ...sasl.Mechanism) Opt {
 	return clientOpt{func(cfg *cfg) { cfg.sasls = append(cfg.sasls, sasls...) }}
 }
@@ -809,6 +1009,72 @@ func WithHooks(hooks ...Hook) Opt {
 	return clientOpt{func(cfg *cfg) { cfg.hooks = append(cfg.hooks, hooks...) }}
 }
 
+// CorrelationIDFn sets a function that returns the correlation ID to use for
+// every request the client writes, overriding the default of a per-connection
+// counter that starts at 0. The function is called with the broker the request
+// is being written to and the request itself, immediately before the request
+// is serialized (including internal requests such as ApiVersions and SASL
+// requests).
+//
+// This is useful when building a proxy on top of the client, where
+// correlation IDs need to be mapped to upstream requests. The client matches
+// responses to requests in order per connection, so IDs do not need to be
+// unique, but the broker echoes the ID back and the client validates that the
+// response has the ID that was used in the request.
+//
+// The function may be called concurrently for requests on different
+// connections.
+func CorrelationIDFn(fn func(BrokerMetadata, kmsg.Request) int32) Opt {
+	return clientOpt{func(cfg *cfg) { cfg.corrIDFn = fn }}
+}
+
+// EnableClientMetrics opts in to KIP-714 client metrics, in which the client
+// periodically pushes its own metrics to brokers that request them. This
+// allows operators to view client side metrics centrally from the brokers.
+//
+// If enabled, the client asks brokers which metrics to push and how often,
+// and pushes matching metrics every push interval as an OpenTelemetry
+// MetricsData payload. The client always includes a few internal metrics
+// (connection and request counts, as well as buffered produce and fetch
+// records); any metrics from the input providers are included as well.
+//
+// Client metrics require Kafka 3.7+; if brokers do not support client
+// metrics, the client stops trying to push them. Because the client metrics
+// requests are newer than the default max versions, enabling this option also
+// allows those requests in the default versions. If you use MaxVersions, the
+// versions must allow GetTelemetrySubscriptions and PushTelemetry, otherwise
+// NewClient returns an error.
+func EnableClientMetrics(providers ...MetricsProvider) Opt {
+	return clientOpt{func(cfg *cfg) {
+		cfg.clientMetrics = true
+		cfg.metricsProviders = append(cfg.metricsProviders, providers...)
+	}}
+}
+
+// KeySerde sets a Serde to encode and decode record keys, allowing typed keys
+// to be produced and consumed through the Record.KeyObject field.
+//
+// When producing, if a record's KeyObject is non-nil, it is encoded into the
+// record's Key before the record is passed to any OnProduceRecordBuffered
+// hooks and before it is partitioned. If encoding fails, the record is failed
+// immediately with the encoding error.
+//
+// When consuming, every record with a non-nil Key has the Key decoded into
+// KeyObject before the record is passed to any hooks. If decoding fails, the
+// record is still returned, but with its SerdeErr field set (see
+// SkipSerdeErrors). Control records are not decoded.
+func KeySerde(s Serde) Opt {
+	return clientOpt{func(cfg *cfg) { cfg.keySerde = s }}
+}
+
+// ValueSerde sets a Serde to encode and decode record values, allowing typed
+// values to be produced and consumed through the Record.ValueObject field.
+// This behaves exactly as KeySerde, but for record values; see KeySerde for
+// more details. Null values (tombstones) are not decoded.
+func ValueSerde(s Serde) Opt {
+	return clientOpt{func(cfg *cfg) { cfg.valueSerde = s }}
+}
+
 // ConcurrentTransactionsBackoff sets the backoff interval to use during
 // transactional requests in case we encounter CONCURRENT_TRANSACTIONS error,
 // overriding the default 20ms.
@@ -878,6 +1144,30 @@ func RequiredAcks(acks Acks) ProducerOpt {
 // IDEMPOTENT_WRITE permission on CLUSTER (pre Kafka 3.0), and not all clients
 // can have that permission.
 //
+// With this option, the client never issues an InitProducerID request, and
+// every produce request is sent with a producer ID and epoch of -1, which
+// brokers treat as non-idempotent. This is useful for brokers that reject
+// InitProducerID. Note that if idempotency is not disabled and the client
+// detects that a broker is too old to support InitProducerID (pre 0.11), the
+// client automatically continues without a producer ID; this option is only
+// necessary to opt out explicitly.
+//
+// Retryable errors are still retried, bounded by RecordRetries,
+// RecordDeliveryTimeout, and the record context, exactly as when idempotent.
+// Without idempotency, though, the broker cannot deduplicate or order
+// retried batches, which has the following implications:
+//
+//   - A batch that the broker wrote but whose response was lost (for
+//     example, due to a request timeout or a severed connection) is written
+//     again when retried, producing duplicates.
+//
+//   - With the default of one in-flight request per broker, records within a
+//     partition stay in order across retries. If
+//     MaxProduceRequestsInflightPerBroker is raised above 1, a failed batch
+//     may be retried after a later batch succeeded, reordering records.
+//
+//   - Acks other than AllISRAcks are allowed.
+//
 // This option is incompatible with specifying a transactional id.
 func DisableIdempotentWrite() ProducerOpt {
 	return producerOpt{func(cfg *cfg) { cfg.disableIdempotency = true }}
@@ -939,6 +1229,43 @@ func RecordPartitioner(partitioner Partitioner) ProducerOpt {
 	return producerOpt{func(cfg *cfg) { cfg.partitioner = partitioner }}
 }
 
+// ProduceToPartition pins all records produced to topic to the given
+// partition, bypassing the partitioner entirely: the record's key and
+// Partition field are ignored. This can be used to guarantee that all records
+// this client produces to a topic are strictly ordered.
+//
+// If the partition does not exist in the topic, records produced to the topic
+// fail with an error. This option can be used multiple times to pin
+// partitions for multiple topics.
+func ProduceToPartition(topic string, partition int32) ProducerOpt {
+	return producerOpt{func(cfg *cfg) {
+		if cfg.pinnedPartitions == nil {
+			cfg.pinnedPartitions = make(map[string]int32)
+		}
+		cfg.pinnedPartitions[topic] = partition
+	}}
+}
+
+// UnavailablePartitionFallback reroutes records from partitions that
+// currently have no known leader to partitions that do.
+//
+// Consistent partitioners (such as the default partitioner for records with
+// keys) map a record to the same partition even if that partition is
+// unavailable, in which case the record waits until the partition has a
+// leader again. With this option, if a consistent partitioner picks a
+// partition without a leader, the record is instead buffered into one of
+// the writable partitions. While all partitions are writable, records are
+// partitioned exactly as they are without this option.
+//
+// This improves availability during leader elections for topics that do not
+// require strict partitioning. If strictKeys is true, records with a non-nil
+// key are never rerouted, preserving the key to partition mapping, and only
+// keyless records fall back. Records that are already buffered in a
+// partition are not rerouted.
+func UnavailablePartitionFallback(strictKeys bool) ProducerOpt {
+	return producerOpt{func(cfg *cfg) { cfg.partitionFallback, cfg.partitionFallbackStrictKeys = true, strictKeys }}
+}
+
 // ProduceRequestTimeout sets how long Kafka broker's are allowed to respond to
 // produce requests, overriding the default 10s. If a broker exceeds this
 // duration, it will reply with a request timeout error.
@@ -974,6 +1301,134 @@ func RecordRetries(n int) ProducerOpt {
 	return producerOpt{func(cfg *cfg) { cfg.recordRetries = int64(n) }}
 }
 
+// ProduceRateLimit limits how fast the client produces, in bytes per second
+// and records per second, overriding the default of no limit. Either limit
+// can be zero to only limit by the other.
+//
+// The limits are enforced with token buckets that allow bursting up to one
+// second's worth of bytes or records. A produce request is charged its
+// actual, post-compression size once it is serialized, and no further produce
+// requests are issued until the limits are repaid; a large request thus
+// delays the requests after it. The limits apply across all brokers.
+//
+// While the client is rate limited, records remain buffered. Once
+// MaxBufferedRecords is reached, Produce blocks until the rate limit allows
+// more records to be written, while TryProduce fails records immediately with
+// ErrMaxBuffered. Use TryProduce if you want to shed load rather than wait
+// when the rate limit is exceeded.
+func ProduceRateLimit(bytesPerSec, recordsPerSec int) ProducerOpt {
+	return producerOpt{func(cfg *cfg) { cfg.produceBytesRate, cfg.produceRecordsRate = bytesPerSec, recordsPerSec }}
+}
+
+// ValidateTimestampType validates records that are produced with an explicit
+// timestamp against their topic's message.timestamp.type config. If a topic
+// uses LogAppendTime, the broker overwrites record timestamps with the time
+// it appends the records, which silently discards the timestamps you set.
+// If fail is true, such records are failed with ErrLogAppendTimestamp;
+// otherwise, the client logs a warning once per topic and produces the
+// records as normal.
+//
+// The client loads topic configs with one DescribeConfigs request per topic,
+// shared with FailOversizedRecords. The first record with an explicit
+// timestamp produced to a topic blocks in Produce until the topic's config is
+// loaded. Configs older than MetadataMaxAge are reloaded in the background
+// while the cached configs continue to be used. If the config has never been
+// loaded (for example, due to missing ACLs), records are not validated.
+// Records without a timestamp (the client sets the timestamp to the current
+// time) are never validated.
+func ValidateTimestampType(fail bool) ProducerOpt {
+	return producerOpt{func(cfg *cfg) { cfg.validateTimestampType, cfg.failLogAppendTimestamps = true, fail }}
+}
+
+// FailOversizedRecords fails records that can never be produced because they
+// are too large, before they are buffered. A record is too large if a record
+// batch containing only the record would be larger than the client's
+// ProducerBatchMaxBytes or, if compression is disabled, the topic's
+// max.message.bytes. Without this option, records larger than the topic's
+// limit are rejected by the broker, possibly only after retries.
+//
+// Oversized records are failed with *ErrRecordTooLarge, which unwraps to
+// kerr.MessageTooLarge. If onOversized is non-nil, it is called with the
+// record and error before the record's promise, allowing you to split the
+// record or route it elsewhere. Record sizes are checked before compression.
+// The broker checks max.message.bytes against compressed batches, so when
+// compressing, records are not checked against the topic's limit: a record
+// that is too large uncompressed may still fit once compressed.
+//
+// If compression is disabled, the client loads topic configs with one
+// DescribeConfigs request per topic, shared with ValidateTimestampType. The
+// first record produced to a topic blocks in Produce until the topic's config
+// is loaded. Configs older than MetadataMaxAge are reloaded in the background
+// while the cached configs continue to be used. If the config has never been
+// loaded (for example, due to missing ACLs), records are only validated
+// against ProducerBatchMaxBytes.
+func FailOversizedRecords(onOversized func(*Record, *ErrRecordTooLarge)) ProducerOpt {
+	return producerOpt{func(cfg *cfg) { cfg.failOversized, cfg.onOversized = true, onOversized }}
+}
+
+// ProduceCircuitBreaker enables a circuit breaker around produce requests to
+// each broker. After failures consecutive produce requests to a broker fail
+// (for example, because the connection is cut or requests time out), the
+// breaker for that broker opens: rather than retrying, records buffered for
+// partitions led by the broker are failed with ErrBrokerCircuitOpen, as are
+// any new records for those partitions. After the cooldown, the client sends
+// one probe request to the broker: if it succeeds, the breaker closes and
+// producing resumes as normal; if it fails, the breaker reopens for another
+// cooldown.
+//
+// Opening a breaker triggers a metadata update. If leadership of a partition
+// moves to a different broker, the partition's records are produced to the
+// new leader rather than failed. If the client is idempotent, records that
+// may have already been written to the broker cannot be failed without
+// breaking the partition's sequence numbers; these records are kept and
+// retried after the cooldown.
+//
+// By default, the client has no circuit breaker and retries records until
+// they hit RecordRetries or RecordDeliveryTimeout.
+func ProduceCircuitBreaker(failures int, cooldown time.Duration) ProducerOpt {
+	return producerOpt{func(cfg *cfg) { cfg.breakerFailures, cfg.breakerCooldown = failures, cooldown }}
+}
+
+// ProduceInterceptors adds functions that are called on every produced record
+// before the record is partitioned, in the order they are added. This option
+// can be used multiple times; each use appends to the chain.
+//
+// An interceptor can modify the record's key, value, headers, or timestamp,
+// for example to stamp tracing headers on every record in one place. If an
+// interceptor returns false, the record is dropped: later interceptors are
+// not called and the record's promise is called with ErrRecordDropped.
+//
+// Interceptors are called after the KeySerde and ValueSerde encode the record,
+// meaning an interceptor sees (and can modify) the final key and value bytes.
+// Interceptors are called concurrently if you produce concurrently, and they
+// should not block, as they are called inline in Produce.
+func ProduceInterceptors(interceptors ...func(*Record) bool) ProducerOpt {
+	return producerOpt{func(cfg *cfg) { cfg.interceptors = append(cfg.interceptors, interceptors...) }}
+}
+
+// ProduceDedup enables an in-memory deduplication window for produced
+// records, keyed on the value of the given record header. If a record is
+// produced with the same header value for the same topic as a record that was
+// successfully produced and is still in the window, the duplicate is dropped
+// before it is batched and its promise is called with ErrDuplicateRecord.
+// Records that do not have the header are never deduplicated.
+//
+// Only delivered records enter the window: a record that fails to be produced
+// can be retried, and a duplicate produced while the original is still in
+// flight is not dropped.
+//
+// The window remembers up to size message IDs, evicting the least recently
+// delivered ID once full. If ttl is non-zero, IDs also expire ttl after they
+// were delivered.
+//
+// This is a best effort, client local guard against applications producing
+// the same message twice in quick succession. It does not survive client
+// restarts and does not deduplicate across clients; use idempotent or
+// transactional producing for broker side guarantees.
+func ProduceDedup(header string, size int, ttl time.Duration) ProducerOpt {
+	return producerOpt{func(cfg *cfg) { cfg.dedupHeader, cfg.dedupSize, cfg.dedupTTL = header, size, ttl }}
+}
+
 // UnknownTopicRetries sets the number of times a record can fail with
 // UNKNOWN_TOPIC_OR_PARTITION, overriding the default 4.
 //
@@ -984,11 +1439,51 @@ func RecordRetries(n int) ProducerOpt {
 // multiple metadata queries (which are going to different brokers), then we
 // may as well stop trying and fail the records.
 //
+// Records that fail due to this limit fail with *ErrUnknownTopic, which
+// unwraps to kerr.UnknownTopicOrPartition.
+//
+// If this is 0, records fail as soon as the first metadata load or produce
+// response indicates that the topic does not exist. This is useful for batch
+// jobs that would rather fail immediately when producing to a misconfigured
+// topic than wait for the record timeout. This has no effect if the
+// AllowAutoTopicCreation option is used, since the topic is created rather
+// than reported as unknown.
+//
 // If this is -1, the client never fails records with this error.
 func UnknownTopicRetries(n int) ProducerOpt {
 	return producerOpt{func(cfg *cfg) { cfg.maxUnknownFailures = int64(n) }}
 }
 
+type autoCreateTopic struct {
+	partitions        int32
+	replicationFactor int16
+	configs           map[string]*string
+}
+
+// AutoCreateTopic sets the client to create topics that do not exist when
+// producing, with the given number of partitions, replication factor, and
+// (optional) topic configs. Partitions and replicationFactor can be -1 to use
+// the broker defaults (Kafka 2.4+).
+//
+// When producing to a topic for the first time, if metadata reports the topic
+// as UNKNOWN_TOPIC_OR_PARTITION, the client issues a CreateTopics request for
+// the topic and then refreshes metadata; records remain buffered and are
+// produced once the topic is loaded. Creation is only attempted once per
+// unknown topic wait. If creation fails with a non-retryable error (for
+// example, authorization or policy violations), all records buffered for the
+// topic are failed with the creation error.
+//
+// Unlike AllowAutoTopicCreation, which relies on the broker's
+// auto.create.topics.enable and creates topics with broker defaults, this
+// gives topics a deterministic shape and works even if broker side auto
+// creation is disabled. If both options are used, the broker may create the
+// topic from the metadata request before this option has a chance to.
+func AutoCreateTopic(partitions int32, replicationFactor int16, configs map[string]*string) ProducerOpt {
+	return producerOpt{func(cfg *cfg) {
+		cfg.autoCreateTopic = &autoCreateTopic{partitions, replicationFactor, configs}
+	}}
+}
+
 // StopProducerOnDataLossDetected sets the client to stop producing if data
 // loss is detected, overriding the default false.
 //
@@ -1039,7 +1534,15 @@ func ManualFlushing() ProducerOpt {
 }
 
 // RecordDeliveryTimeout sets a rough time of how long a record can sit around
-// in a batch before timing out, overriding the unlimited default.
+// in the client before timing out, overriding the unlimited default. Records
+// that time out are failed with ErrRecordTimeout.
+//
+// The timeout covers the entire time a record is in the client: time spent
+// blocked in Produce waiting for buffer space (see MaxBufferedRecords), time
+// spent waiting to load metadata for a new topic, and time spent buffered in
+// a batch across all produce requests and retries. The timeout is measured
+// from when the record enters the client, not from the record's Timestamp, so
+// producing records with old timestamps does not cause them to time out.
 //
 // If idempotency is enabled (as it is by default), this option is only
 // enforced if it is safe to do so without creating invalid sequence numbers.
@@ -1047,10 +1550,10 @@ func ManualFlushing() ProducerOpt {
 // if it was requested and received a response.
 //
 // The timeout for all records in a batch inherit the timeout of the first
-// record in that batch. That is, once the first record's timeout expires, all
-// records in the batch are expired. This generally is a non-issue unless using
-// this option with lingering. In that case, simply add the linger to the
-// record timeout to avoid problems.
+// record in that batch: a batch is expired once the timeout has elapsed since
+// the first record in the batch was passed to Produce. This generally is a
+// non-issue unless using this option with lingering. In that case, simply add
+// the linger to the record timeout to avoid problems.
 //
 // If a record times out, all records buffered in the same partition are failed
 // as well. This ensures gapless ordering: the client will not fail one record
@@ -1187,6 +1690,26 @@ func MaxConcurrentFetches(n int) ConsumerOpt {
 	return consumerOpt{func(cfg *cfg) { cfg.maxConcurrentFetches = n }}
 }
 
+// MaxBufferedFetchBytes sets the maximum amount of fetched record bytes
+// (keys, values, and headers) to allow buffered in the client before
+// applying backpressure, overriding the unbounded default. Once the limit is
+// reached, no new fetch requests are issued until polling drains buffered
+// fetches back under the limit.
+//
+// This is a soft limit: a fetch is allowed to be issued as long as the amount
+// buffered is under the limit, and the response to that fetch can take the
+// amount buffered over the limit by up to FetchMaxBytes (per in flight
+// fetch). Pairing this with MaxConcurrentFetches bounds how far over the
+// limit the client can go.
+//
+// Unlike the MaxBufferedRecords producer option, which bounds the number of
+// records, this bounds memory usage even when records are large.
+//
+// A value of 0 implies no limit.
+func MaxBufferedFetchBytes(n int64) ConsumerOpt {
+	return consumerOpt{func(cfg *cfg) { cfg.maxBufferedFetchBytes = n }}
+}
+
 // ConsumeResetOffset sets the offset to start consuming from, or if
 // OffsetOutOfRange is seen while fetching, to restart consuming from. The
 // default is NewOffset().AtStart(), i.e., the earliest offset.
@@ -1226,6 +1749,27 @@ func Rack(rack string) ConsumerOpt {
 	return consumerOpt{func(cfg *cfg) { cfg.rack = rack }}
 }
 
+// ConsumeReplicaSelector sets a function to choose which replica to consume a
+// partition from, overriding the default of consuming from the leader. This
+// can be used to implement custom replica placement beyond Rack, such as
+// fetching from the least loaded follower.
+//
+// The function is called only for consumed partitions: once when a partition
+// is first loaded, and again whenever the partition has a new leader or
+// leader epoch. It is passed the partition's topic and metadata, and must
+// return the node ID of a replica of the partition. If the function returns
+// a broker that is not a replica of the partition (such as -1), the client
+// consumes from the leader. The function should be fast, because it is called
+// while updating metadata.
+//
+// The client consumes from the chosen replica until the partition's leader or
+// leader epoch changes or until the broker signals a different preferred read
+// replica (KIP-392), at which point the client switches to the broker's
+// preferred replica. Fetching from followers requires Kafka 2.4+.
+func ConsumeReplicaSelector(fn func(topic string, p PartitionMetadata) int32) ConsumerOpt {
+	return consumerOpt{func(cfg *cfg) { cfg.replicaSelector = fn }}
+}
+
 // IsolationLevel controls whether uncommitted or only committed records are
 // returned from fetch requests.
 type IsolationLevel struct {
@@ -1242,17 +1786,32 @@ func ReadCommitted() IsolationLevel { return IsolationLevel{1} }
 // FetchIsolationLevel sets the "isolation level" used for fetching
 // records, overriding the default ReadUncommitted.
 func FetchIsolationLevel(level IsolationLevel) ConsumerOpt {
-	return consumerOpt{func(cfg *cfg) { cfg.isolationLevel = level.level }}
+	return consumerOpt{func(cfg *cfg) { cfg.isolationLevel = lazyI32(level.level) }}
 }
 
 // KeepControlRecords sets the client to keep control messages and return
 // them with fetches, overriding the default that discards them.
 //
-// Generally, control messages are not useful.
+// Generally, control messages are not useful, but tooling may want to see
+// transaction markers to build a timeline of commits and aborts. Control
+// records can be identified with Attrs.IsControl, and Record.ControlType
+// returns whether a marker is a commit or an abort. Regular processing should
+// skip records for which Attrs.IsControl is true.
 func KeepControlRecords() ConsumerOpt {
 	return consumerOpt{func(cfg *cfg) { cfg.keepControl = true }}
 }
 
+// KeepFetchBatchBoundaries sets the client to track the record batches that
+// fetched records were read from, and to return them in each
+// FetchPartition's Batches field. This can be used to process or checkpoint
+// records one batch at a time; see FetchPartition.EachBatch.
+//
+// Batches are only tracked for brokers that use record batches (Kafka
+// 0.11+); records read from older message sets have no batch.
+func KeepFetchBatchBoundaries() ConsumerOpt {
+	return consumerOpt{func(cfg *cfg) { cfg.keepBatches = true }}
+}
+
 // ConsumeTopics adds topics to use for consuming.
 //
 // By default, consuming will start at the beginning of partitions. To change
@@ -1289,10 +1848,36 @@ func ConsumePartitions(partitions map[string]map[int32]Offset) ConsumerOpt {
 // all topics can be passed to any regular expressions. Every topic is
 // evaluated only once ever across all regular expressions; either it
 // permanently is known to match, or is permanently known to not match.
+//
+// Newly created topics that match are discovered on the next metadata
+// refresh, meaning MetadataMaxAge bounds how long it takes to start consuming
+// a new topic. If a previously matching topic is missing from a metadata
+// response, the client assumes the topic was deleted and purges it, which
+// revokes its partitions (and rejoins the group if group consuming). If the
+// topic is later recreated, it is evaluated against the regular expressions
+// again.
 func ConsumeRegex() ConsumerOpt {
 	return consumerOpt{func(cfg *cfg) { cfg.regex = true }}
 }
 
+// ConsumeTopicsRegexp sets the client to consume topics matching any of the
+// given compiled regular expressions. This is equivalent to ConsumeTopics
+// with the string form of each expression combined with ConsumeRegex, but
+// uses the expressions as given rather than recompiling them. See
+// ConsumeRegex for how topics are discovered and removed.
+//
+// This option and ConsumeTopics both replace any previously set topics; the
+// two should not be used together.
+func ConsumeTopicsRegexp(res ...*regexp.Regexp) ConsumerOpt {
+	return consumerOpt{func(cfg *cfg) {
+		cfg.regex = true
+		cfg.topics = make(map[string]*regexp.Regexp, len(res))
+		for _, re := range res {
+			cfg.topics[re.String()] = re
+		}
+	}}
+}
+
 // DisableFetchSessions sets the client to not use fetch sessions (Kafka 1.0+).
 //
 // A "fetch session" is is a way to reduce bandwidth for fetch requests &
@@ -1317,11 +1902,30 @@ func ConsumeRegex() ConsumerOpt {
 // that to determine whether enabling or disabling sessions is beneficial or
 // not.
 //
+// Whether sessions are in use per broker can be checked with
+// Client.FetchSessions.
+//
 // For more details on fetch sessions, see KIP-227.
 func DisableFetchSessions() ConsumerOpt {
 	return consumerOpt{func(cfg *cfg) { cfg.disableFetchSessions = true }}
 }
 
+// DisableFetchSessionsFor is like DisableFetchSessions, but only disables
+// fetch sessions for the given broker node IDs. This can be used to avoid
+// sessions with specific brokers that misbehave while keeping the benefit of
+// sessions everywhere else. Whether sessions are in use per broker can be
+// checked with Client.FetchSessions.
+func DisableFetchSessionsFor(nodeIDs ...int32) ConsumerOpt {
+	return consumerOpt{func(cfg *cfg) {
+		if cfg.disableFetchSessionsFor == nil {
+			cfg.disableFetchSessionsFor = make(map[int32]bool)
+		}
+		for _, id := range nodeIDs {
+			cfg.disableFetchSessionsFor[id] = true
+		}
+	}}
+}
+
 // ConsumePreferringLagFn allows you to re-order partitions before they are
 // fetched, given each partition's current lag.
 //
@@ -1343,6 +1947,29 @@ func ConsumePreferringLagFn(fn PreferLagFn) ConsumerOpt {
 	return consumerOpt{func(cfg *cfg) { cfg.preferLagFn = fn }}
 }
 
+// SkipSerdeErrors opts in to skipping consumed records that fail KeySerde or
+// ValueSerde decoding, preventing a single poison record from stalling a
+// consumer that cannot process undecodable records.
+//
+// Undecodable records are still returned from polling with their SerdeErr
+// field set, and in order with the good records around them. Skip any record
+// with a non-nil SerdeErr: because the record was polled, committing (or
+// autocommitting) advances past it just as it does for good records, even if
+// it is the last record in its partition.
+//
+// If fn is non-nil, it is called with every undecodable record before the
+// record is returned, which can be used to forward the record to a dead
+// letter queue. The function is called while the fetch response is being
+// processed, serially per broker but concurrently across brokers, so it must
+// be safe for concurrent use and must not block. To produce to a dead letter
+// queue, use TryProduce or an asynchronous Produce with a promise rather than
+// ProduceSync. If the client restarts before a skipped record is committed,
+// the record is consumed again and fn is called again; fn should therefore
+// be idempotent.
+func SkipSerdeErrors(fn func(*Record)) ConsumerOpt {
+	return consumerOpt{func(cfg *cfg) { cfg.skipSerdeErrs, cfg.onSerdeErr = true, fn }}
+}
+
 // KeepFetchRetryableErrors switches the client to always return any retryable
 // broker error when fetching, rather than stripping them. By default, the
 // client strips retryable errors from fetch responses; these are usually
@@ -1537,6 +2164,32 @@ func OnPartitionsAssigned(onAssigned func(context.Context, *Client, map[string][
 	return groupOpt{func(cfg *cfg) { cfg.onAssigned, cfg.setAssigned = onAssigned, true }}
 }
 
+// OnPartitionsAssignedVeto sets a function to be called with newly assigned
+// partitions before OnPartitionsAssigned, allowing you to reject partitions
+// that this group member cannot handle (e.g., to enforce a per-instance cap on
+// the number of consumed partitions).
+//
+// The function returns the partitions to reject, or an error to reject every
+// newly assigned partition. Rejected partitions are not consumed, are not
+// passed to OnPartitionsAssigned, and are not reported as currently owned
+// partitions in the next join. If anything is rejected, the member rejoins
+// the group to trigger a new rebalance so that the partitions can be assigned
+// elsewhere. Partitions returned that were not newly assigned are ignored.
+//
+// Whether rejected partitions actually move to other members depends on the
+// group balancer: balancers are not aware of vetoes, and a balancer may
+// assign a rejected partition to this member again, in which case this
+// function is called again. The cooperative-sticky balancer generally gives
+// unowned partitions to the members with the fewest partitions.
+//
+// This function is called before heartbeating begins for the new group
+// session and should be fast: a slow function can exceed the session timeout.
+// The function is passed the client's context, which is only canceled if the
+// client is closed, and is given a new map that you are free to modify.
+func OnPartitionsAssignedVeto(onAssignedVeto func(context.Context, *Client, map[string][]int32) (map[string][]int32, error)) GroupOpt {
+	return groupOpt{func(cfg *cfg) { cfg.onAssignedVeto = onAssignedVeto }}
+}
+
 // OnPartitionsRevoked sets the function to be called once this group member
 // has partitions revoked.
 //
@@ -1568,6 +2221,32 @@ func OnPartitionsRevoked(onRevoked func(context.Context, *Client, map[string][]i
 	return groupOpt{func(cfg *cfg) { cfg.onRevoked, cfg.setRevoked = onRevoked, true }}
 }
 
+// OnRevokeCommit sets a function to decide what to commit when partitions are
+// revoked, overriding the default commit on revoke. The function is passed the
+// revoked partitions and all uncommitted offsets (everything polled that has
+// not yet been committed, the same as UncommittedOffsets), and returns the
+// offsets to commit. The returned offsets are committed synchronously before
+// the partitions are given up, and the result is passed to the commit
+// callback (see AutoCommitCallback). Returning nil skips committing.
+//
+// The function can return the uncommitted offsets unchanged to commit
+// everything polled, return MarkedOffsets to commit what the default
+// autocommit would commit, filter the offsets down to the revoked partitions,
+// or return nil after committing itself with any of the commit functions.
+// Because the decision is made on every revoke, this can be used to commit on
+// some rebalances and not others.
+//
+// This works with and without DisableAutoCommit. If OnPartitionsRevoked is
+// also set, this function and its commit run before OnPartitionsRevoked.
+// Like OnPartitionsRevoked, this is called at the end of every group session,
+// even if no partitions are being revoked, and is passed the client's context,
+// which is only canceled if the client is closed.
+//
+// This option cannot be used with a transactional client.
+func OnRevokeCommit(fn func(ctx context.Context, cl *Client, revoked map[string][]int32, uncommitted map[string]map[int32]EpochOffset) map[string]map[int32]EpochOffset) GroupOpt {
+	return groupOpt{func(cfg *cfg) { cfg.onRevokeCommit = fn }}
+}
+
 // OnPartitionsLost sets the function to be called on "fatal" group errors,
 // such as IllegalGeneration, UnknownMemberID, and authentication failures.
 // This function differs from OnPartitionsRevoked in that it is unlikely that
@@ -1603,6 +2282,26 @@ func OnOffsetsFetched(onFetched func(context.Context, *Client, *kmsg.OffsetFetch
 	return groupOpt{func(cfg *cfg) { cfg.onFetched = onFetched }}
 }
 
+// GroupOffsetStore sets an OffsetStore to commit offsets to and fetch offsets
+// from, rather than committing to and fetching from Kafka. The client still
+// uses Kafka for group membership and partition assignment; only offset
+// storage is delegated.
+//
+// Every commit the client issues, whether through autocommitting or through
+// any of the commit functions, is sent to the store. When partitions are
+// assigned, the client fetches the starting offsets from the store. Any
+// partition the store does not return an offset for begins consuming at the
+// ConsumeResetOffset.
+//
+// The OnOffsetsFetched callback and commit callbacks are still called with
+// responses built from what the store returned, as if the offsets were
+// fetched from or committed to Kafka.
+//
+// This option cannot be used with a transactional client.
+func GroupOffsetStore(store OffsetStore) GroupOpt {
+	return groupOpt{func(cfg *cfg) { cfg.offsetStore = store }}
+}
+
 // DisableAutoCommit disable auto committing.
 //
 // If you disable autocommitting, you may want to use a custom
@@ -1678,6 +2377,14 @@ func AutoCommitMarks() GroupOpt {
 // issues a leave group request on behalf of this instance ID (see kcl), or you
 // can manually use the kmsg package with a proper LeaveGroupRequest.
 //
+// The instance ID must be stable across restarts of the same logical
+// consumer: derive it from something durable (a hostname in a StatefulSet, a
+// configured shard number), never from randomness or the process ID. Two live
+// clients must not share an instance ID; the broker fences the older one.
+// Instance IDs must be non-empty, at most 249 characters, and only contain
+// ASCII alphanumerics, '.', '_', and '-'; NewClient returns an error otherwise.
+// The broker assigned member ID can be read with GroupMemberID.
+//
 // NOTE: Leaving a group with an instance ID is only supported in Kafka 2.4+.
 //
 // NOTE: If you restart a consumer group leader that is using an instance ID,
@@ -1688,6 +2395,25 @@ func InstanceID(id string) GroupOpt {
 	return groupOpt{func(cfg *cfg) { cfg.instanceID = &id }}
 }
 
+// validateInstanceID mirrors Kafka's validation of group instance IDs, which
+// follows the same rules as topic names.
+func validateInstanceID(id string) error {
+	switch {
+	case id == "":
+		return errors.New("invalid empty InstanceID")
+	case id == "." || id == "..":
+		return fmt.Errorf("invalid InstanceID %q", id)
+	case len(id) > 249:
+		return fmt.Errorf("invalid InstanceID %q: longer than the max 249 characters", id)
+	}
+	for _, c := range id {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '.' || c == '_' || c == '-') {
+			return fmt.Errorf("invalid InstanceID %q: contains character %q, only ASCII alphanumerics, '.', '_', and '-' are allowed", id, c)
+		}
+	}
+	return nil
+}
+
 // GroupProtocol sets the group's join protocol, overriding the default value
 // "consumer". The only reason to override this is if you are implementing
 // custom join and sync group logic.
@@ -1695,6 +2421,32 @@ func GroupProtocol(protocol string) GroupOpt {
 	return groupOpt{func(cfg *cfg) { cfg.protocol = protocol }}
 }
 
+// EnableNextGenGroupProtocol opts into the next generation consumer group
+// protocol (KIP-848, Kafka 3.7+ with the protocol enabled on the broker).
+//
+// With this protocol, group members heartbeat with ConsumerGroupHeartbeat
+// requests and the broker computes partition assignments; there is no
+// JoinGroup or SyncGroup and there are no group-wide stop the world
+// rebalances. Assignment changes are applied incrementally, similar to
+// cooperative rebalancing: OnPartitionsRevoked is called with only the
+// partitions this member is losing, and OnPartitionsAssigned with only the
+// partitions it is gaining.
+//
+// Because the broker assigns partitions, Balancers and GroupProtocol are not
+// used and OnPartitionsAssignedVeto cannot be used. The heartbeat interval
+// and session timeout are controlled by the broker, so HeartbeatInterval and
+// SessionTimeout are not used either.
+//
+// If no broker supports the ConsumerGroupHeartbeat request, the client logs
+// and falls back to the classic group protocol.
+//
+// The default MaxVersions are raised to allow ConsumerGroupHeartbeat and
+// OffsetCommit v9. If you use MaxVersions, your versions must allow both,
+// otherwise NewClient returns an error.
+func EnableNextGenGroupProtocol() GroupOpt {
+	return groupOpt{func(cfg *cfg) { cfg.nextGenGroup = true }}
+}
+
 // AutoCommitCallback sets the callback to use if autocommitting is enabled.
 // This overrides the default callback that logs errors and continues.
 func AutoCommitCallback(fn func(*Client, *kmsg.OffsetCommitRequest, *kmsg.OffsetCommitResponse, error)) GroupOpt {
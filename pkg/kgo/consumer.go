@@ -153,6 +153,7 @@ func (o Offset) At(at int64) Offset {
 
 type consumer struct {
 	bufferedRecords atomicI64
+	bufferedBytes   atomicI64
 
 	cl *Client
 
@@ -275,6 +276,13 @@ func (cl *Client) BufferedFetchRecords() int64 {
 	return cl.consumer.bufferedRecords.Load()
 }
 
+// BufferedFetchBytes returns the number of record bytes (keys, values, and
+// headers) currently buffered from fetching within the client. This is the
+// value that is compared against MaxBufferedFetchBytes.
+func (cl *Client) BufferedFetchBytes() int64 {
+	return cl.consumer.bufferedBytes.Load()
+}
+
 type usedCursors map[*cursor]struct{}
 
 func (u *usedCursors) use(c *cursor) {
@@ -525,6 +533,71 @@ func (cl *Client) AllowRebalance() {
 	cl.consumer.allowRebalance()
 }
 
+// ProcessPartitions polls fetches in a loop and calls fn concurrently across
+// partitions, but serially and in order within each partition. Each poll is
+// fully processed before the next poll: fetches are grouped per partition, fn
+// is called in a goroutine per partition, and all goroutines must return
+// before polling again. This is the common "goroutine per partition" pattern,
+// and avoids one slow partition from blocking processing of all others within
+// a single poll.
+//
+// fn may be called with a partition that has a non-nil Err and no records, as
+// well as with the injected errors described in PollFetches. This function
+// returns ErrClientClosed when the client is closed, or the context's error
+// when the context is canceled.
+//
+// Offsets are tracked so that committing is safe: if you use AutoCommitMarks,
+// the records of a partition are marked for commit only after fn returns for
+// that partition, and if you use BlockRebalanceOnPoll, rebalances are allowed
+// only after all partitions from a poll have been processed. With the default
+// autocommitting (without marks), offsets are committed as soon as they are
+// polled, which may commit records that fn has not finished processing.
+func (cl *Client) ProcessPartitions(ctx context.Context, fn func(FetchTopicPartition)) error {
+	type tp struct {
+		t string
+		p int32
+	}
+	for {
+		fetches := cl.PollFetches(ctx)
+		if fetches.IsClientClosed() {
+			return ErrClientClosed
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		var order []tp
+		byPartition := make(map[tp][]FetchTopicPartition)
+		fetches.EachPartition(func(p FetchTopicPartition) {
+			key := tp{p.Topic, p.Partition}
+			if _, exists := byPartition[key]; !exists {
+				order = append(order, key)
+			}
+			byPartition[key] = append(byPartition[key], p)
+		})
+
+		var wg sync.WaitGroup
+		for _, key := range order {
+			ps := byPartition[key]
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for _, p := range ps {
+					fn(p)
+					if len(p.Records) > 0 {
+						cl.MarkCommitRecords(p.Records[len(p.Records)-1])
+					}
+				}
+			}()
+		}
+		wg.Wait()
+
+		if cl.cfg.blockRebalanceOnPoll {
+			cl.AllowRebalance()
+		}
+	}
+}
+
 // UpdateFetchMaxBytes updates the max bytes that a fetch request will ask for
 // and the max partition bytes that a fetch request will ask for each
 // partition.
@@ -533,6 +606,19 @@ func (cl *Client) UpdateFetchMaxBytes(maxBytes, maxPartBytes int32) {
 	cl.cfg.maxPartBytes.store(maxPartBytes)
 }
 
+// SetFetchIsolationLevel changes the isolation level used for fetching,
+// overriding what was set with FetchIsolationLevel. The new level is used for
+// the next fetch request to every broker; any open fetch session is reset so
+// that the new level takes effect in a fresh session. Offset listing for
+// resets (e.g. resetting to the last stable offset) also uses the new level.
+//
+// Fetches that were already buffered before this call are not discarded and
+// are still returned from polling; these may contain records read with the
+// prior isolation level.
+func (cl *Client) SetFetchIsolationLevel(level IsolationLevel) {
+	cl.cfg.isolationLevel.store(int32(level.level))
+}
+
 // PauseFetchTopics sets the client to no longer fetch the given topics and
 // returns all currently paused topics. Paused topics persist until resumed.
 // You can call this function with no topics to simply receive the list of
@@ -1272,6 +1358,7 @@ type consumerSession struct {
 	// they send back when they are done. Thus, three level chan.
 	desireFetchCh       chan chan chan struct{}
 	cancelFetchCh       chan chan chan struct{}
+	unbufferedCh        chan struct{} // signaled when buffered bytes drop while a max is set
 	allowedFetches      int
 	fetchManagerStarted atomicBool // atomic, once true, we start the fetch manager
 
@@ -1320,6 +1407,7 @@ func (c *consumer) newConsumerSession(tps *topicsPartitions) *consumerSession {
 		desireFetchCh: make(chan chan chan struct{}),
 
 		cancelFetchCh:  make(chan chan chan struct{}, 4),
+		unbufferedCh:   make(chan struct{}, 1),
 		allowedFetches: c.cl.cfg.maxConcurrentFetches,
 	}
 	session.workersCond = sync.NewCond(&session.workersMu)
@@ -1364,12 +1452,17 @@ func (s *consumerSession) manageFetchConcurrency() {
 
 		case <-doneFetch:
 			activeFetches--
+		case <-s.unbufferedCh:
 		case <-ctxCh:
 			wantQuit = true
 			ctxCh = nil
 		}
 
-		if len(wantFetch) > 0 && (activeFetches < s.allowedFetches || s.allowedFetches == 0) { // 0 means unbounded
+		// If we have too many bytes buffered, we do not allow a new
+		// fetch. Buffered bytes only drop when records are polled,
+		// and any poll that drops buffered bytes signals
+		// unbufferedCh, waking us up to check again.
+		if len(wantFetch) > 0 && (activeFetches < s.allowedFetches || s.allowedFetches == 0) && !s.c.bufferedBytesExceeded() { // 0 means unbounded
 			wantFetch[0] <- doneFetch
 			wantFetch = wantFetch[1:]
 			activeFetches++
@@ -1403,6 +1496,25 @@ func (s *consumerSession) decWorker() {
 	}
 }
 
+func (c *consumer) bufferedBytesExceeded() bool {
+	max := c.cl.cfg.maxBufferedFetchBytes
+	return max > 0 && c.bufferedBytes.Load() >= max
+}
+
+// unbufferedBytes drops nbytes from our buffered bytes and, if we are
+// limiting buffered bytes, wakes the fetch manager so that it can check
+// whether a fetch is now allowed.
+func (c *consumer) unbufferedBytes(nbytes int64) {
+	c.bufferedBytes.Add(-nbytes)
+	if c.cl.cfg.maxBufferedFetchBytes <= 0 || nbytes == 0 {
+		return
+	}
+	select {
+	case c.loadSession().unbufferedCh <- struct{}{}:
+	default:
+	}
+}
+
 // noConsumerSession exists because we cannot store nil into an atomic.Value.
 var noConsumerSession = new(consumerSession)
 
@@ -1802,7 +1914,7 @@ func (l *loadedOffsets) addAll(as []loadedOffset) loadedOffsets {
 func (cl *Client) listOffsetsForBrokerLoad(ctx context.Context, broker *broker, load offsetLoadMap, tps *topicsPartitions, results chan<- loadedOffsets) {
 	loaded := loadedOffsets{broker: broker.meta.NodeID, loadType: loadTypeList}
 
-	req1, req2 := load.buildListReq(cl.cfg.isolationLevel)
+	req1, req2 := load.buildListReq(int8(cl.cfg.isolationLevel.load()))
 	var (
 		wg     sync.WaitGroup
 		kresp2 kmsg.Response
@@ -3,6 +3,7 @@ package kgo
 import (
 	"context"
 	"fmt"
+	"sync"
 	"testing"
 	"time"
 )
@@ -199,3 +200,127 @@ func TestIssue434(t *testing.T) {
 		}
 	}
 }
+
+// Ensure ProcessPartitions processes each partition serially and in order.
+func TestProcessPartitions(t *testing.T) {
+	t.Parallel()
+
+	const nparts, nrecs = 3, 30
+	topic, cleanup := tmpTopicPartitions(t, nparts)
+	defer cleanup()
+
+	cl, _ := NewClient(
+		getSeedBrokers(),
+		DefaultProduceTopic(topic),
+		RecordPartitioner(ManualPartitioner()),
+		UnknownTopicRetries(-1),
+		ConsumeTopics(topic),
+	)
+	defer cl.Close()
+
+	var rs []*Record
+	for i := 0; i < nrecs; i++ {
+		rs = append(rs, &Record{Partition: int32(i % nparts), Value: []byte(fmt.Sprint(i))})
+	}
+	if err := cl.ProduceSync(context.Background(), rs...).FirstErr(); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var (
+		mu      sync.Mutex
+		active  = make(map[int32]bool)
+		next    = make(map[int32]int64)
+		seen    int
+		errOnce sync.Once
+	)
+	err := cl.ProcessPartitions(ctx, func(p FetchTopicPartition) {
+		mu.Lock()
+		if active[p.Partition] {
+			errOnce.Do(func() { t.Errorf("partition %d processed concurrently", p.Partition) })
+		}
+		active[p.Partition] = true
+		mu.Unlock()
+
+		time.Sleep(time.Millisecond)
+
+		mu.Lock()
+		defer mu.Unlock()
+		active[p.Partition] = false
+		p.EachRecord(func(r *Record) {
+			if r.Offset != next[r.Partition] {
+				t.Errorf("partition %d: got offset %d != exp %d", r.Partition, r.Offset, next[r.Partition])
+			}
+			next[r.Partition] = r.Offset + 1
+			seen++
+		})
+		if seen == nrecs {
+			cancel()
+		}
+	})
+	if err != context.Canceled {
+		t.Errorf("got err %v != exp context.Canceled", err)
+	}
+	if seen != nrecs {
+		t.Errorf("saw %d records != exp %d", seen, nrecs)
+	}
+}
+
+type slowUnbufferHook struct{}
+
+func (slowUnbufferHook) OnFetchRecordUnbuffered(*Record, bool) { time.Sleep(time.Millisecond) }
+
+// Ensure that we can consume past MaxBufferedFetchBytes. The slow unbuffered
+// hook widens the window between a fetch being polled and its bytes being
+// dropped; the fetch manager must not stall in that window.
+func TestMaxBufferedFetchBytes(t *testing.T) {
+	t.Parallel()
+
+	const (
+		batches = 30
+		nrecs   = 10 * batches
+	)
+
+	topic, cleanup := tmpTopic(t)
+	defer cleanup()
+
+	cl, _ := NewClient(
+		getSeedBrokers(),
+		DefaultProduceTopic(topic),
+		UnknownTopicRetries(-1),
+		ConsumeTopics(topic),
+		MaxBufferedFetchBytes(1000),
+		FetchMaxPartitionBytes(2000),
+		WithHooks(slowUnbufferHook{}),
+	)
+	defer cl.Close()
+
+	// We produce many small batches so that consuming requires many
+	// fetches, each of which exceeds our buffered byte limit.
+	value := make([]byte, 100)
+	for i := 0; i < batches; i++ {
+		var rs []*Record
+		for j := 0; j < nrecs/batches; j++ {
+			rs = append(rs, &Record{Value: value})
+		}
+		if err := cl.ProduceSync(context.Background(), rs...).FirstErr(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	var consumed int
+	for consumed < nrecs {
+		fs := cl.PollFetches(ctx)
+		if err := fs.Err0(); err != nil {
+			t.Fatalf("consumed %d of %d records before error: %v", consumed, nrecs, err)
+		}
+		consumed += fs.NumRecords()
+	}
+	if consumed != nrecs {
+		t.Errorf("consumed %d records != exp %d", consumed, nrecs)
+	}
+}
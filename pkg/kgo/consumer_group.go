@@ -26,6 +26,11 @@ type groupConsumer struct {
 
 	cooperative atomicBool // true if the group balancer chosen during Join is cooperative
 
+	// nextGen is whether we are using the next generation group protocol
+	// (KIP-848). This is set once when manage begins and is read in leave
+	// after manage is done.
+	nextGen bool
+
 	// The data for topics that the user assigned. Metadata updates the
 	// atomic.Value in each pointer atomically. If we are consuming via
 	// regex, metadata grabs the lock to add new topics.
@@ -197,6 +202,70 @@ func (cl *Client) GroupMetadata() (string, int32) {
 	return g.memberID, g.generation
 }
 
+// GroupMemberID returns the member ID the broker assigned to this client when
+// it joined its group, or an empty string if not in a group. The member ID
+// changes every time the client joins the group fresh (for example, after
+// leaving or being fenced), and matches the member ID shown when describing
+// the group, which makes it useful for correlating client logs with broker
+// side group state. If using InstanceID, the instance ID is stable while the
+// member ID is not.
+func (cl *Client) GroupMemberID() string {
+	memberID, _ := cl.GroupMetadata()
+	return memberID
+}
+
+// GroupState is a snapshot of the client's membership in its consumer group,
+// as returned from Client.GroupState.
+type GroupState struct {
+	// Group is the group being consumed.
+	Group string
+	// MemberID is the member ID the broker assigned to this client, or
+	// empty if the client is not currently in the group.
+	MemberID string
+	// InstanceID is the InstanceID the client was configured with, if any.
+	InstanceID *string
+	// Generation is the current generation of the group (or, with the
+	// next generation group protocol, the member epoch), or -1 if the
+	// client is not currently in the group.
+	Generation int32
+	// Leader is whether this client is the leader of the group. This is
+	// always false with the next generation group protocol, where the
+	// broker computes assignments.
+	Leader bool
+	// Assigned contains the partitions currently assigned to this client.
+	// This is a copy and can be freely modified.
+	Assigned map[string][]int32
+}
+
+// GroupState returns a snapshot of the client's current group membership: the
+// member ID, generation, and assigned partitions. This is a local view
+// maintained by the group management loop and does not issue any requests,
+// making it cheap to use in logs and health checks. This returns false if the
+// client is not consuming as a group member.
+//
+// The assignment is what the group management loop most recently assigned or
+// revoked; while a rebalance is in progress, it may briefly differ from what
+// was last passed to the OnPartitions callbacks.
+func (cl *Client) GroupState() (GroupState, bool) {
+	g := cl.consumer.g
+	if g == nil {
+		return GroupState{}, false
+	}
+	state := GroupState{
+		Group:      g.cfg.group,
+		InstanceID: g.cfg.instanceID,
+		Generation: -1,
+		Leader:     g.leader.Load(),
+		Assigned:   g.nowAssigned.clone(),
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.memberID != "" {
+		state.MemberID, state.Generation = g.memberID, g.generation
+	}
+	return state, true
+}
+
 func (c *consumer) initGroup() {
 	ctx, cancel := context.WithCancel(c.cl.ctx)
 	g := &groupConsumer{
@@ -225,6 +294,14 @@ func (c *consumer) initGroup() {
 		// set by options.
 		if !g.cfg.setRevoked {
 			g.cfg.onRevoked = g.defaultRevoke
+		} else if g.cfg.onRevokeCommit != nil {
+			user := g.cfg.onRevoked
+			g.cfg.onRevoked = func(ctx context.Context, cl *Client, revoked map[string][]int32) {
+				g.revokeCommit(revoked)
+				if user != nil {
+					user(ctx, cl, revoked)
+				}
+			}
 		}
 		// For onLost, we do not want to commit in onLost, so we
 		// explicitly set onLost to an empty function to avoid the
@@ -294,6 +371,25 @@ func (c *consumer) initGroup() {
 	}
 }
 
+// clearAssigned invalidates everything we are consuming and forgets our
+// assignment and uncommitted offsets. This is called when we lose our
+// partitions, while the rebalance is blocking polls (if configured).
+func (g *groupConsumer) clearAssigned(why string) {
+	g.c.mu.Lock()
+	g.c.assignPartitions(nil, assignInvalidateAll, nil, why)
+	g.mu.Lock()     // before allowing poll to touch uncommitted, lock the group
+	g.c.mu.Unlock() // now part of poll can continue
+	g.uncommitted = nil
+	g.mu.Unlock()
+
+	g.nowAssigned.store(nil)
+	g.lastAssigned = nil
+	g.fetching = nil
+
+	g.leader.Store(false)
+	g.resetExternal()
+}
+
 // Manages the group consumer's join / sync / heartbeat / fetch offset flow.
 //
 // Once a group is assigned, we fire a metadata request for all topics the
@@ -304,14 +400,18 @@ func (g *groupConsumer) manage() {
 	defer close(g.manageDone)
 	g.cfg.logger.Log(LogLevelInfo, "beginning to manage the group lifecycle", "group", g.cfg.group)
 
+	g.nextGen = g.useNextGen()
+
 	var consecutiveErrors int
 	joinWhy := "beginning to manage the group lifecycle"
 	for {
 		if joinWhy == "" {
 			joinWhy = "rejoining from normal rebalance"
 		}
-		err := g.joinAndSync(joinWhy)
-		if err == nil {
+		var err error
+		if g.nextGen {
+			err = g.nextGenSession()
+		} else if err = g.joinAndSync(joinWhy); err == nil {
 			if joinWhy, err = g.setupAssignedAndHeartbeat(); err != nil {
 				if errors.Is(err, kerr.RebalanceInProgress) {
 					err = nil
@@ -365,21 +465,7 @@ func (g *groupConsumer) manage() {
 		// consuming. We need to invalidate everything. Waiting to
 		// resume from poll is necessary, but the user will likely be
 		// unable to commit.
-		{
-			g.c.mu.Lock()
-			g.c.assignPartitions(nil, assignInvalidateAll, nil, "clearing assignment at end of group management session")
-			g.mu.Lock()     // before allowing poll to touch uncommitted, lock the group
-			g.c.mu.Unlock() // now part of poll can continue
-			g.uncommitted = nil
-			g.mu.Unlock()
-
-			g.nowAssigned.store(nil)
-			g.lastAssigned = nil
-			g.fetching = nil
-
-			g.leader.Store(false)
-			g.resetExternal()
-		}
+		g.clearAssigned("clearing assignment at end of group management session")
 
 		// Unblock bolling now that we have called onLost and
 		// re-assigned.
@@ -439,6 +525,13 @@ func (g *groupConsumer) leave() (wait func()) {
 			return
 		}
 
+		if g.nextGen {
+			if wasManaging {
+				g.leaveNextGen()
+			}
+			return
+		}
+
 		if g.cfg.instanceID == nil {
 			g.cfg.logger.Log(LogLevelInfo, "leaving group",
 				"group", g.cfg.group,
@@ -735,6 +828,66 @@ func (s *assignRevokeSession) revoke(g *groupConsumer, leaving bool) <-chan stru
 	return s.revokeDone
 }
 
+// vetoAssigned calls the user's OnPartitionsAssignedVeto, if any, and removes
+// any rejected partitions from what was added and from what we now own. If
+// anything is rejected, we rejoin so that the partitions can be reassigned.
+func (g *groupConsumer) vetoAssigned(added map[string][]int32) map[string][]int32 {
+	if g.cfg.onAssignedVeto == nil || len(added) == 0 {
+		return added
+	}
+	dup := make(map[string][]int32, len(added))
+	for t, ps := range added {
+		dup[t] = append([]int32(nil), ps...)
+	}
+	rejected, err := g.cfg.onAssignedVeto(g.cl.ctx, g.cl, dup)
+	if err != nil {
+		g.cfg.logger.Log(LogLevelInfo, "OnPartitionsAssignedVeto returned an error, rejecting all newly assigned partitions", "group", g.cfg.group, "err", err)
+		rejected = added
+	}
+
+	isRejected := make(map[string]map[int32]bool, len(rejected))
+	for t, ps := range rejected {
+		isRejected[t] = make(map[int32]bool, len(ps))
+		for _, p := range ps {
+			isRejected[t][p] = true
+		}
+	}
+
+	kept := make(map[string][]int32, len(added))
+	vetoed := make(map[string][]int32)
+	for t, ps := range added {
+		for _, p := range ps {
+			if isRejected[t][p] {
+				vetoed[t] = append(vetoed[t], p)
+			} else {
+				kept[t] = append(kept[t], p)
+			}
+		}
+	}
+	if len(vetoed) == 0 {
+		return added
+	}
+
+	g.nowAssigned.write(func(nowAssigned map[string][]int32) {
+		for t := range vetoed {
+			var ps []int32
+			for _, p := range nowAssigned[t] {
+				if !isRejected[t][p] {
+					ps = append(ps, p)
+				}
+			}
+			if len(ps) == 0 {
+				delete(nowAssigned, t)
+			} else {
+				nowAssigned[t] = ps
+			}
+		}
+	})
+	g.cfg.logger.Log(LogLevelInfo, "rejected newly assigned partitions per OnPartitionsAssignedVeto, rejoining", "group", g.cfg.group, "rejected", mtps(vetoed))
+	g.rejoin("rejoin after rejecting assigned partitions")
+	return kept
+}
+
 // This chunk of code "pre" revokes lost partitions for the cooperative
 // consumer and then begins heartbeating while fetching offsets. This returns
 // when heartbeating errors (or if fetch offsets errors).
@@ -754,6 +907,7 @@ func (g *groupConsumer) setupAssignedAndHeartbeat() (string, error) {
 
 	s := newAssignRevokeSession()
 	added, lost := g.diffAssigned()
+	added = g.vetoAssigned(added)
 	g.lastAssigned = g.nowAssigned.clone() // now that we are done with our last assignment, update it per the new assignment
 
 	g.cfg.logger.Log(LogLevelInfo, "new group session begun", "group", g.cfg.group, "added", mtps(added), "lost", mtps(lost))
@@ -1490,6 +1644,10 @@ start:
 	fetchDone := make(chan struct{})
 	go func() {
 		defer close(fetchDone)
+		if g.cfg.offsetStore != nil {
+			resp, err = g.fetchOffsetsFromStore(ctx, req)
+			return
+		}
 		resp, err = req.RequestWith(ctx, g.cl)
 	}()
 	select {
@@ -2415,6 +2573,261 @@ func (cl *Client) commitOffsets(ctx context.Context, offsets map[string]map[int3
 	return rerr
 }
 
+// CommitOffsetsRetry issues a synchronous offset commit for the given offsets,
+// retrying the entire commit if it fails with REBALANCE_IN_PROGRESS or a
+// coordinator error (COORDINATOR_NOT_AVAILABLE, COORDINATOR_LOAD_IN_PROGRESS,
+// NOT_COORDINATOR). This returns nil once the commit succeeds, the first
+// non-retryable error, or the context error if the context is canceled while
+// backing off.
+//
+// The backoff function is called with the number of failed tries so far (one
+// on the first failure) and returns how long to wait before trying again. If
+// backoff is nil, the client's RetryBackoffFn is used.
+//
+// Each try uses the group's current generation and member ID. If a retried
+// commit is retrying across a rebalance, you may be committing offsets for
+// partitions you no longer own; the same caveats as CommitRecords apply.
+func (cl *Client) CommitOffsetsRetry(
+	ctx context.Context,
+	offsets map[string]map[int32]EpochOffset,
+	backoff func(int) time.Duration,
+) error {
+	if backoff == nil {
+		backoff = cl.cfg.retryBackoff
+	}
+	for tries := 1; ; tries++ {
+		err := cl.commitOffsets(ctx, offsets)
+		if err == nil || !isRetryableCommitErr(err) {
+			return err
+		}
+		wait := backoff(tries)
+		cl.cfg.logger.Log(LogLevelInfo, "commit failed with retryable error, backing off before retrying",
+			"group", cl.cfg.group,
+			"tries", tries,
+			"backoff", wait,
+			"err", err,
+		)
+		after := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			after.Stop()
+			return ctx.Err()
+		case <-after.C:
+		}
+	}
+}
+
+func isRetryableCommitErr(err error) bool {
+	return errors.Is(err, kerr.RebalanceInProgress) ||
+		errors.Is(err, kerr.CoordinatorNotAvailable) ||
+		errors.Is(err, kerr.CoordinatorLoadInProgress) ||
+		errors.Is(err, kerr.NotCoordinator)
+}
+
+// PartitionLag is the lag for a single partition, as returned from Lag.
+type PartitionLag struct {
+	// Committed is the offset committed for this partition, or -1 if
+	// nothing has been committed.
+	Committed int64
+	// Start is the log start offset of this partition. This is only
+	// listed if nothing has been committed; otherwise, this is -1.
+	Start int64
+	// End is the end offset of this partition: the high watermark, or the
+	// last stable offset if consuming with the read committed isolation
+	// level. This is -1 if the end offset could not be listed.
+	End int64
+	// Lag is the end offset minus the committed offset. If nothing has
+	// been committed, this is the end offset minus the log start offset.
+	// This is -1 if Err is non-nil.
+	Lag int64
+	// Err is any error encountered fetching the committed offset or
+	// listing the end offset for this partition.
+	Err error
+}
+
+// Lag returns the per-partition lag of the group this client is consuming in.
+// This issues one OffsetFetch request to fetch all offsets committed for the
+// group and one round of ListOffsets requests to list the end offsets of all
+// committed and currently assigned partitions. If using GroupOffsetStore,
+// committed offsets are fetched from the store for assigned partitions only.
+// Partitions that are assigned but have no commits are reported as lagging by
+// everything in the log: the end offset minus the log start offset, which is
+// listed with a second round of ListOffsets requests.
+//
+// If the client is not consuming in a group, this returns an error. If the
+// OffsetFetch request fails, this returns the error. Per-partition errors,
+// including ListOffsets failures, are reported in each PartitionLag.
+//
+// To track lag without issuing requests, see FetchPartition.Lag.
+func (cl *Client) Lag(ctx context.Context) (map[string]map[int32]PartitionLag, error) {
+	g := cl.consumer.g
+	if g == nil {
+		return nil, errNotGroup
+	}
+
+	fetchReq := kmsg.NewPtrOffsetFetchRequest()
+	fetchReq.Group = g.cfg.group
+	fetchReq.Topics = nil // nil fetches all committed offsets
+	var fetchResp *kmsg.OffsetFetchResponse
+	var err error
+	if g.cfg.offsetStore != nil {
+		// Stores cannot list everything committed; we can only ask
+		// for what we are assigned.
+		for t, ps := range g.nowAssigned.read() {
+			rt := kmsg.NewOffsetFetchRequestTopic()
+			rt.Topic = t
+			rt.Partitions = ps
+			fetchReq.Topics = append(fetchReq.Topics, rt)
+		}
+		fetchResp, err = g.fetchOffsetsFromStore(ctx, fetchReq)
+	} else {
+		fetchResp, err = fetchReq.RequestWith(ctx, cl)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := kerr.ErrorForCode(fetchResp.ErrorCode); err != nil {
+		return nil, err
+	}
+
+	lags := make(map[string]map[int32]PartitionLag)
+	set := func(t string, p int32, l PartitionLag) {
+		lt := lags[t]
+		if lt == nil {
+			lt = make(map[int32]PartitionLag)
+			lags[t] = lt
+		}
+		lt[p] = l
+	}
+	for _, t := range fetchResp.Topics {
+		for _, p := range t.Partitions {
+			set(t.Topic, p.Partition, PartitionLag{
+				Committed: p.Offset,
+				Start:     -1,
+				End:       -1,
+				Err:       kerr.ErrorForCode(p.ErrorCode),
+			})
+		}
+	}
+	for t, ps := range g.nowAssigned.read() {
+		for _, p := range ps {
+			if _, exists := lags[t][p]; !exists {
+				set(t, p, PartitionLag{Committed: -1, Start: -1, End: -1})
+			}
+		}
+	}
+	if len(lags) == 0 {
+		return lags, nil
+	}
+
+	// We list the end offset of every partition, and the start offset of
+	// every partition that has no commit: these lag by everything from
+	// the start of the log, not from offset 0.
+	ends := make(map[string][]int32)
+	starts := make(map[string][]int32)
+	for t, ps := range lags {
+		for p, l := range ps {
+			ends[t] = append(ends[t], p)
+			if l.Committed < 0 && l.Err == nil {
+				starts[t] = append(starts[t], p)
+			}
+		}
+	}
+	cl.listLagOffsets(ctx, -1, ends, func(t string, p int32, offset int64, err error) {
+		l := lags[t][p]
+		if l.Err == nil {
+			l.Err = err
+		}
+		if l.Err == nil {
+			l.End = offset
+		}
+		lags[t][p] = l
+	})
+	cl.listLagOffsets(ctx, -2, starts, func(t string, p int32, offset int64, err error) {
+		l := lags[t][p]
+		if l.Err == nil {
+			l.Err = err
+		}
+		if l.Err == nil {
+			l.Start = offset
+		}
+		lags[t][p] = l
+	})
+
+	for _, ps := range lags {
+		for p, l := range ps {
+			l.Lag = -1
+			if l.Err == nil {
+				if l.Committed >= 0 {
+					l.Lag = l.End - l.Committed
+				} else {
+					l.Lag = l.End - l.Start
+				}
+			}
+			ps[p] = l
+		}
+	}
+	return lags, nil
+}
+
+// listLagOffsets lists offsets at the given timestamp (-1 for the end, -2 for
+// the start) for all partitions in tps, calling fn with each partition's
+// offset or the error listing it.
+func (cl *Client) listLagOffsets(ctx context.Context, timestamp int64, tps map[string][]int32, fn func(t string, p int32, offset int64, err error)) {
+	if len(tps) == 0 {
+		return
+	}
+	listReq := kmsg.NewPtrListOffsetsRequest()
+	listReq.ReplicaID = -1
+	listReq.IsolationLevel = int8(cl.cfg.isolationLevel.load())
+	listed := make(map[string]map[int32]bool) // requested partitions, and whether we saw them
+	for t, ps := range tps {
+		rt := kmsg.NewListOffsetsRequestTopic()
+		rt.Topic = t
+		lp := make(map[int32]bool, len(ps))
+		for _, p := range ps {
+			rp := kmsg.NewListOffsetsRequestTopicPartition()
+			rp.Partition = p
+			rp.Timestamp = timestamp
+			rt.Partitions = append(rt.Partitions, rp)
+			lp[p] = false
+		}
+		listReq.Topics = append(listReq.Topics, rt)
+		listed[t] = lp
+	}
+
+	for _, shard := range cl.RequestSharded(ctx, listReq) {
+		if shard.Err != nil {
+			req := shard.Req.(*kmsg.ListOffsetsRequest)
+			for _, t := range req.Topics {
+				for _, p := range t.Partitions {
+					listed[t.Topic][p.Partition] = true
+					fn(t.Topic, p.Partition, -1, shard.Err)
+				}
+			}
+			continue
+		}
+		resp := shard.Resp.(*kmsg.ListOffsetsResponse)
+		for _, t := range resp.Topics {
+			lp := listed[t.Topic]
+			for _, p := range t.Partitions {
+				if seen, requested := lp[p.Partition]; !requested || seen {
+					continue
+				}
+				lp[p.Partition] = true
+				fn(t.Topic, p.Partition, p.Offset, kerr.ErrorForCode(p.ErrorCode))
+			}
+		}
+	}
+	for t, lp := range listed {
+		for p, seen := range lp {
+			if !seen {
+				fn(t, p, -1, errMissingListedPartition)
+			}
+		}
+	}
+}
+
 // CommitOffsetsSync cancels any active CommitOffsets, begins a commit that
 // cannot be canceled, and waits for that commit to complete. This function
 // will not return until the commit is done and the onDone callback is
@@ -2621,7 +3034,11 @@ func (cl *Client) CommitOffsets(
 //
 // Note that the heartbeat loop invalidates all buffered, unpolled fetches
 // before revoking, meaning this truly will commit all polled fetches.
-func (g *groupConsumer) defaultRevoke(context.Context, *Client, map[string][]int32) {
+func (g *groupConsumer) defaultRevoke(_ context.Context, _ *Client, revoked map[string][]int32) {
+	if g.cfg.onRevokeCommit != nil {
+		g.revokeCommit(revoked)
+		return
+	}
 	if !g.cfg.autocommitDisable {
 		// We use the client's context rather than the group context,
 		// because this could come from the group being left. The group
@@ -2630,6 +3047,17 @@ func (g *groupConsumer) defaultRevoke(context.Context, *Client, map[string][]int
 	}
 }
 
+// revokeCommit asks the user's OnRevokeCommit function what to commit for the
+// revoked partitions and commits it, waiting for the commit to finish.
+func (g *groupConsumer) revokeCommit(revoked map[string][]int32) {
+	commit := g.cfg.onRevokeCommit(g.cl.ctx, g.cl, revoked, g.getUncommitted(true))
+	if len(commit) == 0 {
+		g.cfg.logger.Log(LogLevelInfo, "OnRevokeCommit returned no offsets, skipping commit on revoke", "group", g.cfg.group)
+		return
+	}
+	g.commitOffsetsSync(g.cl.ctx, commit, g.cfg.commitCallback)
+}
+
 // The actual logic to commit. This is called under two locks:
 //   - g.noCommitDuringJoinAndSync.RLock()
 //   - g.mu.Lock()
@@ -2747,7 +3175,13 @@ func (g *groupConsumer) commit(
 			}
 		}
 
-		resp, err := req.RequestWith(commitCtx, g.cl)
+		var resp *kmsg.OffsetCommitResponse
+		var err error
+		if g.cfg.offsetStore != nil {
+			resp, err = g.commitToStore(commitCtx, req)
+		} else {
+			resp, err = req.RequestWith(commitCtx, g.cl)
+		}
 		if err != nil {
 			onDone(g.cl, req, nil, err)
 			return
@@ -2757,6 +3191,91 @@ func (g *groupConsumer) commit(
 	}()
 }
 
+// OffsetStore is an external storage backend for group offsets, which can be
+// used with the GroupOffsetStore option. A store can be used to keep offsets
+// alongside the output of processing (e.g., in the same database transaction),
+// which allows for exactly once processing without Kafka transactions.
+type OffsetStore interface {
+	// Commit durably stores the given offsets for the group. If this
+	// returns an error, the commit is considered failed for all
+	// partitions.
+	Commit(ctx context.Context, group string, offsets map[string]map[int32]EpochOffset) error
+
+	// Fetch returns the stored offsets for the given partitions of the
+	// group. Partitions without a stored offset can be omitted, or can
+	// have a negative offset. If this returns an error, the group session
+	// is exited and the group is rejoined, the same as if fetching offsets
+	// from Kafka failed.
+	Fetch(ctx context.Context, group string, partitions map[string][]int32) (map[string]map[int32]EpochOffset, error)
+}
+
+// fetchOffsetsFromStore fetches offsets from the user's OffsetStore and
+// returns them as an OffsetFetchResponse, allowing the rest of fetchOffsets
+// to be unaware of the store.
+func (g *groupConsumer) fetchOffsetsFromStore(ctx context.Context, req *kmsg.OffsetFetchRequest) (*kmsg.OffsetFetchResponse, error) {
+	partitions := make(map[string][]int32, len(req.Topics))
+	for _, t := range req.Topics {
+		partitions[t.Topic] = t.Partitions
+	}
+	stored, err := g.cfg.offsetStore.Fetch(ctx, g.cfg.group, partitions)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := req.ResponseKind().(*kmsg.OffsetFetchResponse)
+	resp.Version = req.MaxVersion() // we always have leader epochs
+	for t, ps := range partitions {
+		rt := kmsg.NewOffsetFetchResponseTopic()
+		rt.Topic = t
+		for _, p := range ps {
+			rp := kmsg.NewOffsetFetchResponseTopicPartition()
+			rp.Partition = p
+			rp.Offset = -1
+			rp.LeaderEpoch = -1
+			if eo, ok := stored[t][p]; ok && eo.Offset >= 0 {
+				rp.Offset = eo.Offset
+				rp.LeaderEpoch = eo.Epoch
+			}
+			rt.Partitions = append(rt.Partitions, rp)
+		}
+		resp.Topics = append(resp.Topics, rt)
+	}
+	return resp, nil
+}
+
+// commitToStore commits the offsets in the request to the user's OffsetStore
+// and returns a successful OffsetCommitResponse for every partition in the
+// request. The offsets come from the request rather than from what we tracked
+// as uncommitted so that any PreCommitFnContext modifications are stored.
+func (g *groupConsumer) commitToStore(ctx context.Context, req *kmsg.OffsetCommitRequest) (*kmsg.OffsetCommitResponse, error) {
+	offsets := make(map[string]map[int32]EpochOffset, len(req.Topics))
+	for _, t := range req.Topics {
+		ps := offsets[t.Topic]
+		if ps == nil {
+			ps = make(map[int32]EpochOffset, len(t.Partitions))
+			offsets[t.Topic] = ps
+		}
+		for _, p := range t.Partitions {
+			ps[p.Partition] = EpochOffset{Epoch: p.LeaderEpoch, Offset: p.Offset}
+		}
+	}
+	if err := g.cfg.offsetStore.Commit(ctx, g.cfg.group, offsets); err != nil {
+		return nil, err
+	}
+	resp := req.ResponseKind().(*kmsg.OffsetCommitResponse)
+	for _, t := range req.Topics {
+		rt := kmsg.NewOffsetCommitResponseTopic()
+		rt.Topic = t.Topic
+		for _, p := range t.Partitions {
+			rp := kmsg.NewOffsetCommitResponseTopicPartition()
+			rp.Partition = p.Partition
+			rt.Partitions = append(rt.Partitions, rp)
+		}
+		resp.Topics = append(resp.Topics, rt)
+	}
+	return resp, nil
+}
+
 type reNews struct {
 	added   map[string][]string
 	skipped []string
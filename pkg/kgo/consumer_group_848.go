@@ -0,0 +1,378 @@
+package kgo
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/burningass23/franz-go/pkg/kerr"
+	"github.com/burningass23/franz-go/pkg/kmsg"
+)
+
+// This file implements the next generation consumer group protocol (KIP-848).
+//
+// Rather than joining and syncing, a member repeatedly issues
+// ConsumerGroupHeartbeat requests containing its subscription and the
+// partitions it owns. The broker replies with the member's epoch and, when it
+// changes, the member's target assignment. The member reconciles its owned
+// partitions toward the target (revoking what it lost, then assigning and
+// fetching offsets for what it gained) and acknowledges the result in its next
+// heartbeat. Reconciling is incremental, so we reuse the cooperative revoke
+// logic of the classic protocol.
+
+// useNextGen returns whether the group should use the next generation group
+// protocol: the user must have opted in, and at least one broker must support
+// ConsumerGroupHeartbeat. If we have not yet connected to any broker, we
+// first load broker metadata so that broker versions are known.
+func (g *groupConsumer) useNextGen() bool {
+	if !g.cfg.nextGenGroup {
+		return false
+	}
+	if g.cl.supportsKeyVersion(int16(kmsg.ConsumerGroupHeartbeat), 0) {
+		return true
+	}
+	if !g.cl.anyBrokerVersionsLoaded() {
+		if err := g.cl.fetchBrokerMetadata(g.ctx); err != nil {
+			g.cfg.logger.Log(LogLevelWarn, "unable to load broker versions to determine support for the next generation group protocol", "group", g.cfg.group, "err", err)
+		}
+		if g.cl.supportsKeyVersion(int16(kmsg.ConsumerGroupHeartbeat), 0) {
+			return true
+		}
+	}
+	g.cfg.logger.Log(LogLevelInfo, "next generation group protocol was requested, but no broker supports ConsumerGroupHeartbeat; falling back to the classic group protocol", "group", g.cfg.group)
+	return false
+}
+
+// anyBrokerVersionsLoaded returns whether we have loaded the API versions of
+// any broker.
+func (cl *Client) anyBrokerVersionsLoaded() bool {
+	cl.brokersMu.RLock()
+	defer cl.brokersMu.RUnlock()
+
+	for _, brokers := range [][]*broker{
+		cl.brokers,
+		cl.loadSeeds(),
+	} {
+		for _, b := range brokers {
+			if b.loadVersions() != nil {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// nextGenMemberID returns a new random member ID. Brokers require ConsumerGroupHeartbeat
+// v1+ to use client generated member IDs; v0 accepts them as well.
+func nextGenMemberID() string {
+	var id [16]byte
+	rand.Read(id[:])
+	return base64.RawURLEncoding.EncodeToString(id[:])
+}
+
+// nextGenSession heartbeats and reconciles assignments until the group is
+// left or heartbeating fails. The returned error is handled in manage the
+// same as errors from a classic group session: context.Canceled revokes
+// everything, while any other error loses everything before the member
+// rejoins with epoch 0 after a backoff.
+//
+// Being fenced (FENCED_MEMBER_EPOCH or UNKNOWN_MEMBER_ID) is not an error:
+// per KIP-848, the member loses its partitions and immediately rejoins with
+// epoch 0 and the same member ID.
+func (g *groupConsumer) nextGenSession() error {
+	g.cooperative.Store(true)
+
+	g.mu.Lock()
+	if g.memberID == "" {
+		g.memberID = nextGenMemberID()
+	}
+	g.generation = 0 // (re)joining
+	g.mu.Unlock()
+
+	var (
+		interval    = g.cfg.heartbeatInterval // until the broker tells us otherwise
+		target      map[[16]byte][]int32      // latest target assignment, until fully resolved
+		fetchCancel = func() {}
+		fetchDone   chan struct{}
+		fetchErrCh  chan error
+	)
+	stopFetch := func() {
+		fetchCancel()
+		if fetchDone != nil {
+			<-fetchDone
+		}
+		fetchCancel, fetchDone, fetchErrCh = func() {}, nil, nil
+	}
+	defer stopFetch()
+
+	timer := time.NewTimer(0) // join immediately
+	defer timer.Stop()
+
+	for {
+		var force func(error)
+		select {
+		case <-g.ctx.Done():
+			return context.Canceled
+		case <-timer.C:
+		case force = <-g.heartbeatForceCh:
+		case why := <-g.rejoinCh:
+			g.cfg.logger.Log(LogLevelDebug, "heartbeating immediately", "group", g.cfg.group, "why", why)
+		case err := <-fetchErrCh:
+			fetchErrCh = nil
+			if err != nil && !errors.Is(err, context.Canceled) {
+				return err
+			}
+			continue
+		}
+		if !timer.Stop() {
+			select {
+			case <-timer.C:
+			default:
+			}
+		}
+
+		resp, err := g.nextGenHeartbeat()
+		if force != nil {
+			force(err)
+		}
+		if err != nil {
+			if errors.Is(err, kerr.FencedMemberEpoch) || errors.Is(err, kerr.UnknownMemberID) {
+				stopFetch()
+				g.nextGenFenced(err)
+				target = nil
+				timer.Reset(0)
+				continue
+			}
+			g.cfg.logger.Log(LogLevelInfo, "consumer group heartbeat errored", "group", g.cfg.group, "err", err)
+			return err
+		}
+
+		if resp.HeartbeatIntervalMillis > 0 {
+			interval = time.Duration(resp.HeartbeatIntervalMillis) * time.Millisecond
+		}
+		timer.Reset(interval)
+
+		if resp.Assignment != nil {
+			target = make(map[[16]byte][]int32, len(resp.Assignment.Topics))
+			for _, t := range resp.Assignment.Topics {
+				target[t.TopicID] = append(target[t.TopicID], t.Partitions...)
+			}
+		}
+		if target == nil {
+			continue
+		}
+
+		resolved, unresolved := g.resolveNextGenTarget(target)
+		if unresolved > 0 {
+			g.cl.triggerUpdateMetadataNow("consumer group assignment contains unknown topic IDs")
+		} else {
+			target = nil
+		}
+		if !g.nextGenAssignmentChanged(resolved) {
+			continue
+		}
+
+		// The assignment changed: we stop any in flight offset fetch
+		// (the cooperative fetch tracking resumes fetching anything we
+		// still own) and reconcile.
+		stopFetch()
+		added := g.nextGenReconcile(resolved)
+		if len(added) > 0 {
+			ctx, cancel := context.WithCancel(g.ctx)
+			done := make(chan struct{})
+			errCh := make(chan error, 1)
+			go func() {
+				defer close(done)
+				errCh <- g.fetchOffsets(ctx, added)
+			}()
+			fetchCancel, fetchDone, fetchErrCh = cancel, done, errCh
+		}
+
+		// We acknowledge our new owned partitions immediately.
+		g.rejoin("acknowledging reconciled assignment")
+	}
+}
+
+// nextGenFenced loses all of our partitions and resets our epoch to 0 so that
+// our next heartbeat rejoins the group.
+func (g *groupConsumer) nextGenFenced(err error) {
+	g.cfg.logger.Log(LogLevelInfo, "consumer group member was fenced, losing all partitions and rejoining", "group", g.cfg.group, "err", err)
+
+	g.c.waitAndAddRebalance()
+	if lost := g.nowAssigned.read(); len(lost) > 0 && g.cfg.onLost != nil {
+		g.cfg.onLost(g.cl.ctx, g.cl, lost)
+	}
+	g.clearAssigned("clearing assignment after being fenced from the group")
+	g.c.unaddRebalance()
+
+	g.mu.Lock()
+	g.generation = 0
+	g.mu.Unlock()
+}
+
+// nextGenHeartbeat issues one ConsumerGroupHeartbeat and updates our member
+// ID and epoch from the response.
+func (g *groupConsumer) nextGenHeartbeat() (*kmsg.ConsumerGroupHeartbeatResponse, error) {
+	g.mu.Lock()
+	memberID, epoch := g.memberID, g.generation
+	subscribed := make([]string, 0, len(g.using))
+	for topic := range g.using {
+		subscribed = append(subscribed, topic)
+	}
+	g.mu.Unlock()
+	sort.Strings(subscribed)
+
+	req := kmsg.NewPtrConsumerGroupHeartbeatRequest()
+	req.Group = g.cfg.group
+	req.MemberID = memberID
+	req.MemberEpoch = epoch
+	req.InstanceID = g.cfg.instanceID
+	if g.cfg.rack != "" {
+		req.RackID = &g.cfg.rack
+	}
+	req.RebalanceTimeoutMillis = int32(g.cfg.rebalanceTimeout.Milliseconds())
+	req.SubscribedTopicNames = subscribed
+
+	// When joining, our owned partitions must be empty (but not null).
+	req.Topics = []kmsg.ConsumerGroupHeartbeatRequestTopic{}
+	if epoch > 0 {
+		t2id := g.nextGenTopicIDs()
+		for topic, partitions := range g.nowAssigned.read() {
+			id, ok := t2id[topic]
+			if !ok {
+				continue
+			}
+			rt := kmsg.NewConsumerGroupHeartbeatRequestTopic()
+			rt.TopicID = id
+			rt.Partitions = partitions
+			req.Topics = append(req.Topics, rt)
+		}
+	}
+
+	g.cfg.logger.Log(LogLevelDebug, "consumer group heartbeating", "group", g.cfg.group, "member_id", memberID, "member_epoch", epoch)
+	resp, err := req.RequestWith(g.ctx, g.cl)
+	if err == nil {
+		err = kerr.ErrorForCode(resp.ErrorCode)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	g.mu.Lock()
+	if resp.MemberID != nil && *resp.MemberID != "" {
+		g.memberID = *resp.MemberID
+	}
+	g.generation = resp.MemberEpoch
+	g.mu.Unlock()
+
+	g.cfg.logger.Log(LogLevelDebug, "consumer group heartbeat complete", "group", g.cfg.group, "member_epoch", resp.MemberEpoch, "has_assignment", resp.Assignment != nil)
+	return resp, nil
+}
+
+// nextGenTopicIDs returns the topic IDs of all topics we have loaded.
+func (g *groupConsumer) nextGenTopicIDs() map[string][16]byte {
+	topics := g.tps.load()
+	t2id := make(map[string][16]byte, len(topics))
+	for topic, tps := range topics {
+		if ps := tps.load().partitions; len(ps) > 0 && ps[0].cursor != nil {
+			t2id[topic] = ps[0].cursor.topicID
+		}
+	}
+	return t2id
+}
+
+// resolveNextGenTarget maps a target assignment of topic IDs to topic names,
+// returning the number of topic IDs we do not know yet.
+func (g *groupConsumer) resolveNextGenTarget(target map[[16]byte][]int32) (map[string][]int32, int) {
+	id2t := make(map[[16]byte]string)
+	for topic, id := range g.nextGenTopicIDs() {
+		id2t[id] = topic
+	}
+	resolved := make(map[string][]int32, len(target))
+	var unresolved int
+	for id, partitions := range target {
+		if len(partitions) == 0 {
+			continue
+		}
+		topic, ok := id2t[id]
+		if !ok {
+			unresolved++
+			continue
+		}
+		ps := append([]int32(nil), partitions...)
+		sort.Slice(ps, func(i, j int) bool { return ps[i] < ps[j] })
+		resolved[topic] = ps
+	}
+	return resolved, unresolved
+}
+
+// nextGenAssignmentChanged returns whether assignment differs from what we
+// currently own.
+func (g *groupConsumer) nextGenAssignmentChanged(assignment map[string][]int32) bool {
+	now := g.nowAssigned.read()
+	if len(now) != len(assignment) {
+		return true
+	}
+	for topic, partitions := range assignment {
+		nowPartitions, ok := now[topic]
+		if !ok || len(nowPartitions) != len(partitions) {
+			return true
+		}
+		for i := range partitions {
+			if nowPartitions[i] != partitions[i] {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// nextGenReconcile moves our owned partitions to the given assignment,
+// revoking what we lost and calling onAssigned with what we gained. This
+// returns the partitions to fetch offsets for.
+func (g *groupConsumer) nextGenReconcile(assignment map[string][]int32) map[string][]int32 {
+	g.nowAssigned.store(assignment)
+	added, lost := g.diffAssigned()
+	g.lastAssigned = g.nowAssigned.clone()
+
+	g.cfg.logger.Log(LogLevelInfo, "reconciling consumer group assignment", "group", g.cfg.group, "added", mtps(added), "lost", mtps(lost))
+
+	// Revoking requests an immediate rejoin once done, which for us
+	// means an immediate heartbeat to acknowledge the revocation.
+	if len(lost) > 0 {
+		g.revoke(revokeLastSession, lost, false)
+	}
+
+	added = g.adjustCooperativeFetchOffsets(added, lost)
+
+	if g.cfg.onAssigned != nil {
+		g.c.waitAndAddRebalance()
+		g.cfg.onAssigned(g.cl.ctx, g.cl, added)
+		g.c.unaddRebalance()
+	}
+	return added
+}
+
+// leaveNextGen leaves the group by heartbeating with a member epoch of -1, or
+// -2 if we are a static member (which allows us to rejoin with our prior
+// assignment within the session timeout).
+func (g *groupConsumer) leaveNextGen() {
+	epoch := int32(-1)
+	if g.cfg.instanceID != nil {
+		epoch = -2
+	}
+	g.cfg.logger.Log(LogLevelInfo, "leaving next generation group",
+		"group", g.cfg.group,
+		"member_id", g.memberID, // lock not needed now since nothing can change it (manageDone)
+		"member_epoch", epoch,
+	)
+	req := kmsg.NewPtrConsumerGroupHeartbeatRequest()
+	req.Group = g.cfg.group
+	req.MemberID = g.memberID
+	req.MemberEpoch = epoch
+	req.InstanceID = g.cfg.instanceID
+	req.RequestWith(g.cl.ctx, g.cl)
+}
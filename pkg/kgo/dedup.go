@@ -0,0 +1,85 @@
+package kgo
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// dedupWindow is a bounded LRU of recently delivered message IDs, with
+// optional expiry, backing ProduceDedup.
+type dedupWindow struct {
+	mu    sync.Mutex
+	size  int
+	ttl   time.Duration
+	order *list.List // front is the most recently added ID
+	ids   map[string]*list.Element
+}
+
+type dedupEntry struct {
+	id    string
+	added time.Time
+}
+
+func newDedupWindow(size int, ttl time.Duration) *dedupWindow {
+	return &dedupWindow{
+		size:  size,
+		ttl:   ttl,
+		order: list.New(),
+		ids:   make(map[string]*list.Element, size),
+	}
+}
+
+// seen returns whether id was delivered within the window.
+func (w *dedupWindow) seen(id string) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.expire(time.Now())
+	_, exists := w.ids[id]
+	return exists
+}
+
+// delivered adds id to the window once its record is successfully produced.
+// If id is already in the window, it is moved to the front.
+func (w *dedupWindow) delivered(id string) {
+	now := time.Now()
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.expire(now)
+	if e, exists := w.ids[id]; exists {
+		w.evict(e)
+	}
+	for w.order.Len() >= w.size {
+		w.evict(w.order.Back())
+	}
+	w.ids[id] = w.order.PushFront(&dedupEntry{id, now})
+}
+
+func (w *dedupWindow) expire(now time.Time) {
+	if w.ttl <= 0 {
+		return
+	}
+	for e := w.order.Back(); e != nil; e = w.order.Back() {
+		if now.Sub(e.Value.(*dedupEntry).added) < w.ttl {
+			return
+		}
+		w.evict(e)
+	}
+}
+
+func (w *dedupWindow) evict(e *list.Element) {
+	w.order.Remove(e)
+	delete(w.ids, e.Value.(*dedupEntry).id)
+}
+
+// dedupID returns the window key for a record: the record's topic and the
+// value of the first header with the given key.
+func (r *Record) dedupID(header string) (string, bool) {
+	v, ok := r.HeaderValue(header)
+	if !ok {
+		return "", false
+	}
+	return r.Topic + "\x00" + string(v), true
+}
@@ -0,0 +1,88 @@
+package kgo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestDedupWindow(t *testing.T) {
+	w := newDedupWindow(2, 0)
+	if w.seen("a") {
+		t.Error("empty window: a seen")
+	}
+	w.delivered("a")
+	w.delivered("b")
+	if !w.seen("a") || !w.seen("b") {
+		t.Error("a and b not seen after delivery")
+	}
+	w.delivered("a") // a is now most recent, b is evicted next
+	w.delivered("c")
+	if w.seen("b") {
+		t.Error("b seen after being evicted")
+	}
+	if !w.seen("a") || !w.seen("c") {
+		t.Error("a and c not seen")
+	}
+
+	w = newDedupWindow(10, time.Minute)
+	w.delivered("a")
+	w.ids["a"].Value.(*dedupEntry).added = time.Now().Add(-2 * time.Minute)
+	w.delivered("b")
+	if w.seen("a") {
+		t.Error("a seen after expiring")
+	}
+	if !w.seen("b") {
+		t.Error("b not seen before expiring")
+	}
+	if len(w.ids) != 1 || w.order.Len() != 1 {
+		t.Errorf("got %d ids, %d entries, exp 1 each", len(w.ids), w.order.Len())
+	}
+}
+
+func TestProduceDedup(t *testing.T) {
+	t.Parallel()
+
+	topic, cleanup := tmpTopic(t)
+	defer cleanup()
+
+	cl, _ := NewClient(
+		getSeedBrokers(),
+		DefaultProduceTopic(topic),
+		UnknownTopicRetries(-1),
+		ProducerBatchMaxBytes(1000),
+		ProduceDedup("id", 10, 0),
+	)
+	defer cl.Close()
+
+	ctx := context.Background()
+	rec := func(id string, size int) *Record {
+		r := &Record{Value: make([]byte, size)}
+		if id != "" {
+			r.Headers = []RecordHeader{{Key: "id", Value: []byte(id)}}
+		}
+		return r
+	}
+
+	// A failed record does not enter the window, so it can be retried.
+	if err := cl.ProduceSync(ctx, rec("a", 2000)).FirstErr(); err == nil || errors.Is(err, ErrDuplicateRecord) {
+		t.Fatalf("oversized record: got err %v, exp a produce failure", err)
+	}
+	if err := cl.ProduceSync(ctx, rec("a", 10)).FirstErr(); err != nil {
+		t.Fatalf("retry after failure: unexpected err %v", err)
+	}
+
+	// Once delivered, a duplicate is dropped.
+	if err := cl.ProduceSync(ctx, rec("a", 10)).FirstErr(); !errors.Is(err, ErrDuplicateRecord) {
+		t.Fatalf("duplicate: got err %v, exp ErrDuplicateRecord", err)
+	}
+
+	// Other IDs and records without the header are not deduplicated.
+	for i := 0; i < 2; i++ {
+		if err := cl.ProduceSync(ctx, rec(fmt.Sprint("b", i), 10), rec("", 10)).FirstErr(); err != nil {
+			t.Fatalf("unique record: unexpected err %v", err)
+		}
+	}
+}
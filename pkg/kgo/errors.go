@@ -7,6 +7,8 @@ import (
 	"io"
 	"net"
 	"os"
+
+	"github.com/burningass23/franz-go/pkg/kerr"
 )
 
 func isRetryableBrokerErr(err error) bool {
@@ -163,11 +165,20 @@ var (
 
 	errNoTopic = errors.New("cannot produce record with no topic and no default topic")
 
+	// Returned from ResetProducerID when it cannot be used.
+	errResetIDNotIdempotent = errors.New("cannot reset the producer ID when idempotent writes are disabled")
+	errResetIDBuffered      = errors.New("cannot reset the producer ID while records are buffered; flush or abort buffered records first")
+	errResetIDInTransaction = errors.New("cannot reset the producer ID while in a transaction")
+
 	// Returned for all buffered produce records when a user purges topics.
 	errPurged = errors.New("topic purged while buffered")
 
 	errMissingMetadataPartition = errors.New("metadata update is missing a partition that we were previously using")
 
+	errMissingListedPartition = errors.New("partition was missing in the list offsets response")
+
+	errMissingDescribedConfig = errors.New("topic was missing in the describe configs response")
+
 	//////////////
 	// EXTERNAL //
 	//////////////
@@ -181,10 +192,29 @@ var (
 	ErrRecordRetries = errors.New("record failed after being retried too many times")
 
 	// ErrMaxBuffered is returned when the maximum amount of records are
-	// buffered and either manual flushing is enabled or you are using
-	// TryProduce.
+	// buffered and either manual flushing is enabled, you are using
+	// TryProduce, or TryProduceFor timed out waiting for space.
 	ErrMaxBuffered = errors.New("the maximum amount of records are buffered, cannot buffer more")
 
+	// ErrLogAppendTimestamp is passed to produce promises when
+	// ValidateTimestampType is used to fail records that have an explicit
+	// timestamp but are produced to a topic that uses LogAppendTime.
+	ErrLogAppendTimestamp = errors.New("record has an explicit timestamp but the topic uses LogAppendTime, which would overwrite the timestamp")
+
+	// ErrRecordDropped is passed to produce promises when a record is
+	// dropped by one of the ProduceInterceptors.
+	ErrRecordDropped = errors.New("record was dropped by a produce interceptor")
+
+	// ErrDuplicateRecord is passed to produce promises when a record is
+	// dropped by the ProduceDedup window because a record with the same
+	// message ID was recently delivered.
+	ErrDuplicateRecord = errors.New("record was deduplicated: a record with the same message ID was recently delivered")
+
+	// ErrBrokerCircuitOpen is passed to produce promises when
+	// ProduceCircuitBreaker is used and records are failed because the
+	// circuit breaker for the partition's leader broker is open.
+	ErrBrokerCircuitOpen = errors.New("produce circuit breaker is open for the partition's leader broker")
+
 	// ErrAborting is returned for all buffered records while
 	// AbortBufferedRecords is being called.
 	ErrAborting = errors.New("client is aborting buffered records")
@@ -256,6 +286,24 @@ func (e *ErrDataLoss) Error() string {
 		e.Topic, e.Partition, e.ConsumedTo, e.ResetTo)
 }
 
+// ErrUnknownTopic is returned when records fail because their topic does not
+// exist: the broker repeatedly replied UNKNOWN_TOPIC_OR_PARTITION, more times
+// than allowed by the UnknownTopicRetries option. This unwraps to
+// kerr.UnknownTopicOrPartition.
+type ErrUnknownTopic struct {
+	// Topic is the topic that does not exist.
+	Topic string
+
+	err error
+}
+
+func (e *ErrUnknownTopic) Error() string {
+	return fmt.Sprintf("topic %s does not exist: %v", e.Topic, e.err)
+}
+
+// Unwrap returns the underlying UNKNOWN_TOPIC_OR_PARTITION error.
+func (e *ErrUnknownTopic) Unwrap() error { return e.err }
+
 type errUnknownController struct {
 	id int32
 }
@@ -289,6 +337,28 @@ func (e *errUnknownCoordinator) Error() string {
 	}
 }
 
+// ErrRecordTooLarge is passed to produce promises when FailOversizedRecords is
+// used and a record batch containing only the record would be larger than
+// the topic's max.message.bytes or the client's ProducerBatchMaxBytes. Such a
+// record can never be produced, so it is failed before being buffered rather
+// than after wasting retries.
+type ErrRecordTooLarge struct {
+	// Topic is the topic the record was produced to.
+	Topic string
+	// Size is the size of a record batch containing only the record,
+	// before compression.
+	Size int32
+	// Limit is the maximum allowed size of a record batch.
+	Limit int32
+}
+
+func (e *ErrRecordTooLarge) Error() string {
+	return fmt.Sprintf("record for topic %s is too large: a batch containing only this record is %d bytes, larger than the limit of %d bytes", e.Topic, e.Size, e.Limit)
+}
+
+// Unwrap returns kerr.MessageTooLarge.
+func (*ErrRecordTooLarge) Unwrap() error { return kerr.MessageTooLarge }
+
 // ErrGroupSession is injected into a poll if an error occurred such that your
 // consumer group member was kicked from the group or was never able to join
 // the group.
@@ -301,3 +371,79 @@ func (e *ErrGroupSession) Error() string {
 }
 
 func (e *ErrGroupSession) Unwrap() error { return e.err }
+
+// IsRetriableError returns whether err, which can be wrapped, is a transient
+// error: a retriable Kafka error (see kerr.IsRetriable), or a network error
+// such as a connection being cut, that may succeed if the operation is
+// retried. The client internally retries these errors up to its retry limits
+// before returning them.
+//
+// Errors that IsFatalError reports as fatal are never retriable, even if they
+// unwrap to a retriable error: an *ErrFirstReadEOF unwraps to io.EOF but
+// indicates a misconfigured client. An *ErrUnknownTopic is also not
+// retriable: the client has already retried up to UnknownTopicRetries before
+// failing records with it.
+func IsRetriableError(err error) bool {
+	if IsFatalError(err) {
+		return false
+	}
+	var ut *ErrUnknownTopic
+	if errors.As(err, &ut) {
+		return false
+	}
+	return kerr.IsRetriable(err) || isRetryableBrokerErr(err)
+}
+
+// IsDataLoss returns whether err, which can be wrapped, is an *ErrDataLoss,
+// which the client injects into fetches when it detects that records it
+// consumed were lost (e.g., through unclean leader election) and it reset
+// to the last valid offset.
+func IsDataLoss(err error) bool {
+	var dl *ErrDataLoss
+	return errors.As(err, &dl)
+}
+
+// IsFatalError returns whether err, which can be wrapped, is an error that
+// retrying cannot fix without outside intervention: the client being closed,
+// TLS or SASL misconfiguration (*ErrFirstReadEOF), authentication and
+// authorization failures, the producer being fenced by a newer producer with
+// the same transactional ID, and brokers not supporting a required request or
+// version.
+//
+// Errors that are neither retriable nor fatal, such as a record being too
+// large, a topic not existing, or an invalid request, depend on what was
+// requested; for these, both IsRetriableError and IsFatalError return false.
+// No error is both retriable and fatal.
+func IsFatalError(err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, ErrClientClosed) {
+		return true
+	}
+	var eof *ErrFirstReadEOF
+	if errors.As(err, &eof) {
+		return true
+	}
+	var ke *kerr.Error
+	if !errors.As(err, &ke) {
+		return false
+	}
+	switch ke {
+	case kerr.TopicAuthorizationFailed,
+		kerr.GroupAuthorizationFailed,
+		kerr.ClusterAuthorizationFailed,
+		kerr.TransactionalIDAuthorizationFailed,
+		kerr.DelegationTokenAuthorizationFailed,
+		kerr.SaslAuthenticationFailed,
+		kerr.UnsupportedSaslMechanism,
+		kerr.IllegalSaslState,
+		kerr.UnsupportedVersion,
+		kerr.UnsupportedForMessageFormat,
+		kerr.ProducerFenced,
+		kerr.TransactionalIDNotFound,
+		kerr.FencedInstanceID:
+		return true
+	}
+	return false
+}
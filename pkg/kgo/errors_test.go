@@ -0,0 +1,58 @@
+package kgo
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"syscall"
+	"testing"
+
+	"github.com/burningass23/franz-go/pkg/kerr"
+)
+
+func TestErrorClassification(t *testing.T) {
+	for _, test := range []struct {
+		name      string
+		err       error
+		retriable bool
+		fatal     bool
+		dataLoss  bool
+	}{
+		{name: "nil"},
+		{name: "client closed", err: ErrClientClosed, fatal: true},
+		{name: "wrapped client closed", err: fmt.Errorf("wrap: %w", ErrClientClosed), fatal: true},
+		{name: "first read eof", err: &ErrFirstReadEOF{kind: firstReadSASL, err: io.EOF}, fatal: true},
+		{name: "unknown topic", err: &ErrUnknownTopic{Topic: "t", err: kerr.UnknownTopicOrPartition}},
+		{name: "data loss", err: &ErrDataLoss{Topic: "t"}, dataLoss: true},
+		{name: "wrapped data loss", err: fmt.Errorf("wrap: %w", &ErrDataLoss{Topic: "t"}), dataLoss: true},
+		{name: "record too large", err: &ErrRecordTooLarge{Topic: "t"}},
+		{name: "retriable kerr", err: kerr.NotLeaderForPartition, retriable: true},
+		{name: "wrapped retriable kerr", err: fmt.Errorf("wrap: %w", kerr.LeaderNotAvailable), retriable: true},
+		{name: "fatal kerr", err: kerr.TopicAuthorizationFailed, fatal: true},
+		{name: "wrapped fatal kerr", err: fmt.Errorf("wrap: %w", kerr.ProducerFenced), fatal: true},
+		{name: "other kerr", err: kerr.InvalidRequest},
+		{name: "eof", err: io.EOF, retriable: true},
+		{name: "net closed", err: net.ErrClosed, retriable: true},
+		{
+			name:      "connection reset",
+			err:       &net.OpError{Op: "read", Net: "tcp", Err: os.NewSyscallError("read", syscall.ECONNRESET)},
+			retriable: true,
+		},
+		{name: "dial", err: &net.OpError{Op: "dial", Net: "tcp", Err: os.NewSyscallError("connect", syscall.ECONNREFUSED)}},
+		{name: "unrelated", err: errors.New("unrelated")},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			if got := IsRetriableError(test.err); got != test.retriable {
+				t.Errorf("IsRetriableError: got %v, exp %v", got, test.retriable)
+			}
+			if got := IsFatalError(test.err); got != test.fatal {
+				t.Errorf("IsFatalError: got %v, exp %v", got, test.fatal)
+			}
+			if got := IsDataLoss(test.err); got != test.dataLoss {
+				t.Errorf("IsDataLoss: got %v, exp %v", got, test.dataLoss)
+			}
+		})
+	}
+}
@@ -0,0 +1,63 @@
+//go:build go1.23
+// +build go1.23
+
+package kgo
+
+import (
+	"context"
+	"errors"
+	"iter"
+)
+
+// Records returns an iterator that yields consumed records one at a time,
+// polling for more records as necessary. This is a simpler alternative to
+// PollFetches for consumers that process records one by one:
+//
+//	for r, err := range cl.Records(ctx) {
+//		if err != nil {
+//			// handle the error
+//			continue
+//		}
+//		// process r
+//	}
+//
+// Records are polled one at a time, so that only records that have been
+// yielded are considered polled. This integrates with autocommitting: if you
+// break out of the loop, records that were not yet yielded are not committed.
+// Rebalances are handled by the client the same as with PollFetches; see the
+// documentation on BlockRebalanceOnPoll if you need to prevent rebalances
+// while processing a record.
+//
+// Partition errors are yielded with a nil record and iteration continues;
+// see Fetches.Errors for the possible errors. If the context is canceled or
+// the client is closed, the context error or ErrClientClosed is yielded and
+// iteration stops. The context must be non-nil.
+func (cl *Client) Records(ctx context.Context) iter.Seq2[*Record, error] {
+	return func(yield func(*Record, error) bool) {
+		for {
+			fs := cl.PollRecords(ctx, 1)
+			if fs.IsClientClosed() {
+				yield(nil, ErrClientClosed)
+				return
+			}
+			if err := ctx.Err(); err != nil {
+				yield(nil, err)
+				return
+			}
+
+			for _, fe := range fs.Errors() {
+				if errors.Is(fe.Err, context.Canceled) || errors.Is(fe.Err, context.DeadlineExceeded) {
+					continue // checked above, or a fetch canceled internally
+				}
+				if !yield(nil, fe.Err) {
+					return
+				}
+			}
+			for it := fs.RecordIter(); !it.Done(); {
+				if !yield(it.Next(), nil) {
+					return
+				}
+			}
+		}
+	}
+}
@@ -6,8 +6,11 @@ import (
 	"fmt"
 	"os"
 	"strconv"
+	"sync"
 	"testing"
 	"time"
+
+	"github.com/burningass23/franz-go/pkg/kmsg"
 )
 
 // TestGroupETL tests:
@@ -241,3 +244,220 @@ func (c *testConsumer) etl(etlsBeforeQuit int) {
 		}
 	}
 }
+
+// A vetoed partition is not consumed and causes a rejoin; if the partition is
+// assigned again, the veto function is called again, and a partition that is
+// accepted the second time around is consumed.
+func TestOnPartitionsAssignedVeto(t *testing.T) {
+	t.Parallel()
+
+	topic, topicCleanup := tmpTopicPartitions(t, 3)
+	defer topicCleanup()
+	group, groupCleanup := tmpGroup(t)
+	defer groupCleanup()
+
+	var (
+		mu       sync.Mutex
+		vetoes   []map[string][]int32
+		assigned []map[string][]int32
+	)
+	cl, _ := NewClient(
+		getSeedBrokers(),
+		DefaultProduceTopic(topic),
+		RecordPartitioner(ManualPartitioner()),
+		UnknownTopicRetries(-1),
+		ConsumerGroup(group),
+		ConsumeTopics(topic),
+		OnPartitionsAssignedVeto(func(_ context.Context, _ *Client, added map[string][]int32) (map[string][]int32, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			vetoes = append(vetoes, added)
+			if len(vetoes) == 1 {
+				return map[string][]int32{topic: {0}}, nil
+			}
+			return nil, nil
+		}),
+		OnPartitionsAssigned(func(_ context.Context, _ *Client, added map[string][]int32) {
+			mu.Lock()
+			defer mu.Unlock()
+			assigned = append(assigned, added)
+		}),
+	)
+	defer cl.Close()
+
+	for p := int32(0); p < 3; p++ {
+		if err := cl.ProduceSync(context.Background(), &Record{Partition: p, Value: []byte("v")}).FirstErr(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	consumed := make(map[int32]bool)
+	for len(consumed) < 3 {
+		fs := cl.PollFetches(ctx)
+		if err := fs.Err0(); err != nil {
+			t.Fatalf("consumed from partitions %v before error: %v", consumed, err)
+		}
+		fs.EachRecord(func(r *Record) { consumed[r.Partition] = true })
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(vetoes) < 2 {
+		t.Fatalf("veto function called %d times, exp at least 2", len(vetoes))
+	}
+	if len(vetoes[0][topic]) != 3 {
+		t.Errorf("first veto saw %v, exp all 3 partitions", vetoes[0])
+	}
+	if got := vetoes[1][topic]; len(got) != 1 || got[0] != 0 {
+		t.Errorf("second veto saw %v, exp only the rejected partition 0", vetoes[1])
+	}
+	if len(assigned) < 2 || len(assigned[0][topic]) != 2 {
+		t.Fatalf("got assignments %v, exp partitions 1 and 2 followed by partition 0", assigned)
+	}
+	for _, p := range assigned[0][topic] {
+		if p == 0 {
+			t.Errorf("vetoed partition 0 was passed to OnPartitionsAssigned: %v", assigned[0])
+		}
+	}
+}
+
+type memOffsetStore struct {
+	mu      sync.Mutex
+	offsets map[string]map[int32]EpochOffset
+}
+
+func (s *memOffsetStore) Commit(_ context.Context, _ string, offsets map[string]map[int32]EpochOffset) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for t, ps := range offsets {
+		if s.offsets[t] == nil {
+			s.offsets[t] = make(map[int32]EpochOffset)
+		}
+		for p, eo := range ps {
+			s.offsets[t][p] = eo
+		}
+	}
+	return nil
+}
+
+func (s *memOffsetStore) Fetch(_ context.Context, _ string, partitions map[string][]int32) (map[string]map[int32]EpochOffset, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	fetched := make(map[string]map[int32]EpochOffset)
+	for t, ps := range partitions {
+		for _, p := range ps {
+			if eo, ok := s.offsets[t][p]; ok {
+				if fetched[t] == nil {
+					fetched[t] = make(map[int32]EpochOffset)
+				}
+				fetched[t][p] = eo
+			}
+		}
+	}
+	return fetched, nil
+}
+
+// Consuming resumes from offsets in the store, and commits go to the store
+// with any changes made by a PreCommitFnContext function.
+func TestGroupOffsetStore(t *testing.T) {
+	t.Parallel()
+
+	topic, topicCleanup := tmpTopicPartitions(t, 1)
+	defer topicCleanup()
+	group, groupCleanup := tmpGroup(t)
+	defer groupCleanup()
+
+	store := &memOffsetStore{offsets: map[string]map[int32]EpochOffset{
+		topic: {0: {Epoch: -1, Offset: 1}},
+	}}
+	cl, _ := NewClient(
+		getSeedBrokers(),
+		DefaultProduceTopic(topic),
+		UnknownTopicRetries(-1),
+		ConsumerGroup(group),
+		ConsumeTopics(topic),
+		GroupOffsetStore(store),
+		DisableAutoCommit(),
+	)
+	defer cl.Close()
+
+	for i := 0; i < 3; i++ {
+		if err := cl.ProduceSync(context.Background(), StringRecord(strconv.Itoa(i))).FirstErr(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	var consumed []int64
+	for len(consumed) < 2 {
+		fs := cl.PollFetches(ctx)
+		if err := fs.Err0(); err != nil {
+			t.Fatalf("consumed offsets %v before error: %v", consumed, err)
+		}
+		fs.EachRecord(func(r *Record) { consumed = append(consumed, r.Offset) })
+	}
+	if len(consumed) != 2 || consumed[0] != 1 || consumed[1] != 2 {
+		t.Fatalf("consumed offsets %v, exp [1 2]", consumed)
+	}
+
+	// We rewind what we commit by one, which must be what is stored.
+	pre := PreCommitFnContext(ctx, func(req *kmsg.OffsetCommitRequest) error {
+		for i := range req.Topics {
+			for j := range req.Topics[i].Partitions {
+				req.Topics[i].Partitions[j].Offset--
+			}
+		}
+		return nil
+	})
+	if err := cl.CommitUncommittedOffsets(pre); err != nil {
+		t.Fatal(err)
+	}
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	if got := store.offsets[topic][0].Offset; got != 2 {
+		t.Errorf("stored offset %d, exp 2", got)
+	}
+}
+
+func TestGroupMemberID(t *testing.T) {
+	t.Parallel()
+
+	topic, topicCleanup := tmpTopicPartitions(t, 1)
+	defer topicCleanup()
+	group, groupCleanup := tmpGroup(t)
+	defer groupCleanup()
+
+	cl, _ := NewClient(
+		getSeedBrokers(),
+		DefaultProduceTopic(topic),
+		UnknownTopicRetries(-1),
+		ConsumerGroup(group),
+		ConsumeTopics(topic),
+	)
+	defer cl.Close()
+
+	if memberID := cl.GroupMemberID(); memberID != "" {
+		t.Errorf("before joining: got member ID %q, exp empty", memberID)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if err := cl.ProduceSync(ctx, StringRecord("v")).FirstErr(); err != nil {
+		t.Fatal(err)
+	}
+	for consumed := 0; consumed < 1; {
+		fs := cl.PollFetches(ctx)
+		if err := fs.Err0(); err != nil {
+			t.Fatal(err)
+		}
+		consumed += fs.NumRecords()
+	}
+
+	memberID, _ := cl.GroupMetadata()
+	if got := cl.GroupMemberID(); got == "" || got != memberID {
+		t.Errorf("after joining: got member ID %q, exp the broker assigned %q", got, memberID)
+	}
+}
@@ -1,6 +1,7 @@
 package kgo
 
 import (
+	"crypto/tls"
 	"net"
 	"time"
 )
@@ -60,6 +61,22 @@ type HookBrokerConnect interface {
 	OnBrokerConnect(meta BrokerMetadata, dialDur time.Duration, conn net.Conn, err error)
 }
 
+// HookBrokerTLSHandshake is called after a TLS handshake with a broker
+// completes or fails. This hook is only called for connections that use TLS,
+// i.e., connections that are a *tls.Conn (as is the case when using
+// DialTLSConfig). If a connection does not use TLS, this hook is not called,
+// which can be used to detect plaintext connections in OnBrokerConnect.
+//
+// If a custom Dialer already performs the handshake, the handshake error (if
+// any) is returned from the dial and is passed to OnBrokerConnect, and this
+// hook is only called for successful handshakes.
+type HookBrokerTLSHandshake interface {
+	// OnBrokerTLSHandshake is passed the broker metadata, the state of
+	// the TLS connection (the negotiated version, cipher suite, peer
+	// certificates, etc.), and any handshake error.
+	OnBrokerTLSHandshake(meta BrokerMetadata, state tls.ConnectionState, err error)
+}
+
 // HookBrokerDisconnect is called when a connection to a broker is closed.
 type HookBrokerDisconnect interface {
 	// OnBrokerDisconnect is passed the broker metadata and the connection
@@ -203,6 +220,77 @@ type HookGroupManageError interface {
 	OnGroupManageError(error)
 }
 
+// MetadataLeaderChange is a partition leader change seen in a metadata
+// refresh.
+type MetadataLeaderChange struct {
+	Topic     string // Topic is the topic of the partition.
+	Partition int32  // Partition is the partition whose leader changed.
+
+	OldLeader      int32 // OldLeader is the broker that was the leader.
+	NewLeader      int32 // NewLeader is the broker that is now the leader.
+	OldLeaderEpoch int32 // OldLeaderEpoch is the prior leader epoch.
+	NewLeaderEpoch int32 // NewLeaderEpoch is the new leader epoch.
+}
+
+// MetadataRefresh describes a single metadata refresh issued by the client's
+// internal metadata loop.
+type MetadataRefresh struct {
+	// Trigger is why the refresh occurred. This is "periodic" if the
+	// refresh is from MetadataMaxAge passing, otherwise this is the reason
+	// the client internally triggered a refresh (e.g., a produce or fetch
+	// response error, a topic being added, or a retry of a prior failed
+	// refresh).
+	Trigger string
+
+	// Immediate is true if the refresh was triggered to happen
+	// immediately, bypassing MetadataMinAge.
+	Immediate bool
+
+	// Topics are the topics that were requested. If the client requested
+	// all topics (regex consuming), this is nil.
+	Topics []string
+
+	// LeaderChanges are the partition leader changes seen in this refresh
+	// for any partition the client is producing to or consuming from.
+	LeaderChanges []MetadataLeaderChange
+
+	// Duration is how long the refresh took, including processing the
+	// response.
+	Duration time.Duration
+
+	// Err is non-nil if the metadata request failed.
+	Err error
+}
+
+// HookMetadataRefresh is called after every metadata refresh issued by the
+// client's internal metadata loop. This can be used to understand why and
+// how often the client refreshes metadata.
+type HookMetadataRefresh interface {
+	// OnMetadataRefresh is passed information about a metadata refresh
+	// that just completed.
+	OnMetadataRefresh(MetadataRefresh)
+}
+
+// HookTopicPartitionsChanged is called when a metadata refresh shows that the
+// number of partitions in a topic the client is producing to or consuming
+// from has changed. This can be used to react to partitions being added,
+// which changes how keyed records are partitioned.
+//
+// This hook is called after the client has processed the refresh, meaning
+// new partitions can already be produced to or consumed from. The hook is not
+// called when a topic's partitions are first loaded.
+type HookTopicPartitionsChanged interface {
+	// OnTopicPartitionsChanged is passed the topic whose partition count
+	// changed and the old and new partition counts.
+	//
+	// The count can decrease if a topic was deleted and recreated with
+	// fewer partitions, or if the client received metadata from a broker
+	// that is behind. The client keeps the old partitions around in
+	// either case, so the count returned here is what the broker replied
+	// with, not the number of partitions the client tracks.
+	OnTopicPartitionsChanged(topic string, oldPartitions, newPartitions int)
+}
+
 ///////////////////////////////
 // PRODUCE & CONSUME BATCHES //
 ///////////////////////////////
@@ -302,6 +390,54 @@ type HookFetchBatchRead interface {
 	OnFetchBatchRead(meta BrokerMetadata, topic string, partition int32, metrics FetchBatchMetrics)
 }
 
+// FetchErrorAction is the action the client takes for a partition that had
+// an error in a fetch response.
+type FetchErrorAction int8
+
+const (
+	// FetchErrorReturn means the error is returned to the user from
+	// polling. This is the action for errors the client does not retry.
+	FetchErrorReturn FetchErrorAction = iota + 1
+	// FetchErrorRetry means the error is retryable and is not returned;
+	// the partition is fetched again after a metadata refresh.
+	FetchErrorRetry
+	// FetchErrorResetOffset means the partition's offset was out of range
+	// and is reset by listing offsets per ConsumeResetOffset.
+	FetchErrorResetOffset
+	// FetchErrorValidateEpoch means the partition's offset is validated
+	// with an OffsetForLeaderEpoch request to detect log truncation
+	// (KIP-320) before fetching resumes.
+	FetchErrorValidateEpoch
+)
+
+func (a FetchErrorAction) String() string {
+	switch a {
+	case FetchErrorReturn:
+		return "return"
+	case FetchErrorRetry:
+		return "retry"
+	case FetchErrorResetOffset:
+		return "reset offset"
+	case FetchErrorValidateEpoch:
+		return "validate epoch"
+	default:
+		return "unknown"
+	}
+}
+
+// HookFetchPartitionError is called for every partition that has an error in
+// a fetch response, along with the action the client takes for the error.
+//
+// Most per-partition fetch errors are handled internally and never returned
+// from polling. This hook can be used to observe these errors, for example to
+// detect a single partition that is chronically failing while consuming is
+// otherwise healthy. Every partition error also triggers a metadata refresh.
+type HookFetchPartitionError interface {
+	// OnFetchPartitionError is called per partition with an error in a
+	// fetch response from the given broker.
+	OnFetchPartitionError(meta BrokerMetadata, topic string, partition int32, err error, action FetchErrorAction)
+}
+
 ///////////////////////////////
 // PRODUCE & CONSUME RECORDS //
 ///////////////////////////////
@@ -401,14 +537,18 @@ func implementsAnyHook(h Hook) bool {
 	case HookNewClient,
 		HookClientClosed,
 		HookBrokerConnect,
+		HookBrokerTLSHandshake,
 		HookBrokerDisconnect,
 		HookBrokerWrite,
 		HookBrokerRead,
 		HookBrokerE2E,
 		HookBrokerThrottle,
 		HookGroupManageError,
+		HookMetadataRefresh,
+		HookTopicPartitionsChanged,
 		HookProduceBatchWritten,
 		HookFetchBatchRead,
+		HookFetchPartitionError,
 		HookProduceRecordBuffered,
 		HookProduceRecordPartitioned,
 		HookProduceRecordUnbuffered,
@@ -10,6 +10,7 @@ import (
 	"time"
 
 	"github.com/burningass23/franz-go/pkg/kerr"
+	"github.com/burningass23/franz-go/pkg/kmsg"
 )
 
 type metawait struct {
@@ -169,17 +170,24 @@ func (cl *Client) updateMetadataLoop() {
 	defer ticker.Stop()
 loop:
 	for {
-		var now bool
+		var (
+			now       bool
+			immediate bool // now, or an immediate trigger cut our wait short
+			trigger   string
+		)
 		select {
 		case <-cl.ctx.Done():
 			return
 		case <-ticker.C:
 			// We do not log on the standard update case.
+			trigger = "periodic"
 		case why := <-cl.updateMetadataCh:
 			cl.cfg.logger.Log(LogLevelInfo, "metadata update triggered", "why", why)
+			trigger = why
 		case why := <-cl.updateMetadataNowCh:
 			cl.cfg.logger.Log(LogLevelInfo, "immediate metadata update triggered", "why", why)
-			now = true
+			trigger = why
+			now, immediate = true, true
 		case fn := <-cl.blockingMetadataFnCh:
 			fn()
 			continue loop
@@ -199,6 +207,8 @@ loop:
 				case why := <-cl.updateMetadataNowCh:
 					timer.Stop()
 					cl.cfg.logger.Log(LogLevelInfo, "immediate metadata update triggered, bypassing normal wait", "why", why)
+					trigger = why
+					immediate = true
 				case <-timer.C:
 				case fn := <-cl.blockingMetadataFnCh:
 					fn()
@@ -207,9 +217,22 @@ loop:
 			}
 		}
 
-		// Even with an "update now", we sleep just a bit to allow some
-		// potential pile on now triggers.
-		time.Sleep(time.Until(lastAt.Add(10 * time.Millisecond)))
+		// Even with an "update now", we wait a bit to allow some
+		// potential pile on now triggers, and to avoid hammering
+		// brokers if many immediate updates are triggered in a row.
+		if wait := time.Until(lastAt.Add(cl.cfg.metadataMinImmediateAge)); wait > 0 {
+			timer := time.NewTimer(wait)
+		nowwait:
+			select {
+			case <-cl.ctx.Done():
+				timer.Stop()
+				return
+			case <-timer.C:
+			case fn := <-cl.blockingMetadataFnCh:
+				fn()
+				goto nowwait
+			}
+		}
 
 		// Drain any refires that occurred during our waiting.
 	out:
@@ -224,7 +247,23 @@ loop:
 			}
 		}
 
-		retryWhy, err := cl.updateMetadata()
+		var refresh *MetadataRefresh
+		cl.cfg.hooks.each(func(h Hook) {
+			if _, ok := h.(HookMetadataRefresh); ok && refresh == nil {
+				refresh = &MetadataRefresh{Trigger: trigger, Immediate: immediate}
+			}
+		})
+		updateStart := time.Now()
+		retryWhy, err := cl.updateMetadata(refresh)
+		if refresh != nil {
+			refresh.Duration = time.Since(updateStart)
+			refresh.Err = err
+			cl.cfg.hooks.each(func(h Hook) {
+				if h, ok := h.(HookMetadataRefresh); ok {
+					h.OnMetadataRefresh(*refresh)
+				}
+			})
+		}
 		if retryWhy != nil || err != nil {
 			// If err is non-nil, the metadata request failed
 			// itself and already retried 3x; we do not loop more.
@@ -293,7 +332,10 @@ loop:
 // The producer and consumer use different topic maps and underlying
 // topicPartitionsData pointers, but we update those underlying pointers
 // equally.
-func (cl *Client) updateMetadata() (retryWhy multiUpdateWhy, err error) {
+//
+// If refresh is non-nil, the requested topics and any leader changes are
+// recorded in it for the HookMetadataRefresh hook.
+func (cl *Client) updateMetadata(refresh *MetadataRefresh) (retryWhy multiUpdateWhy, err error) {
 	var (
 		tpsProducerLoad = cl.producer.topics.load()
 		tpsConsumer     *topicsPartitions
@@ -329,6 +371,10 @@ func (cl *Client) updateMetadata() (retryWhy multiUpdateWhy, err error) {
 		}
 	}
 
+	if refresh != nil {
+		refresh.Topics = reqTopics
+	}
+
 	latest, err := cl.fetchTopicMetadata(all, reqTopics)
 	if err != nil {
 		cl.bumpMetadataFailForTopics( // bump load failures for all topics
@@ -369,6 +415,8 @@ func (cl *Client) updateMetadata() (retryWhy multiUpdateWhy, err error) {
 		}
 	}
 
+	cl.selectReplicas(tpsConsumerLoad, latest)
+
 	// Migrating a cursor requires stopping any consumer session. If we
 	// stop a session, we need to eventually re-start any offset listing or
 	// epoch loading that was stopped. Thus, we simply merge what we
@@ -395,7 +443,12 @@ func (cl *Client) updateMetadata() (retryWhy multiUpdateWhy, err error) {
 		}
 	}()
 
-	var missingProduceTopics []string
+	var (
+		missingProduceTopics []string
+		seenLeaderChanges    map[string]bool // producer & consumer can have the same topic
+		partitionChanges     []topicPartitionsChange
+		seenPartitionChanges map[string]bool
+	)
 	for _, m := range []struct {
 		priors    map[string]*topicPartitions
 		isProduce bool
@@ -411,6 +464,23 @@ func (cl *Client) updateMetadata() (retryWhy multiUpdateWhy, err error) {
 				}
 				continue
 			}
+			if refresh != nil && !seenLeaderChanges[topic] {
+				if seenLeaderChanges == nil {
+					seenLeaderChanges = make(map[string]bool)
+				}
+				seenLeaderChanges[topic] = true
+				refresh.LeaderChanges = appendLeaderChanges(refresh.LeaderChanges, topic, priorParts.load(), newParts)
+			}
+			if prior := len(priorParts.load().partitions); newParts.loadErr == nil &&
+				prior > 0 &&
+				prior != len(newParts.partitions) &&
+				!seenPartitionChanges[topic] {
+				if seenPartitionChanges == nil {
+					seenPartitionChanges = make(map[string]bool)
+				}
+				seenPartitionChanges[topic] = true
+				partitionChanges = append(partitionChanges, topicPartitionsChange{topic, prior, len(newParts.partitions)})
+			}
 			cl.mergeTopicPartitions(
 				topic,
 				priorParts,
@@ -430,9 +500,54 @@ func (cl *Client) updateMetadata() (retryWhy multiUpdateWhy, err error) {
 		)
 	}
 
+	for _, c := range partitionChanges {
+		cl.cfg.logger.Log(LogLevelInfo, "metadata refresh saw a topic's partition count change",
+			"topic", c.topic,
+			"old_partitions", c.old,
+			"new_partitions", c.new,
+		)
+		cl.cfg.hooks.each(func(h Hook) {
+			if h, ok := h.(HookTopicPartitionsChanged); ok {
+				h.OnTopicPartitionsChanged(c.topic, c.old, c.new)
+			}
+		})
+	}
+
 	return retryWhy, nil
 }
 
+// topicPartitionsChange is a partition count change seen in a metadata
+// refresh, for the HookTopicPartitionsChanged hook.
+type topicPartitionsChange struct {
+	topic    string
+	old, new int
+}
+
+// appendLeaderChanges appends any partition whose leader or leader epoch
+// differs between the prior and new metadata. Partitions with a load error
+// keep their old leader and are skipped, as are new partitions.
+func appendLeaderChanges(changes []MetadataLeaderChange, topic string, prior *topicPartitionsData, latest *metadataTopic) []MetadataLeaderChange {
+	for i := range latest.partitions {
+		np := &latest.partitions[i]
+		if np.loadErr != 0 || int(np.partition) >= len(prior.partitions) || np.partition < 0 {
+			continue
+		}
+		op := prior.partitions[np.partition]
+		if op.leader == np.leader && op.leaderEpoch == np.leaderEpoch {
+			continue
+		}
+		changes = append(changes, MetadataLeaderChange{
+			Topic:          topic,
+			Partition:      np.partition,
+			OldLeader:      op.leader,
+			NewLeader:      np.leader,
+			OldLeaderEpoch: op.leaderEpoch,
+			NewLeaderEpoch: np.leaderEpoch,
+		})
+	}
+	return changes
+}
+
 // We use a special structure to repesent metadata before we *actually* convert
 // it to topicPartitionsData. This helps avoid any pointer reuse problems
 // because we want to keep the client's producer and consumer maps completely
@@ -473,6 +588,8 @@ type metadataPartition struct {
 	leader      int32
 	leaderEpoch int32
 	sns         sinkAndSource
+
+	meta *kmsg.MetadataResponseTopicPartition // only set if using ConsumeReplicaSelector
 }
 
 func (mp metadataPartition) newPartition(cl *Client, isProduce bool) *topicPartition {
@@ -501,6 +618,7 @@ func (mp metadataPartition) newPartition(cl *Client, isProduce bool) *topicParti
 			topicID:            mp.topicID,
 			partition:          mp.partition,
 			keepControl:        cl.cfg.keepControl,
+			keepBatches:        cl.cfg.keepBatches,
 			cursorsIdx:         -1,
 			source:             mp.sns.source,
 			topicPartitionData: td,
@@ -610,6 +728,9 @@ func (cl *Client) fetchTopicMetadata(all bool, reqTopics []string) (map[string]*
 			}
 			cl.sinksAndSourcesMu.Unlock()
 			mp.sns = sns
+			if cl.cfg.replicaSelector != nil {
+				mp.meta = partMeta
+			}
 			mt.partitions = append(mt.partitions, mp)
 		}
 	}
@@ -617,6 +738,72 @@ func (cl *Client) fetchTopicMetadata(all bool, reqTopics []string) (map[string]*
 	return topics, nil
 }
 
+// selectReplicas calls the user's ConsumeReplicaSelector for every consumed
+// partition that is new or whose leader or leader epoch changed, and swaps
+// the partition's source to the selected replica. Partitions whose leader
+// and epoch are unchanged keep their existing cursor, and thus their
+// existing source, when merged.
+//
+// The sink is only used for producing and the source is only used for
+// consuming, so we can swap the source without affecting producing. The
+// selector is called without sinksAndSourcesMu held; we only take the lock
+// to resolve the selected sources.
+func (cl *Client) selectReplicas(priors map[string]*topicPartitions, latest map[string]*metadataTopic) {
+	if cl.cfg.replicaSelector == nil {
+		return
+	}
+	type selection struct {
+		mp       *metadataPartition
+		selected int32
+	}
+	var selections []selection
+	for topic, priorParts := range priors {
+		mt, exists := latest[topic]
+		if !exists || mt.loadErr != nil {
+			continue
+		}
+		prior := priorParts.load().partitions
+		for i := range mt.partitions {
+			mp := &mt.partitions[i]
+			if mp.loadErr != 0 || mp.meta == nil {
+				continue
+			}
+			td := topicPartitionData{leader: mp.leader, leaderEpoch: mp.leaderEpoch}
+			if i < len(prior) && prior[i].topicPartitionData == td {
+				continue
+			}
+			selected := cl.cfg.replicaSelector(topic, PartitionMetadata{
+				Partition:       mp.meta.Partition,
+				Leader:          mp.meta.Leader,
+				LeaderEpoch:     mp.meta.LeaderEpoch,
+				Replicas:        mp.meta.Replicas,
+				ISR:             mp.meta.ISR,
+				OfflineReplicas: mp.meta.OfflineReplicas,
+			})
+			if selected == mp.leader || selected < 0 {
+				continue
+			}
+			for _, replica := range mp.meta.Replicas {
+				if replica == selected {
+					selections = append(selections, selection{mp, selected})
+					break
+				}
+			}
+		}
+	}
+	if len(selections) == 0 {
+		return
+	}
+
+	cl.sinksAndSourcesMu.Lock()
+	defer cl.sinksAndSourcesMu.Unlock()
+	for _, s := range selections {
+		if sns, exists := cl.sinksAndSources[s.selected]; exists {
+			s.mp.sns.source = sns.source
+		}
+	}
+}
+
 // mergeTopicPartitions merges a new topicPartition into an old and returns
 // whether the metadata update that caused this merge needs to be retried.
 //
@@ -0,0 +1,44 @@
+package kgo
+
+import "context"
+
+// recordBatchOverhead is the length of a v2 record batch with no records.
+const recordBatchOverhead = 61
+
+// validateRecordSize returns *ErrRecordTooLarge if a record batch containing
+// only this record would be larger than the client's ProducerBatchMaxBytes, or
+// if compression is disabled, the topic's max.message.bytes. If the record is
+// too large, the FailOversizedRecords callback is called before this returns.
+//
+// The first record for a topic blocks while the topic's config is loaded. If
+// the config has never been loaded successfully, the record is only validated
+// against the client's limit.
+func (cl *Client) validateRecordSize(ctx context.Context, r *Record) error {
+	// The client limit includes the four byte length prefix of the
+	// records in a produce request; the broker limit does not.
+	limit := cl.maxRecordBatchBytesForTopic(r.Topic) - 4
+
+	// The broker checks max.message.bytes against the compressed batch,
+	// which we do not know until the batch is built. A record that is too
+	// large uncompressed may fit once compressed, so we only check the
+	// topic limit if we are not compressing.
+	if cl.compressor == nil {
+		cfg, _, err := cl.producer.topicConfigs.get(ctx, cl.ctx, r.Topic)
+		if err != nil {
+			return err
+		}
+		if cfg.maxMessageBytes > 0 && cfg.maxMessageBytes < limit {
+			limit = cfg.maxMessageBytes
+		}
+	}
+	size := recordBatchOverhead + new(recBatch).calculateRecordNumbers(r).wireLength()
+	if size <= limit {
+		return nil
+	}
+
+	tooLarge := &ErrRecordTooLarge{Topic: r.Topic, Size: size, Limit: limit}
+	if fn := cl.cfg.onOversized; fn != nil {
+		fn(r, tooLarge)
+	}
+	return tooLarge
+}
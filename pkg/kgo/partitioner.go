@@ -253,6 +253,10 @@ func (p *leastBackupTopicPartitioner) PartitionByBackup(_ *Record, n int, backup
 // standard hashing based on record key for records with non-nil keys. hasher
 // is optional; if nil, the default hasher murmur2 (Kafka's default).
 //
+// With keys true, this is useful for topics that mix keyed and keyless
+// records: keyed records keep their partition affinity, while keyless records
+// still get the batching benefit of sticky partitioning.
+//
 // The point of this hasher is to create larger batches while producing the
 // same amount to all partitions over the long run. Adaptive opts in to a
 // slight imbalance so that this can produce more to brokers that are less
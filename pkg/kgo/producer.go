@@ -22,6 +22,11 @@ type producer struct {
 	topicsMu sync.Mutex // locked to prevent concurrent updates; reads are always atomic
 	topics   *topicsPartitions
 
+	limiter *produceLimiter // non-nil if ProduceRateLimit is used
+	dedup   *dedupWindow    // non-nil if ProduceDedup is used
+
+	topicConfigs *topicConfigs // non-nil if ValidateTimestampType or FailOversizedRecords is used
+
 	// Hooks exist behind a pointer because likely they are not used.
 	// We only take up one byte vs. 6.
 	hooks *struct {
@@ -48,6 +53,14 @@ type producer struct {
 
 	aborting atomicI32 // >0 if aborting, can abort many times concurrently
 
+	// closing is set in CloseGracefully to reject any new produce. Once
+	// the graceful flush is done, collectUnflushed is set and every record
+	// that is subsequently failed is saved to unflushed to be returned.
+	closing          atomicBool
+	collectUnflushed atomicBool
+	unflushedMu      sync.Mutex
+	unflushed        []*Record
+
 	idMu       sync.Mutex
 	idVersion  int16
 	waitBuffer chan struct{}
@@ -105,6 +118,15 @@ func (p *producer) init(cl *Client) {
 		err:   errReloadProducerID,
 	})
 	p.c = sync.NewCond(&p.mu)
+	if cl.cfg.produceBytesRate > 0 || cl.cfg.produceRecordsRate > 0 {
+		p.limiter = newProduceLimiter(cl.cfg.produceBytesRate, cl.cfg.produceRecordsRate)
+	}
+	if cl.cfg.dedupHeader != "" {
+		p.dedup = newDedupWindow(cl.cfg.dedupSize, cl.cfg.dedupTTL)
+	}
+	if cl.cfg.validateTimestampType || cl.cfg.failOversized {
+		p.topicConfigs = newTopicConfigs(cl)
+	}
 
 	inithooks := func() {
 		if p.hooks == nil {
@@ -320,7 +342,29 @@ func (cl *Client) TryProduce(
 	r *Record,
 	promise func(*Record, error),
 ) {
-	cl.produce(ctx, r, promise, false)
+	cl.produce(ctx, r, promise, 0)
+}
+
+// TryProduceFor is similar to Produce, but rather than blocking indefinitely
+// if the client currently has MaxBufferedRecords buffered, this blocks for at
+// most wait for space to become available. If space does not become available
+// in time, the record is failed with ErrMaxBuffered. A non-positive wait
+// behaves exactly like TryProduce.
+//
+// This allows latency sensitive producers to shed load deterministically
+// rather than choosing between blocking indefinitely and failing immediately.
+// As with Produce, canceling the context stops waiting and fails the record
+// with the context error. See the Produce documentation for more details.
+func (cl *Client) TryProduceFor(
+	ctx context.Context,
+	wait time.Duration,
+	r *Record,
+	promise func(*Record, error),
+) {
+	if wait <= 0 {
+		wait = 0
+	}
+	cl.produce(ctx, r, promise, wait)
 }
 
 // Produce sends a Kafka record to the topic in the record's Topic field,
@@ -341,7 +385,8 @@ func (cl *Client) TryProduce(
 // the configured maximum amount of records buffered, Produce will block. The
 // context can be used to cancel waiting while records flush to make space. In
 // contrast, if flushing is configured, the record will be failed immediately
-// with ErrMaxBuffered (this same behavior can be had with TryProduce).
+// with ErrMaxBuffered (this same behavior can be had with TryProduce). To
+// bound how long Produce may block, use TryProduceFor.
 //
 // Once a record is buffered into a batch, it can be canceled in three ways:
 // canceling the context, the record timing out, or hitting the maximum
@@ -358,14 +403,17 @@ func (cl *Client) Produce(
 	r *Record,
 	promise func(*Record, error),
 ) {
-	cl.produce(ctx, r, promise, true)
+	cl.produce(ctx, r, promise, -1)
 }
 
+// produce buffers a record. If the client has MaxBufferedRecords buffered,
+// a negative wait blocks until space is available, a zero wait fails the
+// record immediately, and a positive wait blocks for at most that long.
 func (cl *Client) produce(
 	ctx context.Context,
 	r *Record,
 	promise func(*Record, error),
-	block bool,
+	wait time.Duration,
 ) {
 	if ctx == nil {
 		ctx = context.Background()
@@ -379,6 +427,12 @@ func (cl *Client) produce(
 	if r.Topic == "" {
 		r.Topic = cl.cfg.defaultProduceTopic
 	}
+	start := time.Now()
+
+	// We encode typed keys and values before calling any buffered hooks
+	// so that hooks see the record as it will be produced. Encoding
+	// errors are returned once the record is counted as buffered.
+	encodeErr := cl.encodeRecord(r)
 
 	p := &cl.producer
 	if p.hooks != nil && len(p.hooks.buffered) > 0 {
@@ -388,11 +442,15 @@ func (cl *Client) produce(
 	}
 
 	if r.Topic == "" {
-		p.promiseRecord(promisedRec{ctx, promise, r}, errNoTopic)
+		p.promiseRecord(promisedRec{ctx: ctx, promise: promise, Record: r, start: start}, errNoTopic)
+		return
+	}
+	if p.closing.Load() {
+		p.promiseRecord(promisedRec{ctx: ctx, promise: promise, Record: r, start: start}, ErrClientClosed)
 		return
 	}
 	if cl.cfg.txnID != nil && !p.producingTxn.Load() {
-		p.promiseRecord(promisedRec{ctx, promise, r}, errNotInTransaction)
+		p.promiseRecord(promisedRec{ctx: ctx, promise: promise, Record: r, start: start}, errNotInTransaction)
 		return
 	}
 
@@ -402,15 +460,33 @@ func (cl *Client) produce(
 		// to drain a slot from the waitBuffer chan, which could be
 		// sent to right when we are erroring.
 		drainBuffered := func(err error) {
-			p.promiseRecord(promisedRec{ctx, promise, r}, err)
+			p.promiseRecord(promisedRec{ctx: ctx, promise: promise, Record: r, start: start}, err)
 			<-p.waitBuffer
 		}
-		if !block || cl.cfg.manualFlushing {
+		if wait == 0 || cl.cfg.manualFlushing {
 			drainBuffered(ErrMaxBuffered)
 			return
 		}
+		// Time spent waiting for buffer space counts against the
+		// record delivery timeout. If the record timeout is shorter
+		// than our wait, we fail with ErrRecordTimeout instead.
+		var (
+			timeout    <-chan time.Time
+			timeoutErr = ErrMaxBuffered
+		)
+		if rt := cl.cfg.recordTimeout; rt > 0 && (wait < 0 || rt < wait) {
+			wait, timeoutErr = rt, ErrRecordTimeout
+		}
+		if wait > 0 {
+			timer := time.NewTimer(wait)
+			defer timer.Stop()
+			timeout = timer.C
+		}
 		select {
 		case <-p.waitBuffer:
+		case <-timeout:
+			drainBuffered(timeoutErr)
+			return
 		case <-cl.ctx.Done():
 			drainBuffered(ErrClientClosed)
 			return
@@ -420,7 +496,49 @@ func (cl *Client) produce(
 		}
 	}
 
-	cl.partitionRecord(promisedRec{ctx, promise, r})
+	if encodeErr != nil {
+		p.promiseRecord(promisedRec{ctx: ctx, promise: promise, Record: r, start: start}, encodeErr)
+		return
+	}
+
+	for _, intercept := range cl.cfg.interceptors {
+		if !intercept(r) {
+			p.promiseRecord(promisedRec{ctx: ctx, promise: promise, Record: r, start: start}, ErrRecordDropped)
+			return
+		}
+	}
+
+	if cl.cfg.validateTimestampType {
+		if err := cl.validateTimestampType(ctx, r); err != nil {
+			p.promiseRecord(promisedRec{ctx: ctx, promise: promise, Record: r, start: start}, err)
+			return
+		}
+	}
+
+	if cl.cfg.failOversized {
+		if err := cl.validateRecordSize(ctx, r); err != nil {
+			p.promiseRecord(promisedRec{ctx: ctx, promise: promise, Record: r, start: start}, err)
+			return
+		}
+	}
+
+	if p.dedup != nil {
+		if id, ok := r.dedupID(cl.cfg.dedupHeader); ok {
+			if p.dedup.seen(id) {
+				p.promiseRecord(promisedRec{ctx: ctx, promise: promise, Record: r, start: start}, ErrDuplicateRecord)
+				return
+			}
+			userPromise := promise
+			promise = func(r *Record, err error) {
+				if err == nil {
+					p.dedup.delivered(id)
+				}
+				userPromise(r, err)
+			}
+		}
+	}
+
+	cl.partitionRecord(promisedRec{ctx: ctx, promise: promise, Record: r, start: start})
 }
 
 type batchPromise struct {
@@ -478,15 +596,25 @@ func (cl *Client) finishRecordPromise(pr promisedRec, err error) {
 		}
 	}
 
+	if err != nil && err != ErrDuplicateRecord && err != ErrRecordDropped && p.collectUnflushed.Load() {
+		p.unflushedMu.Lock()
+		p.unflushed = append(p.unflushed, pr.Record)
+		p.unflushedMu.Unlock()
+	}
+
 	// We call the promise before finishing the record; this allows users
 	// of Flush to know that all buffered records are completely done
 	// before Flush returns.
 	pr.promise(pr.Record, err)
 
+	topicBuffered := int64(-1)
+	if pr.parts != nil {
+		topicBuffered = pr.parts.buffered.Add(-1)
+	}
 	buffered := p.bufferedRecords.Add(-1)
 	if buffered >= cl.cfg.maxBufferedRecords {
 		p.waitBuffer <- struct{}{}
-	} else if buffered == 0 && p.flushing.Load() > 0 {
+	} else if (buffered == 0 || topicBuffered == 0) && p.flushing.Load() > 0 {
 		p.mu.Lock()
 		p.mu.Unlock() //nolint:gocritic,staticcheck // We use the lock as a barrier, unlocking immediately is safe.
 		p.c.Broadcast()
@@ -497,7 +625,7 @@ func (cl *Client) finishRecordPromise(pr promisedRec, err error) {
 // the topic does not currently exist, the record is buffered in unknownTopics
 // for a metadata update to deal with.
 func (cl *Client) partitionRecord(pr promisedRec) {
-	parts, partsData := cl.partitionsForTopicProduce(pr)
+	parts, partsData := cl.partitionsForTopicProduce(&pr)
 	if parts == nil { // saved in unknownTopics
 		return
 	}
@@ -512,6 +640,15 @@ func (cl *Client) doPartitionRecord(parts *topicPartitions, partsData *topicPart
 		return
 	}
 
+	if pin, ok := cl.cfg.pinnedPartitions[pr.Topic]; ok {
+		if int(pin) >= len(partsData.partitions) {
+			cl.producer.promiseRecord(pr, fmt.Errorf("unable to produce to pinned partition %d: topic has %d partitions", pin, len(partsData.partitions)))
+			return
+		}
+		partsData.partitions[pin].records.bufferRecord(pr, false)
+		return
+	}
+
 	parts.partsMu.Lock()
 	defer parts.partsMu.Unlock()
 	if parts.partitioner == nil {
@@ -543,7 +680,7 @@ func (cl *Client) doPartitionRecord(parts *topicPartitions, partsData *topicPart
 		return
 	}
 
-	partition := mapping[pick]
+	partition := cl.fallbackPartition(partsData, mapping, pick, pr.Record)
 
 	onNewBatch, _ := parts.partitioner.(TopicPartitionerOnNewBatch)
 	abortOnNewBatch := onNewBatch != nil
@@ -562,11 +699,25 @@ func (cl *Client) doPartitionRecord(parts *topicPartitions, partsData *topicPart
 			cl.producer.promiseRecord(pr, fmt.Errorf("invalid record partitioning choice of %d from %d available", pick, len(mapping)))
 			return
 		}
-		partition = mapping[pick]
+		partition = cl.fallbackPartition(partsData, mapping, pick, pr.Record)
 		partition.records.bufferRecord(pr, false) // KIP-480
 	}
 }
 
+// fallbackPartition returns mapping[pick], or, if UnavailablePartitionFallback
+// is enabled and applies to the record, a writable partition if
+// mapping[pick] currently has no leader.
+func (cl *Client) fallbackPartition(partsData *topicPartitionsData, mapping []*topicPartition, pick int, r *Record) *topicPartition {
+	partition := mapping[pick]
+	if !cl.cfg.partitionFallback ||
+		partition.loadErr == nil ||
+		len(partsData.writablePartitions) == 0 ||
+		cl.cfg.partitionFallbackStrictKeys && r.Key != nil {
+		return partition
+	}
+	return partsData.writablePartitions[pick%len(partsData.writablePartitions)]
+}
+
 // ProducerID returns, loading if necessary, the current producer ID and epoch.
 // This returns an error if the producer ID could not be loaded, if the
 // producer ID has fatally errored, or if the context is canceled.
@@ -592,6 +743,83 @@ func (cl *Client) ProducerID(ctx context.Context) (int64, int16, error) {
 	}
 }
 
+// ResetProducerID forces the client to initialize a new producer ID and
+// epoch, returning the new ID and epoch. This issues InitProducerID with the
+// client's current ID and epoch, which fences the old epoch on the broker (or
+// allocates a new ID, on brokers older than Kafka 2.5), and resets sequence
+// numbers for every partition.
+//
+// This can be used to recover from producer ID errors (such as
+// UNKNOWN_PRODUCER_ID) without recreating the client. Records cannot be
+// buffered while resetting: this returns an error if any records are
+// buffered, so you must Flush or AbortBufferedRecords first. Transactional
+// clients cannot reset the producer ID while in a transaction.
+//
+// Producing is paused for the duration of the reset: records can still be
+// buffered, but no produce requests are issued until the reset is done, at
+// which point the records are produced with the new ID and epoch.
+//
+// If initializing a new producer ID fails, the client keeps its current
+// producer ID and this returns the error. If the context is canceled while
+// waiting for in flight produce requests to finish, this returns the context
+// error without resetting. If the context is canceled after that, this
+// returns early with the context error but the reset continues in the
+// background.
+func (cl *Client) ResetProducerID(ctx context.Context) (int64, int16, error) {
+	p := &cl.producer
+	if cl.cfg.disableIdempotency {
+		return -1, -1, errResetIDNotIdempotent
+	}
+
+	var (
+		id    int64
+		epoch int16
+		err   error
+
+		done = make(chan struct{})
+	)
+
+	// We block produce requests so that nothing is produced with the old
+	// ID and epoch once we have checked that nothing is buffered.
+	if err := p.pause(ctx); err != nil {
+		return 0, 0, err
+	}
+
+	go func() {
+		defer close(done)
+		defer p.resume()
+
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		if cl.cfg.txnID != nil && p.producingTxn.Load() {
+			err = errResetIDInTransaction
+			return
+		}
+		if p.bufferedRecords.Load() > 0 {
+			err = errResetIDBuffered
+			return
+		}
+
+		p.idMu.Lock()
+		defer p.idMu.Unlock()
+
+		old := p.id.Load().(*producerID)
+		newID, keep := cl.doInitProducerID(old.id, old.epoch)
+		if keep && newID.err == nil {
+			cl.resetAllProducerSequences()
+			p.id.Store(newID)
+		}
+		id, epoch, err = newID.id, newID.epoch, newID.err
+	}()
+
+	select {
+	case <-ctx.Done():
+		return 0, 0, ctx.Err()
+	case <-done:
+		return id, epoch, err
+	}
+}
+
 type producerID struct {
 	id    int64
 	epoch int16
@@ -781,7 +1009,10 @@ func (cl *Client) doInitProducerID(lastID int64, lastEpoch int16) (*producerID,
 // partitionsForTopicProduce returns the topic partitions for a record.
 // If the topic is not loaded yet, this buffers the record and returns
 // nil, nil.
-func (cl *Client) partitionsForTopicProduce(pr promisedRec) (*topicPartitions, *topicPartitionsData) {
+//
+// This counts the record against its topic for FlushTopics; the record is
+// uncounted once its promise is finished.
+func (cl *Client) partitionsForTopicProduce(pr *promisedRec) (*topicPartitions, *topicPartitionsData) {
 	p := &cl.producer
 	topic := pr.Topic
 
@@ -789,6 +1020,7 @@ func (cl *Client) partitionsForTopicProduce(pr promisedRec) (*topicPartitions, *
 	parts, exists := topics[topic]
 	if exists {
 		if v := parts.load(); len(v.partitions) > 0 {
+			pr.countAgainst(parts)
 			return parts, v
 		}
 	}
@@ -807,7 +1039,8 @@ func (cl *Client) partitionsForTopicProduce(pr promisedRec) (*topicPartitions, *
 			defer p.unknownTopicsMu.Unlock()
 
 			p.topics.storeTopics([]string{topic})
-			cl.addUnknownTopicRecord(pr)
+			pr.countAgainst(p.topics.load()[topic])
+			cl.addUnknownTopicRecord(*pr)
 			cl.triggerUpdateMetadataNow("forced load because we are producing to a topic for the first time")
 			return nil, nil
 		}
@@ -819,10 +1052,11 @@ func (cl *Client) partitionsForTopicProduce(pr promisedRec) (*topicPartitions, *
 	p.unknownTopicsMu.Lock()
 	defer p.unknownTopicsMu.Unlock()
 
+	pr.countAgainst(parts)
 	if v := parts.load(); len(v.partitions) > 0 {
 		return parts, v
 	}
-	cl.addUnknownTopicRecord(pr)
+	cl.addUnknownTopicRecord(*pr)
 	cl.triggerUpdateMetadata(false, "reload trigger due to produce topic still not known")
 
 	return nil, nil // our record is buffered waiting for metadata update; nothing to return
@@ -846,6 +1080,45 @@ func (cl *Client) addUnknownTopicRecord(pr promisedRec) {
 	}
 }
 
+// autoCreateTopic issues a CreateTopics request for a topic we are producing
+// to, per the AutoCreateTopic option. This returns nil if the topic was
+// created, already exists, or creation failed with a retryable error (in
+// which case the normal unknown topic retries continue).
+func (cl *Client) autoCreateTopic(ctx context.Context, topic string) error {
+	ac := cl.cfg.autoCreateTopic
+	req := kmsg.NewPtrCreateTopicsRequest()
+	req.TimeoutMillis = 15000 // same as kadm's default
+	rt := kmsg.NewCreateTopicsRequestTopic()
+	rt.Topic = topic
+	rt.NumPartitions = ac.partitions
+	rt.ReplicationFactor = ac.replicationFactor
+	for k, v := range ac.configs {
+		rc := kmsg.NewCreateTopicsRequestTopicConfig()
+		rc.Name = k
+		rc.Value = v
+		rt.Configs = append(rt.Configs, rc)
+	}
+	req.Topics = append(req.Topics, rt)
+
+	cl.cfg.logger.Log(LogLevelInfo, "topic we are producing to does not exist, creating it", "topic", topic, "partitions", ac.partitions, "replication_factor", ac.replicationFactor)
+	resp, err := req.RequestWith(ctx, cl)
+	if err == nil {
+		if len(resp.Topics) != 1 {
+			err = fmt.Errorf("create topics response returned %d topics when we requested 1", len(resp.Topics))
+		} else if err = kerr.ErrorForCode(resp.Topics[0].ErrorCode); err == kerr.TopicAlreadyExists {
+			err = nil
+		}
+	}
+	if err != nil {
+		if kerr.IsRetriable(err) || isRetryableBrokerErr(err) {
+			cl.cfg.logger.Log(LogLevelWarn, "unable to create topic we are producing to, continuing to wait for it", "topic", topic, "err", err)
+			return nil
+		}
+		return fmt.Errorf("unable to create topic %q: %w", topic, err)
+	}
+	return nil
+}
+
 // waitUnknownTopic waits for a notification
 func (cl *Client) waitUnknownTopic(
 	rctx context.Context,
@@ -859,6 +1132,7 @@ func (cl *Client) waitUnknownTopic(
 		unknownTries int64
 		err          error
 		after        <-chan time.Time
+		created      bool
 	)
 
 	if timeout := cl.cfg.recordTimeout; timeout > 0 {
@@ -889,6 +1163,13 @@ func (cl *Client) waitUnknownTopic(
 				return // metadata was successful!
 			}
 			cl.cfg.logger.Log(LogLevelInfo, "new topic metadata wait failed, retrying wait", "topic", topic, "err", retryableErr)
+			if cl.cfg.autoCreateTopic != nil && !created && errors.Is(retryableErr, kerr.UnknownTopicOrPartition) {
+				created = true
+				if err = cl.autoCreateTopic(rctx, topic); err == nil {
+					cl.triggerUpdateMetadataNow("reload after auto creating topic we are producing to")
+				}
+				continue
+			}
 			tries++
 			if int64(tries) >= cl.cfg.recordRetries {
 				err = fmt.Errorf("no partitions available after attempting to refresh metadata %d times, last err: %w", tries, retryableErr)
@@ -896,7 +1177,7 @@ func (cl *Client) waitUnknownTopic(
 			if cl.cfg.maxUnknownFailures >= 0 && errors.Is(retryableErr, kerr.UnknownTopicOrPartition) {
 				unknownTries++
 				if unknownTries > cl.cfg.maxUnknownFailures {
-					err = retryableErr
+					err = &ErrUnknownTopic{Topic: topic, err: retryableErr}
 				}
 			}
 		}
@@ -980,6 +1261,81 @@ func (cl *Client) Flush(ctx context.Context) error {
 	}
 }
 
+// FlushTopics is like Flush, but only waits for records buffered for the given
+// topics. This allows checkpointing latency sensitive topics without waiting
+// on records for slow, unrelated topics. If no topics are given, this returns
+// immediately.
+//
+// Records are waited on once they are partitioned; records produced to the
+// given topics while this is running are waited on as well. Records that are
+// still blocked in Produce (for example, waiting for MaxBufferedRecords) are
+// not yet partitioned and are not waited on.
+//
+// Sinks drain per broker rather than per topic, so while this is running, it
+// behaves like Flush for lingering and manual flushing: lingering is disabled
+// for all topics, and if ManualFlushing is used, all topics are drained. Only
+// the given topics have an in progress linger cut short.
+func (cl *Client) FlushTopics(ctx context.Context, topics ...string) error {
+	if len(topics) == 0 {
+		return nil
+	}
+	p := &cl.producer
+
+	p.flushing.Add(1)
+	defer p.flushing.Add(-1)
+
+	cl.cfg.logger.Log(LogLevelInfo, "flushing topics", "topics", topics)
+	defer cl.cfg.logger.Log(LogLevelDebug, "flushed topics", "topics", topics)
+
+	if cl.cfg.linger > 0 || cl.cfg.manualFlushing {
+		loaded := p.topics.load()
+		for _, topic := range topics {
+			parts, ok := loaded[topic]
+			if !ok {
+				continue
+			}
+			for _, part := range parts.load().partitions {
+				part.records.unlingerAndManuallyDrain()
+			}
+		}
+	}
+
+	// We reload the topics every check, since a topic we are flushing
+	// may be produced to for the first time while we are waiting.
+	buffered := func() bool {
+		loaded := p.topics.load()
+		for _, topic := range topics {
+			if parts, ok := loaded[topic]; ok && parts.buffered.Load() > 0 {
+				return true
+			}
+		}
+		return false
+	}
+
+	quit := false
+	done := make(chan struct{})
+	go func() {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		defer close(done)
+
+		for !quit && buffered() {
+			p.c.Wait()
+		}
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		p.mu.Lock()
+		quit = true
+		p.mu.Unlock()
+		p.c.Broadcast()
+		return ctx.Err()
+	}
+}
+
 func (p *producer) pause(ctx context.Context) error {
 	p.inflight.Add(1 << 48)
 
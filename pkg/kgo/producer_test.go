@@ -0,0 +1,254 @@
+package kgo
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/burningass23/franz-go/pkg/kerr"
+	"github.com/burningass23/franz-go/pkg/kmsg"
+)
+
+func TestTryProduceFor(t *testing.T) {
+	t.Parallel()
+
+	topic, cleanup := tmpTopic(t)
+	defer cleanup()
+
+	// With a long linger, our first record sits in the buffer until we
+	// flush, leaving no space for more.
+	cl, _ := NewClient(
+		getSeedBrokers(),
+		DefaultProduceTopic(topic),
+		UnknownTopicRetries(-1),
+		MaxBufferedRecords(1),
+		ProducerLinger(time.Minute),
+	)
+	defer cl.Close()
+
+	ctx := context.Background()
+	tryProduce := func(ctx context.Context, wait time.Duration) (time.Duration, error) {
+		start := time.Now()
+		errCh := make(chan error, 1)
+		cl.TryProduceFor(ctx, wait, &Record{Value: []byte("v")}, func(_ *Record, err error) { errCh <- err })
+		err := <-errCh
+		return time.Since(start), err
+	}
+
+	firstErr := make(chan error, 1)
+	cl.Produce(ctx, &Record{Value: []byte("v")}, func(_ *Record, err error) { firstErr <- err })
+
+	if elapsed, err := tryProduce(ctx, 0); err != ErrMaxBuffered || elapsed > 50*time.Millisecond {
+		t.Errorf("no wait: got %v after %v, exp ErrMaxBuffered immediately", err, elapsed)
+	}
+	if elapsed, err := tryProduce(ctx, 100*time.Millisecond); err != ErrMaxBuffered || elapsed < 100*time.Millisecond {
+		t.Errorf("100ms wait: got %v after %v, exp ErrMaxBuffered after 100ms", err, elapsed)
+	}
+
+	canceledCtx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	if _, err := tryProduce(canceledCtx, time.Minute); err != context.DeadlineExceeded {
+		t.Errorf("canceled ctx: got %v, exp context.DeadlineExceeded", err)
+	}
+
+	// Once space is available, the waiting record is buffered.
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		cl.Flush(ctx)
+	}()
+	errCh := make(chan error, 1)
+	cl.TryProduceFor(ctx, 10*time.Second, &Record{Value: []byte("v")}, func(_ *Record, err error) { errCh <- err })
+	if err := <-firstErr; err != nil {
+		t.Fatalf("first record: unexpected err %v", err)
+	}
+	if err := cl.Flush(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-errCh; err != nil {
+		t.Errorf("record waiting for space: unexpected err %v", err)
+	}
+}
+
+func TestAutoCreateTopic(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+
+	topic := randsha()
+	defer func() {
+		req := kmsg.NewPtrDeleteTopicsRequest()
+		req.TopicNames = []string{topic}
+		rt := kmsg.NewDeleteTopicsRequestTopic()
+		rt.Topic = kmsg.StringPtr(topic)
+		req.Topics = append(req.Topics, rt)
+		req.RequestWith(context.Background(), adm)
+	}()
+
+	cl, _ := NewClient(
+		getSeedBrokers(),
+		DefaultProduceTopic(topic),
+		AutoCreateTopic(7, int16(testrf), nil),
+	)
+	defer cl.Close()
+
+	if err := cl.ProduceSync(ctx, &Record{Value: []byte("v")}).FirstErr(); err != nil {
+		t.Fatalf("unexpected produce err: %v", err)
+	}
+
+	mreq := kmsg.NewPtrMetadataRequest()
+	mt := kmsg.NewMetadataRequestTopic()
+	mt.Topic = kmsg.StringPtr(topic)
+	mreq.Topics = append(mreq.Topics, mt)
+	mresp, err := mreq.RequestWith(ctx, adm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := len(mresp.Topics[0].Partitions); n != 7 {
+		t.Errorf("got %d partitions, exp 7 from AutoCreateTopic", n)
+	}
+}
+
+func TestAutoCreateTopicFailure(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+
+	// An impossible replication factor fails creation with a
+	// non-retryable error, which fails the buffered record.
+	cl, _ := NewClient(
+		getSeedBrokers(),
+		DefaultProduceTopic(randsha()),
+		AutoCreateTopic(1, 1000, nil),
+	)
+	defer cl.Close()
+
+	if err := cl.ProduceSync(ctx, &Record{Value: []byte("v")}).FirstErr(); !errors.Is(err, kerr.InvalidReplicationFactor) {
+		t.Errorf("got err %v, exp InvalidReplicationFactor", err)
+	}
+}
+
+func TestFlushTopics(t *testing.T) {
+	t.Parallel()
+
+	// Our "broker" accepts connections and never responds, so records
+	// stay buffered waiting for their topic to load.
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+
+	cl, _ := NewClient(
+		SeedBrokers(ln.Addr().String()),
+		UnknownTopicRetries(-1),
+		ProduceInterceptors(func(r *Record) bool {
+			if r.Topic == "rewrite" {
+				r.Topic = "slow"
+			}
+			return true
+		}),
+	)
+	defer cl.Close()
+
+	ctx := context.Background()
+	produceCtx, cancelProduce := context.WithCancel(ctx)
+	defer cancelProduce()
+	errs := make(chan error, 2)
+	cl.Produce(produceCtx, &Record{Topic: "slow", Value: []byte("v")}, func(_ *Record, err error) { errs <- err })
+	cl.Produce(produceCtx, &Record{Topic: "rewrite", Value: []byte("v")}, func(_ *Record, err error) { errs <- err })
+
+	if err := cl.FlushTopics(ctx, "fast"); err != nil {
+		t.Errorf("flushing a topic with nothing buffered: got %v, exp nil", err)
+	}
+	if err := cl.FlushTopics(ctx, "rewrite"); err != nil {
+		t.Errorf("flushing a topic whose records were rewritten away: got %v, exp nil", err)
+	}
+
+	shortCtx, cancel := context.WithTimeout(ctx, 100*time.Millisecond)
+	defer cancel()
+	if err := cl.FlushTopics(shortCtx, "fast", "slow"); err != context.DeadlineExceeded {
+		t.Errorf("flushing a topic with buffered records: got %v, exp context.DeadlineExceeded", err)
+	}
+
+	// Once the slow topic's records are finished, flushing it returns.
+	flushed := make(chan error, 1)
+	go func() { flushed <- cl.FlushTopics(ctx, "slow") }()
+	time.Sleep(50 * time.Millisecond)
+	cancelProduce()
+	for i := 0; i < 2; i++ {
+		if err := <-errs; err != context.Canceled {
+			t.Errorf("canceled record: got %v, exp context.Canceled", err)
+		}
+	}
+	select {
+	case err := <-flushed:
+		if err != nil {
+			t.Errorf("flushing after records finished: got %v, exp nil", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Error("FlushTopics did not return once the topic's records finished")
+	}
+}
+
+func TestResetProducerID(t *testing.T) {
+	t.Parallel()
+
+	topic, topicCleanup := tmpTopic(t)
+	defer topicCleanup()
+
+	cl, _ := NewClient(
+		getSeedBrokers(),
+		DefaultProduceTopic(topic),
+		UnknownTopicRetries(-1),
+	)
+	defer cl.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	r1, err := cl.ProduceSync(ctx, StringRecord("v1")).First()
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, epoch, err := cl.ResetProducerID(ctx)
+	if err != nil {
+		t.Fatalf("unable to reset producer ID: %v", err)
+	}
+	if id == r1.ProducerID && epoch == r1.ProducerEpoch {
+		t.Errorf("reset kept producer ID %d and epoch %d", id, epoch)
+	}
+
+	// Producing is no longer paused, and uses the new ID and epoch.
+	r2, err := cl.ProduceSync(ctx, StringRecord("v2")).First()
+	if err != nil {
+		t.Fatalf("unable to produce after resetting: %v", err)
+	}
+	if r2.ProducerID != id || r2.ProducerEpoch != epoch {
+		t.Errorf("produced with ID %d epoch %d, exp ID %d epoch %d", r2.ProducerID, r2.ProducerEpoch, id, epoch)
+	}
+	if got := cl.producer.inflight.Load() >> 48; got != 0 {
+		t.Errorf("producing is still paused by %d waiters", got)
+	}
+
+	// Buffered records prevent resetting, which must also unpause.
+	cl.Produce(ctx, StringRecord("v3"), nil)
+	if _, _, err := cl.ResetProducerID(ctx); err != nil && err != errResetIDBuffered {
+		t.Errorf("reset with buffered records: got %v, exp nil or errResetIDBuffered", err)
+	}
+	if err := cl.Flush(ctx); err != nil {
+		t.Errorf("unable to flush after resetting: %v", err)
+	}
+}
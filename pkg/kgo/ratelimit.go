@@ -0,0 +1,101 @@
+package kgo
+
+import (
+	"sync"
+	"time"
+)
+
+// tokenBucket is a token bucket that refills at rate tokens per second, up to
+// a burst of one second's worth of tokens. Taking more tokens than are
+// available puts the bucket into debt, and callers must wait until the debt
+// is repaid before taking more.
+type tokenBucket struct {
+	rate   float64
+	tokens float64
+	last   time.Time
+}
+
+func newTokenBucket(rate int) *tokenBucket {
+	return &tokenBucket{
+		rate:   float64(rate),
+		tokens: float64(rate),
+		last:   time.Now(),
+	}
+}
+
+func (b *tokenBucket) refill(now time.Time) {
+	b.tokens += now.Sub(b.last).Seconds() * b.rate
+	if b.tokens > b.rate {
+		b.tokens = b.rate
+	}
+	b.last = now
+}
+
+// debt returns how long until the bucket is no longer in debt.
+func (b *tokenBucket) debt(now time.Time) time.Duration {
+	b.refill(now)
+	if b.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-b.tokens / b.rate * float64(time.Second))
+}
+
+// take takes n tokens, potentially putting the bucket into debt.
+func (b *tokenBucket) take(now time.Time, n int) {
+	b.refill(now)
+	b.tokens -= float64(n)
+}
+
+// produceLimiter enforces ProduceRateLimit.
+//
+// Sinks wait for the limiter before issuing a produce request, and a produce
+// request takes from the limiter once it is serialized, at which point we know
+// its actual (post-compression) size. A request that overdraws the limiter
+// delays the next request.
+type produceLimiter struct {
+	mu      sync.Mutex
+	bytes   *tokenBucket // nil if unlimited
+	records *tokenBucket // nil if unlimited
+}
+
+func newProduceLimiter(bytesPerSec, recordsPerSec int) *produceLimiter {
+	l := new(produceLimiter)
+	if bytesPerSec > 0 {
+		l.bytes = newTokenBucket(bytesPerSec)
+	}
+	if recordsPerSec > 0 {
+		l.records = newTokenBucket(recordsPerSec)
+	}
+	return l
+}
+
+// wait returns how long to wait before issuing another produce request.
+func (l *produceLimiter) wait() time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := time.Now()
+	var wait time.Duration
+	for _, b := range []*tokenBucket{l.bytes, l.records} {
+		if b == nil {
+			continue
+		}
+		if debt := b.debt(now); debt > wait {
+			wait = debt
+		}
+	}
+	return wait
+}
+
+// take takes the bytes and records of a serialized produce request from the
+// buckets.
+func (l *produceLimiter) take(nbytes, nrecords int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := time.Now()
+	if l.bytes != nil {
+		l.bytes.take(now, nbytes)
+	}
+	if l.records != nil {
+		l.records.take(now, nrecords)
+	}
+}
@@ -0,0 +1,77 @@
+package kgo
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestTokenBucket(t *testing.T) {
+	now := time.Now()
+	b := newTokenBucket(100)
+	b.last = now
+
+	if debt := b.debt(now); debt != 0 {
+		t.Errorf("new bucket: got debt %v != exp 0", debt)
+	}
+	b.take(now, 150) // burst of 100, 50 in debt
+	if debt := b.debt(now); debt != 500*time.Millisecond {
+		t.Errorf("overdrawn bucket: got debt %v != exp 500ms", debt)
+	}
+	if debt := b.debt(now.Add(250 * time.Millisecond)); debt != 250*time.Millisecond {
+		t.Errorf("partially repaid bucket: got debt %v != exp 250ms", debt)
+	}
+	if debt := b.debt(now.Add(10 * time.Second)); debt != 0 || b.tokens != 100 {
+		t.Errorf("repaid bucket: got debt %v, tokens %v != exp 0, 100 (burst cap)", debt, b.tokens)
+	}
+}
+
+func TestProduceLimiter(t *testing.T) {
+	l := newProduceLimiter(0, 10)
+	if l.bytes != nil {
+		t.Error("expected no byte bucket with a zero byte limit")
+	}
+	if wait := l.wait(); wait != 0 {
+		t.Errorf("got wait %v != exp 0", wait)
+	}
+	l.take(1<<20, 20) // bytes are unlimited; 10 records in debt
+	if wait := l.wait(); wait < 900*time.Millisecond || wait > time.Second {
+		t.Errorf("got wait %v, exp about 1s", wait)
+	}
+}
+
+// Once a produce request overdraws the rate limit, the next request is not
+// issued until the limit is repaid.
+func TestProduceRateLimit(t *testing.T) {
+	t.Parallel()
+
+	topic, cleanup := tmpTopic(t)
+	defer cleanup()
+
+	cl, _ := NewClient(
+		getSeedBrokers(),
+		DefaultProduceTopic(topic),
+		UnknownTopicRetries(-1),
+		ProduceRateLimit(0, 100),
+	)
+	defer cl.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var rs []*Record
+	for i := 0; i < 150; i++ {
+		rs = append(rs, StringRecord("v"))
+	}
+	if err := cl.ProduceSync(ctx, rs...).FirstErr(); err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Now()
+	if err := cl.ProduceSync(ctx, StringRecord("v")).FirstErr(); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed < 300*time.Millisecond {
+		t.Errorf("produce after overdrawing the limit took %v, exp at least 300ms", elapsed)
+	}
+}
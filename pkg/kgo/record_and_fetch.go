@@ -1,11 +1,17 @@
 package kgo
 
 import (
+	"bytes"
 	"context"
+	"encoding/binary"
 	"errors"
+	"fmt"
+	"io"
 	"reflect"
 	"time"
 	"unsafe"
+
+	"github.com/burningass23/franz-go/pkg/kmsg"
 )
 
 // RecordHeader contains extra information that can be sent with Records.
@@ -142,6 +148,21 @@ type Record struct {
 	// not mirror the offset actually stored within Kafka.
 	Offset int64
 
+	// KeyObject is the typed key of a record, used with the KeySerde
+	// option. When producing, if this is non-nil, it is encoded into Key.
+	// When consuming, this is set to the decoded Key.
+	KeyObject any
+
+	// ValueObject is the typed value of a record, used with the ValueSerde
+	// option. When producing, if this is non-nil, it is encoded into Value.
+	// When consuming, this is set to the decoded Value.
+	ValueObject any
+
+	// SerdeErr is set when consuming if the KeySerde or ValueSerde option
+	// is used and decoding the key or value of this record failed. The
+	// record is still returned so that you can decide how to handle it.
+	SerdeErr error
+
 	// Context is an optional field that is used for enriching records.
 	//
 	// If this field is nil when producing, it is set to the Produce ctx
@@ -228,6 +249,82 @@ func KeySliceRecord(key, value []byte) *Record {
 	return &Record{Key: key, Value: value}
 }
 
+// ReadFullRecord reads exactly size bytes from r into memory and returns a
+// Record with the Value field set to what was read. The value is read into a
+// single allocation of exactly size bytes, avoiding the repeated growing and
+// copying that reading a large value with io.ReadAll incurs.
+//
+// This does not stream the value: the client must hold the full value in
+// memory. Records are buffered until they are acknowledged by Kafka so that
+// they can be retried, and the value is needed to build (and possibly
+// compress) the record batch. Kafka itself is also a poor fit for very large
+// values: every value must fit within the broker's message.max.bytes and the
+// consumer's fetch limits. For large blobs, consider storing the blob
+// elsewhere and producing a reference to it.
+func ReadFullRecord(r io.Reader, size int) (*Record, error) {
+	if size < 0 {
+		return nil, fmt.Errorf("invalid negative record value size %d", size)
+	}
+	value := make([]byte, size)
+	if _, err := io.ReadFull(r, value); err != nil {
+		return nil, fmt.Errorf("unable to read record value: %w", err)
+	}
+	return &Record{Value: value}, nil
+}
+
+// ControlType returns the type of a control record and whether the record is
+// a control record with a well formed key. Transaction markers have the type
+// kmsg.ControlRecordKeyTypeAbort or kmsg.ControlRecordKeyTypeCommit; the
+// transaction the marker ends is identified by the record's ProducerID and
+// ProducerEpoch.
+//
+// Control records are only returned from fetches if the client is configured
+// with KeepControlRecords.
+func (r *Record) ControlType() (kmsg.ControlRecordKeyType, bool) {
+	// A control record key is an int16 version followed by an int16 type.
+	if !r.Attrs.IsControl() || len(r.Key) < 4 {
+		return 0, false
+	}
+	return kmsg.ControlRecordKeyType(binary.BigEndian.Uint16(r.Key[2:])), true
+}
+
+// ValueReader returns an io.Reader over the record's value, which is useful
+// for passing consumed values to functions that take a reader. Consumed
+// values are always fully in memory; this does not stream the value from
+// Kafka, but it also does not copy the value. Values cannot be streamed
+// because records are decoded from entire (possibly compressed) batches. The
+// value must not be modified while the reader is in use.
+func (r *Record) ValueReader() io.Reader {
+	return bytes.NewReader(r.Value)
+}
+
+// HeaderValue returns the value of the first header with the given key, and
+// whether such a header exists. Kafka allows a key to appear in multiple
+// headers; use HeaderValues to get every value.
+//
+// Records usually have only a few headers, for which a scan is cheaper than
+// building and keeping an index, so this does not allocate.
+func (r *Record) HeaderValue(key string) ([]byte, bool) {
+	for i := range r.Headers {
+		if r.Headers[i].Key == key {
+			return r.Headers[i].Value, true
+		}
+	}
+	return nil, false
+}
+
+// HeaderValues returns the values of all headers with the given key, in the
+// order the headers appear in the record.
+func (r *Record) HeaderValues(key string) [][]byte {
+	var vs [][]byte
+	for i := range r.Headers {
+		if r.Headers[i].Key == key {
+			vs = append(vs, r.Headers[i].Value)
+		}
+	}
+	return vs
+}
+
 // FetchPartition is a response for a partition in a fetched topic from a
 // broker.
 type FetchPartition struct {
@@ -240,6 +337,14 @@ type FetchPartition struct {
 	Err error
 	// HighWatermark is the current high watermark for this partition, that
 	// is, the current offset that is on all in sync replicas.
+	//
+	// HighWatermark, LastStableOffset, and LogStartOffset are the
+	// watermarks the broker returned in the fetch response that these
+	// records came from. They can be used to compute lag (see Lag) or the
+	// size of the partition without issuing a separate ListOffsets
+	// request. If a fetch response contains no records and no errors for
+	// any partition, the response is not returned from polling; the
+	// partitions in such a response are caught up.
 	HighWatermark int64
 	// LastStableOffset is the offset at which all prior offsets have been
 	// "decided". Non transactional records are always decided immediately,
@@ -249,10 +354,60 @@ type FetchPartition struct {
 	// The LastStableOffset will always be at or under the HighWatermark.
 	LastStableOffset int64
 	// LogStartOffset is the low watermark of this partition, otherwise
-	// known as the earliest offset in the partition.
+	// known as the earliest offset in the partition. This is -1 if the
+	// broker does not support fetch v5+ (Kafka 1.0).
 	LogStartOffset int64
 	// Records contains feched records for this partition.
 	Records []*Record
+	// Batches contains the boundaries of the record batches that Records
+	// were read from, in order. This is only populated if the client uses
+	// KeepFetchBatchBoundaries.
+	Batches []FetchBatch
+}
+
+// FetchBatch describes a record batch that fetched records were read from.
+type FetchBatch struct {
+	// FirstOffset is the offset of the first record in the batch.
+	FirstOffset int64
+	// LastOffset is the offset of the last record in the batch. Records
+	// at the end of a batch may have been compacted away, meaning the
+	// last record returned from this batch can have an offset before
+	// LastOffset.
+	LastOffset int64
+	// ProducerID is the producer ID that produced the batch, or -1.
+	ProducerID int64
+	// ProducerEpoch is the producer epoch that produced the batch, or -1.
+	ProducerEpoch int16
+	// Transactional is whether the batch was produced in a transaction.
+	Transactional bool
+	// Control is whether the batch is a control batch.
+	Control bool
+}
+
+// EachBatch calls fn for each batch in the partition with the records in
+// Records that were read from that batch. Batches with no records (e.g.,
+// control batches or aborted transactional batches) are skipped. This
+// requires the client to use KeepFetchBatchBoundaries; otherwise, fn is
+// never called.
+//
+// If records are polled with PollRecords, a batch may be split across
+// multiple polls. A batch is fully processed once you have processed a record
+// at LastOffset, or once a later batch has been returned.
+func (p *FetchPartition) EachBatch(fn func(FetchBatch, []*Record)) {
+	rs := p.Records
+	for _, b := range p.Batches {
+		for len(rs) > 0 && rs[0].Offset < b.FirstOffset {
+			rs = rs[1:]
+		}
+		var n int
+		for n < len(rs) && rs[n].Offset <= b.LastOffset {
+			n++
+		}
+		if n > 0 {
+			fn(b, rs[:n])
+			rs = rs[n:]
+		}
+	}
 }
 
 // EachRecord calls fn for each record in the partition.
@@ -262,6 +417,25 @@ func (p *FetchPartition) EachRecord(fn func(*Record)) {
 	}
 }
 
+// Lag returns the lag of this partition as of the last record in this fetch,
+// that is, the high watermark minus the offset after the last record. This
+// can be used to track lag on every poll without issuing any requests. If
+// there are no records in this partition, this returns -1.
+//
+// If you are consuming with the read committed isolation level, the high
+// watermark may include uncommitted transactional records that you will not
+// see until they are committed.
+func (p *FetchPartition) Lag() int64 {
+	if len(p.Records) == 0 {
+		return -1
+	}
+	lag := p.HighWatermark - (p.Records[len(p.Records)-1].Offset + 1)
+	if lag < 0 {
+		lag = 0
+	}
+	return lag
+}
+
 // FetchTopic is a response for a fetched topic from a broker.
 type FetchTopic struct {
 	// Topic is the topic this is for.
@@ -0,0 +1,156 @@
+package kgo
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/burningass23/franz-go/pkg/kmsg"
+)
+
+func TestReadFullRecord(t *testing.T) {
+	r, err := ReadFullRecord(strings.NewReader("foobar"), 6)
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if string(r.Value) != "foobar" || cap(r.Value) != 6 {
+		t.Errorf("got value %q (cap %d) != exp \"foobar\" (cap 6)", r.Value, cap(r.Value))
+	}
+
+	// A short reader is an error, as is a negative size.
+	if _, err := ReadFullRecord(strings.NewReader("foo"), 6); err == nil {
+		t.Error("expected error reading from a short reader")
+	}
+	if _, err := ReadFullRecord(strings.NewReader("foo"), -1); err == nil {
+		t.Error("expected error with a negative size")
+	}
+
+	// Reading only consumes size bytes.
+	src := strings.NewReader("foobar")
+	if r, err = ReadFullRecord(src, 3); err != nil || string(r.Value) != "foo" {
+		t.Errorf("got %q, %v != exp \"foo\", <nil>", r.Value, err)
+	}
+	if rem, _ := io.ReadAll(src); string(rem) != "bar" {
+		t.Errorf("got remaining %q != exp \"bar\"", rem)
+	}
+}
+
+func TestValueReader(t *testing.T) {
+	r := SliceRecord([]byte("foobar"))
+	got, err := io.ReadAll(r.ValueReader())
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if !bytes.Equal(got, r.Value) {
+		t.Errorf("got %q != exp %q", got, r.Value)
+	}
+}
+
+func TestFetchPartitionLag(t *testing.T) {
+	for _, test := range []struct {
+		name    string
+		hwm     int64
+		offsets []int64
+		exp     int64
+	}{
+		{"no records", 10, nil, -1},
+		{"caught up", 10, []int64{8, 9}, 0},
+		{"lagging", 10, []int64{3, 4}, 5},
+		{"stale high watermark", 3, []int64{3, 4}, 0},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			p := FetchPartition{HighWatermark: test.hwm}
+			for _, o := range test.offsets {
+				p.Records = append(p.Records, &Record{Offset: o})
+			}
+			if got := p.Lag(); got != test.exp {
+				t.Errorf("got lag %d, exp %d", got, test.exp)
+			}
+		})
+	}
+}
+
+func TestFetchSessionStats(t *testing.T) {
+	s := &source{nodeID: 3}
+	s.session.id = 10
+	s.session.epoch = 2
+	s.session.used = map[string]map[int32]fetchSessionOffsetEpoch{
+		"foo": {0: {}, 1: {}},
+		"bar": {0: {}},
+	}
+	s.publishSessionStats()
+	exp := FetchSessionStats{NodeID: 3, Incremental: true, SessionID: 10, Epoch: 2, Partitions: 3}
+	if s.sessionStats != exp {
+		t.Errorf("incremental session: got %+v != exp %+v", s.sessionStats, exp)
+	}
+
+	s.session.reset() // the next request is a full request, epoch 0
+	s.publishSessionStats()
+	exp = FetchSessionStats{NodeID: 3, SessionID: 10}
+	if s.sessionStats != exp {
+		t.Errorf("reset session: got %+v != exp %+v", s.sessionStats, exp)
+	}
+
+	s.session.kill()
+	s.publishSessionStats()
+	exp = FetchSessionStats{NodeID: 3, Disabled: true}
+	if s.sessionStats != exp {
+		t.Errorf("killed session: got %+v != exp %+v", s.sessionStats, exp)
+	}
+}
+
+func TestDisableFetchSessionsFor(t *testing.T) {
+	t.Parallel()
+
+	topic, cleanup := tmpTopicPartitions(t, 1)
+	defer cleanup()
+
+	// We look up the partition leader so that we can disable sessions
+	// with only that broker.
+	meta, err := adm.Request(context.Background(), kmsg.NewPtrMetadataRequest())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var leader int32 = -1
+	for _, mt := range meta.(*kmsg.MetadataResponse).Topics {
+		if mt.Topic != nil && *mt.Topic == topic {
+			leader = mt.Partitions[0].Leader
+		}
+	}
+	if leader == -1 {
+		t.Fatalf("unable to find leader for %s", topic)
+	}
+
+	cl, _ := NewClient(
+		getSeedBrokers(),
+		ConsumeTopics(topic),
+		DisableFetchSessionsFor(leader, leader+100),
+		FetchMaxWait(100*time.Millisecond),
+	)
+	defer cl.Close()
+
+	if v := cl.OptValue(DisableFetchSessionsFor); !reflect.DeepEqual(v, []int32{leader, leader + 100}) {
+		t.Errorf("got OptValue %v, exp %v", v, []int32{leader, leader + 100})
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+	cl.PollFetches(ctx) // the topic is empty; we just need to have fetched
+
+	var found bool
+	for _, s := range cl.FetchSessions() {
+		if s.NodeID == leader {
+			found = true
+			if !s.Disabled || s.SessionID != 0 || s.Partitions != 0 {
+				t.Errorf("got %+v, exp a disabled session", s)
+			}
+		}
+	}
+	if !found {
+		t.Errorf("no session stats for leader %d in %+v", leader, cl.FetchSessions())
+	}
+}
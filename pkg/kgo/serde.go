@@ -0,0 +1,84 @@
+package kgo
+
+import "fmt"
+
+// Serde encodes and decodes record keys or values, allowing the client to
+// transparently convert between typed objects and the bytes that are written
+// to and read from Kafka. See the KeySerde and ValueSerde options.
+//
+// The sr package's *sr.Serde implements this interface, allowing schema
+// registry encoding to be configured once on the client. Any other encoding
+// (JSON, encryption, etc.) can be used by implementing these two methods.
+type Serde interface {
+	// Encode encodes v, which is the KeyObject or ValueObject of a record
+	// being produced.
+	Encode(v any) ([]byte, error)
+
+	// DecodeNew decodes b, which is the Key or Value of a consumed record,
+	// into a new object.
+	DecodeNew(b []byte) (any, error)
+}
+
+// encodeRecord encodes the key and value objects of a record if the
+// corresponding serde is configured and the object is non-nil.
+func (cl *Client) encodeRecord(r *Record) error {
+	if s := cl.cfg.keySerde; s != nil && r.KeyObject != nil {
+		k, err := s.Encode(r.KeyObject)
+		if err != nil {
+			return fmt.Errorf("unable to encode record key: %w", err)
+		}
+		r.Key = k
+	}
+	if s := cl.cfg.valueSerde; s != nil && r.ValueObject != nil {
+		v, err := s.Encode(r.ValueObject)
+		if err != nil {
+			return fmt.Errorf("unable to encode record value: %w", err)
+		}
+		r.Value = v
+	}
+	return nil
+}
+
+// decodeRecords decodes the key and value of every non-control record if the
+// corresponding serde is configured and the key or value is non-nil. The
+// first decoding error for a record is saved in the record's SerdeErr.
+//
+// If SkipSerdeErrors is used with a function, records that fail decoding are
+// passed to that function. The records are still returned from polling so
+// that committing can advance past them.
+func (cl *Client) decodeRecords(rs []*Record) {
+	ks, vs := cl.cfg.keySerde, cl.cfg.valueSerde
+	if ks == nil && vs == nil {
+		return
+	}
+	for _, r := range rs {
+		if r.Attrs.IsControl() {
+			continue
+		}
+		if ks != nil && r.Key != nil {
+			k, err := ks.DecodeNew(r.Key)
+			if err != nil {
+				r.SerdeErr = fmt.Errorf("unable to decode record key: %w", err)
+				continue
+			}
+			r.KeyObject = k
+		}
+		if vs != nil && r.Value != nil {
+			v, err := vs.DecodeNew(r.Value)
+			if err != nil {
+				r.SerdeErr = fmt.Errorf("unable to decode record value: %w", err)
+				continue
+			}
+			r.ValueObject = v
+		}
+	}
+
+	if !cl.cfg.skipSerdeErrs || cl.cfg.onSerdeErr == nil {
+		return
+	}
+	for _, r := range rs {
+		if r.SerdeErr != nil {
+			cl.cfg.onSerdeErr(r)
+		}
+	}
+}
@@ -0,0 +1,163 @@
+package kgo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// prefixSerde encodes strings with a "s:" prefix and fails to encode anything
+// else, or to decode anything without the prefix.
+type prefixSerde struct{}
+
+func (prefixSerde) Encode(v any) ([]byte, error) {
+	s, ok := v.(string)
+	if !ok {
+		return nil, fmt.Errorf("cannot encode %T", v)
+	}
+	return []byte("s:" + s), nil
+}
+
+func (prefixSerde) DecodeNew(b []byte) (any, error) {
+	if !strings.HasPrefix(string(b), "s:") {
+		return nil, errors.New("missing prefix")
+	}
+	return string(b[2:]), nil
+}
+
+type bufferedValuesHook struct {
+	mu     sync.Mutex
+	values []string
+}
+
+func (h *bufferedValuesHook) OnProduceRecordBuffered(r *Record) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.values = append(h.values, string(r.Value))
+}
+
+func TestSerde(t *testing.T) {
+	t.Parallel()
+
+	topic, topicCleanup := tmpTopicPartitions(t, 1)
+	defer topicCleanup()
+
+	hook := new(bufferedValuesHook)
+	cl, _ := NewClient(
+		getSeedBrokers(),
+		DefaultProduceTopic(topic),
+		UnknownTopicRetries(-1),
+		ConsumeTopics(topic),
+		KeySerde(prefixSerde{}),
+		ValueSerde(prefixSerde{}),
+		WithHooks(hook),
+	)
+	defer cl.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// Typed records are encoded before buffered hooks see them.
+	if err := cl.ProduceSync(ctx, &Record{KeyObject: "k", ValueObject: "v"}).FirstErr(); err != nil {
+		t.Fatal(err)
+	}
+	hook.mu.Lock()
+	if len(hook.values) != 1 || hook.values[0] != "s:v" {
+		t.Errorf("buffered hook saw values %q, exp [s:v]", hook.values)
+	}
+	hook.mu.Unlock()
+
+	// Encoding failures fail the record.
+	if err := cl.ProduceSync(ctx, &Record{ValueObject: 1}).FirstErr(); err == nil || !strings.Contains(err.Error(), "unable to encode record value") {
+		t.Errorf("producing an unencodable value: got %v, exp an encoding error", err)
+	}
+
+	// Raw values are produced as is, and fail decoding when consumed.
+	if err := cl.ProduceSync(ctx, StringRecord("raw")).FirstErr(); err != nil {
+		t.Fatal(err)
+	}
+
+	var rs []*Record
+	for len(rs) < 2 {
+		fs := cl.PollFetches(ctx)
+		if err := fs.Err0(); err != nil {
+			t.Fatal(err)
+		}
+		rs = append(rs, fs.Records()...)
+	}
+	if r := rs[0]; r.SerdeErr != nil || r.KeyObject != "k" || r.ValueObject != "v" {
+		t.Errorf("first record: got key %v, value %v, err %v; exp k, v, nil", r.KeyObject, r.ValueObject, r.SerdeErr)
+	}
+	if r := rs[1]; r.SerdeErr == nil || r.ValueObject != nil || string(r.Value) != "raw" {
+		t.Errorf("second record: got value %q, object %v, err %v; exp raw, nil, a decoding error", r.Value, r.ValueObject, r.SerdeErr)
+	}
+}
+
+func TestSkipSerdeErrors(t *testing.T) {
+	t.Parallel()
+
+	topic, topicCleanup := tmpTopicPartitions(t, 1)
+	defer topicCleanup()
+	group, groupCleanup := tmpGroup(t)
+	defer groupCleanup()
+
+	var (
+		mu      sync.Mutex
+		skipped []string
+	)
+	cl, _ := NewClient(
+		getSeedBrokers(),
+		DefaultProduceTopic(topic),
+		UnknownTopicRetries(-1),
+		ConsumeTopics(topic),
+		ConsumerGroup(group),
+		DisableAutoCommit(),
+		ValueSerde(prefixSerde{}),
+		SkipSerdeErrors(func(r *Record) {
+			mu.Lock()
+			defer mu.Unlock()
+			skipped = append(skipped, string(r.Value))
+		}),
+	)
+	defer cl.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// The undecodable record is last in the partition.
+	if err := cl.ProduceSync(ctx, &Record{ValueObject: "v"}, StringRecord("raw")).FirstErr(); err != nil {
+		t.Fatal(err)
+	}
+
+	var rs []*Record
+	for len(rs) < 2 {
+		fs := cl.PollFetches(ctx)
+		if err := fs.Err0(); err != nil {
+			t.Fatal(err)
+		}
+		rs = append(rs, fs.Records()...)
+	}
+	if r := rs[0]; r.SerdeErr != nil || r.ValueObject != "v" {
+		t.Errorf("first record: got value %v, err %v; exp v, nil", r.ValueObject, r.SerdeErr)
+	}
+	if r := rs[1]; r.SerdeErr == nil || string(r.Value) != "raw" {
+		t.Errorf("second record: got value %q, err %v; exp raw, a decoding error", r.Value, r.SerdeErr)
+	}
+	mu.Lock()
+	if len(skipped) != 1 || skipped[0] != "raw" {
+		t.Errorf("skip function saw %q, exp [raw]", skipped)
+	}
+	mu.Unlock()
+
+	// Committing advances past the undecodable record.
+	if err := cl.CommitUncommittedOffsets(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if o := cl.CommittedOffsets()[topic][0]; o.Offset != 2 {
+		t.Errorf("got committed offset %d, exp 2", o.Offset)
+	}
+}
@@ -45,6 +45,8 @@ type sink struct {
 	// occurs, the backoff is not cleared.
 	consecutiveFailures atomicU32
 
+	breaker circuitBreaker // used if ProduceCircuitBreaker is set
+
 	recBufsMu    sync.Mutex // guards the following
 	recBufs      []*recBuf  // contains all partition records for batch building
 	recBufsStart int        // incremented every req to avoid large batch starvation
@@ -86,6 +88,7 @@ func (s *sink) createReq(id int64, epoch int16) (*produceRequest, *kmsg.AddParti
 
 		hasHook:    s.cl.producer.hasHookBatchWritten,
 		compressor: s.cl.compressor,
+		limiter:    s.cl.producer.limiter,
 
 		wireLength:      s.cl.baseProduceRequestLength(), // start length with no topics
 		wireLengthLimit: s.cl.cfg.maxBrokerWriteBytes,
@@ -211,6 +214,27 @@ func (s *sink) maybeBackoff() {
 	}
 }
 
+// waitRateLimit waits until ProduceRateLimit allows another produce request,
+// returning false if the client is closed while waiting.
+func (s *sink) waitRateLimit() bool {
+	l := s.cl.producer.limiter
+	if l == nil {
+		return true
+	}
+	wait := l.wait()
+	if wait <= 0 {
+		return true
+	}
+	after := time.NewTimer(wait)
+	defer after.Stop()
+	select {
+	case <-after.C:
+		return true
+	case <-s.cl.ctx.Done():
+		return false
+	}
+}
+
 func (s *sink) maybeTriggerBackoff(seq uint32) {
 	s.backoffMu.Lock()
 	defer s.backoffMu.Unlock()
@@ -262,6 +286,28 @@ func (s *sink) produce(sem <-chan struct{}) bool {
 		return false
 	}
 
+	// If we are rate limited, we wait until prior produce requests are
+	// paid for before issuing another.
+	if !s.waitRateLimit() {
+		return false
+	}
+
+	// If our circuit breaker is open, we do not produce, and we fail
+	// what we can. If this is the probe after the cooldown and we return
+	// before issuing it, we clear the probe so that the next drain can
+	// probe again.
+	allowed, probe := s.breakerAllows()
+	if !allowed {
+		return false
+	}
+	if probe {
+		defer func() {
+			if !produced {
+				s.breakerProbeUnsent()
+			}
+		}()
+	}
+
 	// producerID can fail from:
 	// - retry failure
 	// - auth failure
@@ -526,6 +572,9 @@ func (s *sink) firstRespCheck(idempotent bool, version int16) {
 // handleReqClientErr is called when the client errors before receiving a
 // produce response.
 func (s *sink) handleReqClientErr(req *produceRequest, err error) {
+	if !errors.Is(err, ErrClientClosed) {
+		s.breakerFailure(err)
+	}
 	switch {
 	default:
 		s.cl.cfg.logger.Log(LogLevelWarn, "random error while producing, requeueing unattempted request", "broker", logID(s.nodeID), "err", err)
@@ -583,6 +632,7 @@ func (s *sink) handleReqResp(br *broker, req *produceRequest, resp kmsg.Response
 	}
 	s.firstRespCheck(req.idempotent(), req.version)
 	s.consecutiveFailures.Store(0)
+	s.breakerSuccess()
 	defer req.metrics.hook(&s.cl.cfg, br) // defer to end so that non-written batches are removed
 
 	var b *bytes.Buffer
@@ -824,6 +874,9 @@ func (s *sink) handleReqRespBatch(
 		err = nil
 		fallthrough
 	default:
+		if failUnknown {
+			err = &ErrUnknownTopic{Topic: topic, err: err}
+		}
 		if err != nil {
 			s.cl.cfg.logger.Log(LogLevelInfo, "batch in a produce request failed",
 				"broker", logID(s.nodeID),
@@ -1289,6 +1342,9 @@ func (recBuf *recBuf) bumpRepeatedLoadErr(err error) {
 		"will_fail", willFail,
 	)
 	if willFail {
+		if isUnknownLimit && !batch0Fail {
+			err = &ErrUnknownTopic{Topic: recBuf.topic, err: err}
+		}
 		recBuf.failAllRecords(err)
 	}
 }
@@ -1363,6 +1419,16 @@ type promisedRec struct {
 	ctx     context.Context
 	promise func(*Record, error)
 	*Record
+	start time.Time // when the record was produced, for RecordDeliveryTimeout
+
+	parts *topicPartitions // non-nil once the record is partitioned, for FlushTopics
+}
+
+// countAgainst counts the record as buffered for its topic, which
+// finishRecordPromise undoes.
+func (pr *promisedRec) countAgainst(parts *topicPartitions) {
+	pr.parts = parts
+	parts.buffered.Add(1)
 }
 
 // recBatch is the type used for buffering records before they are written.
@@ -1482,7 +1548,7 @@ func (b *recBatch) isTimedOut(limit time.Duration) bool {
 	if limit == 0 {
 		return false
 	}
-	return time.Since(b.records[0].Timestamp) > limit
+	return time.Since(b.records[0].start) > limit
 }
 
 // Decrements the inflight count for this batch.
@@ -1527,6 +1593,8 @@ type produceRequest struct {
 	timeout int32
 	batches seqRecBatches
 
+	numRecords int // total records across all batches, for rate limiting
+
 	producerID    int64
 	producerEpoch int16
 
@@ -1538,6 +1606,7 @@ type produceRequest struct {
 	hasHook bool
 
 	compressor *compressor
+	limiter    *produceLimiter // non-nil if ProduceRateLimit is used
 
 	// wireLength is initially the size of sending a produce request,
 	// including the request header, with no topics. We start with the
@@ -1618,6 +1687,7 @@ func (p *produceRequest) tryAddBatch(produceVersion int32, recBuf *recBuf, batch
 
 	batch.tries++
 	p.wireLength += batchWireLength
+	p.numRecords += len(batch.records)
 	p.batches.addBatch(
 		recBuf.topic,
 		recBuf.partition,
@@ -1891,6 +1961,7 @@ func (p *produceRequest) GetVersion() int16  { return p.version }
 func (p *produceRequest) IsFlexible() bool   { return p.version >= 9 }
 func (p *produceRequest) AppendTo(dst []byte) []byte {
 	flexible := p.IsFlexible()
+	start := len(dst)
 
 	if p.hasHook {
 		p.metrics = make(map[string]map[int32]ProduceBatchMetrics)
@@ -1962,6 +2033,10 @@ func (p *produceRequest) AppendTo(dst []byte) []byte {
 		dst = append(dst, 0)
 	}
 
+	if p.limiter != nil {
+		p.limiter.take(len(dst)-start, p.numRecords)
+	}
+
 	return dst
 }
 
@@ -38,6 +38,11 @@ type source struct {
 
 	session fetchSession // supports fetch sessions as per KIP-227
 
+	// sessionStats is a snapshot of session, published after every fetch
+	// so that FetchSessions can read it without racing the fetch loop.
+	sessionStatsMu sync.Mutex
+	sessionStats   FetchSessionStats
+
 	cursorsMu    sync.Mutex
 	cursors      []*cursor // contains all partitions being consumed on this source
 	cursorsStart int       // incremented every fetch req to ensure all partitions are fetched
@@ -49,9 +54,10 @@ func (cl *Client) newSource(nodeID int32) *source {
 		nodeID: nodeID,
 		sem:    make(chan struct{}),
 	}
-	if cl.cfg.disableFetchSessions {
+	if cl.cfg.disableFetchSessions || cl.cfg.disableFetchSessionsFor[nodeID] {
 		s.session.kill()
 	}
+	s.publishSessionStats()
 	close(s.sem)
 	return s
 }
@@ -99,6 +105,7 @@ type cursor struct {
 	unknownIDFails atomicI32
 
 	keepControl bool // whether to keep control records
+	keepBatches bool // whether to track record batch boundaries
 
 	cursorsIdx int // updated under source mutex
 
@@ -290,6 +297,11 @@ type bufferedFetch struct {
 }
 
 func (s *source) hook(f *Fetch, buffered, polled bool) {
+	s.userHook(f, buffered, polled)
+	s.countBuffered(f, buffered)
+}
+
+func (s *source) userHook(f *Fetch, buffered, polled bool) {
 	s.cl.cfg.hooks.each(func(h Hook) {
 		if buffered {
 			h, ok := h.(HookFetchRecordBuffered)
@@ -321,19 +333,38 @@ func (s *source) hook(f *Fetch, buffered, polled bool) {
 			}
 		}
 	})
+}
 
+func (s *source) countBuffered(f *Fetch, buffered bool) {
 	var nrecs int
+	var nbytes int64
 	for i := range f.Topics {
 		t := &f.Topics[i]
 		for j := range t.Partitions {
-			nrecs += len(t.Partitions[j].Records)
+			p := &t.Partitions[j]
+			nrecs += len(p.Records)
+			for _, r := range p.Records {
+				nbytes += recordUserBytes(r)
+			}
 		}
 	}
 	if buffered {
 		s.cl.consumer.bufferedRecords.Add(int64(nrecs))
+		s.cl.consumer.bufferedBytes.Add(nbytes)
 	} else {
 		s.cl.consumer.bufferedRecords.Add(-int64(nrecs))
+		s.cl.consumer.unbufferedBytes(nbytes)
+	}
+}
+
+// recordUserBytes returns the size of the user data in a record: the key,
+// value, and headers.
+func recordUserBytes(r *Record) int64 {
+	n := int64(len(r.Key) + len(r.Value))
+	for _, h := range r.Headers {
+		n += int64(len(h.Key) + len(h.Value))
 	}
+	return n
 }
 
 // takeBuffered drains a buffered fetch and updates offsets.
@@ -424,23 +455,36 @@ func (s *source) takeBufferedFn(polled bool, offsetFn func(usedOffsets)) Fetch {
 	r := s.buffered
 	s.buffered = bufferedFetch{}
 	offsetFn(r.usedOffsets)
+
+	// We drop our buffered bytes before signaling that the fetch is
+	// done so that the fetch manager sees the new byte count when it
+	// decides whether another fetch is allowed.
+	s.countBuffered(&r.fetch, false)
 	r.doneFetch <- struct{}{}
 	close(s.sem)
 
-	s.hook(&r.fetch, false, polled) // unbuffered, potentially polled
+	s.userHook(&r.fetch, false, polled) // unbuffered, potentially polled
 
 	return r.fetch
 }
 
 // createReq actually creates a fetch request.
 func (s *source) createReq() *fetchRequest {
+	// If the isolation level was changed with SetFetchIsolationLevel, we
+	// reset our session so that the new level applies to a fresh session.
+	isolationLevel := int8(s.cl.cfg.isolationLevel.load())
+	if isolationLevel != s.session.isolationLevel {
+		s.session.reset()
+		s.session.isolationLevel = isolationLevel
+	}
+
 	req := &fetchRequest{
 		maxWait:        s.cl.cfg.maxWait,
 		minBytes:       s.cl.cfg.minBytes,
 		maxBytes:       s.cl.cfg.maxBytes.load(),
 		maxPartBytes:   s.cl.cfg.maxPartBytes.load(),
 		rack:           s.cl.cfg.rack,
-		isolationLevel: s.cl.cfg.isolationLevel,
+		isolationLevel: isolationLevel,
 		preferLagFn:    s.cl.cfg.preferLagFn,
 
 		// We copy a view of the session for the request, which allows
@@ -538,13 +582,14 @@ func (s *source) killSessionOnClose(ctx context.Context) {
 		return
 	}
 	s.session.kill()
+	s.publishSessionStats()
 	req := &fetchRequest{
 		maxWait:        1,
 		minBytes:       1,
 		maxBytes:       1,
 		maxPartBytes:   1,
 		rack:           s.cl.cfg.rack,
-		isolationLevel: s.cl.cfg.isolationLevel,
+		isolationLevel: int8(s.cl.cfg.isolationLevel.load()),
 		session:        s.session,
 	}
 	ch := make(chan struct{})
@@ -570,6 +615,7 @@ func (s *source) killSessionOnClose(ctx context.Context) {
 // replica to use would not be out of date even if the consumer session is
 // changing.
 func (s *source) fetch(consumerSession *consumerSession, doneFetch chan<- struct{}) (fetched bool) {
+	defer s.publishSessionStats()
 	req := s.createReq()
 
 	// For all returns, if we do not buffer our fetch, then we want to
@@ -851,6 +897,7 @@ func (s *source) handleReqResp(br *broker, req *fetchRequest, resp *kmsg.FetchRe
 			}
 
 			fp := partOffset.processRespPartition(br, rp, s.cl.decompressor, s.cl.cfg.hooks)
+			s.cl.decodeRecords(fp.Records)
 			if fp.Err != nil {
 				updateMeta = true
 				updateWhy.add(topic, partition, fp.Err)
@@ -858,7 +905,10 @@ func (s *source) handleReqResp(br *broker, req *fetchRequest, resp *kmsg.FetchRe
 
 			// We only keep the partition if it has no error, or an
 			// error we do not internally retry.
-			var keep bool
+			var (
+				keep   bool
+				action FetchErrorAction // zero if we keep or retry
+			)
 			switch fp.Err {
 			default:
 				if kerr.IsRetriable(fp.Err) && !s.cl.cfg.keepFetchRetryableErrors {
@@ -932,6 +982,7 @@ func (s *source) handleReqResp(br *broker, req *fetchRequest, resp *kmsg.FetchRe
 					if s.cl.cfg.resetOffset.noReset {
 						keep = true
 					} else {
+						action = FetchErrorResetOffset
 						reloadOffsets.addLoad(topic, partition, loadTypeList, offsetLoad{
 							replica: replica,
 							Offset:  s.cl.cfg.resetOffset,
@@ -948,6 +999,7 @@ func (s *source) handleReqResp(br *broker, req *fetchRequest, resp *kmsg.FetchRe
 
 				default: // partOffset.offset > fp.HighWatermark, KIP-392 case 4
 					if kip320 {
+						action = FetchErrorValidateEpoch
 						reloadOffsets.addLoad(topic, partition, loadTypeEpoch, offsetLoad{
 							replica: -1,
 							Offset: Offset{
@@ -974,6 +1026,7 @@ func (s *source) handleReqResp(br *broker, req *fetchRequest, resp *kmsg.FetchRe
 				// but not support offset for leader epoch, so we do
 				// not check KIP-320 support here.
 				if partOffset.lastConsumedEpoch >= 0 {
+					action = FetchErrorValidateEpoch
 					reloadOffsets.addLoad(topic, partition, loadTypeEpoch, offsetLoad{
 						replica: -1,
 						Offset: Offset{
@@ -984,6 +1037,20 @@ func (s *source) handleReqResp(br *broker, req *fetchRequest, resp *kmsg.FetchRe
 				}
 			}
 
+			if fp.Err != nil {
+				if action == 0 {
+					action = FetchErrorRetry
+					if keep {
+						action = FetchErrorReturn
+					}
+				}
+				s.cl.cfg.hooks.each(func(h Hook) {
+					if h, ok := h.(HookFetchPartitionError); ok {
+						h.OnFetchPartitionError(br.meta, topic, partition, fp.Err, action)
+					}
+				})
+			}
+
 			if keep {
 				fetchTopic.Partitions = append(fetchTopic.Partitions, fp)
 			}
@@ -1252,6 +1319,17 @@ func (o *cursorOffsetNext) processRecordBatch(
 		}
 	}()
 
+	if o.from.keepBatches {
+		fp.Batches = append(fp.Batches, FetchBatch{
+			FirstOffset:   batch.FirstOffset,
+			LastOffset:    lastOffset,
+			ProducerID:    batch.ProducerID,
+			ProducerEpoch: batch.ProducerEpoch,
+			Transactional: batch.Attributes&0x0010 != 0,
+			Control:       batch.Attributes&0x0020 != 0,
+		})
+	}
+
 	abortBatch := aborter.shouldAbortBatch(batch)
 	for i := range krecords {
 		record := recordToRecord(
@@ -1950,6 +2028,67 @@ func (f *fetchRequest) ResponseKind() kmsg.Response {
 	return r
 }
 
+// FetchSessionStats describes the fetch session (KIP-227) the client has with
+// a broker it is consuming from.
+type FetchSessionStats struct {
+	// NodeID is the broker this session is with.
+	NodeID int32
+	// Disabled is true if the client does not use a session with this
+	// broker: sessions were disabled with DisableFetchSessions or
+	// DisableFetchSessionsFor, the broker is too old to support sessions,
+	// or the broker refused to create a session (likely because it is at
+	// its session limit).
+	Disabled bool
+	// Incremental is true if an incremental fetch session is established,
+	// meaning fetch requests only include partitions that changed.
+	Incremental bool
+	// SessionID is the broker assigned session ID, or 0 if none.
+	SessionID int32
+	// Epoch is the epoch of the next fetch request in the session. An
+	// epoch of 0 means the next fetch request is a full request that
+	// (re)creates the session.
+	Epoch int32
+	// Partitions is the number of partitions currently tracked in the
+	// session.
+	Partitions int
+}
+
+// FetchSessions returns the state of the fetch session with every broker the
+// client has consumed from, sorted by node ID. This can be used to check
+// whether incremental fetch sessions are actually in use, which matters for
+// the size of fetch requests when consuming many partitions.
+func (cl *Client) FetchSessions() []FetchSessionStats {
+	cl.sinksAndSourcesMu.Lock()
+	stats := make([]FetchSessionStats, 0, len(cl.sinksAndSources))
+	for _, sns := range cl.sinksAndSources {
+		s := sns.source
+		s.sessionStatsMu.Lock()
+		stats = append(stats, s.sessionStats)
+		s.sessionStatsMu.Unlock()
+	}
+	cl.sinksAndSourcesMu.Unlock()
+	sort.Slice(stats, func(i, j int) bool { return stats[i].NodeID < stats[j].NodeID })
+	return stats
+}
+
+func (s *source) publishSessionStats() {
+	stats := FetchSessionStats{
+		NodeID:   s.nodeID,
+		Disabled: s.session.killed,
+	}
+	if !s.session.killed {
+		stats.Incremental = s.session.id != 0 && s.session.epoch > 0
+		stats.SessionID = s.session.id
+		stats.Epoch = s.session.epoch
+		for _, ps := range s.session.used {
+			stats.Partitions += len(ps)
+		}
+	}
+	s.sessionStatsMu.Lock()
+	s.sessionStats = stats
+	s.sessionStatsMu.Unlock()
+}
+
 // fetchSessions, introduced in KIP-227, allow us to send less information back
 // and forth to a Kafka broker.
 type fetchSession struct {
@@ -1959,6 +2098,8 @@ type fetchSession struct {
 	used map[string]map[int32]fetchSessionOffsetEpoch // what we have in the session so far
 
 	killed bool // if we cannot use a session anymore
+
+	isolationLevel int8 // the isolation level this session was created with
 }
 
 func (s *fetchSession) kill() {
@@ -0,0 +1,31 @@
+package kgo
+
+import "context"
+
+// validateTimestampType returns ErrLogAppendTimestamp if the record has an
+// explicit timestamp and its topic uses LogAppendTime and the client is
+// configured to fail such records. If the client is only configured to warn,
+// this logs once per topic and returns nil.
+//
+// The first record for a topic blocks while the topic's config is loaded. If
+// the config has never been loaded successfully, the record is not validated.
+func (cl *Client) validateTimestampType(ctx context.Context, r *Record) error {
+	if r.Timestamp.IsZero() {
+		return nil
+	}
+	cfg, ok, err := cl.producer.topicConfigs.get(ctx, cl.ctx, r.Topic)
+	if err != nil {
+		return err
+	}
+	if !ok || !cfg.logAppendTime {
+		return nil
+	}
+	if cl.cfg.failLogAppendTimestamps {
+		return ErrLogAppendTimestamp
+	}
+
+	if cl.producer.topicConfigs.warnLogAppendOnce(r.Topic) {
+		cl.cfg.logger.Log(LogLevelWarn, "producing a record with an explicit timestamp to a topic that uses LogAppendTime, the broker will overwrite the timestamp", "topic", r.Topic)
+	}
+	return nil
+}
@@ -0,0 +1,153 @@
+package kgo
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/burningass23/franz-go/pkg/kerr"
+	"github.com/burningass23/franz-go/pkg/kmsg"
+)
+
+// topicConfigs caches the configs of topics being produced to, backing
+// ValidateTimestampType and FailOversizedRecords. Both options share one
+// DescribeConfigs request per topic.
+//
+// The first lookup for a topic blocks until the topic's configs are loaded.
+// Afterwards, configs are always served from the cache; once they are older
+// than MetadataMaxAge, they are reloaded in the background while the stale
+// configs continue to be served.
+type topicConfigs struct {
+	maxAge time.Duration
+	load   func(topic string) (topicConfig, error)
+
+	mu     sync.Mutex
+	topics map[string]*topicConfigEntry
+}
+
+// topicConfig is the subset of a topic's configs that producing uses.
+type topicConfig struct {
+	logAppendTime   bool  // message.timestamp.type is LogAppendTime
+	maxMessageBytes int32 // max.message.bytes, 0 if unknown
+}
+
+type topicConfigEntry struct {
+	loaded  chan struct{} // closed once the first load completes
+	loading bool          // whether a load is in flight
+	at      time.Time     // when the last load completed
+
+	cfg topicConfig
+	ok  bool // whether any load succeeded; if not, cfg is empty
+
+	warnedLogAppend bool
+}
+
+func newTopicConfigs(cl *Client) *topicConfigs {
+	return &topicConfigs{
+		maxAge: cl.cfg.metadataMaxAge,
+		load:   cl.describeTopicConfig,
+		topics: make(map[string]*topicConfigEntry),
+	}
+}
+
+// get returns the topic's configs, and whether they were ever successfully
+// loaded. This only blocks for the first lookup of a topic.
+func (c *topicConfigs) get(ctx, clientCtx context.Context, topic string) (topicConfig, bool, error) {
+	c.mu.Lock()
+	e := c.topics[topic]
+	if e == nil {
+		e = &topicConfigEntry{loaded: make(chan struct{})}
+		c.topics[topic] = e
+		c.reload(topic, e)
+	} else if !e.loading && time.Since(e.at) > c.maxAge {
+		c.reload(topic, e)
+	}
+	c.mu.Unlock()
+
+	select {
+	case <-e.loaded:
+	case <-ctx.Done():
+		return topicConfig{}, false, ctx.Err()
+	case <-clientCtx.Done():
+		return topicConfig{}, false, ErrClientClosed
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return e.cfg, e.ok, nil
+}
+
+// reload loads the topic's configs in the background. If the load fails, any
+// previously loaded configs continue to be served. c.mu must be held.
+func (c *topicConfigs) reload(topic string, e *topicConfigEntry) {
+	e.loading = true
+	go func() {
+		cfg, err := c.load(topic)
+
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		e.loading = false
+		e.at = time.Now()
+		if err == nil {
+			e.cfg, e.ok = cfg, true
+		}
+		select {
+		case <-e.loaded:
+		default:
+			close(e.loaded)
+		}
+	}()
+}
+
+// warnLogAppendOnce returns true the first time it is called for a topic.
+func (c *topicConfigs) warnLogAppendOnce(topic string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e := c.topics[topic]
+	if e == nil || e.warnedLogAppend {
+		return false
+	}
+	e.warnedLogAppend = true
+	return true
+}
+
+func (cl *Client) describeTopicConfig(topic string) (topicConfig, error) {
+	var cfg topicConfig
+
+	req := kmsg.NewPtrDescribeConfigsRequest()
+	rr := kmsg.NewDescribeConfigsRequestResource()
+	rr.ResourceType = kmsg.ConfigResourceTypeTopic
+	rr.ResourceName = topic
+	rr.ConfigNames = []string{"message.timestamp.type", "max.message.bytes"}
+	req.Resources = append(req.Resources, rr)
+
+	resp, err := req.RequestWith(cl.ctx, cl)
+	if err == nil {
+		if len(resp.Resources) != 1 {
+			err = errMissingDescribedConfig
+		} else {
+			rr := resp.Resources[0]
+			if err = kerr.ErrorForCode(rr.ErrorCode); err == nil {
+				for _, c := range rr.Configs {
+					if c.Value == nil {
+						continue
+					}
+					switch c.Name {
+					case "message.timestamp.type":
+						cfg.logAppendTime = *c.Value == "LogAppendTime"
+					case "max.message.bytes":
+						limit, perr := strconv.ParseInt(*c.Value, 10, 32)
+						if perr == nil && limit > 0 {
+							cfg.maxMessageBytes = int32(limit)
+						}
+					}
+				}
+			}
+		}
+	}
+	if err != nil {
+		cl.cfg.logger.Log(LogLevelWarn, "unable to describe topic config to validate produced records, continuing with the last known config (if any) until the config is reloaded", "topic", topic, "err", err)
+	}
+	return cfg, err
+}
@@ -0,0 +1,214 @@
+package kgo
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeTopicConfigLoader serves loads from a channel, counting loads per topic.
+type fakeTopicConfigLoader struct {
+	mu    sync.Mutex
+	loads map[string]int
+	resps chan fakeTopicConfigResp
+}
+
+type fakeTopicConfigResp struct {
+	cfg topicConfig
+	err error
+}
+
+func newFakeTopicConfigLoader() *fakeTopicConfigLoader {
+	return &fakeTopicConfigLoader{
+		loads: make(map[string]int),
+		resps: make(chan fakeTopicConfigResp),
+	}
+}
+
+func (f *fakeTopicConfigLoader) load(topic string) (topicConfig, error) {
+	f.mu.Lock()
+	f.loads[topic]++
+	f.mu.Unlock()
+	resp := <-f.resps
+	return resp.cfg, resp.err
+}
+
+func (f *fakeTopicConfigLoader) nloads(topic string) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.loads[topic]
+}
+
+func TestTopicConfigs(t *testing.T) {
+	f := newFakeTopicConfigLoader()
+	c := &topicConfigs{
+		maxAge: time.Hour,
+		load:   f.load,
+		topics: make(map[string]*topicConfigEntry),
+	}
+	ctx := context.Background()
+
+	type got struct {
+		cfg topicConfig
+		ok  bool
+		err error
+	}
+	get := func(ctx context.Context) <-chan got {
+		ch := make(chan got, 1)
+		go func() {
+			cfg, ok, err := c.get(ctx, context.Background(), "foo")
+			ch <- got{cfg, ok, err}
+		}()
+		return ch
+	}
+
+	// The first lookups block until the single load completes.
+	g1, g2 := get(ctx), get(ctx)
+	canceledCtx, cancel := context.WithCancel(ctx)
+	g3 := get(canceledCtx)
+	cancel()
+	if g := <-g3; !errors.Is(g.err, context.Canceled) {
+		t.Errorf("canceled lookup: got err %v, exp context.Canceled", g.err)
+	}
+	select {
+	case <-g1:
+		t.Fatal("lookup returned before the first load completed")
+	case <-time.After(20 * time.Millisecond):
+	}
+	v1 := topicConfig{logAppendTime: true, maxMessageBytes: 100}
+	f.resps <- fakeTopicConfigResp{cfg: v1}
+	for _, ch := range []<-chan got{g1, g2} {
+		if g := <-ch; g.cfg != v1 || !g.ok || g.err != nil {
+			t.Errorf("first lookup: got %+v, exp %+v", g, v1)
+		}
+	}
+	if n := f.nloads("foo"); n != 1 {
+		t.Errorf("got %d loads, exp 1", n)
+	}
+
+	// Once stale, lookups return the cached config immediately and
+	// trigger one background reload.
+	c.mu.Lock()
+	c.topics["foo"].at = time.Now().Add(-2 * time.Hour)
+	c.mu.Unlock()
+	for i := 0; i < 3; i++ {
+		select {
+		case g := <-get(ctx):
+			if g.cfg != v1 || !g.ok {
+				t.Errorf("stale lookup: got %+v, exp cached %+v", g, v1)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("stale lookup blocked on the reload")
+		}
+	}
+	for start := time.Now(); f.nloads("foo") < 2 && time.Since(start) < time.Second; {
+		time.Sleep(time.Millisecond)
+	}
+	if n := f.nloads("foo"); n != 2 {
+		t.Errorf("got %d loads, exp 2", n)
+	}
+
+	// A failed reload keeps the prior config.
+	f.resps <- fakeTopicConfigResp{err: errors.New("denied")}
+	waitNotLoading := func() {
+		for start := time.Now(); time.Since(start) < time.Second; time.Sleep(time.Millisecond) {
+			c.mu.Lock()
+			loading := c.topics["foo"].loading
+			c.mu.Unlock()
+			if !loading {
+				return
+			}
+		}
+		t.Fatal("reload did not complete")
+	}
+	waitNotLoading()
+	if g := <-get(ctx); g.cfg != v1 || !g.ok {
+		t.Errorf("after failed reload: got %+v, exp cached %+v", g, v1)
+	}
+
+	// A successful reload replaces the config.
+	c.mu.Lock()
+	c.topics["foo"].at = time.Time{}
+	c.mu.Unlock()
+	<-get(ctx)
+	v2 := topicConfig{maxMessageBytes: 200}
+	f.resps <- fakeTopicConfigResp{cfg: v2}
+	waitNotLoading()
+	if g := <-get(ctx); g.cfg != v2 || !g.ok {
+		t.Errorf("after reload: got %+v, exp %+v", g, v2)
+	}
+
+	// A topic whose config never loads is reported as not ok.
+	ch := make(chan got, 1)
+	go func() {
+		cfg, ok, err := c.get(ctx, context.Background(), "bar")
+		ch <- got{cfg, ok, err}
+	}()
+	f.resps <- fakeTopicConfigResp{err: errors.New("denied")}
+	if g := <-ch; g.ok || g.err != nil || g.cfg != (topicConfig{}) {
+		t.Errorf("failed first load: got %+v, exp not ok", g)
+	}
+}
+
+// ValidateTimestampType and FailOversizedRecords share one config load per
+// topic.
+func TestTopicConfigsShared(t *testing.T) {
+	var oversized []*Record
+	cl, _ := NewClient(
+		getSeedBrokers(),
+		DefaultProduceTopic("foo"),
+		ProducerBatchCompression(NoCompression()),
+		ValidateTimestampType(true),
+		FailOversizedRecords(func(r *Record, _ *ErrRecordTooLarge) { oversized = append(oversized, r) }),
+	)
+	defer cl.Close()
+
+	f := newFakeTopicConfigLoader()
+	cl.producer.topicConfigs.load = f.load
+	go func() { f.resps <- fakeTopicConfigResp{cfg: topicConfig{logAppendTime: true, maxMessageBytes: 200}} }()
+
+	ctx := context.Background()
+	if err := cl.ProduceSync(ctx, &Record{Timestamp: time.Now(), Value: []byte("v")}).FirstErr(); !errors.Is(err, ErrLogAppendTimestamp) {
+		t.Errorf("explicit timestamp: got err %v, exp ErrLogAppendTimestamp", err)
+	}
+	big := &Record{Value: make([]byte, 300)}
+	var tooLarge *ErrRecordTooLarge
+	if err := cl.ProduceSync(ctx, big).FirstErr(); !errors.As(err, &tooLarge) || tooLarge.Limit != 200 {
+		t.Errorf("oversized record: got err %v, exp *ErrRecordTooLarge with limit 200", err)
+	}
+	if len(oversized) != 1 || oversized[0] != big {
+		t.Errorf("got oversized callbacks for %v, exp the big record", oversized)
+	}
+	if n := f.nloads("foo"); n != 1 {
+		t.Errorf("got %d config loads, exp 1 shared load", n)
+	}
+}
+
+// When compressing, records are not checked against the topic's limit, which
+// the broker applies to compressed batches.
+func TestFailOversizedRecordsCompressed(t *testing.T) {
+	cl, _ := NewClient(
+		getSeedBrokers(),
+		ProducerBatchCompression(SnappyCompression()),
+		FailOversizedRecords(nil),
+	)
+	defer cl.Close()
+
+	f := newFakeTopicConfigLoader()
+	cl.producer.topicConfigs.load = f.load
+
+	ctx := context.Background()
+	if err := cl.validateRecordSize(ctx, &Record{Topic: "foo", Value: make([]byte, 300)}); err != nil {
+		t.Errorf("compressible record: got err %v, exp nil", err)
+	}
+	var tooLarge *ErrRecordTooLarge
+	huge := &Record{Topic: "foo", Value: make([]byte, cl.cfg.maxRecordBatchBytes)}
+	if err := cl.validateRecordSize(ctx, huge); !errors.As(err, &tooLarge) {
+		t.Errorf("record over ProducerBatchMaxBytes: got err %v, exp *ErrRecordTooLarge", err)
+	}
+	if n := f.nloads("foo"); n != 0 {
+		t.Errorf("got %d config loads, exp 0", n)
+	}
+}
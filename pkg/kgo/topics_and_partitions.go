@@ -232,6 +232,8 @@ type topicPartitions struct {
 	partsMu     sync.Mutex
 	partitioner TopicPartitioner
 	lb          *leastBackupInput // for partitioning if the partitioner is a LoadTopicPartitioner
+
+	buffered atomicI64 // partitioned records that are not yet finished, for FlushTopics
 }
 
 func (t *topicPartitions) load() *topicPartitionsData { return t.v.Load().(*topicPartitionsData) }
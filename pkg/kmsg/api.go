@@ -21,8 +21,16 @@
 //	struct := kmsg.NewFoo()
 //	struct.Field = "value I want to set"
 //
+// Every request also has a builder, which initializes nested structs with
+// their New functions before passing them to you:
+//
+//	req := kmsg.BuildMetadataRequest().
+//		Topics(func(t *kmsg.MetadataRequestTopic) { t.Topic = kmsg.StringPtr("foo") }).
+//		Build()
+//
 // Most of this package is generated, but a few things are manual. What is
-// manual: all interfaces, the RequestFormatter, record / message / record
+// manual: all interfaces, the RequestFormatter, request header
+// parsing, version conversion, record / message / record
 // batch reading, and sticky member metadata serialization.
 package kmsg
 
@@ -211,6 +219,21 @@ func (f *RequestFormatter) AppendRequest(
 	r Request,
 	correlationID int32,
 ) []byte {
+	dst = f.appendHeader(dst, r, correlationID)
+	if r.Key() == 7 && r.GetVersion() == 0 {
+		return dst
+	}
+
+	// Now the request body.
+	dst = r.AppendTo(dst)
+
+	kbin.AppendInt32(dst[:0], int32(len(dst[4:])))
+	return dst
+}
+
+// appendHeader appends a reserved length and the request header to dst. The
+// caller must fill in the length.
+func (f *RequestFormatter) appendHeader(dst []byte, r Request, correlationID int32) []byte {
 	dst = append(dst, 0, 0, 0, 0) // reserve length
 	k := r.Key()
 	v := r.GetVersion()
@@ -236,12 +259,40 @@ func (f *RequestFormatter) AppendRequest(
 			// TODO when tags are added
 		}
 	}
+	return dst
+}
 
-	// Now the request body.
-	dst = r.AppendTo(dst)
+// HeaderSize returns the number of bytes that AppendRequest writes before the
+// request body for r at r's current version: the four byte length prefix and
+// the request header (including the client ID and, for flexible requests, the
+// header tags). Other than for ControlledShutdown v0, which has a special
+// format, AppendRequest writes exactly HeaderSize(r) + RequestBodySize(r)
+// bytes.
+func (f *RequestFormatter) HeaderSize(r Request) int {
+	k := r.Key()
+	v := r.GetVersion()
+	n := 4 + 2 + 2 + 4 // length, key, version, correlation ID
+	if k == 7 && v == 0 {
+		return n
+	}
+	n += 2 // nullable client ID length
+	if f.clientID != nil {
+		n += len(*f.clientID)
+	}
+	if r.IsFlexible() {
+		n++ // no header tags
+	}
+	return n
+}
 
-	kbin.AppendInt32(dst[:0], int32(len(dst[4:])))
-	return dst
+// RequestBodySize returns the size of the request body for r at r's current
+// version, that is, the number of bytes r.AppendTo produces. This does not
+// include the length prefix nor the request header; see
+// RequestFormatter.HeaderSize.
+//
+// This function encodes the request to determine its size.
+func RequestBodySize(r Request) int {
+	return len(r.AppendTo(nil))
 }
 
 // StringPtr is a helper to return a pointer to a string.
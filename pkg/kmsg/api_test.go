@@ -0,0 +1,58 @@
+package kmsg
+
+import "testing"
+
+func TestRequestHeaderAndBodySize(t *testing.T) {
+	for _, f := range []*RequestFormatter{
+		NewRequestFormatter(),
+		NewRequestFormatter(FormatterClientID("")),
+		NewRequestFormatter(FormatterClientID("kgo-client")),
+	} {
+		for key := int16(0); key <= MaxKey; key++ {
+			r := RequestForKey(key)
+			if r == nil {
+				continue
+			}
+			for v := int16(0); v <= r.MaxVersion(); v++ {
+				r.SetVersion(v)
+				if key == 7 && v == 0 {
+					continue // ControlledShutdown v0 has a special format, as documented
+				}
+				header, body := f.HeaderSize(r), RequestBodySize(r)
+				if got := len(f.AppendRequest(nil, r, 1)); got != header+body {
+					t.Errorf("key %d v%d: AppendRequest wrote %d bytes != header %d + body %d", key, v, got, header, body)
+				}
+				if body != len(r.AppendTo(nil)) {
+					t.Errorf("key %d v%d: body size %d != AppendTo size %d", key, v, body, len(r.AppendTo(nil)))
+				}
+			}
+		}
+	}
+}
+
+func TestApiVersionRanges(t *testing.T) {
+	ranges := ApiVersionRanges()
+	for key := int16(0); key <= MaxKey; key++ {
+		req := RequestForKey(key)
+		r, ok := ranges[key]
+		if req == nil {
+			if ok {
+				t.Errorf("key %d: has a range but no request", key)
+			}
+			continue
+		}
+		if !ok {
+			t.Errorf("key %d: missing range", key)
+			continue
+		}
+		if r.MaxVersion != req.MaxVersion() || r.MinVersion < 0 || r.MinVersion > r.MaxVersion {
+			t.Errorf("key %d: got range %v, exp min in [0, %d] and max %d", key, r, req.MaxVersion(), req.MaxVersion())
+		}
+	}
+
+	// The returned map is new on every call.
+	delete(ranges, 0)
+	if _, ok := ApiVersionRanges()[0]; !ok {
+		t.Error("modifying the returned map modified later calls")
+	}
+}
@@ -0,0 +1,57 @@
+package kmsg
+
+import "testing"
+
+func TestBuilders(t *testing.T) {
+	{
+		exp := NewPtrMetadataRequest()
+		exp.Version = 4
+		for _, topic := range []string{"foo", "bar"} {
+			rt := NewMetadataRequestTopic()
+			rt.Topic = StringPtr(topic)
+			exp.Topics = append(exp.Topics, rt)
+		}
+		exp.AllowAutoTopicCreation = false
+
+		got := BuildMetadataRequest().
+			Version(4).
+			Topics(
+				func(t *MetadataRequestTopic) { t.Topic = StringPtr("foo") },
+				func(t *MetadataRequestTopic) { t.Topic = StringPtr("bar") },
+			).
+			AllowAutoTopicCreation(false).
+			Build()
+		if !got.Equal(exp) {
+			t.Errorf("metadata: got diff %v", got.Diff(exp))
+		}
+	}
+
+	{
+		exp := NewPtrDeleteTopicsRequest()
+		exp.TopicNames = []string{"foo", "bar", "baz"}
+		exp.TimeoutMillis = 1000
+
+		got := BuildDeleteTopicsRequest().
+			TopicNames("foo", "bar").
+			TopicNames("baz").
+			TimeoutMillis(1000).
+			Build()
+		if !got.Equal(exp) {
+			t.Errorf("delete topics: got diff %v", got.Diff(exp))
+		}
+	}
+
+	// Nested structs are initialized with their defaults, which differ
+	// from the zero value.
+	acls := BuildCreateACLsRequest().
+		Creations(func(c *CreateACLsRequestCreation) { c.ResourceName = "foo" }).
+		Build()
+	if c := acls.Creations[0]; c.ResourceName != "foo" || c.ResourcePatternType != 3 {
+		t.Errorf("got creation name %q, pattern type %d != exp foo, 3 (literal)", c.ResourceName, c.ResourcePatternType)
+	}
+
+	// An unused builder returns the default request.
+	if got, exp := BuildFetchRequest().Build(), NewPtrFetchRequest(); !got.Equal(exp) {
+		t.Errorf("default fetch: got diff %v", got.Diff(exp))
+	}
+}
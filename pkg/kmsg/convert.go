@@ -0,0 +1,69 @@
+package kmsg
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ConvertVersionError is returned from the generated ConvertVersion functions
+// if a message cannot be converted to a different version without losing
+// information.
+type ConvertVersionError struct {
+	// Message is the name of the message type, e.g. "FetchResponse".
+	Message string
+	// From is the version the message is at.
+	From int16
+	// To is the version the message could not be converted to.
+	To int16
+	// Diffs describes each field that cannot be represented at the new
+	// version, in the same format as the generated Diff functions. This
+	// is empty if To is outside the supported version range.
+	Diffs []string
+}
+
+func (e *ConvertVersionError) Error() string {
+	if len(e.Diffs) == 0 {
+		return fmt.Sprintf("unable to convert %s from v%d to v%d: version is not supported", e.Message, e.From, e.To)
+	}
+	return fmt.Sprintf("unable to convert %s from v%d to v%d: %s", e.Message, e.From, e.To, strings.Join(e.Diffs, "; "))
+}
+
+// versionedMessage is the subset of Request and Response needed to convert
+// versions.
+type versionedMessage interface {
+	MaxVersion() int16
+	SetVersion(int16)
+	GetVersion() int16
+	AppendTo([]byte) []byte
+	ReadFrom([]byte) error
+}
+
+// convertVersion converts v to version by encoding v at the new version and
+// decoding into w, which must be a new message of the same type. If diff,
+// which compares v to w at v's version, returns anything, some field did not
+// survive the round trip and v is left at its current version.
+func convertVersion(name string, v, w versionedMessage, version int16, diff func() []string) error {
+	from := v.GetVersion()
+	if version < 0 || version > v.MaxVersion() {
+		return &ConvertVersionError{Message: name, From: from, To: version}
+	}
+	if version == from {
+		return nil
+	}
+
+	v.SetVersion(version)
+	b := v.AppendTo(nil)
+	v.SetVersion(from)
+
+	w.SetVersion(version)
+	if err := w.ReadFrom(b); err != nil {
+		return fmt.Errorf("unable to convert %s from v%d to v%d: %w", name, from, version, err)
+	}
+	w.SetVersion(from)
+	if ds := diff(); len(ds) > 0 {
+		return &ConvertVersionError{Message: name, From: from, To: version, Diffs: ds}
+	}
+
+	v.SetVersion(version)
+	return nil
+}
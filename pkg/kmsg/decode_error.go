@@ -0,0 +1,50 @@
+package kmsg
+
+import (
+	"fmt"
+
+	"github.com/burningass23/franz-go/pkg/kmsg/internal/kbin"
+)
+
+// DecodeError is returned from the generated ReadFrom functions if the input
+// ran out of data while decoding, and indicates the field that failed.
+type DecodeError struct {
+	// Type is the name of the type being decoded, e.g. "MetadataResponse".
+	Type string
+	// Version is the version being decoded, or -1 if the type is not
+	// versioned.
+	Version int16
+	// Field is the path to the field that failed to decode, e.g.
+	// "Topics[3].Partitions[0].ISR".
+	Field string
+	// Err is the underlying decode error.
+	Err error
+}
+
+func (e *DecodeError) Error() string {
+	if e.Version < 0 {
+		return fmt.Sprintf("%s: unable to decode %s: %v", e.Type, e.Field, e.Err)
+	}
+	return fmt.Sprintf("%s v%d: unable to decode %s: %v", e.Type, e.Version, e.Field, e.Err)
+}
+
+// Unwrap returns the underlying decode error.
+func (e *DecodeError) Unwrap() error { return e.Err }
+
+// decodeErr returns a *DecodeError for a short read; field is formatted with
+// the array indices leading to the field.
+func decodeErr(typ string, version int16, field string, idxs ...int32) error {
+	if len(idxs) > 0 {
+		args := make([]any, len(idxs))
+		for i, idx := range idxs {
+			args[i] = idx
+		}
+		field = fmt.Sprintf(field, args...)
+	}
+	return &DecodeError{
+		Type:    typ,
+		Version: version,
+		Field:   field,
+		Err:     kbin.ErrNotEnoughData,
+	}
+}
@@ -0,0 +1,137 @@
+package kmsg
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// The generated Diff functions build field paths from the bottom up: a nested
+// struct returns differences relative to itself, and the parent prefixes the
+// field name (and index) to only the new differences. This keeps comparing
+// equal messages allocation free.
+
+// diffValue appends a difference to ds if a != b.
+func diffValue[T comparable](ds []string, name string, a, b T) []string {
+	if a != b {
+		ds = append(ds, fmt.Sprintf("%s: %v != %v", name, a, b))
+	}
+	return ds
+}
+
+// diffSlice appends differences in length or elements of a and b. If the
+// slices are not nullable, a nil slice is equal to an empty slice.
+func diffSlice[T comparable](ds []string, name string, a, b []T, nullable bool) []string {
+	if ds, ok := diffLen(ds, name, len(a), len(b), a == nil, b == nil, nullable); !ok {
+		return ds
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			ds = append(ds, fmt.Sprintf("%s[%d]: %v != %v", name, i, a[i], b[i]))
+		}
+	}
+	return ds
+}
+
+// diffLen appends a difference if the lengths of two slices differ, or if
+// the slices are nullable and one is nil while the other is not. This returns
+// whether the slices can be compared element by element.
+func diffLen(ds []string, name string, la, lb int, anil, bnil, nullable bool) ([]string, bool) {
+	switch {
+	case nullable && anil != bnil:
+		return append(ds, fmt.Sprintf("%s: %s != %s", name, nilOrLen(anil, la), nilOrLen(bnil, lb))), false
+	case la != lb:
+		return append(ds, fmt.Sprintf("%s: len %d != len %d", name, la, lb)), false
+	}
+	return ds, true
+}
+
+func nilOrLen(isNil bool, l int) string {
+	if isNil {
+		return "null"
+	}
+	return fmt.Sprintf("len %d", l)
+}
+
+// diffBytes appends a difference if a and b are not equal. If the bytes are
+// not nullable, nil is equal to empty.
+func diffBytes(ds []string, name string, a, b []byte, nullable bool) []string {
+	if nullable && (a == nil) != (b == nil) || !bytes.Equal(a, b) {
+		ds = append(ds, fmt.Sprintf("%s: %s != %s", name, fmtNullableBytes(a, nullable), fmtNullableBytes(b, nullable)))
+	}
+	return ds
+}
+
+func fmtNullableBytes(b []byte, nullable bool) string {
+	if nullable && b == nil {
+		return "null"
+	}
+	return fmt.Sprintf("%q", b)
+}
+
+// diffNullableString appends a difference if a and b are not both null or
+// both non-null and equal.
+func diffNullableString(ds []string, name string, a, b *string) []string {
+	if (a == nil) != (b == nil) || a != nil && *a != *b {
+		ds = append(ds, fmt.Sprintf("%s: %s != %s", name, fmtNullableString(a), fmtNullableString(b)))
+	}
+	return ds
+}
+
+func fmtNullableString(s *string) string {
+	if s == nil {
+		return "null"
+	}
+	return fmt.Sprintf("%q", *s)
+}
+
+// diffNullableStruct appends a difference if one of a or b is nil while the
+// other is not. This returns whether both are non-nil and can be compared.
+func diffNullableStruct(ds []string, name string, anil, bnil bool) ([]string, bool) {
+	if anil != bnil {
+		return append(ds, fmt.Sprintf("%s: %s != %s", name, nilOrSet(anil), nilOrSet(bnil))), false
+	}
+	return ds, !anil
+}
+
+func nilOrSet(isNil bool) string {
+	if isNil {
+		return "null"
+	}
+	return "set"
+}
+
+// prefixDiffs prefixes each difference with name, for differences in a
+// nested struct.
+func prefixDiffs(ds []string, name string) {
+	for i := range ds {
+		ds[i] = name + "." + ds[i]
+	}
+}
+
+// prefixIndexDiffs prefixes each difference with name and an index, for
+// differences in a struct in an array.
+func prefixIndexDiffs(ds []string, name string, idx int) {
+	for i := range ds {
+		ds[i] = fmt.Sprintf("%s[%d].%s", name, idx, ds[i])
+	}
+}
+
+// diff appends differences between the unknown tags in t and o, in key
+// order.
+func (t *Tags) diff(o *Tags, ds []string) []string {
+	t.Each(func(key uint32, val []byte) {
+		oval, exists := o.keyvals[key]
+		switch {
+		case !exists:
+			ds = append(ds, fmt.Sprintf("UnknownTags[%d]: %q != missing", key, val))
+		case !bytes.Equal(val, oval):
+			ds = append(ds, fmt.Sprintf("UnknownTags[%d]: %q != %q", key, val, oval))
+		}
+	})
+	o.Each(func(key uint32, oval []byte) {
+		if _, exists := t.keyvals[key]; !exists {
+			ds = append(ds, fmt.Sprintf("UnknownTags[%d]: missing != %q", key, oval))
+		}
+	})
+	return ds
+}
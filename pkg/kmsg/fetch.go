@@ -0,0 +1,171 @@
+package kmsg
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/burningass23/franz-go/pkg/kmsg/internal/kbin"
+)
+
+// IterPartitions lazily decodes a serialized fetch response body, calling fn
+// for every topic partition as it is decoded. This is an alternative to
+// ReadFrom for large fetch responses where only some partitions are needed:
+// decoding stops as soon as fn returns false, and no partition is kept in
+// memory unless fn keeps it.
+//
+// The response's Version must be set before calling this function. The
+// top-level fields (ThrottleMillis, ErrorCode, SessionID) are decoded into v,
+// but Topics is left empty. The topic passed to fn has its Topic (or TopicID,
+// for v13+) set, but its Partitions and UnknownTags are not populated. Each
+// partition is newly allocated and can be kept by fn.
+//
+// The context is checked before decoding every partition; if it is canceled,
+// this returns the context's error. This returns nil if fn stops iteration
+// early, or an error if the response is malformed.
+func (v *FetchResponse) IterPartitions(
+	ctx context.Context,
+	src []byte,
+	fn func(*FetchResponseTopic, *FetchResponseTopicPartition) bool,
+) error {
+	v.Default()
+	v.Topics = nil
+	b := kbin.Reader{Src: src}
+	version := v.Version
+	isFlexible := version >= 12
+	if version >= 1 {
+		v.ThrottleMillis = b.Int32()
+	}
+	if version >= 7 {
+		v.ErrorCode = b.Int16()
+		v.SessionID = b.Int32()
+	}
+
+	arrayLen := func() int32 {
+		if isFlexible {
+			return b.CompactArrayLen()
+		}
+		return b.ArrayLen()
+	}
+
+	for nt := arrayLen(); nt > 0; nt-- {
+		if !b.Ok() {
+			return b.Complete()
+		}
+		t := NewFetchResponseTopic()
+		if version <= 12 {
+			if isFlexible {
+				t.Topic = b.CompactString()
+			} else {
+				t.Topic = b.String()
+			}
+		}
+		if version >= 13 {
+			t.TopicID = b.Uuid()
+		}
+		for np := arrayLen(); np > 0; np-- {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			if !b.Ok() {
+				return b.Complete()
+			}
+			p := NewFetchResponseTopicPartition()
+			if err := p.readVersionFrom(&b, version, false); err != nil {
+				return err
+			}
+			if !fn(&t, &p) {
+				return nil
+			}
+		}
+		if isFlexible {
+			internalReadTags(&b) // topic tags are read after partitions and are not passed to fn
+		}
+	}
+	if isFlexible {
+		v.UnknownTags = internalReadTags(&b)
+	}
+	return b.Complete()
+}
+
+// MergeFetchResponses merges multiple fetch responses into one, which is
+// useful when a single fetch is split across several brokers (for example, in
+// a proxy) and the results must be returned as one response.
+//
+// All responses must have the same version. The merged response has:
+//
+//   - ThrottleMillis: the max throttle across all responses
+//   - ErrorCode: the first non-zero top level error code
+//   - SessionID: 0, since a merged response cannot represent the fetch
+//     sessions of multiple brokers; proxies must manage sessions per broker
+//   - Topics: topics combined by name (v0-v12) or ID (v13+), in the order
+//     they are first seen, with partitions concatenated
+//   - UnknownTags: the union of all unknown tags, with earlier responses
+//     winning if a tag is duplicated (for both responses and topics)
+//
+// Partitions are not copied; the merged response shares them with the input
+// responses. This returns an error if versions differ or if the same
+// partition is present in multiple responses.
+func MergeFetchResponses(resps ...*FetchResponse) (*FetchResponse, error) {
+	merged := NewPtrFetchResponse()
+	if len(resps) == 0 {
+		return merged, nil
+	}
+	merged.Version = resps[0].Version
+
+	type topicKey struct {
+		topic string
+		id    [16]byte
+	}
+	tidx := make(map[topicKey]int)
+	seen := make(map[topicKey]map[int32]bool)
+
+	for _, resp := range resps {
+		if resp.Version != merged.Version {
+			return nil, fmt.Errorf("cannot merge fetch responses of differing versions %d and %d", merged.Version, resp.Version)
+		}
+		if resp.ThrottleMillis > merged.ThrottleMillis {
+			merged.ThrottleMillis = resp.ThrottleMillis
+		}
+		if merged.ErrorCode == 0 {
+			merged.ErrorCode = resp.ErrorCode
+		}
+		mergeTags(&merged.UnknownTags, &resp.UnknownTags)
+
+		for i := range resp.Topics {
+			t := &resp.Topics[i]
+			key := topicKey{topic: t.Topic}
+			if merged.Version >= 13 {
+				key = topicKey{id: t.TopicID}
+			}
+			idx, ok := tidx[key]
+			if !ok {
+				idx = len(merged.Topics)
+				tidx[key] = idx
+				seen[key] = make(map[int32]bool)
+				mt := NewFetchResponseTopic()
+				mt.Topic = t.Topic
+				mt.TopicID = t.TopicID
+				merged.Topics = append(merged.Topics, mt)
+			}
+			mt := &merged.Topics[idx]
+			mergeTags(&mt.UnknownTags, &t.UnknownTags)
+			for _, p := range t.Partitions {
+				if seen[key][p.Partition] {
+					return nil, fmt.Errorf("cannot merge fetch responses: topic %q (id %x) partition %d is in multiple responses", t.Topic, t.TopicID, p.Partition)
+				}
+				seen[key][p.Partition] = true
+				mt.Partitions = append(mt.Partitions, p)
+			}
+		}
+	}
+	return merged, nil
+}
+
+// mergeTags sets every tag in src into dst that is not already in dst.
+func mergeTags(dst, src *Tags) {
+	src.Each(func(key uint32, val []byte) {
+		if _, exists := dst.keyvals[key]; !exists {
+			dst.Set(key, val)
+		}
+	})
+}
@@ -0,0 +1,188 @@
+package kmsg
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestFetchIterPartitionsMatchesReadFrom(t *testing.T) {
+	aborted := NewFetchResponseTopicPartitionAbortedTransaction()
+	aborted.ProducerID = 3
+	aborted.FirstOffset = 10
+
+	p0 := NewFetchResponseTopicPartition() // nil AbortedTransactions
+	p0.Partition = 0
+	p0.HighWatermark = 5
+	p0.RecordBatches = []byte("batches")
+
+	p1 := NewFetchResponseTopicPartition() // empty AbortedTransactions
+	p1.Partition = 1
+	p1.AbortedTransactions = []FetchResponseTopicPartitionAbortedTransaction{}
+
+	p2 := NewFetchResponseTopicPartition()
+	p2.Partition = 2
+	p2.ErrorCode = 1
+	p2.AbortedTransactions = []FetchResponseTopicPartitionAbortedTransaction{aborted}
+	p2.DivergingEpoch.Epoch = 4
+	p2.DivergingEpoch.EndOffset = 40
+	p2.CurrentLeader.LeaderID = 2
+	p2.CurrentLeader.LeaderEpoch = 6
+
+	t0 := NewFetchResponseTopic()
+	t0.Topic = "foo"
+	t0.TopicID = [16]byte{1}
+	t0.Partitions = []FetchResponseTopicPartition{p0, p1}
+	t1 := NewFetchResponseTopic()
+	t1.Topic = "bar"
+	t1.TopicID = [16]byte{2}
+	t1.Partitions = []FetchResponseTopicPartition{p2}
+
+	for version := int16(0); version <= new(FetchResponse).MaxVersion(); version++ {
+		resp := NewPtrFetchResponse()
+		resp.Version = version
+		resp.ThrottleMillis = 7
+		resp.SessionID = 9
+		resp.Topics = []FetchResponseTopic{t0, t1}
+		src := resp.AppendTo(nil)
+
+		exp := NewPtrFetchResponse()
+		exp.Version = version
+		if err := exp.ReadFrom(src); err != nil {
+			t.Fatalf("v%d: unable to read: %v", version, err)
+		}
+
+		got := NewPtrFetchResponse()
+		got.Version = version
+		if err := got.IterPartitions(context.Background(), src, func(t *FetchResponseTopic, p *FetchResponseTopicPartition) bool {
+			if n := len(got.Topics); n == 0 || got.Topics[n-1].Topic != t.Topic || got.Topics[n-1].TopicID != t.TopicID {
+				got.Topics = append(got.Topics, *t)
+			}
+			last := &got.Topics[len(got.Topics)-1]
+			last.Partitions = append(last.Partitions, *p)
+			return true
+		}); err != nil {
+			t.Fatalf("v%d: unable to iterate: %v", version, err)
+		}
+
+		if !reflect.DeepEqual(got, exp) {
+			t.Errorf("v%d: iterated response differs from ReadFrom:\ngot %#v\nexp %#v", version, got, exp)
+		}
+	}
+}
+
+func TestFetchIterPartitionsStops(t *testing.T) {
+	resp := NewPtrFetchResponse()
+	resp.Version = 4
+	for _, topic := range []string{"foo", "bar"} {
+		rt := NewFetchResponseTopic()
+		rt.Topic = topic
+		for p := int32(0); p < 3; p++ {
+			rp := NewFetchResponseTopicPartition()
+			rp.Partition = p
+			rt.Partitions = append(rt.Partitions, rp)
+		}
+		resp.Topics = append(resp.Topics, rt)
+	}
+	src := resp.AppendTo(nil)
+
+	var seen int
+	got := NewPtrFetchResponse()
+	got.Version = 4
+	if err := got.IterPartitions(context.Background(), src, func(*FetchResponseTopic, *FetchResponseTopicPartition) bool {
+		seen++
+		return seen < 2
+	}); err != nil || seen != 2 {
+		t.Errorf("got %d partitions, err %v; exp 2 partitions and no error", seen, err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := got.IterPartitions(ctx, src, func(*FetchResponseTopic, *FetchResponseTopicPartition) bool { return true }); err != context.Canceled {
+		t.Errorf("got err %v != exp %v", err, context.Canceled)
+	}
+
+	if err := got.IterPartitions(context.Background(), src[:len(src)-3], func(*FetchResponseTopic, *FetchResponseTopicPartition) bool { return true }); err == nil {
+		t.Error("expected error iterating a truncated response")
+	}
+}
+
+func TestMergeFetchResponses(t *testing.T) {
+	mkp := func(partition int32) FetchResponseTopicPartition {
+		p := NewFetchResponseTopicPartition()
+		p.Partition = partition
+		return p
+	}
+	mkt := func(topic string, id byte, partitions ...int32) FetchResponseTopic {
+		t := NewFetchResponseTopic()
+		t.Topic = topic
+		t.TopicID[0] = id
+		for _, p := range partitions {
+			t.Partitions = append(t.Partitions, mkp(p))
+		}
+		return t
+	}
+
+	if merged, err := MergeFetchResponses(); err != nil || len(merged.Topics) != 0 {
+		t.Errorf("no responses: got %v, %v; exp empty response", merged, err)
+	}
+
+	r1 := NewPtrFetchResponse()
+	r1.Version = 12
+	r1.ThrottleMillis = 10
+	r1.SessionID = 5
+	r1.Topics = []FetchResponseTopic{mkt("foo", 0, 0, 1), mkt("bar", 0, 0)}
+	r1.UnknownTags.Set(1, []byte("r1"))
+
+	r2 := NewPtrFetchResponse()
+	r2.Version = 12
+	r2.ThrottleMillis = 20
+	r2.ErrorCode = 1
+	r2.SessionID = 6
+	r2.Topics = []FetchResponseTopic{mkt("baz", 0, 0), mkt("foo", 0, 2)}
+	r2.UnknownTags.Set(1, []byte("r2"))
+	r2.UnknownTags.Set(2, []byte("r2"))
+
+	merged, err := MergeFetchResponses(r1, r2)
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if merged.Version != 12 || merged.ThrottleMillis != 20 || merged.ErrorCode != 1 || merged.SessionID != 0 {
+		t.Errorf("got version %d, throttle %d, error %d, session %d; exp 12, 20, 1, 0",
+			merged.Version, merged.ThrottleMillis, merged.ErrorCode, merged.SessionID)
+	}
+	var got []string
+	for _, mt := range merged.Topics {
+		for _, p := range mt.Partitions {
+			got = append(got, fmt.Sprintf("%s-%d", mt.Topic, p.Partition))
+		}
+	}
+	if exp := []string{"foo-0", "foo-1", "foo-2", "bar-0", "baz-0"}; !reflect.DeepEqual(got, exp) {
+		t.Errorf("got partitions %v, exp %v", got, exp)
+	}
+	if v := merged.UnknownTags.keyvals[1]; string(v) != "r1" {
+		t.Errorf("got tag 1 %q, exp the first response's r1", v)
+	}
+	if v := merged.UnknownTags.keyvals[2]; string(v) != "r2" {
+		t.Errorf("got tag 2 %q, exp r2", v)
+	}
+
+	// v13+ merges by topic ID rather than name.
+	r1.Version, r2.Version = 13, 13
+	r1.Topics = []FetchResponseTopic{mkt("", 1, 0)}
+	r2.Topics = []FetchResponseTopic{mkt("", 1, 1), mkt("", 2, 0)}
+	if merged, err := MergeFetchResponses(r1, r2); err != nil || len(merged.Topics) != 2 || len(merged.Topics[0].Partitions) != 2 {
+		t.Errorf("v13: got %v, %v; exp two topics, the first with two partitions", merged, err)
+	}
+
+	// Duplicate partitions and differing versions are errors.
+	r2.Topics = []FetchResponseTopic{mkt("", 1, 0)}
+	if _, err := MergeFetchResponses(r1, r2); err == nil {
+		t.Error("expected error for a duplicate partition")
+	}
+	r2.Version = 12
+	if _, err := MergeFetchResponses(r1, r2); err == nil {
+		t.Error("expected error for differing versions")
+	}
+}
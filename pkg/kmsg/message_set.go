@@ -0,0 +1,184 @@
+package kmsg
+
+import (
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+)
+
+// messageSetMagic is the offset of the magic byte in a serialized v0 or v1
+// message, and in a magic v2 RecordBatch: after the int64 offset, the int32
+// length, and the int32 CRC (message sets) or int32 partition leader epoch
+// (record batches).
+const messageSetMagic = 16
+
+// RecordsMagic returns the magic byte of the first message or record batch in
+// src, which can be the records of a produce request or fetch response. Magic
+// 0 and 1 are message sets, which can be read with a MessageSetReader, and
+// magic 2 is a RecordBatch.
+func RecordsMagic(src []byte) (int8, error) {
+	if len(src) <= messageSetMagic {
+		return 0, fmt.Errorf("records of %d bytes are too short to contain a magic byte", len(src))
+	}
+	return int8(src[messageSetMagic]), nil
+}
+
+// MessageSetReader iterates over the messages in a v0 or v1 message set, the
+// format used before Kafka 0.11 introduced record batches.
+//
+// Messages are returned as MessageV1 regardless of their magic; v0 messages
+// have a Timestamp of -1. Compressed wrapper messages are expanded: the inner
+// messages are returned in place of the wrapper, with absolute offsets
+// (inner v1 offsets are relative to the wrapper), with the wrapper's
+// compression codec in their Attributes, and, if the wrapper uses
+// LogAppendTime, with the wrapper's timestamp.
+//
+// Fetch responses can end with a partial message. Reading stops without
+// error at a partial message, which is what Kafka's own clients do.
+type MessageSetReader struct {
+	src        []byte
+	inner      []MessageV1
+	decompress func(codec int8, src []byte) ([]byte, error)
+	err        error
+}
+
+// NewMessageSetReader returns a reader for the messages in src. If a
+// message is compressed, decompress is called with the compression codec and
+// the message's value; if decompress is nil, reading a compressed message
+// returns an error.
+func NewMessageSetReader(src []byte, decompress func(codec int8, src []byte) ([]byte, error)) *MessageSetReader {
+	return &MessageSetReader{
+		src:        src,
+		decompress: decompress,
+	}
+}
+
+// Next returns the next message in the message set, or false if there are no
+// more messages or a message could not be read.
+func (r *MessageSetReader) Next() (MessageV1, bool) {
+	for len(r.inner) == 0 {
+		if r.err != nil {
+			return MessageV1{}, false
+		}
+		m, ok := r.readMessage(&r.src)
+		if !ok {
+			return MessageV1{}, false
+		}
+		codec := int8(m.Attributes & 0x0003)
+		if codec == 0 {
+			return m, true
+		}
+		r.inner, r.err = r.readWrapped(&m, codec)
+	}
+	m := r.inner[0]
+	r.inner = r.inner[1:]
+	return m, true
+}
+
+// Err returns any error encountered while reading messages.
+func (r *MessageSetReader) Err() error {
+	return r.err
+}
+
+// readMessage reads the next full message from src, advancing src past it.
+func (r *MessageSetReader) readMessage(src *[]byte) (MessageV1, bool) {
+	b := *src
+	if len(b) <= messageSetMagic {
+		return MessageV1{}, false // partial message
+	}
+	size := int32(binary.BigEndian.Uint32(b[8:]))
+	length := 12 + int(size) // offset and size fields
+	if size < 0 || len(b) < length {
+		return MessageV1{}, false // partial message
+	}
+	raw := b[:length]
+
+	var m MessageV1
+	switch magic := int8(b[messageSetMagic]); magic {
+	case 0:
+		var m0 MessageV0
+		if r.err = m0.ReadFrom(raw); r.err != nil {
+			return MessageV1{}, false
+		}
+		m = MessageV1{
+			Offset:      m0.Offset,
+			MessageSize: m0.MessageSize,
+			CRC:         m0.CRC,
+			Magic:       m0.Magic,
+			Attributes:  m0.Attributes,
+			Timestamp:   -1,
+			Key:         m0.Key,
+			Value:       m0.Value,
+		}
+	case 1:
+		if r.err = m.ReadFrom(raw); r.err != nil {
+			return MessageV1{}, false
+		}
+	default:
+		r.err = fmt.Errorf("message at offset %d has magic %d, only magic 0 and 1 are message sets", int64(binary.BigEndian.Uint64(b)), magic)
+		return MessageV1{}, false
+	}
+	if computed := int32(crc32.ChecksumIEEE(raw[messageSetMagic:])); computed != m.CRC {
+		r.err = fmt.Errorf("message at offset %d CRC mismatch: stored %#08x, computed %#08x", m.Offset, uint32(m.CRC), uint32(computed))
+		return MessageV1{}, false
+	}
+	*src = b[length:]
+	return m, true
+}
+
+// readWrapped decompresses and reads the inner messages of a compressed
+// wrapper message.
+func (r *MessageSetReader) readWrapped(wrapper *MessageV1, codec int8) ([]MessageV1, error) {
+	if r.decompress == nil {
+		return nil, fmt.Errorf("message at offset %d is compressed with codec %d, but no decompress function was provided", wrapper.Offset, codec)
+	}
+	raw, err := r.decompress(codec, wrapper.Value)
+	if err != nil {
+		return nil, fmt.Errorf("unable to decompress message at offset %d: %w", wrapper.Offset, err)
+	}
+
+	var inner []MessageV1
+	for {
+		m, ok := r.readMessage(&raw)
+		if !ok {
+			break
+		}
+		if m.Attributes&0x0003 != 0 {
+			return nil, fmt.Errorf("message at offset %d has a compressed inner message", wrapper.Offset)
+		}
+		inner = append(inner, m)
+	}
+	if r.err != nil {
+		return nil, r.err
+	}
+
+	// The wrapper's offset is the offset of the last inner message. For
+	// v0, inner offsets are absolute; for v1, inner offsets are relative.
+	// Computing from the wrapper's offset handles both.
+	firstOffset := wrapper.Offset - int64(len(inner)) + 1
+	logAppendTime := wrapper.Magic == 1 && wrapper.Attributes&0x0008 != 0
+	for i := range inner {
+		m := &inner[i]
+		m.Offset = firstOffset + int64(i)
+		m.Attributes |= int8(codec)
+		if logAppendTime {
+			m.Timestamp = wrapper.Timestamp
+		}
+	}
+	return inner, nil
+}
+
+// ReadMessageSet returns all messages in a v0 or v1 message set. This is a
+// shortcut for iterating with a MessageSetReader.
+func ReadMessageSet(src []byte, decompress func(codec int8, src []byte) ([]byte, error)) ([]MessageV1, error) {
+	r := NewMessageSetReader(src, decompress)
+	var msgs []MessageV1
+	for {
+		m, ok := r.Next()
+		if !ok {
+			break
+		}
+		msgs = append(msgs, m)
+	}
+	return msgs, r.Err()
+}
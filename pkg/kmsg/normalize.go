@@ -0,0 +1,48 @@
+package kmsg
+
+import "bytes"
+
+// Every request has a generated Normalize method that sorts the request's
+// order-insensitive collections into a canonical order, so that the same
+// logical request always serializes to the same bytes. This is useful for
+// fingerprinting, signing, or caching serialized requests.
+//
+// Kafka does not assign meaning to the order of topics or partitions within a
+// request, so Normalize sorts:
+//
+//   - arrays of structs keyed by topic (a Topic name or TopicID field), by
+//     topic name and then by topic ID;
+//   - arrays of structs keyed by partition (a Partition field), by
+//     partition;
+//   - arrays of structs keyed by both, by topic and then partition;
+//   - Partitions fields that are arrays of partition numbers.
+//
+// All other arrays are left as is: many are order sensitive, such as replica
+// lists (the first replica is the preferred leader) or the protocols in a join
+// group request (ordered by preference). Note that although the order of
+// fetch request partitions does not affect correctness, brokers fill fetch
+// responses in request order, meaning normalizing a fetch request can change
+// which partitions are returned when a response is size limited.
+//
+// Normalize does not deduplicate entries; sorting is stable, so entries with
+// equal keys keep their relative order.
+
+// compareTopic compares two topics by name and then by ID.
+func compareTopic(lt string, lid [16]byte, rt string, rid [16]byte) int {
+	switch {
+	case lt < rt:
+		return -1
+	case lt > rt:
+		return 1
+	}
+	return bytes.Compare(lid[:], rid[:])
+}
+
+// normString returns the dereferenced string, or the empty string if s is
+// nil.
+func normString(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
@@ -0,0 +1,57 @@
+package kmsg
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestReadFromRawTail(t *testing.T) {
+	for _, version := range []int16{0, 9} { // non-flexible and flexible
+		resp := NewMetadataResponse()
+		resp.Version = version
+		b := NewMetadataResponseBroker()
+		b.NodeID = 1
+		b.Host = "host"
+		b.Port = 9092
+		resp.Brokers = append(resp.Brokers, b)
+		enc := resp.AppendTo(nil)
+
+		// Without trailing bytes, RawTail is nil, even if it was set
+		// from a prior decode.
+		dec := NewMetadataResponse()
+		dec.Version = version
+		dec.RawTail = []byte("stale")
+		if err := dec.ReadFrom(enc); err != nil {
+			t.Fatalf("v%d: unexpected err: %v", version, err)
+		}
+		if dec.RawTail != nil {
+			t.Errorf("v%d: got RawTail %q, exp nil", version, dec.RawTail)
+		}
+
+		// Trailing bytes, e.g. from fields in a newer version, are
+		// kept rather than failing the decode.
+		tail := []byte{0, 1, 2, 3}
+		if err := dec.ReadFrom(append(enc[:len(enc):len(enc)], tail...)); err != nil {
+			t.Fatalf("v%d: unexpected err with trailing bytes: %v", version, err)
+		}
+		if !bytes.Equal(dec.RawTail, tail) {
+			t.Errorf("v%d: got RawTail %v, exp %v", version, dec.RawTail, tail)
+		}
+		if len(dec.Brokers) != 1 || dec.Brokers[0].Host != "host" || dec.Brokers[0].Port != 9092 {
+			t.Errorf("v%d: known fields not decoded: %+v", version, dec.Brokers)
+		}
+
+		// RawTail is never encoded.
+		if reenc := dec.AppendTo(nil); !bytes.Equal(reenc, enc) {
+			t.Errorf("v%d: RawTail was encoded", version)
+		}
+
+		// Truncated input is still an error, with no tail.
+		if err := dec.ReadFrom(enc[:len(enc)-1]); err == nil {
+			t.Errorf("v%d: expected error on truncated input", version)
+		}
+		if dec.RawTail != nil {
+			t.Errorf("v%d: got RawTail %v on truncated input, exp nil", version, dec.RawTail)
+		}
+	}
+}
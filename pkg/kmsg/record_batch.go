@@ -0,0 +1,440 @@
+package kmsg
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"time"
+
+	"github.com/burningass23/franz-go/pkg/kmsg/internal/kbin"
+)
+
+var crc32c = crc32.MakeTable(crc32.Castagnoli) // record batch crc's use Castagnoli
+
+// recordBatchCRCStart is the offset in a serialized RecordBatch at which the
+// CRC begins being calculated: everything from Attributes to the end.
+const recordBatchCRCStart = 8 + 4 + 4 + 1 + 4 // FirstOffset, Length, PartitionLeaderEpoch, Magic, CRC
+
+// recordBatchLengthStart is the offset in a serialized RecordBatch at which
+// Length starts being counted: everything after the Length field.
+const recordBatchLengthStart = 8 + 4 // FirstOffset, Length
+
+// RecordBatchBuilder builds a magic v2 RecordBatch, handling record varint
+// encoding, offset and timestamp deltas, the batch CRC, and optional
+// compression.
+//
+// The exported fields are copied into the built batch as is and can be set
+// before calling Build. The zero value of the builder is not usable; use
+// NewRecordBatchBuilder.
+type RecordBatchBuilder struct {
+	// FirstOffset is the offset of the first record. Producers use 0;
+	// brokers assign offsets when they write the batch.
+	FirstOffset int64
+
+	// PartitionLeaderEpoch is the leader epoch of the partition. Producers
+	// use -1.
+	PartitionLeaderEpoch int32
+
+	// ProducerID, ProducerEpoch, and FirstSequence are used for idempotent
+	// and transactional producing. These default to -1, meaning the batch
+	// is not idempotent.
+	ProducerID    int64
+	ProducerEpoch int16
+	FirstSequence int32
+
+	// Attributes are extra batch attributes, such as the transactional
+	// (0x0010) or control (0x0020) bits. The compression codec bits are
+	// set with SetCompression and are ignored here.
+	Attributes int16
+
+	codec    int8
+	compress func([]byte) ([]byte, error)
+
+	recs    []Record
+	firstTs int64
+	maxTs   int64
+}
+
+// NewRecordBatchBuilder returns a new, empty RecordBatchBuilder.
+func NewRecordBatchBuilder() *RecordBatchBuilder {
+	return &RecordBatchBuilder{
+		PartitionLeaderEpoch: -1,
+		ProducerID:           -1,
+		ProducerEpoch:        -1,
+		FirstSequence:        -1,
+	}
+}
+
+// SetCompression sets the compression codec to use for the built batch, as
+// well as the function to compress the serialized records with. The codec is
+// what is stored in the batch attributes: 1 for gzip, 2 for snappy, 3 for
+// lz4, and 4 for zstd. This package does not import any compression
+// libraries, so the compression function must be provided.
+//
+// Setting the codec to 0 (or the compress function to nil) disables
+// compression.
+func (b *RecordBatchBuilder) SetCompression(codec int8, compress func([]byte) ([]byte, error)) {
+	if codec == 0 || compress == nil {
+		b.codec, b.compress = 0, nil
+		return
+	}
+	b.codec, b.compress = codec, compress
+}
+
+// Add adds a record with the given key, value, headers, and timestamp to the
+// batch being built.
+func (b *RecordBatchBuilder) Add(key, value []byte, headers []Header, timestamp time.Time) {
+	b.AddMillis(key, value, headers, timestamp.UnixNano()/1e6)
+}
+
+// AddMillis is the same as Add, but uses a timestamp in milliseconds since
+// the epoch.
+func (b *RecordBatchBuilder) AddMillis(key, value []byte, headers []Header, timestampMillis int64) {
+	if len(b.recs) == 0 {
+		b.firstTs = timestampMillis
+		b.maxTs = timestampMillis
+	} else if timestampMillis > b.maxTs {
+		b.maxTs = timestampMillis
+	}
+	b.recs = append(b.recs, Record{
+		Key:     key,
+		Value:   value,
+		Headers: headers,
+
+		// TimestampDelta64 and OffsetDelta are set in Build, since
+		// the first timestamp can change if records are added with
+		// earlier timestamps than the first.
+		TimestampDelta64: timestampMillis,
+	})
+}
+
+// NumRecords returns the number of records added to the builder.
+func (b *RecordBatchBuilder) NumRecords() int {
+	return len(b.recs)
+}
+
+// Reset clears all added records, allowing the builder to be reused. The
+// exported fields and compression are kept.
+func (b *RecordBatchBuilder) Reset() {
+	b.recs = b.recs[:0]
+	b.firstTs, b.maxTs = 0, 0
+}
+
+// Build returns the RecordBatch for all added records, with Length and CRC
+// set. This returns an error if no records have been added or if
+// compression fails.
+func (b *RecordBatchBuilder) Build() (RecordBatch, error) {
+	batch := NewRecordBatch()
+	if len(b.recs) == 0 {
+		return batch, errors.New("record batch has no records")
+	}
+
+	firstTs := b.firstTs
+	for i := range b.recs {
+		if ts := b.recs[i].TimestampDelta64; ts < firstTs {
+			firstTs = ts
+		}
+	}
+
+	var recs []byte
+	for i := range b.recs {
+		r := b.recs[i] // copy: we keep the absolute timestamp in the builder
+		r.OffsetDelta = int32(i)
+		r.TimestampDelta64 -= firstTs
+		r.TimestampDelta = int32(r.TimestampDelta64)
+		r.Length = 0
+		r.Length = int32(len(r.AppendTo(nil)) - 1) // minus the 1 byte 0 length
+		recs = r.AppendTo(recs)
+	}
+
+	attrs := b.Attributes &^ 0x0007
+	if b.compress != nil {
+		compressed, err := b.compress(recs)
+		if err != nil {
+			return batch, fmt.Errorf("unable to compress records: %w", err)
+		}
+		recs = compressed
+		attrs |= int16(b.codec) & 0x0007
+	}
+
+	batch.FirstOffset = b.FirstOffset
+	batch.PartitionLeaderEpoch = b.PartitionLeaderEpoch
+	batch.Magic = 2
+	batch.Attributes = attrs
+	batch.LastOffsetDelta = int32(len(b.recs) - 1)
+	batch.FirstTimestamp = firstTs
+	batch.MaxTimestamp = b.maxTs
+	batch.ProducerID = b.ProducerID
+	batch.ProducerEpoch = b.ProducerEpoch
+	batch.FirstSequence = b.FirstSequence
+	batch.NumRecords = int32(len(b.recs))
+	batch.Records = recs
+
+	serialized := batch.AppendTo(nil)
+	batch.Length = int32(len(serialized) - recordBatchLengthStart)
+	batch.CRC = batch.ComputeCRC()
+	return batch, nil
+}
+
+// AppendTo builds the batch and appends the serialized batch to dst.
+func (b *RecordBatchBuilder) AppendTo(dst []byte) ([]byte, error) {
+	batch, err := b.Build()
+	if err != nil {
+		return dst, err
+	}
+	return batch.AppendTo(dst), nil
+}
+
+// ComputeCRC returns the CRC for this batch, calculated over everything from
+// the Attributes field to the end of the batch.
+func (v *RecordBatch) ComputeCRC() int32 {
+	serialized := v.AppendTo(nil)
+	return int32(crc32.Checksum(serialized[recordBatchCRCStart:], crc32c))
+}
+
+// ValidCRC returns whether the batch's CRC field matches the computed CRC.
+func (v *RecordBatch) ValidCRC() bool {
+	return v.CRC == v.ComputeCRC()
+}
+
+// recordBatchCRCSpan returns the serialized batch's stored CRC and the span
+// of bytes the CRC covers, validating that the batch is a complete magic v2
+// batch.
+func recordBatchCRCSpan(batch []byte) (stored uint32, span []byte, err error) {
+	if len(batch) < recordBatchCRCStart {
+		return 0, nil, fmt.Errorf("record batch of %d bytes is too short to contain a header", len(batch))
+	}
+	if magic := int8(batch[16]); magic != 2 {
+		return 0, nil, fmt.Errorf("record batch has magic %d, only magic 2 has a CRC-32C", magic)
+	}
+	length := int32(binary.BigEndian.Uint32(batch[8:]))
+	end := recordBatchLengthStart + int(length)
+	if length < 0 || end < recordBatchCRCStart || end > len(batch) {
+		return 0, nil, fmt.Errorf("record batch length %d is invalid for %d bytes", length, len(batch))
+	}
+	return binary.BigEndian.Uint32(batch[17:]), batch[recordBatchCRCStart:end], nil
+}
+
+// ValidateRecordBatchCRC validates the CRC-32C of the serialized magic v2
+// record batch at the start of batch, returning an error if the batch is
+// malformed or if the stored CRC does not match the computed CRC. Bytes after
+// the batch's Length are ignored.
+//
+// This works on raw bytes, and does not require decoding the batch first.
+func ValidateRecordBatchCRC(batch []byte) error {
+	stored, span, err := recordBatchCRCSpan(batch)
+	if err != nil {
+		return err
+	}
+	if computed := crc32.Checksum(span, crc32c); computed != stored {
+		return fmt.Errorf("record batch CRC mismatch: stored %#08x, computed %#08x", stored, computed)
+	}
+	return nil
+}
+
+// PatchRecordBatchCRC recomputes the CRC-32C of the serialized magic v2 record
+// batch at the start of batch and writes it into the batch's CRC field in
+// place. Tools that rewrite serialized batches (for example, to change
+// timestamps) can use this to keep their output valid. Note that the CRC does
+// not cover the FirstOffset, Length, or PartitionLeaderEpoch fields; if the
+// size of the batch changes, Length must be updated separately.
+func PatchRecordBatchCRC(batch []byte) error {
+	_, span, err := recordBatchCRCSpan(batch)
+	if err != nil {
+		return err
+	}
+	binary.BigEndian.PutUint32(batch[17:], crc32.Checksum(span, crc32c))
+	return nil
+}
+
+// RecordBatchReader iterates over the records in a RecordBatch.
+type RecordBatchReader struct {
+	records     []byte
+	firstOffset int64
+
+	src  []byte
+	left int32
+	err  error
+}
+
+// NewRecordBatchReader returns a reader for the records in the given batch.
+// If the batch is compressed, decompress is called with the compression
+// codec and the compressed records; if decompress is nil, reading a
+// compressed batch returns an error.
+func NewRecordBatchReader(batch *RecordBatch, decompress func(codec int8, src []byte) ([]byte, error)) (*RecordBatchReader, error) {
+	src := batch.Records
+	if codec := int8(batch.Attributes & 0x0007); codec != 0 {
+		if decompress == nil {
+			return nil, fmt.Errorf("record batch is compressed with codec %d, but no decompress function was provided", codec)
+		}
+		var err error
+		if src, err = decompress(codec, src); err != nil {
+			return nil, fmt.Errorf("unable to decompress records: %w", err)
+		}
+	}
+	return &RecordBatchReader{
+		records:     src,
+		firstOffset: batch.FirstOffset,
+		src:         src,
+		left:        batch.NumRecords,
+	}, nil
+}
+
+// Next returns the next record in the batch, or false if there are no more
+// records or a record could not be decoded. Record timestamp and offset
+// deltas are relative to the batch's FirstTimestamp and FirstOffset.
+func (r *RecordBatchReader) Next() (Record, bool) {
+	if r.left <= 0 || r.err != nil {
+		return Record{}, false
+	}
+	length, n := kbin.Varint(r.src)
+	if n == 0 || length < 0 || int(length) > len(r.src)-n {
+		r.err = kbin.ErrNotEnoughData
+		return Record{}, false
+	}
+	var rec Record
+	if r.err = rec.ReadFrom(r.src[:n+int(length)]); r.err != nil {
+		return Record{}, false
+	}
+	r.src = r.src[n+int(length):]
+	r.left--
+	return rec, true
+}
+
+// Err returns any error encountered while reading records.
+func (r *RecordBatchReader) Err() error {
+	return r.err
+}
+
+// Records returns the (decompressed) records the reader is iterating over.
+// The ValueOffset of a RecordKey is an index into this slice.
+func (r *RecordBatchReader) Records() []byte {
+	return r.records
+}
+
+// RecordKey is a record's key and the location of its value, as returned from
+// RecordBatchReader.NextKey.
+type RecordKey struct {
+	// Offset is the absolute offset of the record: the batch's
+	// FirstOffset plus the record's OffsetDelta.
+	Offset int64
+
+	// TimestampDelta is the millisecond delta of the record's timestamp
+	// from the batch's FirstTimestamp.
+	TimestampDelta int64
+
+	// Attributes are the record's attributes.
+	Attributes int8
+
+	// Key is the record's key, which aliases the reader's Records. This is
+	// nil if the key is null.
+	Key []byte
+
+	// ValueOffset and ValueLength are the location of the record's value
+	// in the reader's Records. ValueLength is -1 if the value is null.
+	ValueOffset int
+	ValueLength int
+
+	records []byte
+}
+
+// Value returns the record's value, which aliases the reader's Records, or
+// nil if the value is null.
+func (k *RecordKey) Value() []byte {
+	if k.ValueLength < 0 {
+		return nil
+	}
+	return k.records[k.ValueOffset : k.ValueOffset+k.ValueLength : k.ValueOffset+k.ValueLength]
+}
+
+// NextKey returns the key of the next record in the batch, or false if there
+// are no more records or a record could not be decoded.
+//
+// Unlike Next, this does not decode the record's value or headers and does
+// not allocate: the key aliases the reader's Records, and the value is
+// returned as a location that can be read with Value if necessary. This can
+// be used to cheaply filter records by key in large batches. Next and NextKey
+// can be mixed; each call advances to the next record.
+func (r *RecordBatchReader) NextKey() (RecordKey, bool) {
+	if r.left <= 0 || r.err != nil {
+		return RecordKey{}, false
+	}
+	length, n := kbin.Varint(r.src)
+	if n == 0 || length < 0 || int(length) > len(r.src)-n {
+		r.err = kbin.ErrNotEnoughData
+		return RecordKey{}, false
+	}
+	start := len(r.records) - len(r.src) + n // start of the record after its length
+	b := kbin.Reader{Src: r.src[n : n+int(length)]}
+
+	k := RecordKey{records: r.records}
+	k.Attributes = b.Int8()
+	k.TimestampDelta = b.Varlong()
+	k.Offset = r.firstOffset + int64(b.Varint())
+	k.Key = b.VarintBytes()
+	k.ValueLength = int(b.Varint())
+	k.ValueOffset = start + int(length) - len(b.Src)
+	if k.ValueLength > len(b.Src) {
+		b.Span(k.ValueLength) // marks the reader bad
+	}
+	if r.err = b.Complete(); r.err != nil {
+		return RecordKey{}, false
+	}
+	r.src = r.src[n+int(length):]
+	r.left--
+	return k, true
+}
+
+// ReadRecords returns all records in the batch. This is a shortcut for
+// iterating with a RecordBatchReader.
+func (v *RecordBatch) ReadRecords(decompress func(codec int8, src []byte) ([]byte, error)) ([]Record, error) {
+	r, err := NewRecordBatchReader(v, decompress)
+	if err != nil {
+		return nil, err
+	}
+	recs := make([]Record, 0, v.NumRecords)
+	for {
+		rec, ok := r.Next()
+		if !ok {
+			break
+		}
+		recs = append(recs, rec)
+	}
+	if r.Err() != nil {
+		return recs, r.Err()
+	}
+	if len(recs) != int(v.NumRecords) {
+		return recs, fmt.Errorf("record batch claims %d records, but only %d could be read", v.NumRecords, len(recs))
+	}
+	return recs, nil
+}
+
+// ScanKeys calls fn with the key of every record in the batch, stopping early
+// if fn returns false. Values are not decoded; fn can call Value on the key to
+// read a record's value if necessary. This is a shortcut for iterating with
+// RecordBatchReader.NextKey.
+func (v *RecordBatch) ScanKeys(decompress func(codec int8, src []byte) ([]byte, error), fn func(*RecordKey) bool) error {
+	r, err := NewRecordBatchReader(v, decompress)
+	if err != nil {
+		return err
+	}
+	var n int32
+	for {
+		k, ok := r.NextKey()
+		if !ok {
+			break
+		}
+		n++
+		if !fn(&k) {
+			return nil
+		}
+	}
+	if r.Err() != nil {
+		return r.Err()
+	}
+	if n != v.NumRecords {
+		return fmt.Errorf("record batch claims %d records, but only %d could be read", v.NumRecords, n)
+	}
+	return nil
+}
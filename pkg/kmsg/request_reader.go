@@ -0,0 +1,88 @@
+package kmsg
+
+import (
+	"fmt"
+
+	"github.com/burningass23/franz-go/pkg/kmsg/internal/kbin"
+)
+
+// RequestHeader is a parsed request header, as written by
+// RequestFormatter.AppendRequest.
+type RequestHeader struct {
+	// Key is the request's protocol key.
+	Key int16
+	// Version is the request's version.
+	Version int16
+	// CorrelationID is the ID the client uses to match the response to
+	// this request.
+	CorrelationID int32
+	// ClientID is the client ID in the request, if any. ControlledShutdown
+	// v0 has no client ID in its header.
+	ClientID *string
+	// UnknownTags are tags in the header of flexible requests.
+	UnknownTags Tags
+}
+
+// ParseRequestHeader parses a request header from src, returning the header
+// and the request body that follows it. The body can be passed to
+// DecodeRequest with the header's key and version.
+//
+// src must not include the four byte length prefix that begins every request
+// on the wire; that is, src should be exactly the bytes that the length prefix
+// covers.
+//
+// Whether a request header contains tags depends on whether the request is
+// flexible at its version, so this returns an error if the key is unknown to
+// this package (and the request is not ControlledShutdown v0).
+func ParseRequestHeader(src []byte) (RequestHeader, []byte, error) {
+	var h RequestHeader
+	b := kbin.Reader{Src: src}
+	h.Key = b.Int16()
+	h.Version = b.Int16()
+	h.CorrelationID = b.Int32()
+	if err := b.Complete(); err != nil {
+		return h, nil, fmt.Errorf("unable to read request header: %w", err)
+	}
+	if h.Key == 7 && h.Version == 0 {
+		return h, b.Src, nil
+	}
+
+	// The client ID is never compact, even for flexible requests; see
+	// RequestFormatter.AppendRequest.
+	h.ClientID = b.NullableString()
+
+	r := RequestForKey(h.Key)
+	if r == nil {
+		return h, nil, fmt.Errorf("unknown request key %d", h.Key)
+	}
+	r.SetVersion(h.Version)
+	if r.IsFlexible() {
+		h.UnknownTags = internalReadTags(&b)
+	}
+	if err := b.Complete(); err != nil {
+		return h, nil, fmt.Errorf("unable to read request header: %w", err)
+	}
+	return h, b.Src, nil
+}
+
+// DecodeRequest decodes src as the body of the request for the given key and
+// version, returning the decoded request with its version set. src should be
+// the body returned from ParseRequestHeader.
+//
+// This returns an error if the key is unknown, if the version is greater than
+// the max version this package supports for the key, or if src cannot be
+// decoded.
+func DecodeRequest(key, version int16, src []byte) (Request, error) {
+	r := RequestForKey(key)
+	if r == nil {
+		return nil, fmt.Errorf("unknown request key %d", key)
+	}
+	if version < 0 || version > r.MaxVersion() {
+		return nil, fmt.Errorf("request key %d version %d is outside the supported range [0, %d]", key, version, r.MaxVersion())
+	}
+	r.SetVersion(version)
+	if err := r.ReadFrom(src); err != nil {
+		return nil, fmt.Errorf("unable to decode request key %d version %d: %w", key, version, err)
+	}
+	return r, nil
+}
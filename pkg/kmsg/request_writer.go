@@ -0,0 +1,115 @@
+package kmsg
+
+import (
+	"io"
+	"net"
+
+	"github.com/burningass23/franz-go/pkg/kmsg/internal/kbin"
+)
+
+// WriteRequest writes a full message request to w, exactly as AppendRequest
+// would format it, and returns the number of bytes written.
+//
+// Unlike AppendRequest, this does not encode the request into one contiguous
+// slice. Produce requests are split into segments: the small encoded fields
+// between record batches are encoded into a scratch buffer, while each
+// partition's Records are written directly from the request without being
+// copied. The length prefix is computed from the segment sizes before anything
+// is written, so the request is written in a single pass. Other requests are
+// small in practice and are encoded whole.
+//
+// The segments are written with net.Buffers, so if w is a net.Conn that
+// supports vectored writes (e.g., a *net.TCPConn), the request is written with
+// writev. As with any io.Writer, a failed write can leave a partial request
+// on w; the connection should be closed on error.
+func (f *RequestFormatter) WriteRequest(w io.Writer, r Request, correlationID int32) (int64, error) {
+	buf := f.appendHeader(nil, r, correlationID)
+	if r.Key() == 7 && r.GetVersion() == 0 {
+		n, err := w.Write(buf)
+		return int64(n), err
+	}
+
+	var segs net.Buffers
+	if p, ok := r.(*ProduceRequest); ok {
+		segs = p.appendSegments(buf)
+	} else {
+		segs = net.Buffers{r.AppendTo(buf)}
+	}
+
+	var size int
+	for _, seg := range segs {
+		size += len(seg)
+	}
+	kbin.AppendInt32(segs[0][:0], int32(size-4))
+
+	return segs.WriteTo(w)
+}
+
+// appendSegments encodes v with dst as the prefix of the first segment,
+// returning segments that reference v's record batches rather than copying
+// them. Concatenating the segments is equivalent to v.AppendTo(dst).
+//
+// This must be kept in sync with the generated ProduceRequest.AppendTo.
+func (v *ProduceRequest) appendSegments(dst []byte) net.Buffers {
+	var segs net.Buffers
+	version := v.Version
+	isFlexible := version >= 9
+	if version >= 3 {
+		if isFlexible {
+			dst = kbin.AppendCompactNullableString(dst, v.TransactionID)
+		} else {
+			dst = kbin.AppendNullableString(dst, v.TransactionID)
+		}
+	}
+	dst = kbin.AppendInt16(dst, v.Acks)
+	dst = kbin.AppendInt32(dst, v.TimeoutMillis)
+	if isFlexible {
+		dst = kbin.AppendCompactArrayLen(dst, len(v.Topics))
+	} else {
+		dst = kbin.AppendArrayLen(dst, len(v.Topics))
+	}
+	for i := range v.Topics {
+		t := &v.Topics[i]
+		if isFlexible {
+			dst = kbin.AppendCompactString(dst, t.Topic)
+			dst = kbin.AppendCompactArrayLen(dst, len(t.Partitions))
+		} else {
+			dst = kbin.AppendString(dst, t.Topic)
+			dst = kbin.AppendArrayLen(dst, len(t.Partitions))
+		}
+		for j := range t.Partitions {
+			p := &t.Partitions[j]
+			dst = kbin.AppendInt32(dst, p.Partition)
+			switch {
+			case p.Records == nil && isFlexible:
+				dst = kbin.AppendUvarint(dst, 0)
+			case p.Records == nil:
+				dst = kbin.AppendInt32(dst, -1)
+			case isFlexible:
+				dst = kbin.AppendUvarint(dst, uint32(len(p.Records))+1)
+			default:
+				dst = kbin.AppendInt32(dst, int32(len(p.Records)))
+			}
+			if len(p.Records) > 0 {
+				// Cut the current segment and reference the
+				// records directly. Further appends to dst
+				// write past the end of the cut segment.
+				segs = append(segs, dst, p.Records)
+				dst = dst[len(dst):]
+			}
+			if isFlexible {
+				dst = kbin.AppendUvarint(dst, uint32(p.UnknownTags.Len()))
+				dst = p.UnknownTags.AppendEach(dst)
+			}
+		}
+		if isFlexible {
+			dst = kbin.AppendUvarint(dst, uint32(t.UnknownTags.Len()))
+			dst = t.UnknownTags.AppendEach(dst)
+		}
+	}
+	if isFlexible {
+		dst = kbin.AppendUvarint(dst, uint32(v.UnknownTags.Len()))
+		dst = v.UnknownTags.AppendEach(dst)
+	}
+	return append(segs, dst)
+}
@@ -0,0 +1,39 @@
+package kmsg
+
+import "fmt"
+
+// EnumError is returned from the generated Validate functions if an enum
+// field holds a value that is not known for the enum's type, or if a bitfield
+// of enum values (such as AuthorizedOperations) has a bit set that is not a
+// known value.
+type EnumError struct {
+	// Field is the path to the invalid field, e.g. "Creations[1].Operation".
+	Field string
+	// Type is the name of the enum's type, e.g. "ACLOperation", or for
+	// bitfields, the enum's type followed by " bitfield".
+	Type string
+	// Value is the unknown value.
+	Value int64
+}
+
+func (e *EnumError) Error() string {
+	return fmt.Sprintf("%s: unknown %s value %d", e.Field, e.Type, e.Value)
+}
+
+// prefixEnumError prefixes the field of an *EnumError with name, for errors
+// in a nested struct.
+func prefixEnumError(err error, name string) error {
+	if ee, ok := err.(*EnumError); ok {
+		ee.Field = name + "." + ee.Field
+	}
+	return err
+}
+
+// prefixIndexEnumError prefixes the field of an *EnumError with name and an
+// index, for errors in a struct in an array.
+func prefixIndexEnumError(err error, name string, idx int) error {
+	if ee, ok := err.(*EnumError); ok {
+		ee.Field = fmt.Sprintf("%s[%d].%s", name, idx, ee.Field)
+	}
+	return err
+}
@@ -0,0 +1,95 @@
+package kmsg
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestEnumIsKnown(t *testing.T) {
+	if !ACLOperationDescribeTokens.IsKnown() || ACLOperation(99).IsKnown() {
+		t.Error("ACLOperation IsKnown is incorrect")
+	}
+	// ConfigSource has no zero value in Kafka; the generated Unknown
+	// placeholder is not a known value.
+	if !ConfigSourceDefaultConfig.IsKnown() || ConfigSourceUnknown.IsKnown() {
+		t.Error("ConfigSource IsKnown is incorrect")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	valid := func() *CreateACLsRequest {
+		c := NewCreateACLsRequestCreation()
+		c.ResourceType = ACLResourceTypeTopic
+		c.ResourcePatternType = ACLResourcePatternTypeLiteral
+		c.Operation = ACLOperationRead
+		c.PermissionType = ACLPermissionTypeAllow
+		req := NewPtrCreateACLsRequest()
+		req.Version = 1
+		req.Creations = append(req.Creations, c, c)
+		return req
+	}
+
+	if err := valid().Validate(); err != nil {
+		t.Fatalf("unexpected error validating a valid request: %v", err)
+	}
+
+	req := valid()
+	req.Creations[1].Operation = 99
+	var ee *EnumError
+	if err := req.Validate(); !errors.As(err, &ee) {
+		t.Fatalf("got %v, exp *EnumError", err)
+	}
+	if ee.Field != "Creations[1].Operation" || ee.Type != "ACLOperation" || ee.Value != 99 {
+		t.Errorf("got %#v, exp Creations[1].Operation ACLOperation 99", ee)
+	}
+
+	// ResourcePatternType is only serialized in v1+, so v0 ignores it.
+	req = valid()
+	req.Creations[0].ResourcePatternType = 99
+	if err := req.Validate(); err == nil {
+		t.Error("expected error validating an unknown v1 pattern type")
+	}
+	req.Version = 0
+	if err := req.Validate(); err != nil {
+		t.Errorf("unexpected error validating a v1+ field at v0: %v", err)
+	}
+}
+
+func TestValidateBitfield(t *testing.T) {
+	bit := func(op ACLOperation) int32 { return 1 << op }
+
+	for i, test := range []struct {
+		version int16
+		ops     int32
+		expErr  bool
+	}{
+		{8, -2147483648, false}, // default: not requested
+		{8, 0, false},
+		{8, bit(ACLOperationRead) | bit(ACLOperationDescribeTokens), false},
+		{8, bit(ACLOperationDescribeTokens) << 1, true},
+		{8, 1 << 30, true},
+		{7, 1 << 30, false}, // not serialized before v8
+	} {
+		resp := NewPtrMetadataResponse()
+		resp.Version = test.version
+		topic := NewMetadataResponseTopic()
+		topic.AuthorizedOperations = test.ops
+		resp.Topics = append(resp.Topics, topic)
+
+		err := resp.Validate()
+		if gotErr := err != nil; gotErr != test.expErr {
+			t.Errorf("#%d: got err %v, exp err? %v", i, err, test.expErr)
+			continue
+		}
+		var ee *EnumError
+		if test.expErr && (!errors.As(err, &ee) || ee.Field != "Topics[0].AuthorizedOperations" || ee.Type != "ACLOperation bitfield") {
+			t.Errorf("#%d: got %v, exp *EnumError for Topics[0].AuthorizedOperations", i, err)
+		}
+	}
+
+	cluster := NewPtrDescribeClusterResponse()
+	cluster.ClusterAuthorizedOperations = 1 << 20
+	if err := cluster.Validate(); err == nil {
+		t.Error("expected error validating unknown cluster authorized operation bits")
+	}
+}
@@ -0,0 +1,62 @@
+package kmsg
+
+import "github.com/burningass23/franz-go/pkg/kmsg/internal/kbin"
+
+// This file exports the zig-zag varint primitives used throughout record
+// batches, so that code manipulating records outside of this package does not
+// need to reimplement them. Varints are 32 bit and varlongs are 64 bit; both
+// are zig-zag encoded. Uvarints are unsigned and not zig-zag encoded.
+//
+// The read functions have the same return semantics as binary.Varint: the
+// returned int is the number of bytes read if positive, 0 if the input is too
+// short, and negative if the encoding overflows.
+
+// VarintLen returns how long i would be if it were varint encoded.
+func VarintLen(i int32) int { return kbin.VarintLen(i) }
+
+// UvarintLen returns how long u would be if it were uvarint encoded.
+func UvarintLen(u uint32) int { return kbin.UvarintLen(u) }
+
+// VarlongLen returns how long i would be if it were varlong encoded.
+func VarlongLen(i int64) int { return kbin.VarlongLen(i) }
+
+// ReadVarint reads a varint from the beginning of in.
+func ReadVarint(in []byte) (int32, int) { return kbin.Varint(in) }
+
+// ReadUvarint reads a uvarint from the beginning of in.
+func ReadUvarint(in []byte) (uint32, int) { return kbin.Uvarint(in) }
+
+// ReadVarlong reads a varlong from the beginning of in.
+func ReadVarlong(in []byte) (int64, int) { return kbin.Varlong(in) }
+
+// ReadVarintBytes reads a slice prefixed with its length encoded as a varint,
+// as record keys, values, and header values are. A length of -1 returns a nil
+// slice. The returned slice aliases in. The returned int is the total number
+// of bytes read, or 0 if the input is too short or the length is invalid.
+func ReadVarintBytes(in []byte) ([]byte, int) {
+	length, n := kbin.Varint(in)
+	if n <= 0 || length < -1 {
+		return nil, 0
+	}
+	if length == -1 {
+		return nil, n
+	}
+	if len(in[n:]) < int(length) {
+		return nil, 0
+	}
+	end := n + int(length)
+	return in[n:end:end], end
+}
+
+// AppendVarint appends a varint encoded i to dst.
+func AppendVarint(dst []byte, i int32) []byte { return kbin.AppendVarint(dst, i) }
+
+// AppendUvarint appends a uvarint encoded u to dst.
+func AppendUvarint(dst []byte, u uint32) []byte { return kbin.AppendUvarint(dst, u) }
+
+// AppendVarlong appends a varlong encoded i to dst.
+func AppendVarlong(dst []byte, i int64) []byte { return kbin.AppendVarlong(dst, i) }
+
+// AppendVarintBytes appends b to dst prefixed with its length encoded as a
+// varint. A nil slice is encoded with a length of -1.
+func AppendVarintBytes(dst, b []byte) []byte { return kbin.AppendVarintBytes(dst, b) }
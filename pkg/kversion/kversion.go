@@ -44,6 +44,72 @@ func (vs *Versions) HasKey(k int16) bool {
 	return has
 }
 
+// HasKeyVersion returns true if the versions contains the given key at a max
+// version of at least v.
+func (vs *Versions) HasKeyVersion(k, v int16) bool {
+	max, has := vs.LookupMaxKeyVersion(k)
+	return has && max >= v
+}
+
+// hasAll returns whether the versions contain every given key.
+func (vs *Versions) hasAll(ks ...kmsg.Key) bool {
+	for _, k := range ks {
+		if !vs.HasKey(int16(k)) {
+			return false
+		}
+	}
+	return true
+}
+
+// SupportsIdempotentProduce returns whether the versions support idempotent
+// producing (Kafka 0.11+): InitProducerID and Produce v3+.
+func (vs *Versions) SupportsIdempotentProduce() bool {
+	return vs.HasKey(int16(kmsg.InitProducerID)) && vs.HasKeyVersion(int16(kmsg.Produce), 3)
+}
+
+// SupportsTransactions returns whether the versions support transactional
+// producing and consuming (Kafka 0.11+): idempotent producing, as well as
+// AddPartitionsToTxn, AddOffsetsToTxn, EndTxn, and TxnOffsetCommit.
+func (vs *Versions) SupportsTransactions() bool {
+	return vs.SupportsIdempotentProduce() && vs.hasAll(
+		kmsg.AddPartitionsToTxn,
+		kmsg.AddOffsetsToTxn,
+		kmsg.EndTxn,
+		kmsg.TxnOffsetCommit,
+	)
+}
+
+// SupportsFetchSessions returns whether the versions support incremental
+// fetch sessions (KIP-227, Kafka 1.1+): Fetch v7+.
+func (vs *Versions) SupportsFetchSessions() bool {
+	return vs.HasKeyVersion(int16(kmsg.Fetch), 7)
+}
+
+// SupportsStaticMembership returns whether the versions support static group
+// membership with instance IDs (KIP-345, Kafka 2.3+): JoinGroup v5+.
+func (vs *Versions) SupportsStaticMembership() bool {
+	return vs.HasKeyVersion(int16(kmsg.JoinGroup), 5)
+}
+
+// SupportsIncrementalAlterConfigs returns whether the versions support
+// IncrementalAlterConfigs (KIP-339, Kafka 2.3+).
+func (vs *Versions) SupportsIncrementalAlterConfigs() bool {
+	return vs.HasKey(int16(kmsg.IncrementalAlterConfigs))
+}
+
+// SupportsTopicIDs returns whether the versions support fetching by topic ID
+// (KIP-516, Kafka 3.1+): Fetch v13+.
+func (vs *Versions) SupportsTopicIDs() bool {
+	return vs.HasKeyVersion(int16(kmsg.Fetch), 13)
+}
+
+// SupportsNextGenConsumerGroups returns whether the versions support the next
+// generation consumer group rebalance protocol (KIP-848): the
+// ConsumerGroupHeartbeat key.
+func (vs *Versions) SupportsNextGenConsumerGroups() bool {
+	return vs.HasKey(int16(kmsg.ConsumerGroupHeartbeat))
+}
+
 // LookupMaxKeyVersion returns the version for the given key and whether the
 // key exists. If the key does not exist, this returns (-1, false).
 func (vs *Versions) LookupMaxKeyVersion(k int16) (int16, bool) {
@@ -963,6 +1029,24 @@ var max340 = nextMax(max330, func(v listenerKeys) listenerKeys {
 var (
 	maxStable = max340
 	maxTip    = nextMax(maxStable, func(v listenerKeys) listenerKeys {
-		return maxStable
+		// KAFKA-14462 KIP-848
+		v = append(v,
+			k(rBroker), // 68 consumer group heartbeat
+		)
+		v[68].inc() // 1 consumer group heartbeat (client generated member IDs)
+		v[8].inc()  // 9 offset commit
+
+		// Keys 69 and 70 are not yet supported in this package.
+		v = append(v,
+			k(), // 69 consumer group describe
+			k(), // 70 controller registration
+		)
+
+		// KAFKA-15601 KIP-714
+		v = append(v,
+			k(zkBroker, rBroker), // 71 get telemetry subscriptions
+			k(zkBroker, rBroker), // 72 push telemetry
+		)
+		return v
 	})
 )
@@ -119,3 +119,35 @@ func TestEqual(t *testing.T) {
 		t.Errorf("unexpectedly not equal after backing v0.8.1 down to v0.8.0, opposite direction")
 	}
 }
+
+func TestFeatures(t *testing.T) {
+	for _, test := range []struct {
+		name string
+		vs   *Versions
+		fn   func(*Versions) bool
+		exp  bool
+	}{
+		{"idempotent 0.10.2", V0_10_2(), (*Versions).SupportsIdempotentProduce, false},
+		{"idempotent 0.11.0", V0_11_0(), (*Versions).SupportsIdempotentProduce, true},
+		{"transactions 0.10.2", V0_10_2(), (*Versions).SupportsTransactions, false},
+		{"transactions 0.11.0", V0_11_0(), (*Versions).SupportsTransactions, true},
+		{"fetch sessions 1.0.0", V1_0_0(), (*Versions).SupportsFetchSessions, false},
+		{"fetch sessions 1.1.0", V1_1_0(), (*Versions).SupportsFetchSessions, true},
+		{"static membership 2.2.0", V2_2_0(), (*Versions).SupportsStaticMembership, false},
+		{"static membership 2.3.0", V2_3_0(), (*Versions).SupportsStaticMembership, true},
+		{"incremental alter configs 2.2.0", V2_2_0(), (*Versions).SupportsIncrementalAlterConfigs, false},
+		{"incremental alter configs 2.3.0", V2_3_0(), (*Versions).SupportsIncrementalAlterConfigs, true},
+		{"topic IDs 3.0.0", V3_0_0(), (*Versions).SupportsTopicIDs, false},
+		{"topic IDs 3.1.0", V3_1_0(), (*Versions).SupportsTopicIDs, true},
+		{"next gen groups 3.4.0", V3_4_0(), (*Versions).SupportsNextGenConsumerGroups, false},
+	} {
+		if got := test.fn(test.vs); got != test.exp {
+			t.Errorf("%s: got %v != exp %v", test.name, got, test.exp)
+		}
+	}
+
+	vs := V2_3_0()
+	if !vs.HasKeyVersion(0, 7) || vs.HasKeyVersion(0, 8) || vs.HasKeyVersion(68, 0) {
+		t.Errorf("unexpected HasKeyVersion results for v2.3.0")
+	}
+}
@@ -1,8 +1,24 @@
 // Package sasl specifies interfaces that any sasl authentication must provide
 // to interop with Kafka SASL.
+//
+// The mechanisms in this package's subdirectories are not special: any type
+// implementing Mechanism can be passed to kgo.SASL. The client handles the
+// Kafka side of the exchange (SASLHandshake, and SASLAuthenticate or raw
+// framing for old brokers), and a mechanism only needs to produce and consume
+// the opaque authentication bytes. Every time a connection (re)authenticates,
+// Authenticate is called to begin a new Session, and the session's Challenge
+// is called with each server response until the session reports it is done.
+//
+// If the broker returns a session lifetime (KIP-368), the client
+// reauthenticates before the lifetime expires. A session can also report its
+// own lifetime by implementing LifetimeSession, which is useful if the
+// mechanism's credentials expire before the broker's session does.
 package sasl
 
-import "context"
+import (
+	"context"
+	"time"
+)
 
 // Session is an authentication session.
 type Session interface {
@@ -16,6 +32,20 @@ type Session interface {
 	Challenge([]byte) (bool, []byte, error)
 }
 
+// LifetimeSession is an optional interface for a Session to report how long
+// the authenticated session is valid for. This is called once after the
+// authentication exchange completes successfully.
+//
+// If the returned lifetime is positive and is less than the session lifetime
+// returned from the broker (or the broker did not return a lifetime), the
+// client uses this lifetime to schedule reauthentication. The client always
+// reauthenticates slightly before the lifetime expires.
+type LifetimeSession interface {
+	// SessionLifetime returns how long the authenticated session is
+	// valid for, or zero if there is no limit.
+	SessionLifetime() time.Duration
+}
+
 // Mechanism authenticates with SASL.
 type Mechanism interface {
 	// Name is the name of this SASL authentication mechanism.